@@ -162,6 +162,31 @@ func KubeServerAddr() string {
 	return viper.GetString(setting.ENVKubeServerAddr)
 }
 
+func SMTPHost() string {
+	return viper.GetString(setting.ENVSMTPHost)
+}
+
+// SMTP默认使用25端口
+func SMTPPort() int {
+	port := viper.GetInt(setting.ENVSMTPPort)
+	if port == 0 {
+		return 25
+	}
+	return port
+}
+
+func SMTPUsername() string {
+	return viper.GetString(setting.ENVSMTPUsername)
+}
+
+func SMTPPassword() string {
+	return viper.GetString(setting.ENVSMTPPassword)
+}
+
+func SMTPFrom() string {
+	return viper.GetString(setting.ENVSMTPFrom)
+}
+
 func RegistryAddress() string {
 	return viper.GetString(setting.ENVAslanRegAddress)
 }
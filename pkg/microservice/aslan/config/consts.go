@@ -36,6 +36,7 @@ const (
 	EnvRecyclePolicyAlways     = "always"
 	EnvRecyclePolicyTaskStatus = "success"
 	EnvRecyclePolicyNever      = "never"
+	EnvRecyclePolicyPrClosed   = "pr_closed"
 
 	// 定时器的所属job类型
 	WorkflowCronjob = "workflow"
@@ -112,6 +113,17 @@ const (
 	TaskStatusPass      TaskStatus = "pass"
 )
 
+// 任务在队列中的优先级, 数值越大越先被调度, 取值范围为[TaskPriorityMin, TaskPriorityMax]
+// 高于TaskPriorityManual的优先级只有项目管理员可以设置
+const (
+	TaskPriorityMin     = 1
+	TaskPriorityCron    = 10
+	TaskPriorityWebhook = 20
+	TaskPriorityManual  = 30
+	TaskPriorityHotfix  = 40
+	TaskPriorityMax     = 100
+)
+
 type TaskType string
 
 const (
@@ -129,6 +141,15 @@ const (
 	TaskSecurity       TaskType = "security"
 	TaskResetImage     TaskType = "reset_image"
 	TaskDistribute     TaskType = "distribute"
+	TaskApproval       TaskType = "approval"
+)
+
+type ApprovalStatus string
+
+const (
+	ApprovalStatusWaiting  ApprovalStatus = "waiting"
+	ApprovalStatusApproved ApprovalStatus = "approved"
+	ApprovalStatusRejected ApprovalStatus = "rejected"
 )
 
 type DistributeType string
@@ -151,6 +172,7 @@ var (
 	Announcement   NotifyType = 1 // 公告
 	PipelineStatus NotifyType = 2 // 提醒
 	Message        NotifyType = 3 // 消息
+	EnvRecycle     NotifyType = 4 // 环境回收提醒
 )
 
 // Validation constants
@@ -198,6 +220,8 @@ const (
 	HookEventPush    = HookEventType("push")
 	HookEventPr      = HookEventType("pull_request")
 	HookEventUpdated = HookEventType("ref-updated")
+	HookEventTag     = HookEventType("tag")
+	HookEventRelease = HookEventType("release")
 )
 
 const (
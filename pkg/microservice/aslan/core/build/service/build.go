@@ -112,6 +112,9 @@ func CreateBuild(username string, build *commonmodels.Build, log *zap.SugaredLog
 	if len(build.Name) == 0 {
 		return e.ErrCreateBuildModule.AddDesc("empty name")
 	}
+	if err := validateBuild(build); err != nil {
+		return e.ErrCreateBuildModule.AddErr(err)
+	}
 
 	build.UpdateBy = username
 	correctFields(build)
@@ -128,6 +131,9 @@ func UpdateBuild(username string, build *commonmodels.Build, log *zap.SugaredLog
 	if len(build.Name) == 0 {
 		return e.ErrUpdateBuildModule.AddDesc("empty name")
 	}
+	if err := validateBuild(build); err != nil {
+		return e.ErrUpdateBuildModule.AddErr(err)
+	}
 
 	existed, err := commonrepo.NewBuildColl().Find(&commonrepo.BuildFindOption{Name: build.Name, ProductName: build.ProductName})
 	if err == nil && existed.PreBuild != nil && build.PreBuild != nil {
@@ -324,6 +330,15 @@ func correctFields(build *commonmodels.Build) {
 	}
 	build.Caches = caches
 
+	keyFiles := make([]string, 0)
+	for _, file := range build.CacheKeyFiles {
+		file = strings.Trim(file, " /")
+		if file != "" {
+			keyFiles = append(keyFiles, file)
+		}
+	}
+	build.CacheKeyFiles = keyFiles
+
 	// trim the docker file and context
 	if build.PostBuild != nil && build.PostBuild.DockerBuild != nil {
 		build.PostBuild.DockerBuild.DockerFile = strings.Trim(build.PostBuild.DockerBuild.DockerFile, " ")
@@ -362,3 +377,18 @@ func hasDuplicateTargets(serviceModuleTargets []*commonmodels.ServiceModuleTarge
 	}
 	return false
 }
+
+func validateBuild(build *commonmodels.Build) error {
+	switch build.CacheType {
+	case "", setting.BuildCacheTypeTar, setting.BuildCacheTypeChunk:
+	default:
+		return fmt.Errorf("invalid cache type: %s", build.CacheType)
+	}
+
+	for _, step := range build.Steps {
+		if err := step.Validate(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -24,10 +24,12 @@ import (
 	"github.com/koderover/zadig/pkg/microservice/aslan/config"
 	git "github.com/koderover/zadig/pkg/microservice/aslan/core/common/service/github"
 	"github.com/koderover/zadig/pkg/shared/codehost"
+	"github.com/koderover/zadig/pkg/tool/bitbucket"
 	"github.com/koderover/zadig/pkg/tool/codehub"
 	e "github.com/koderover/zadig/pkg/tool/errors"
 	"github.com/koderover/zadig/pkg/tool/gerrit"
 	"github.com/koderover/zadig/pkg/tool/git/gitlab"
+	"github.com/koderover/zadig/pkg/tool/gitea"
 )
 
 func CodeHostListBranches(codeHostID int, projectName, namespace string, log *zap.SugaredLogger) ([]*Branch, error) {
@@ -65,6 +67,20 @@ func CodeHostListBranches(codeHostID int, projectName, namespace string, log *za
 			return nil, err
 		}
 		return ToBranches(branchList), nil
+	} else if ch.Type == codeHostGitea {
+		client := gitea.NewClient(ch.Address, ch.AccessToken)
+		branches, err := client.ListBranches(namespace, projectName)
+		if err != nil {
+			return nil, err
+		}
+		return ToBranches(branches), nil
+	} else if ch.Type == codeHostBitbucket {
+		client := bitbucket.NewClient(ch.Address, ch.AccessToken)
+		branches, err := client.ListBranches(namespace, projectName)
+		if err != nil {
+			return nil, err
+		}
+		return ToBranches(branches), nil
 	} else {
 		//	github
 		gh := git.NewClient(ch.AccessToken, config.ProxyHTTPSAddr())
@@ -25,9 +25,11 @@ import (
 	"github.com/koderover/zadig/pkg/microservice/aslan/config"
 	git "github.com/koderover/zadig/pkg/microservice/aslan/core/common/service/github"
 	"github.com/koderover/zadig/pkg/shared/codehost"
+	"github.com/koderover/zadig/pkg/tool/bitbucket"
 	e "github.com/koderover/zadig/pkg/tool/errors"
 	"github.com/koderover/zadig/pkg/tool/gerrit"
 	"github.com/koderover/zadig/pkg/tool/git/gitlab"
+	"github.com/koderover/zadig/pkg/tool/gitea"
 )
 
 func CodeHostListPRs(codeHostID int, projectName, namespace, targetBr string, log *zap.SugaredLogger) ([]*PullRequest, error) {
@@ -58,6 +60,22 @@ func CodeHostListPRs(codeHostID int, projectName, namespace, targetBr string, lo
 		return nil, nil
 	} else if ch.Type == CodeHostCodeHub {
 		return nil, nil
+	} else if ch.Type == codeHostGitea {
+		client := gitea.NewClient(ch.Address, ch.AccessToken)
+		prs, err := client.ListOpenPullRequests(namespace, projectName, targetBr)
+		if err != nil {
+			log.Error(err)
+			return nil, e.ErrCodehostListPrs.AddDesc(err.Error())
+		}
+		return ToPullRequests(prs), nil
+	} else if ch.Type == codeHostBitbucket {
+		client := bitbucket.NewClient(ch.Address, ch.AccessToken)
+		prs, err := client.ListOpenPullRequests(namespace, projectName, targetBr)
+		if err != nil {
+			log.Error(err)
+			return nil, e.ErrCodehostListPrs.AddDesc(err.Error())
+		}
+		return ToPullRequests(prs), nil
 	} else {
 		//	github
 		gh := git.NewClient(ch.AccessToken, config.ProxyHTTPSAddr())
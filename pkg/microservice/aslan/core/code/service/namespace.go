@@ -24,10 +24,12 @@ import (
 	"github.com/koderover/zadig/pkg/microservice/aslan/config"
 	git "github.com/koderover/zadig/pkg/microservice/aslan/core/common/service/github"
 	"github.com/koderover/zadig/pkg/shared/codehost"
+	"github.com/koderover/zadig/pkg/tool/bitbucket"
 	"github.com/koderover/zadig/pkg/tool/codehub"
 	e "github.com/koderover/zadig/pkg/tool/errors"
 	"github.com/koderover/zadig/pkg/tool/gerrit"
 	"github.com/koderover/zadig/pkg/tool/git/gitlab"
+	"github.com/koderover/zadig/pkg/tool/gitea"
 )
 
 const (
@@ -38,6 +40,9 @@ const (
 	page            = 1
 	perPage         = 100
 	CodeHostCodeHub = "codehub"
+
+	codeHostGitea     = "gitea"
+	codeHostBitbucket = "bitbucket"
 )
 
 func CodeHostListNamespaces(codeHostID int, keyword string, log *zap.SugaredLogger) ([]*Namespace, error) {
@@ -74,6 +79,30 @@ func CodeHostListNamespaces(codeHostID int, keyword string, log *zap.SugaredLogg
 			return nil, err
 		}
 		return ToNamespaces(codeHubNamespace), nil
+	} else if ch.Type == codeHostGitea {
+		client := gitea.NewClient(ch.Address, ch.AccessToken)
+		namespaces := make([]*Namespace, 0)
+
+		user, err := client.GetAuthenticatedUser()
+		if err != nil {
+			return nil, e.ErrCodehostListNamespaces.AddDesc(err.Error())
+		}
+		namespaces = append(namespaces, ToNamespaces(user)...)
+
+		organizations, err := client.ListOrganizations()
+		if err != nil {
+			return nil, e.ErrCodehostListNamespaces.AddDesc(err.Error())
+		}
+		namespaces = append(namespaces, ToNamespaces(organizations)...)
+
+		return namespaces, nil
+	} else if ch.Type == codeHostBitbucket {
+		client := bitbucket.NewClient(ch.Address, ch.AccessToken)
+		projects, err := client.ListProjects()
+		if err != nil {
+			return nil, e.ErrCodehostListNamespaces.AddDesc(err.Error())
+		}
+		return ToNamespaces(projects), nil
 	} else {
 		//	github
 		gh := git.NewClient(ch.AccessToken, config.ProxyHTTPSAddr())
@@ -24,10 +24,12 @@ import (
 	"github.com/koderover/zadig/pkg/microservice/aslan/config"
 	git "github.com/koderover/zadig/pkg/microservice/aslan/core/common/service/github"
 	"github.com/koderover/zadig/pkg/shared/codehost"
+	"github.com/koderover/zadig/pkg/tool/bitbucket"
 	"github.com/koderover/zadig/pkg/tool/codehub"
 	e "github.com/koderover/zadig/pkg/tool/errors"
 	"github.com/koderover/zadig/pkg/tool/gerrit"
 	"github.com/koderover/zadig/pkg/tool/git/gitlab"
+	"github.com/koderover/zadig/pkg/tool/gitea"
 )
 
 func CodeHostListProjects(codeHostID int, namespace, namespaceType, keyword string, log *zap.SugaredLogger) ([]*Project, error) {
@@ -79,6 +81,22 @@ func CodeHostListProjects(codeHostID int, namespace, namespaceType, keyword stri
 			return nil, e.ErrCodehostListProjects.AddDesc(err.Error())
 		}
 		return ToProjects(projects), nil
+	} else if ch.Type == codeHostGitea {
+		client := gitea.NewClient(ch.Address, ch.AccessToken)
+		repos, err := client.ListRepositories(namespace, namespaceType == OrgKind, keyword)
+		if err != nil {
+			log.Error(err)
+			return nil, e.ErrCodehostListProjects.AddDesc(err.Error())
+		}
+		return ToProjects(repos), nil
+	} else if ch.Type == codeHostBitbucket {
+		client := bitbucket.NewClient(ch.Address, ch.AccessToken)
+		repos, err := client.ListRepositories(namespace, keyword)
+		if err != nil {
+			log.Error(err)
+			return nil, e.ErrCodehostListProjects.AddDesc(err.Error())
+		}
+		return ToProjects(repos), nil
 	} else {
 		//	github
 		gh := git.NewClient(ch.AccessToken, config.ProxyHTTPSAddr())
@@ -24,10 +24,12 @@ import (
 	"github.com/koderover/zadig/pkg/microservice/aslan/config"
 	git "github.com/koderover/zadig/pkg/microservice/aslan/core/common/service/github"
 	"github.com/koderover/zadig/pkg/shared/codehost"
+	"github.com/koderover/zadig/pkg/tool/bitbucket"
 	"github.com/koderover/zadig/pkg/tool/codehub"
 	e "github.com/koderover/zadig/pkg/tool/errors"
 	"github.com/koderover/zadig/pkg/tool/gerrit"
 	"github.com/koderover/zadig/pkg/tool/git/gitlab"
+	"github.com/koderover/zadig/pkg/tool/gitea"
 )
 
 func CodeHostListTags(codeHostID int, projectName string, namespace string, log *zap.SugaredLogger) ([]*Tag, error) {
@@ -67,6 +69,20 @@ func CodeHostListTags(codeHostID int, projectName string, namespace string, log
 			return nil, err
 		}
 		return ToTags(tags), nil
+	} else if ch.Type == codeHostGitea {
+		client := gitea.NewClient(ch.Address, ch.AccessToken)
+		tags, err := client.ListTags(namespace, projectName)
+		if err != nil {
+			return nil, err
+		}
+		return ToTags(tags), nil
+	} else if ch.Type == codeHostBitbucket {
+		client := bitbucket.NewClient(ch.Address, ch.AccessToken)
+		tags, err := client.ListTags(namespace, projectName)
+		if err != nil {
+			return nil, err
+		}
+		return ToTags(tags), nil
 	} else {
 		//	github
 		gh := git.NewClient(ch.AccessToken, config.ProxyHTTPSAddr())
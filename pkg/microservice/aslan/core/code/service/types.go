@@ -21,8 +21,10 @@ import (
 	"github.com/google/go-github/v35/github"
 	"github.com/xanzy/go-gitlab"
 
+	"github.com/koderover/zadig/pkg/tool/bitbucket"
 	"github.com/koderover/zadig/pkg/tool/codehub"
 	gerrittool "github.com/koderover/zadig/pkg/tool/gerrit"
+	"github.com/koderover/zadig/pkg/tool/gitea"
 )
 
 type Branch struct {
@@ -103,6 +105,19 @@ func ToBranches(obj interface{}) []*Branch {
 				Merged:    o.Merged,
 			})
 		}
+	case []*gitea.Branch:
+		for _, o := range os {
+			res = append(res, &Branch{
+				Name:      o.Name,
+				Protected: o.Protected,
+			})
+		}
+	case []*bitbucket.Ref:
+		for _, o := range os {
+			res = append(res, &Branch{
+				Name: o.DisplayID,
+			})
+		}
 	}
 
 	return res
@@ -141,6 +156,54 @@ func ToPullRequests(obj interface{}) []*PullRequest {
 				AuthorUsername: o.Author.Username,
 			})
 		}
+	case []*gitea.PullRequest:
+		for _, o := range os {
+			pr := &PullRequest{
+				ID:     o.Index,
+				Number: o.Index,
+				Title:  o.Title,
+				State:  o.State,
+			}
+			if o.Poster != nil {
+				pr.User = o.Poster.UserName
+				pr.AuthorUsername = o.Poster.UserName
+			}
+			if o.Head != nil {
+				pr.SourceBranch = o.Head.Ref
+			}
+			if o.Base != nil {
+				pr.TargetBranch = o.Base.Ref
+			}
+			if o.Created != nil {
+				pr.CreatedAt = o.Created.Unix()
+			}
+			if o.Updated != nil {
+				pr.UpdatedAt = o.Updated.Unix()
+			}
+			res = append(res, pr)
+		}
+	case []*bitbucket.PullRequest:
+		for _, o := range os {
+			pr := &PullRequest{
+				ID:        o.ID,
+				Number:    o.ID,
+				Title:     o.Title,
+				State:     o.State,
+				CreatedAt: o.CreatedDate / 1000,
+				UpdatedAt: o.UpdatedDate / 1000,
+			}
+			if o.Author != nil && o.Author.User != nil {
+				pr.User = o.Author.User.Name
+				pr.AuthorUsername = o.Author.User.Name
+			}
+			if o.FromRef != nil {
+				pr.SourceBranch = o.FromRef.DisplayID
+			}
+			if o.ToRef != nil {
+				pr.TargetBranch = o.ToRef.DisplayID
+			}
+			res = append(res, pr)
+		}
 	}
 
 	return res
@@ -189,6 +252,29 @@ func ToNamespaces(obj interface{}) []*Namespace {
 				ProjectUUID: o.ProjectUUID,
 			})
 		}
+	case *gitea.User:
+		res = append(res, &Namespace{
+			Name: os.UserName,
+			Path: os.UserName,
+			Kind: UserKind,
+		})
+	case []*gitea.Organization:
+		for _, o := range os {
+			res = append(res, &Namespace{
+				Name: o.UserName,
+				Path: o.UserName,
+				Kind: OrgKind,
+			})
+		}
+	case []*bitbucket.Project:
+		// bitbucket server以project key作为代码库的命名空间
+		for _, o := range os {
+			res = append(res, &Namespace{
+				Name: o.Name,
+				Path: o.Key,
+				Kind: GroupKind,
+			})
+		}
 	}
 
 	return res
@@ -238,6 +324,30 @@ func ToProjects(obj interface{}) []*Project {
 				RepoID:        project.RepoID,
 			})
 		}
+	case []*gitea.Repository:
+		for _, o := range os {
+			project := &Project{
+				ID:            int(o.ID),
+				Name:          o.Name,
+				Description:   o.Description,
+				DefaultBranch: o.DefaultBranch,
+			}
+			if o.Owner != nil {
+				project.Namespace = o.Owner.UserName
+			}
+			res = append(res, project)
+		}
+	case []*bitbucket.Repository:
+		for _, o := range os {
+			project := &Project{
+				ID:   o.ID,
+				Name: o.Slug,
+			}
+			if o.Project != nil {
+				project.Namespace = o.Project.Key
+			}
+			res = append(res, project)
+		}
 	}
 
 	return res
@@ -275,6 +385,21 @@ func ToTags(obj interface{}) []*Tag {
 				Name: o.Name,
 			})
 		}
+	case []*gitea.Tag:
+		for _, o := range os {
+			res = append(res, &Tag{
+				Name:       o.Name,
+				Message:    o.Message,
+				ZipballURL: o.ZipballURL,
+				TarballURL: o.TarballURL,
+			})
+		}
+	case []*bitbucket.Ref:
+		for _, o := range os {
+			res = append(res, &Tag{
+				Name: o.DisplayID,
+			})
+		}
 	}
 
 	return res
@@ -17,6 +17,8 @@ limitations under the License.
 package models
 
 import (
+	"fmt"
+
 	"go.mongodb.org/mongo-driver/bson/primitive"
 
 	"github.com/koderover/zadig/pkg/setting"
@@ -42,9 +44,65 @@ type Build struct {
 	Scripts         string                 `bson:"scripts"                       json:"scripts"`
 	PostBuild       *PostBuild             `bson:"post_build,omitempty"          json:"post_build"`
 	Caches          []string               `bson:"caches"                        json:"caches"`
+	CacheType       string                 `bson:"cache_type,omitempty"          json:"cache_type,omitempty"`
+	CacheKeyFiles   []string               `bson:"cache_key_files,omitempty"     json:"cache_key_files,omitempty"`
 	ProductName     string                 `bson:"product_name"                  json:"product_name"`
 	SSHs            []string               `bson:"sshs,omitempty"                json:"sshs,omitempty"`
 	PMDeployScripts string                 `bson:"pm_deploy_scripts"             json:"pm_deploy_scripts"`
+	Steps           []*Step                `bson:"steps,omitempty"               json:"steps,omitempty"` // 配置后按顺序执行, 代替 Scripts 和 PostBuild 的固定流程
+}
+
+// Step 构建步骤
+type Step struct {
+	Name string `bson:"name"                         json:"name"`
+	// Type 支持 shell, git, docker_build, archive, upload, junit
+	Type string `bson:"type"                         json:"type"`
+	// When 执行条件: on_success(默认), on_failure, always
+	When string `bson:"when,omitempty"               json:"when,omitempty"`
+	// Timeout 超时时间, 单位为分钟, 0表示不限制
+	Timeout int       `bson:"timeout,omitempty"            json:"timeout,omitempty"`
+	Envs    []*KeyVal `bson:"envs,omitempty"               json:"envs,omitempty"`
+	// Scripts shell步骤执行的脚本
+	Scripts string `bson:"scripts,omitempty"            json:"scripts,omitempty"`
+	// DockerBuild docker_build步骤的配置
+	DockerBuild *DockerBuild `bson:"docker_build,omitempty"       json:"docker_build,omitempty"`
+	// Paths archive步骤打包的路径或者upload步骤上传的文件, 相对于工作目录
+	Paths []string `bson:"paths,omitempty"              json:"paths,omitempty"`
+	// Dest archive步骤生成的文件
+	Dest string `bson:"dest,omitempty"               json:"dest,omitempty"`
+	// ResultPath junit步骤测试结果所在的目录
+	ResultPath string `bson:"result_path,omitempty"        json:"result_path,omitempty"`
+}
+
+// Validate validate step setting
+func (s *Step) Validate() error {
+	switch s.Type {
+	case setting.BuildStepShell, setting.BuildStepGit, setting.BuildStepDockerBuild:
+	case setting.BuildStepArchive:
+		if s.Dest == "" || len(s.Paths) == 0 {
+			return fmt.Errorf("dest and paths are required by archive step %s", s.Name)
+		}
+	case setting.BuildStepUpload:
+		if len(s.Paths) == 0 {
+			return fmt.Errorf("paths are required by upload step %s", s.Name)
+		}
+	case setting.BuildStepJunit:
+		if s.ResultPath == "" {
+			return fmt.Errorf("result path is required by junit step %s", s.Name)
+		}
+	default:
+		return fmt.Errorf("unsupported type %s of step %s", s.Type, s.Name)
+	}
+
+	switch s.When {
+	case "", setting.BuildStepOnSuccess, setting.BuildStepOnFailure, setting.BuildStepAlways:
+	default:
+		return fmt.Errorf("invalid condition %s of step %s", s.When, s.Name)
+	}
+	if s.Timeout < 0 {
+		return fmt.Errorf("invalid timeout %d of step %s", s.Timeout, s.Name)
+	}
+	return nil
 }
 
 // PreBuild prepares an environment for a job
@@ -102,6 +160,8 @@ type DockerBuild struct {
 	TemplateID string `bson:"template_id"            json:"template_id"`
 	// TemplateName is the name of the template dockerfile
 	TemplateName string `bson:"template_name"        json:"template_name"`
+	// SBOMFormat format of the SBOM generated after the image is pushed, spdx-json or cyclonedx-json, empty means disabled
+	SBOMFormat string `bson:"sbom_format,omitempty"     json:"sbom_format,omitempty"`
 }
 
 type JenkinsBuild struct {
@@ -36,6 +36,7 @@ type DeliveryArtifact struct {
 	Layers              []Descriptor       `bson:"layers,omitempty"                json:"layers,omitempty"`
 	PackageFileLocation string             `bson:"package_file_location,omitempty" json:"package_file_location,omitempty"`
 	PackageStorageURI   string             `bson:"package_storage_uri,omitempty"   json:"package_storage_uri,omitempty"`
+	SBOM                *ArtifactSBOM      `bson:"sbom,omitempty"                  json:"sbom,omitempty"`
 	CreatedBy           string             `bson:"created_by"                      json:"created_by"`
 	CreatedTime         int64              `bson:"created_time"                    json:"created_time"`
 }
@@ -47,6 +48,21 @@ type Descriptor struct {
 	URLs      []string `bson:"urls" json:"urls,omitempty"`
 }
 
+// ArtifactSBOM 镜像的SBOM文件以及其中包含的软件包
+type ArtifactSBOM struct {
+	Format     string         `bson:"format"           json:"format"`
+	FileName   string         `bson:"file_name"        json:"file_name"`
+	ObjectKey  string         `bson:"object_key"       json:"-"`
+	StorageURI string         `bson:"storage_uri"      json:"-"`
+	Packages   []*SBOMPackage `bson:"packages"         json:"packages,omitempty"`
+}
+
+type SBOMPackage struct {
+	Name    string `bson:"name"             json:"name"`
+	Version string `bson:"version"          json:"version"`
+	PURL    string `bson:"purl,omitempty"   json:"purl,omitempty"`
+}
+
 func (DeliveryArtifact) TableName() string {
 	return "artifact"
 }
@@ -0,0 +1,35 @@
+/*
+Copyright 2021 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package models
+
+import (
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// EmailDigest 开启汇总模式的订阅, 邮件内容先暂存, 定时合并成一封邮件发送
+type EmailDigest struct {
+	ID           primitive.ObjectID `bson:"_id,omitempty"     json:"id,omitempty"`
+	Receiver     string             `bson:"receiver"          json:"receiver"`
+	EmailAddress string             `bson:"email_address"     json:"email_address"`
+	Subject      string             `bson:"subject"           json:"subject"`
+	Content      string             `bson:"content"           json:"content"`
+	CreateTime   int64              `bson:"create_time"       json:"create_time"`
+}
+
+func (EmailDigest) TableName() string {
+	return "notify_email_digest"
+}
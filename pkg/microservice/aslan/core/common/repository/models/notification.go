@@ -19,6 +19,7 @@ package models
 import (
 	"bytes"
 	"fmt"
+	"strings"
 	"text/template"
 
 	"go.mongodb.org/mongo-driver/bson/primitive"
@@ -48,6 +49,20 @@ type PrTaskInfo struct {
 	EnvName          string `bson:"env_name,omitempty"                  json:"env_name,omitempty"`
 	EnvRecyclePolicy string `bson:"env_recycle_policy,omitempty"        json:"env_recycle_policy,omitempty"`
 	ProductName      string `bson:"product_name,omitempty"              json:"product_name,omitempty"`
+
+	// EnvHosts 环境中ingress的访问地址
+	EnvHosts []string `bson:"env_hosts,omitempty" json:"env_hosts,omitempty"`
+	// CommentedEnvStatus gerrit已经发送过消息的环境状态
+	CommentedEnvStatus string `bson:"commented_env_status,omitempty" json:"commented_env_status,omitempty"`
+}
+
+// EnvMessage 不支持markdown的代码平台(gerrit)使用的环境信息
+func (p *PrTaskInfo) EnvMessage(baseURI string) string {
+	msg := fmt.Sprintf("环境 %s 状态：%s %s/v1/projects/detail/%s/envs/detail?envName=%s", p.EnvName, p.EnvStatus, baseURI, p.ProductName, p.EnvName)
+	if len(p.EnvHosts) > 0 {
+		msg = fmt.Sprintf("%s\n访问地址：%s", msg, strings.Join(p.EnvHosts, " "))
+	}
+	return msg
 }
 
 type NotificationTask struct {
@@ -59,9 +74,26 @@ type NotificationTask struct {
 	Status       config.TaskStatus `bson:"status"          json:"status"`
 	TestReports  []*TestSuite      `bson:"test_reports,omitempty" json:"test_reports,omitempty"`
 
+	Coverages []*NotificationCoverage `bson:"coverages,omitempty" json:"coverages,omitempty"`
+
 	FirstCommented bool `json:"first_commented,omitempty" bson:"first_commented,omitempty"`
 }
 
+// NotificationCoverage 测试任务的代码覆盖率以及目标分支的基准覆盖率
+type NotificationCoverage struct {
+	TestName     string   `bson:"test_name"                json:"test_name"`
+	LineRate     float64  `bson:"line_rate"                json:"line_rate"`
+	BaseLineRate *float64 `bson:"base_line_rate,omitempty" json:"base_line_rate,omitempty"`
+}
+
+// Delta 返回相对目标分支基准覆盖率的变化, 没有基准时返回 "-"
+func (c NotificationCoverage) Delta() string {
+	if c.BaseLineRate == nil {
+		return "-"
+	}
+	return fmt.Sprintf("%+.2f%%", c.LineRate-*c.BaseLineRate)
+}
+
 func (t NotificationTask) StatusVerbose() string {
 	switch t.Status {
 	case config.TaskStatusReady:
@@ -93,10 +125,13 @@ func (n *Notification) ToString() string {
 
 func (n *Notification) CreateCommentBody() (comment string, err error) {
 	hasTest := false
+	hasCoverage := false
 	for _, task := range n.Tasks {
 		if len(task.TestReports) != 0 {
 			hasTest = true
-			break
+		}
+		if len(task.Coverages) != 0 {
+			hasCoverage = true
 		}
 	}
 
@@ -133,9 +168,21 @@ func (n *Notification) CreateCommentBody() (comment string, err error) {
 		}
 	}
 
+	if hasCoverage {
+		tmplSource = fmt.Sprintf("%s%s", tmplSource,
+			"\n\n|测试|行覆盖率|相对目标分支变化| \n |---|---|---| \n {{range .Tasks}}{{range .Coverages}}|{{.TestName}} | {{printf \"%.2f\" .LineRate}}% | {{.Delta}} | \n {{end}}{{end}}")
+	}
+
 	if n.PrTask != nil {
 		if n.PrTask.EnvName != "" {
 			content := fmt.Sprintf("生成基准环境：[%s]({{$.BaseURI}}/v1/projects/detail/%s/envs/detail?envName=%s) 状态：%s \n\n", n.PrTask.EnvName, n.PrTask.ProductName, n.PrTask.EnvName, n.PrTask.EnvStatus)
+			if len(n.PrTask.EnvHosts) > 0 {
+				hosts := make([]string, 0, len(n.PrTask.EnvHosts))
+				for _, host := range n.PrTask.EnvHosts {
+					hosts = append(hosts, fmt.Sprintf("[%s](%s)", host, host))
+				}
+				content = fmt.Sprintf("%s访问地址：%s \n\n", content, strings.Join(hosts, " "))
+			}
 			tmplSource = fmt.Sprintf("%s%s", content, tmplSource)
 		}
 
@@ -174,6 +221,8 @@ func getEnvRecyclePolicy(policy string) string {
 		return "工作流成功之后销毁"
 	case config.EnvRecyclePolicyNever:
 		return "每次保留"
+	case config.EnvRecyclePolicyPrClosed:
+		return "PR关闭之后销毁"
 	default:
 		return "每次保留"
 	}
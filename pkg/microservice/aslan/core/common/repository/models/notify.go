@@ -48,6 +48,13 @@ type PipelineStatusCtx struct {
 	TeamName     string              `bson:"team"                      json:"team"`
 }
 
+type EnvRecycleCtx struct {
+	ProductName string `bson:"product_name"          json:"product_name"`
+	EnvName     string `bson:"env_name"              json:"env_name"`
+	RecycleDay  int    `bson:"recycle_day"           json:"recycle_day"`
+	RecycleTime int64  `bson:"recycle_time"          json:"recycle_time"` // 预计回收时间
+}
+
 type MessageCtx struct {
 	ReqID   string `bson:"req_id"                json:"req_id"`
 	Title   string `bson:"title"                 json:"title"`   // 消息标题
@@ -68,6 +68,42 @@ type Stage struct {
 	Desc        string                            `bson:"desc,omitempty"     json:"desc,omitempty"`
 	SubTasks    map[string]map[string]interface{} `bson:"sub_tasks"          json:"sub_tasks"`
 	AfterAll    bool                              `json:"after_all" bson:"after_all"`
+	// DependsOn 任务以DAG方式运行时, 当前stage依赖的stage
+	DependsOn []*StageDependency `bson:"depends_on,omitempty" json:"depends_on,omitempty"`
+	// SubTaskTargets subtask key对应的target, 同一个target有多个部署目标时key会带上序号
+	SubTaskTargets map[string]string `bson:"sub_task_targets,omitempty" json:"sub_task_targets,omitempty"`
+	// RetryPolicy subtask失败后的自动重试策略, ModuleRetryPolicies 中的服务组件使用单独配置的策略
+	RetryPolicy         *RetryPolicy            `bson:"retry_policy,omitempty"          json:"retry_policy,omitempty"`
+	ModuleRetryPolicies map[string]*RetryPolicy `bson:"module_retry_policies,omitempty" json:"module_retry_policies,omitempty"`
+	// RetryAttempts 记录subtask每一次被重试前的执行结果
+	RetryAttempts map[string][]*SubTaskAttempt `bson:"retry_attempts,omitempty" json:"retry_attempts,omitempty"`
+}
+
+// StageDependency 描述DAG中stage之间的一条依赖
+// SameTarget 为true时, 子任务只等待被依赖stage中相同target的子任务, 否则等待被依赖stage的全部子任务
+type StageDependency struct {
+	TaskType   config.TaskType `bson:"type"        json:"type"`
+	SameTarget bool            `bson:"same_target" json:"same_target"`
+}
+
+// RetryPolicy subtask执行失败后的自动重试策略
+type RetryPolicy struct {
+	// MaxAttempts 包含第一次执行在内的最大执行次数
+	MaxAttempts int `bson:"max_attempts"       json:"max_attempts"`
+	// Backoff 第一次重试前等待的秒数, 之后每次重试等待时间翻倍
+	Backoff int `bson:"backoff"            json:"backoff"`
+	// RetryOn 需要重试的subtask状态, 为空时重试failed和timeout
+	RetryOn []config.Status `bson:"retry_on,omitempty" json:"retry_on,omitempty"`
+}
+
+// SubTaskAttempt subtask一次失败执行的结果, LogFile 为归档后的日志文件名
+type SubTaskAttempt struct {
+	Attempt   int           `bson:"attempt"              json:"attempt"`
+	Status    config.Status `bson:"status"               json:"status"`
+	Error     string        `bson:"error,omitempty"      json:"error,omitempty"`
+	StartTime int64         `bson:"start_time,omitempty" json:"start_time,omitempty"`
+	EndTime   int64         `bson:"end_time,omitempty"   json:"end_time,omitempty"`
+	LogFile   string        `bson:"log_file,omitempty"   json:"log_file,omitempty"`
 }
 
 type Hook struct {
@@ -0,0 +1,37 @@
+/*
+Copyright 2021 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package models
+
+// PrEnv 根据PR自动创建的临时环境, 同一个PR的后续提交复用该环境, PR关闭或合并时回收
+type PrEnv struct {
+	ProductName      string `bson:"product_name"               json:"product_name"`
+	EnvName          string `bson:"env_name"                   json:"env_name"`
+	BaseEnvName      string `bson:"base_env_name"              json:"base_env_name"`
+	WorkflowName     string `bson:"workflow_name"              json:"workflow_name"`
+	EnvRecyclePolicy string `bson:"env_recycle_policy"         json:"env_recycle_policy"`
+	Source           string `bson:"source"                     json:"source"`
+	CodehostID       int    `bson:"codehost_id"                json:"codehost_id"`
+	RepoOwner        string `bson:"repo_owner"                 json:"repo_owner"`
+	RepoName         string `bson:"repo_name"                  json:"repo_name"`
+	PrID             int    `bson:"pr_id"                      json:"pr_id"`
+	NotificationID   string `bson:"notification_id,omitempty"  json:"notification_id,omitempty"`
+	CreateTime       int64  `bson:"create_time"                json:"create_time"`
+}
+
+func (PrEnv) TableName() string {
+	return "pr_env"
+}
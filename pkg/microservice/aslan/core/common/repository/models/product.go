@@ -50,6 +50,8 @@ type Product struct {
 	RecycleDay   int                           `bson:"recycle_day"               json:"recycle_day"`
 	Source       string                        `bson:"source"                    json:"source"`
 	IsOpenSource bool                          `bson:"is_opensource"             json:"is_opensource"`
+	// RecycleWarnTime 最近一次发送环境回收提醒的时间
+	RecycleWarnTime int64 `bson:"recycle_warn_time,omitempty" json:"recycle_warn_time,omitempty"`
 	// TODO: temp flag
 	IsForkedProduct bool `bson:"-" json:"-"`
 }
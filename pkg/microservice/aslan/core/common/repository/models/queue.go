@@ -78,12 +78,18 @@ type Queue struct {
 	RwLock sync.Mutex `bson:"-" json:"-"`
 
 	ResetImage bool `json:"resetImage" bson:"resetImage"`
+	// RollbackOnFailure 部署失败或超时后将服务回滚到部署前的版本
+	RollbackOnFailure bool `json:"rollback_on_failure" bson:"rollback_on_failure"`
+	// SignatureKeyID 部署交付物前使用该签名密钥验证镜像签名, 为空时不验证
+	SignatureKeyID string `json:"signature_key_id,omitempty" bson:"signature_key_id,omitempty"`
 
 	TriggerBy *TriggerBy `json:"trigger_by,omitempty" bson:"trigger_by,omitempty"`
 
 	Features        []string `bson:"features" json:"features"`
 	IsRestart       bool     `bson:"is_restart"                      json:"is_restart"`
 	StorageEndpoint string   `bson:"storage_endpoint"            json:"storage_endpoint"`
+	DAGEnabled      bool     `bson:"dag_enabled"                 json:"dag_enabled"`
+	Priority        int      `bson:"priority"                    json:"priority"`
 }
 
 type TriggerBy struct {
@@ -139,6 +145,8 @@ type ConfigPayload struct {
 	ResetCache         bool               `json:"reset_cache"`
 	JenkinsBuildConfig JenkinsBuildConfig `json:"jenkins_build_config"`
 	PrivateKeys        []*PrivateKey      `json:"private_keys"`
+	// SigningKeys 工作流中镜像签名和验证用到的密钥
+	SigningKeys []*SigningKey `json:"signing_keys,omitempty"`
 }
 
 type AslanConfig struct {
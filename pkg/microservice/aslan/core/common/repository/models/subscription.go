@@ -23,6 +23,11 @@ type Subscription struct {
 	Type           config.NotifyType `bson:"type"                         json:"type"`                     // 消息类型
 	CreateTime     int64             `bson:"create_time"                  json:"create_time,omitempty"`    // 创建时间
 	PipelineStatus config.Status     `bson:"pipelinestatus"               json:"pipelinestatus,omitempty"` // pipeline 状态关注
+	ProductNames   []string          `bson:"product_names,omitempty"      json:"product_names,omitempty"`  // 关注的项目, 为空时不过滤
+	PipelineNames  []string          `bson:"pipeline_names,omitempty"     json:"pipeline_names,omitempty"` // 关注的工作流, 为空时不过滤
+	Email          bool              `bson:"email"                        json:"email"`                    // 是否发送邮件
+	EmailAddress   string            `bson:"email_address,omitempty"      json:"email_address,omitempty"`  // 接收邮件的地址
+	Digest         bool              `bson:"digest"                       json:"digest"`                   // 邮件定时汇总发送
 }
 
 func (Subscription) TableName() string {
@@ -128,6 +128,7 @@ type JobCtx struct {
 	// BuildJobCtx
 	Builds     []*types.Repository `bson:"builds"                         json:"builds"`
 	BuildSteps []*BuildStep        `bson:"build_steps,omitempty"          json:"build_steps"`
+	Steps      []*Step             `bson:"steps,omitempty"                json:"steps,omitempty"` // 配置后代替 BuildSteps 以及构建后的固定流程
 	SSHs       []*SSH              `bson:"sshs,omitempty"                 json:"sshs"`
 	// Envs stores user defined env key val for build
 	// TODO: 之后可以不用keystore, 将用户敏感信息保存在此字段
@@ -141,6 +142,16 @@ type JobCtx struct {
 	TestResultPath string `bson:"test_result_path,omitempty"     json:"test_result_path,omitempty"`
 	TestReportPath string `bson:"test_report_path"               json:"test_report_path"`
 	TestJobName    string `bson:"test_job_name,omitempty"        json:"test_job_name,omitempty"`
+	// QuarantinedCases 被隔离的测试用例(classname.name), 失败时不计入失败数
+	QuarantinedCases []string `bson:"quarantined_cases,omitempty" json:"quarantined_cases,omitempty"`
+	// CoverageReportPath 覆盖率报告路径, BaseLineRate 为基准分支最近一次测试的行覆盖率
+	CoverageReportPath string                `bson:"coverage_report_path,omitempty" json:"coverage_report_path,omitempty"`
+	CoverageFormat     string                `bson:"coverage_format,omitempty"      json:"coverage_format,omitempty"`
+	CoveragePolicy     *types.CoveragePolicy `bson:"coverage_policy,omitempty"      json:"coverage_policy,omitempty"`
+	BaseLineRate       *float64              `bson:"base_line_rate,omitempty"       json:"base_line_rate,omitempty"`
+	// ShardCount 测试分片数量, TestShards 为按照历史耗时划分的每个分片的测试类
+	ShardCount int        `bson:"shard_count,omitempty" json:"shard_count,omitempty"`
+	TestShards [][]string `bson:"test_shards,omitempty" json:"test_shards,omitempty"`
 	// DockerBuildCtx
 	DockerBuildCtx *DockerBuildCtx `bson:"docker_build_ctx,omitempty" json:"docker_build_ctx,omitempty"`
 	FileArchiveCtx *FileArchiveCtx `bson:"file_archive_ctx,omitempty" json:"file_archive_ctx,omitempty"`
@@ -148,6 +159,8 @@ type JobCtx struct {
 	TestType string `bson:"test_type"                       json:"test_type"`
 	// Caches
 	Caches        []string `bson:"caches" json:"caches"`
+	CacheType     string   `bson:"cache_type,omitempty" json:"cache_type,omitempty"`
+	CacheKeyFiles []string `bson:"cache_key_files,omitempty" json:"cache_key_files,omitempty"`
 	ArtifactPaths []string `bson:"artifact_paths,omitempty" json:"artifact_paths,omitempty"`
 	IsHasArtifact bool     `bson:"is_has_artifact" json:"is_has_artifact"`
 	// StorageUri is used for qbox release-candidates
@@ -159,6 +172,20 @@ type JobCtx struct {
 	PMDeployScripts string `bson:"pm_deploy_scripts,omitempty"    json:"pm_deploy_scripts"`
 }
 
+// Step 构建步骤, 配置后由reaper按顺序执行
+type Step struct {
+	Name        string           `yaml:"name" bson:"name" json:"name"`
+	Type        string           `yaml:"type" bson:"type" json:"type"`
+	When        string           `yaml:"when,omitempty" bson:"when,omitempty" json:"when,omitempty"`
+	Timeout     int              `yaml:"timeout,omitempty" bson:"timeout,omitempty" json:"timeout,omitempty"`
+	Envs        []*models.KeyVal `yaml:"envs,omitempty" bson:"envs,omitempty" json:"envs,omitempty"`
+	Scripts     string           `yaml:"scripts,omitempty" bson:"scripts,omitempty" json:"scripts,omitempty"`
+	DockerBuild *DockerBuildCtx  `yaml:"docker_build,omitempty" bson:"docker_build,omitempty" json:"docker_build,omitempty"`
+	Paths       []string         `yaml:"paths,omitempty" bson:"paths,omitempty" json:"paths,omitempty"`
+	Dest        string           `yaml:"dest,omitempty" bson:"dest,omitempty" json:"dest,omitempty"`
+	ResultPath  string           `yaml:"result_path,omitempty" bson:"result_path,omitempty" json:"result_path,omitempty"`
+}
+
 type BuildStep struct {
 	BuildType  string `bson:"type"                         json:"type"`
 	Scripts    string `bson:"scripts"                      json:"scripts"`
@@ -61,6 +61,7 @@ type DockerBuildCtx struct {
 	ImageName       string `yaml:"image_name" bson:"image_name" json:"image_name"`
 	BuildArgs       string `yaml:"build_args" bson:"build_args" json:"build_args"`
 	ImageReleaseTag string `yaml:"image_release_tag,omitempty" bson:"image_release_tag,omitempty" json:"image_release_tag"`
+	SBOMFormat      string `yaml:"sbom_format,omitempty" bson:"sbom_format,omitempty" json:"sbom_format,omitempty"`
 }
 
 type FileArchiveCtx struct {
@@ -81,12 +81,20 @@ type Task struct {
 	RwLock sync.Mutex `bson:"-" json:"-"`
 
 	ResetImage bool `json:"resetImage" bson:"resetImage"`
+	// RollbackOnFailure 部署失败或超时后将服务回滚到部署前的版本
+	RollbackOnFailure bool `json:"rollback_on_failure" bson:"rollback_on_failure"`
+	// SignatureKeyID 部署交付物前使用该签名密钥验证镜像签名, 为空时不验证
+	SignatureKeyID string `json:"signature_key_id,omitempty" bson:"signature_key_id,omitempty"`
 
 	TriggerBy *models.TriggerBy `json:"trigger_by,omitempty" bson:"trigger_by,omitempty"`
 
 	Features        []string `bson:"features" json:"features"`
 	IsRestart       bool     `bson:"is_restart"                      json:"is_restart"`
 	StorageEndpoint string   `bson:"storage_endpoint"            json:"storage_endpoint"`
+	// DAGEnabled 为true时, stages按照DependsOn以DAG方式调度
+	DAGEnabled bool `bson:"dag_enabled"                 json:"dag_enabled"`
+	// Priority 任务在队列中的优先级, 数值越大越先被调度
+	Priority int `bson:"priority"                    json:"priority"`
 }
 
 //type RenderInfo struct {
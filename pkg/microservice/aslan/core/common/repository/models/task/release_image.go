@@ -52,7 +52,8 @@ type ReleaseImage struct {
 	LogFile      string          `bson:"log_file"                       json:"log_file"`
 
 	// destinations to distribute images
-	Releases []models.RepoImage `bson:"releases"                 json:"releases"`
+	Releases []models.RepoImage   `bson:"releases"                 json:"releases"`
+	Signing  *models.ImageSigning `bson:"signing,omitempty"        json:"signing,omitempty"`
 }
 
 // SetImage ...
@@ -20,20 +20,27 @@ import (
 	"fmt"
 
 	"github.com/koderover/zadig/pkg/microservice/aslan/config"
+	"github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/models"
 )
 
 type Security struct {
-	TaskType   config.TaskType `bson:"type"                          json:"type"`
-	Enabled    bool            `bson:"enabled"                       json:"enabled"`
-	TaskStatus config.Status   `bson:"status"                        json:"status"`
-	ImageName  string          `bson:"image_name"                    json:"image_name"`
-	ImageID    string          `bson:"image_id"                      json:"image_id"`
-	Timeout    int             `bson:"timeout,omitempty"             json:"timeout,omitempty"`
-	Error      string          `bson:"error,omitempty"               json:"error,omitempty"`
-	StartTime  int64           `bson:"start_time,omitempty"          json:"start_time,omitempty"`
-	EndTime    int64           `bson:"end_time,omitempty"            json:"end_time,omitempty"`
-	LogFile    string          `bson:"log_file"                      json:"log_file"`
-	Summary    map[string]int  `bson:"summary"                       json:"summary"`
+	TaskType   config.TaskType        `bson:"type"                          json:"type"`
+	Enabled    bool                   `bson:"enabled"                       json:"enabled"`
+	TaskStatus config.Status          `bson:"status"                        json:"status"`
+	ImageName  string                 `bson:"image_name"                    json:"image_name"`
+	ImageID    string                 `bson:"image_id"                      json:"image_id"`
+	Timeout    int                    `bson:"timeout,omitempty"             json:"timeout,omitempty"`
+	Error      string                 `bson:"error,omitempty"               json:"error,omitempty"`
+	StartTime  int64                  `bson:"start_time,omitempty"          json:"start_time,omitempty"`
+	EndTime    int64                  `bson:"end_time,omitempty"            json:"end_time,omitempty"`
+	LogFile    string                 `bson:"log_file"                      json:"log_file"`
+	Summary    map[string]int         `bson:"summary"                       json:"summary"`
+	Policy     *models.SecurityPolicy `bson:"policy,omitempty"              json:"policy,omitempty"`
+	// Violations 违反安全策略的说明
+	Violations   []string         `bson:"violations,omitempty"          json:"violations,omitempty"`
+	Scanner      string           `bson:"scanner,omitempty"             json:"scanner,omitempty"`
+	ScannerImage string           `bson:"scanner_image,omitempty"       json:"scanner_image,omitempty"`
+	ScannerEnvs  []*models.KeyVal `bson:"scanner_envs,omitempty"        json:"scanner_envs,omitempty"`
 }
 
 func (s *Security) SetImageName(imageName string) {
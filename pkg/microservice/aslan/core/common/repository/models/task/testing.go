@@ -21,6 +21,7 @@ import (
 
 	"github.com/koderover/zadig/pkg/microservice/aslan/config"
 	"github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/models"
+	"github.com/koderover/zadig/pkg/types"
 )
 
 type Testing struct {
@@ -45,6 +46,8 @@ type Testing struct {
 	ReportReady    bool                        `bson:"report_ready"                    json:"report_ready"`
 	IsRestart      bool                        `bson:"is_restart"                      json:"is_restart"`
 	Registries     []*models.RegistryNamespace `bson:"-"                               json:"registries"`
+	// Coverage 覆盖率报告的汇总结果
+	Coverage *types.CoverageSummary `bson:"coverage,omitempty"              json:"coverage,omitempty"`
 }
 
 func (t *Testing) ToSubTask() (map[string]interface{}, error) {
@@ -48,6 +48,8 @@ type Product struct {
 	ImageSearchingRules []*ImageSearchingRule `bson:"image_searching_rules,omitempty" json:"image_searching_rules,omitempty"`
 	// onboarding状态，0表示onboarding完成，1、2、3、4代表当前onboarding所在的步骤
 	OnboardingStatus int `bson:"onboarding_status"         json:"onboarding_status"`
+	// MaxConcurrentTasks 项目同时运行的工作流任务数上限, 0表示不限制
+	MaxConcurrentTasks int `bson:"max_concurrent_tasks,omitempty" json:"max_concurrent_tasks,omitempty"`
 	// CI场景的onboarding流程创建的ci工作流id，用于前端跳转
 	CiPipelineID               string      `bson:"-"                                   json:"ci_pipeline_id"`
 	Role                       string      `bson:"-"                                   json:"role,omitempty"`
@@ -0,0 +1,40 @@
+/*
+Copyright 2021 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package models
+
+// TestCaseStat 测试用例在多次运行中的结果, 用于计算不稳定程度
+type TestCaseStat struct {
+	TestName     string `bson:"test_name"                 json:"test_name"`
+	ClassName    string `bson:"classname"                 json:"classname"`
+	Name         string `bson:"name"                      json:"name"`
+	TotalSuccess int    `bson:"total_success"             json:"total_success"`
+	TotalFailure int    `bson:"total_failure"             json:"total_failure"`
+	// Recent 最近若干次的运行结果, true为成功, 按时间先后排列
+	Recent []bool `bson:"recent"                    json:"recent"`
+	// Duration 最近一次运行的耗时, 单位为秒, 用于按耗时划分测试分片
+	Duration float64 `bson:"duration"                  json:"duration"`
+	// Flakiness 最近的运行结果中成功和失败交替出现的比例, 0表示稳定
+	Flakiness      float64 `bson:"flakiness"                 json:"flakiness"`
+	Quarantined    bool    `bson:"quarantined"               json:"quarantined"`
+	QuarantinedBy  string  `bson:"quarantined_by,omitempty"  json:"quarantined_by,omitempty"`
+	QuarantineTime int64   `bson:"quarantine_time,omitempty" json:"quarantine_time,omitempty"`
+	UpdateTime     int64   `bson:"update_time"               json:"update_time"`
+}
+
+func (TestCaseStat) TableName() string {
+	return "test_case_stat"
+}
@@ -0,0 +1,37 @@
+/*
+Copyright 2021 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package models
+
+import (
+	"github.com/koderover/zadig/pkg/types"
+)
+
+// TestCoverage 测试任务的覆盖率, 用于展示测试模块的覆盖率趋势和比较基准分支
+type TestCoverage struct {
+	TestName     string                 `bson:"test_name"               json:"test_name"`
+	PipelineName string                 `bson:"pipeline_name"           json:"pipeline_name"`
+	TaskID       int64                  `bson:"task_id"                 json:"task_id"`
+	Branch       string                 `bson:"branch"                  json:"branch"`
+	PR           int                    `bson:"pr,omitempty"            json:"pr,omitempty"`
+	CommitID     string                 `bson:"commit_id,omitempty"     json:"commit_id,omitempty"`
+	Coverage     *types.CoverageSummary `bson:"coverage"                json:"coverage"`
+	CreateTime   int64                  `bson:"create_time"             json:"create_time"`
+}
+
+func (TestCoverage) TableName() string {
+	return "test_coverage"
+}
@@ -50,7 +50,15 @@ type Testing struct {
 	Workflows       []*Workflow      `bson:"-"                        json:"workflows,omitempty"`
 	Schedules       *ScheduleCtrl    `bson:"schedules,omitempty"      json:"schedules,omitempty"`
 	HookCtl         *TestingHookCtrl `bson:"hook_ctl"                 json:"hook_ctl"`
+	NotifyCtl       *NotifyCtl       `bson:"notify_ctl,omitempty"     json:"notify_ctl,omitempty"`
 	ScheduleEnabled bool             `bson:"schedule_enabled"         json:"-"`
+	// 覆盖率报告, 支持 cobertura, jacoco, lcov 和 gocover 格式
+	CoverageReportPath string                `bson:"coverage_report_path,omitempty"   json:"coverage_report_path,omitempty"`
+	CoverageFormat     string                `bson:"coverage_format,omitempty"        json:"coverage_format,omitempty"`
+	CoveragePolicy     *types.CoveragePolicy `bson:"coverage_policy,omitempty"        json:"coverage_policy,omitempty"`
+	// 测试分片, 大于1时并行启动多个job, 每个job通过 ZADIG_SHARD_INDEX/ZADIG_SHARD_TOTAL 区分自己的分片
+	ShardCount    int    `bson:"shard_count,omitempty"    json:"shard_count,omitempty"`
+	ShardStrategy string `bson:"shard_strategy,omitempty" json:"shard_strategy,omitempty"`
 }
 
 type TestingHookCtrl struct {
@@ -47,6 +47,7 @@ type Workflow struct {
 	TestStage       *TestStage         `bson:"test_stage"                   json:"test_stage"`
 	SecurityStage   *SecurityStage     `bson:"security_stage"               json:"security_stage"`
 	DistributeStage *DistributeStage   `bson:"distribute_stage"             json:"distribute_stage"`
+	ApprovalStage   *ApprovalStage     `bson:"approval_stage,omitempty"     json:"approval_stage,omitempty"`
 	NotifyCtl       *NotifyCtl         `bson:"notify_ctl,omitempty"         json:"notify_ctl,omitempty"`
 	HookCtl         *WorkflowHookCtrl  `bson:"hook_ctl"                     json:"hook_ctl"`
 	IsFavorite      bool               `bson:"-"                            json:"is_favorite"`
@@ -59,8 +60,35 @@ type Workflow struct {
 
 	// ResetImage indicate whether reset image to original version after completion
 	ResetImage bool `json:"reset_image" bson:"reset_image"`
+	// RollbackOnFailure 部署失败或超时后将服务回滚到部署前的版本
+	RollbackOnFailure bool `json:"rollback_on_failure" bson:"rollback_on_failure"`
+	// SignatureKeyID 部署交付物前使用该签名密钥验证镜像签名, 为空时不验证
+	// 构建后直接部署的镜像在分发时才签名, 不做验证
+	SignatureKeyID string `json:"signature_key_id,omitempty" bson:"signature_key_id,omitempty"`
 	// IsParallel 控制单一工作流的任务是否支持并行处理
 	IsParallel bool `json:"is_parallel" bson:"is_parallel"`
+	// DAG 控制工作流任务的stage是否按照依赖关系以DAG方式调度
+	DAG *WorkflowDAG `json:"dag,omitempty" bson:"dag,omitempty"`
+	// RetryPolicies 按照stage和服务组件配置subtask失败后的自动重试策略
+	RetryPolicies []*StageRetryPolicy `json:"retry_policies,omitempty" bson:"retry_policies,omitempty"`
+}
+
+type StageRetryPolicy struct {
+	TaskType config.TaskType `bson:"type"              json:"type"`
+	// Modules 为空时对stage中所有服务组件生效
+	Modules []string     `bson:"modules,omitempty" json:"modules,omitempty"`
+	Policy  *RetryPolicy `bson:"policy"            json:"policy"`
+}
+
+type WorkflowDAG struct {
+	Enabled bool `bson:"enabled" json:"enabled"`
+	// Stages 自定义的stage依赖关系, 未定义的stage使用默认依赖关系
+	Stages []*DAGStage `bson:"stages,omitempty" json:"stages,omitempty"`
+}
+
+type DAGStage struct {
+	TaskType  config.TaskType    `bson:"type"       json:"type"`
+	DependsOn []*StageDependency `bson:"depends_on" json:"depends_on"`
 }
 
 type WorkflowHookCtrl struct {
@@ -88,6 +116,8 @@ type MainHookRepo struct {
 	Label        string                 `bson:"label"                     json:"label"`
 	Revision     string                 `bson:"revision"                  json:"revision"`
 	IsRegular    bool                   `bson:"is_regular"                json:"is_regular"`
+	// TagPattern tag和release事件匹配的tag格式, 例如 v*.*.*, 为空时匹配所有tag
+	TagPattern string `bson:"tag_pattern,omitempty" json:"tag_pattern,omitempty"`
 }
 
 func (m MainHookRepo) GetLabelValue() string {
@@ -175,6 +205,8 @@ type WorkflowTaskArgs struct {
 	// 请求模式，openAPI表示外部客户调用
 	RequestMode string `json:"request_mode,omitempty"`
 	IsParallel  bool   `json:"is_parallel" bson:"is_parallel"`
+	// Priority 手动指定的任务优先级, 例如hotfix, 为空时按照任务触发方式设置
+	Priority int `json:"priority,omitempty" bson:"priority,omitempty"`
 }
 
 type TestTaskArgs struct {
@@ -197,6 +229,8 @@ type Slack struct {
 	Enabled    bool                   `bson:"enabled"      json:"enabled"`
 	Notifiers  []string               `bson:"notifiers"    json:"notifiers"`
 	NotifyType config.SlackNotifyType `bson:"notify_type"  json:"notify_type"`
+	// WebHook slack incoming webhook地址
+	WebHook string `bson:"webhook,omitempty" json:"webhook,omitempty"`
 }
 
 type BuildStage struct {
@@ -233,7 +267,41 @@ type TestExecArgs struct {
 }
 
 type SecurityStage struct {
-	Enabled bool `bson:"enabled"                    json:"enabled"`
+	Enabled bool            `bson:"enabled"                    json:"enabled"`
+	Policy  *SecurityPolicy `bson:"policy,omitempty"           json:"policy,omitempty"`
+	// Scanner 扫描镜像使用的工具: clair/trivy/grype, 为空时使用clair
+	Scanner string `bson:"scanner,omitempty"          json:"scanner,omitempty"`
+	// ScannerImage 运行trivy或grype扫描Job使用的镜像, 为空时使用默认镜像
+	ScannerImage string `bson:"scanner_image,omitempty"    json:"scanner_image,omitempty"`
+	// ScannerEnvs 扫描Job的环境变量, 例如离线环境中指定本地漏洞库
+	ScannerEnvs []*KeyVal `bson:"scanner_envs,omitempty"     json:"scanner_envs,omitempty"`
+}
+
+// SecurityPolicy 镜像安全扫描的准入策略, 违反策略时安全扫描任务失败
+type SecurityPolicy struct {
+	// MaxSeverityCount 各个级别允许的最大漏洞数量, 例如 {"Critical": 0, "High": 10}, 没有配置的级别不做限制
+	MaxSeverityCount map[string]int `bson:"max_severity_count"     json:"max_severity_count"`
+	// AllowList 豁免的漏洞, 不计入漏洞数量
+	AllowList []*AllowedVulnerability `bson:"allow_list"             json:"allow_list"`
+	// FixableOnly 为true时只统计已经有修复版本的漏洞
+	FixableOnly bool `bson:"fixable_only"           json:"fixable_only"`
+}
+
+type AllowedVulnerability struct {
+	// Name 漏洞编号, 例如 CVE-2021-3711
+	Name   string `bson:"name"                   json:"name"`
+	Reason string `bson:"reason,omitempty"       json:"reason,omitempty"`
+	// ExpireAt 豁免的过期时间, 为0时一直有效
+	ExpireAt int64 `bson:"expire_at,omitempty"    json:"expire_at,omitempty"`
+}
+
+// ApprovalStage 开启后工作流任务在部署前暂停, 等待审批人通过后继续执行
+type ApprovalStage struct {
+	Enabled     bool     `bson:"enabled"                    json:"enabled"`
+	Approvers   []string `bson:"approvers"                  json:"approvers"`
+	Description string   `bson:"description"                json:"description"`
+	// Timeout 等待审批的超时时间, 单位为分钟
+	Timeout int `bson:"timeout"                    json:"timeout"`
 }
 
 type DistributeStage struct {
@@ -245,6 +313,15 @@ type DistributeStage struct {
 
 	// repos to release images
 	Releases []RepoImage `bson:"releases" json:"releases"`
+	// Signing 分发后对镜像签名, 为空时不签名
+	Signing *ImageSigning `bson:"signing,omitempty" json:"signing,omitempty"`
+}
+
+// ImageSigning 使用cosign签名密钥对分发的镜像签名
+type ImageSigning struct {
+	KeyID string `bson:"key_id"      json:"key_id"`
+	// Provenance 是否同时附加包含工作流、任务和代码提交信息的构建来源证明
+	Provenance bool `bson:"provenance"  json:"provenance"`
 }
 
 type RepoImage struct {
@@ -269,9 +346,26 @@ type NotifyCtl struct {
 	WeChatWebHook   string   `bson:"weChat_webHook,omitempty"         json:"weChat_webHook,omitempty"`
 	DingDingWebHook string   `bson:"dingding_webhook,omitempty"       json:"dingding_webhook,omitempty"`
 	FeiShuWebHook   string   `bson:"feishu_webhook,omitempty"         json:"feishu_webhook,omitempty"`
+	SlackWebHook    string   `bson:"slack_webhook,omitempty"          json:"slack_webhook,omitempty"`
+	TeamsWebHook    string   `bson:"teams_webhook,omitempty"          json:"teams_webhook,omitempty"`
 	AtMobiles       []string `bson:"at_mobiles,omitempty"             json:"at_mobiles,omitempty"`
 	IsAtAll         bool     `bson:"is_at_all,omitempty"              json:"is_at_all,omitempty"`
 	NotifyTypes     []string `bson:"notify_type"                      json:"notify_type"`
+	// WebHooks 通用的webhook通知, 和上面的IM通知同时生效
+	WebHooks []*WebHookNotify `bson:"webhooks,omitempty" json:"webhooks,omitempty"`
+}
+
+// WebHookNotify 通用的webhook通知, 请求体由go template根据任务渲染
+type WebHookNotify struct {
+	Name    string            `bson:"name"              json:"name"`
+	URL     string            `bson:"url"               json:"url"`
+	Headers map[string]string `bson:"headers,omitempty" json:"headers,omitempty"`
+	// Secret 不为空时使用HMAC-SHA256对请求体签名
+	Secret string `bson:"secret,omitempty" json:"secret,omitempty"`
+	// Template 请求体模板, 为空时发送默认的json格式
+	Template string `bson:"template,omitempty" json:"template,omitempty"`
+	// Events 触发通知的任务状态, 为空时使用NotifyTypes
+	Events []string `bson:"events,omitempty" json:"events,omitempty"`
 }
 
 type TaskInfo struct {
@@ -335,9 +429,91 @@ type BuildArgs struct {
 }
 
 type DeployEnv struct {
-	Env         string `json:"env"`
-	Type        string `json:"type"`
-	ProductName string `json:"product_name,omitempty"`
+	Env         string          `json:"env"`
+	Type        string          `json:"type"`
+	ProductName string          `json:"product_name,omitempty"`
+	Strategy    *DeployStrategy `json:"strategy,omitempty"`
+	// Verifications 部署就绪后按顺序执行的验证, 任意一项失败时部署任务失败
+	Verifications []*DeployVerification `json:"verifications,omitempty"`
+}
+
+// DeployVerification 部署就绪后的验证
+type DeployVerification struct {
+	Name string `bson:"name"                      json:"name"`
+	// Type http/prometheus/job
+	Type string `bson:"type"                      json:"type"`
+	// URL http验证请求的地址
+	URL string `bson:"url,omitempty"             json:"url,omitempty"`
+	// ExpectedStatus http验证期望的状态码, 为0时只要求请求成功
+	ExpectedStatus int `bson:"expected_status,omitempty" json:"expected_status,omitempty"`
+	// PrometheusURL prometheus验证查询的地址, 查询结果通过 Operator 和 Threshold 比较
+	PrometheusURL string  `bson:"prometheus_url,omitempty"  json:"prometheus_url,omitempty"`
+	Query         string  `bson:"query,omitempty"           json:"query,omitempty"`
+	Operator      string  `bson:"operator,omitempty"        json:"operator,omitempty"`
+	Threshold     float64 `bson:"threshold,omitempty"       json:"threshold,omitempty"`
+	// Image 和 Script 自定义验证Job使用的镜像和脚本
+	Image  string `bson:"image,omitempty"           json:"image,omitempty"`
+	Script string `bson:"script,omitempty"          json:"script,omitempty"`
+	// Window 验证持续的时间(秒), 也是http验证和Job验证的超时时间
+	Window int `bson:"window,omitempty"          json:"window,omitempty"`
+	// Interval 两次检查之间的间隔(秒)
+	Interval int `bson:"interval,omitempty"        json:"interval,omitempty"`
+}
+
+var verificationOperators = map[string]bool{">": true, ">=": true, "<": true, "<=": true, "==": true, "!=": true}
+
+func (v *DeployVerification) Validate() error {
+	if v.Window < 0 || v.Interval < 0 {
+		return fmt.Errorf("window and interval of verification %s must not be negative", v.Name)
+	}
+
+	switch v.Type {
+	case setting.DeployVerificationHTTP:
+		if v.URL == "" {
+			return fmt.Errorf("url is required by verification %s", v.Name)
+		}
+	case setting.DeployVerificationPrometheus:
+		if v.PrometheusURL == "" || v.Query == "" {
+			return fmt.Errorf("prometheus url and query are required by verification %s", v.Name)
+		}
+		if !verificationOperators[v.Operator] {
+			return fmt.Errorf("unsupported operator %q of verification %s", v.Operator, v.Name)
+		}
+	case setting.DeployVerificationJob:
+		if v.Image == "" || v.Script == "" {
+			return fmt.Errorf("image and script are required by verification %s", v.Name)
+		}
+	default:
+		return fmt.Errorf("unsupported verification type: %s", v.Type)
+	}
+	return nil
+}
+
+// DeployStrategy 部署策略, 只支持k8s服务中的Deployment
+type DeployStrategy struct {
+	// Type 为空时等同于rolling, 直接替换工作负载的镜像
+	Type string `bson:"type"                     json:"type"`
+	// CanaryPercent 金丝雀版本的副本数占原工作负载副本数的百分比
+	CanaryPercent int `bson:"canary_percent,omitempty" json:"canary_percent,omitempty"`
+	// PauseSeconds 金丝雀版本就绪后观察多长时间再验证
+	PauseSeconds int `bson:"pause_seconds,omitempty"  json:"pause_seconds,omitempty"`
+}
+
+func (s *DeployStrategy) Validate() error {
+	switch s.Type {
+	case "", setting.DeployStrategyRolling, setting.DeployStrategyBlueGreen:
+		return nil
+	case setting.DeployStrategyCanary:
+		if s.CanaryPercent <= 0 || s.CanaryPercent >= 100 {
+			return fmt.Errorf("canary percent must be between 1 and 99")
+		}
+		if s.PauseSeconds < 0 {
+			return fmt.Errorf("pause seconds must not be negative")
+		}
+		return nil
+	default:
+		return fmt.Errorf("unsupported deploy strategy: %s", s.Type)
+	}
 }
 
 type ArtifactArgs struct {
@@ -388,7 +564,7 @@ func (d *DistributeStage) IsDistributeS3Enabled() bool {
 	return false
 }
 
-//Validate validate schedule setting
+// Validate validate schedule setting
 func (schedule *Schedule) Validate() error {
 	switch schedule.Type {
 	case config.TimingSchedule:
@@ -0,0 +1,43 @@
+/*
+Copyright 2021 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package models
+
+import (
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"github.com/koderover/zadig/pkg/microservice/aslan/config"
+)
+
+// WorkflowApproval 工作流任务中审批stage的审批记录, 每个工作流任务最多有一条
+type WorkflowApproval struct {
+	ID           primitive.ObjectID    `bson:"_id,omitempty"          json:"id,omitempty"`
+	PipelineName string                `bson:"pipeline_name"          json:"pipeline_name"`
+	TaskID       int64                 `bson:"task_id"                json:"task_id"`
+	ProductName  string                `bson:"product_name"           json:"product_name"`
+	Approvers    []string              `bson:"approvers"              json:"approvers"`
+	Description  string                `bson:"description"            json:"description"`
+	Status       config.ApprovalStatus `bson:"status"                 json:"status"`
+	Approver     string                `bson:"approver"               json:"approver"`
+	Comment      string                `bson:"comment"                json:"comment"`
+	ExpireTime   int64                 `bson:"expire_time"            json:"expire_time"`
+	CreateTime   int64                 `bson:"create_time"            json:"create_time"`
+	UpdateTime   int64                 `bson:"update_time"            json:"update_time"`
+}
+
+func (WorkflowApproval) TableName() string {
+	return "workflow_approval"
+}
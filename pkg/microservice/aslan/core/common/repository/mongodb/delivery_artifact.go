@@ -20,6 +20,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"regexp"
 
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
@@ -67,16 +68,24 @@ func (c *DeliveryArtifactColl) GetCollectionName() string {
 }
 
 func (c *DeliveryArtifactColl) EnsureIndex(ctx context.Context) error {
-	mod := mongo.IndexModel{
-		Keys: bson.D{
-			bson.E{Key: "name", Value: 1},
-			bson.E{Key: "type", Value: 1},
-			bson.E{Key: "image_tag", Value: 1},
+	mod := []mongo.IndexModel{
+		{
+			Keys: bson.D{
+				bson.E{Key: "name", Value: 1},
+				bson.E{Key: "type", Value: 1},
+				bson.E{Key: "image_tag", Value: 1},
+			},
+			Options: options.Index().SetUnique(false),
+		},
+		{
+			Keys: bson.D{
+				bson.E{Key: "sbom.packages.name", Value: 1},
+			},
+			Options: options.Index().SetUnique(false),
 		},
-		Options: options.Index().SetUnique(false),
 	}
 
-	_, err := c.Indexes().CreateOne(ctx, mod)
+	_, err := c.Indexes().CreateMany(ctx, mod)
 
 	return err
 }
@@ -171,6 +180,27 @@ func (c *DeliveryArtifactColl) Insert(args *models.DeliveryArtifact) error {
 	return nil
 }
 
+// ListBySBOMPackage 查询SBOM中包含指定软件包的镜像, 软件包名称模糊匹配, 版本号前缀匹配
+func (c *DeliveryArtifactColl) ListBySBOMPackage(name, version string) ([]*models.DeliveryArtifact, error) {
+	match := bson.M{"name": bson.M{"$regex": regexp.QuoteMeta(name), "$options": "i"}}
+	if version != "" {
+		match["version"] = bson.M{"$regex": "^" + regexp.QuoteMeta(version)}
+	}
+	query := bson.M{"sbom.packages": bson.M{"$elemMatch": match}}
+
+	resp := make([]*models.DeliveryArtifact, 0)
+	opt := options.Find().SetSort(bson.D{{"created_time", -1}})
+	cursor, err := c.Collection.Find(context.TODO(), query, opt)
+	if err != nil {
+		return nil, err
+	}
+	err = cursor.All(context.TODO(), &resp)
+	if err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
 func (c *DeliveryArtifactColl) Update(args *DeliveryArtifactArgs) error {
 	query := bson.M{"_id": args.ID}
 	if args.ImageHash != "" {
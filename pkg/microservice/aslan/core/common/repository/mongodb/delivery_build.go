@@ -72,6 +72,13 @@ func (c *DeliveryBuildColl) EnsureIndex(ctx context.Context) error {
 			},
 			Options: options.Index().SetUnique(false),
 		},
+		{
+			Keys: bson.D{
+				bson.E{Key: "image_name", Value: 1},
+				bson.E{Key: "deleted_at", Value: 1},
+			},
+			Options: options.Index().SetUnique(false),
+		},
 	}
 
 	_, err := c.Indexes().CreateMany(ctx, mod)
@@ -101,6 +108,21 @@ func (c *DeliveryBuildColl) Find(args *DeliveryBuildArgs) ([]*models.DeliveryBui
 	return resp, nil
 }
 
+func (c *DeliveryBuildColl) ListByImageNames(imageNames []string) ([]*models.DeliveryBuild, error) {
+	resp := make([]*models.DeliveryBuild, 0)
+	query := bson.M{"image_name": bson.M{"$in": imageNames}, "deleted_at": 0}
+
+	cursor, err := c.Collection.Find(context.TODO(), query)
+	if err != nil {
+		return nil, err
+	}
+	err = cursor.All(context.TODO(), &resp)
+	if err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
 func (c *DeliveryBuildColl) Delete(releaseID string) error {
 	oid, err := primitive.ObjectIDFromHex(releaseID)
 	if err != nil {
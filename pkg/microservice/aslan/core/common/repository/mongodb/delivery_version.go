@@ -174,6 +174,21 @@ func (c *DeliveryVersionColl) Get(args *DeliveryVersionArgs) (*models.DeliveryVe
 	return resp, err
 }
 
+func (c *DeliveryVersionColl) ListByIDs(ids []primitive.ObjectID) ([]*models.DeliveryVersion, error) {
+	resp := make([]*models.DeliveryVersion, 0)
+	query := bson.M{"_id": bson.M{"$in": ids}, "deleted_at": 0}
+
+	cursor, err := c.Collection.Find(context.TODO(), query)
+	if err != nil {
+		return nil, err
+	}
+	err = cursor.All(context.TODO(), &resp)
+	if err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
 func (c *DeliveryVersionColl) Insert(args *models.DeliveryVersion) error {
 	if args == nil {
 		return errors.New("nil delivery_version args")
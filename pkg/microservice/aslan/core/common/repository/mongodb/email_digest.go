@@ -0,0 +1,86 @@
+/*
+Copyright 2021 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mongodb
+
+import (
+	"context"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/koderover/zadig/pkg/microservice/aslan/config"
+	"github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/models"
+	mongotool "github.com/koderover/zadig/pkg/tool/mongo"
+)
+
+type EmailDigestColl struct {
+	*mongo.Collection
+
+	coll string
+}
+
+func NewEmailDigestColl() *EmailDigestColl {
+	name := models.EmailDigest{}.TableName()
+	return &EmailDigestColl{Collection: mongotool.Database(config.MongoDatabase()).Collection(name), coll: name}
+}
+
+func (c *EmailDigestColl) GetCollectionName() string {
+	return c.coll
+}
+
+func (c *EmailDigestColl) EnsureIndex(ctx context.Context) error {
+	mod := mongo.IndexModel{
+		Keys: bson.D{
+			bson.E{Key: "receiver", Value: 1},
+			bson.E{Key: "create_time", Value: 1},
+		},
+		Options: options.Index().SetUnique(false),
+	}
+
+	_, err := c.Indexes().CreateOne(ctx, mod)
+
+	return err
+}
+
+func (c *EmailDigestColl) Create(args *models.EmailDigest) error {
+	args.CreateTime = time.Now().Unix()
+	_, err := c.InsertOne(context.TODO(), args)
+
+	return err
+}
+
+func (c *EmailDigestColl) List() ([]*models.EmailDigest, error) {
+	var resp []*models.EmailDigest
+	opts := options.Find().SetSort(bson.D{{"create_time", 1}})
+	cursor, err := c.Collection.Find(context.TODO(), bson.M{}, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	err = cursor.All(context.TODO(), &resp)
+	return resp, err
+}
+
+func (c *EmailDigestColl) DeleteByIDs(ids []primitive.ObjectID) error {
+	query := bson.M{"_id": bson.M{"$in": ids}}
+	_, err := c.DeleteMany(context.TODO(), query)
+
+	return err
+}
@@ -0,0 +1,135 @@
+/*
+Copyright 2021 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mongodb
+
+import (
+	"context"
+	"errors"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/koderover/zadig/pkg/microservice/aslan/config"
+	"github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/models"
+	mongotool "github.com/koderover/zadig/pkg/tool/mongo"
+)
+
+type PrEnvFindOption struct {
+	CodehostID   int
+	Source       string
+	RepoOwner    string
+	RepoName     string
+	PrID         int
+	WorkflowName string
+}
+
+type PrEnvColl struct {
+	*mongo.Collection
+
+	coll string
+}
+
+func NewPrEnvColl() *PrEnvColl {
+	name := models.PrEnv{}.TableName()
+	return &PrEnvColl{Collection: mongotool.Database(config.MongoDatabase()).Collection(name), coll: name}
+}
+
+func (c *PrEnvColl) GetCollectionName() string {
+	return c.coll
+}
+
+func (c *PrEnvColl) EnsureIndex(ctx context.Context) error {
+	mod := []mongo.IndexModel{
+		{
+			Keys: bson.D{
+				bson.E{Key: "codehost_id", Value: 1},
+				bson.E{Key: "repo_owner", Value: 1},
+				bson.E{Key: "repo_name", Value: 1},
+				bson.E{Key: "pr_id", Value: 1},
+			},
+			Options: options.Index().SetUnique(false),
+		},
+		{
+			Keys: bson.D{
+				bson.E{Key: "product_name", Value: 1},
+				bson.E{Key: "env_name", Value: 1},
+			},
+			Options: options.Index().SetUnique(true),
+		},
+	}
+
+	_, err := c.Indexes().CreateMany(ctx, mod)
+
+	return err
+}
+
+func (c *PrEnvColl) Create(args *models.PrEnv) error {
+	if args == nil {
+		return errors.New("nil prEnv args")
+	}
+
+	_, err := c.InsertOne(context.TODO(), args)
+	return err
+}
+
+// Find 查找工作流为该PR创建的环境
+func (c *PrEnvColl) Find(opt *PrEnvFindOption) (*models.PrEnv, error) {
+	query := prEnvQuery(opt)
+	query["workflow_name"] = opt.WorkflowName
+
+	resp := new(models.PrEnv)
+	err := c.FindOne(context.TODO(), query).Decode(resp)
+	return resp, err
+}
+
+// List 列出为该PR创建的所有环境, 指定工作流时只列出该工作流创建的环境
+func (c *PrEnvColl) List(opt *PrEnvFindOption) ([]*models.PrEnv, error) {
+	resp := make([]*models.PrEnv, 0)
+	cursor, err := c.Collection.Find(context.TODO(), prEnvQuery(opt))
+	if err != nil {
+		return nil, err
+	}
+	err = cursor.All(context.TODO(), &resp)
+	return resp, err
+}
+
+func (c *PrEnvColl) Delete(productName, envName string) error {
+	_, err := c.DeleteOne(context.TODO(), bson.M{"product_name": productName, "env_name": envName})
+	return err
+}
+
+func prEnvQuery(opt *PrEnvFindOption) bson.M {
+	query := bson.M{
+		"repo_name": opt.RepoName,
+		"pr_id":     opt.PrID,
+	}
+	// gerrit没有repo owner
+	if opt.RepoOwner != "" {
+		query["repo_owner"] = opt.RepoOwner
+	}
+	if opt.CodehostID > 0 {
+		query["codehost_id"] = opt.CodehostID
+	}
+	if opt.Source != "" {
+		query["source"] = opt.Source
+	}
+	if opt.WorkflowName != "" {
+		query["workflow_name"] = opt.WorkflowName
+	}
+	return query
+}
@@ -137,3 +137,24 @@ func (c *QueueColl) UpdateAgent(taskID int64, pipelineName string, createTime in
 	_, err := c.UpdateOne(context.TODO(), query, change)
 	return err
 }
+
+// UpdatePriority 只允许调整还未发送到warpdrive的任务的优先级
+func (c *QueueColl) UpdatePriority(pipelineName string, taskID int64, priority int) error {
+	query := bson.M{
+		"task_id":       taskID,
+		"pipeline_name": pipelineName,
+		"status":        bson.M{"$in": []config.Status{config.StatusWaiting, config.StatusBlocked}},
+	}
+	change := bson.M{"$set": bson.M{
+		"priority": priority,
+	}}
+
+	res, err := c.UpdateOne(context.TODO(), query, change)
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return errors.New("no waiting or blocked task found")
+	}
+	return nil
+}
@@ -0,0 +1,137 @@
+/*
+Copyright 2021 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mongodb
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/koderover/zadig/pkg/microservice/aslan/config"
+	"github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/models"
+	mongotool "github.com/koderover/zadig/pkg/tool/mongo"
+)
+
+type SigningKeyColl struct {
+	*mongo.Collection
+
+	coll string
+}
+
+func NewSigningKeyColl() *SigningKeyColl {
+	name := models.SigningKey{}.TableName()
+	return &SigningKeyColl{
+		Collection: mongotool.Database(config.MongoDatabase()).Collection(name),
+		coll:       name,
+	}
+}
+
+func (c *SigningKeyColl) GetCollectionName() string {
+	return c.coll
+}
+
+func (c *SigningKeyColl) EnsureIndex(ctx context.Context) error {
+	mod := mongo.IndexModel{
+		Keys:    bson.M{"name": 1},
+		Options: options.Index().SetUnique(true),
+	}
+
+	_, err := c.Indexes().CreateOne(ctx, mod)
+	return err
+}
+
+func (c *SigningKeyColl) Find(id string) (*models.SigningKey, error) {
+	oid, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, err
+	}
+
+	signingKey := new(models.SigningKey)
+	err = c.FindOne(context.TODO(), bson.M{"_id": oid}).Decode(signingKey)
+	return signingKey, err
+}
+
+func (c *SigningKeyColl) List() ([]*models.SigningKey, error) {
+	resp := make([]*models.SigningKey, 0)
+	ctx := context.Background()
+
+	cursor, err := c.Collection.Find(ctx, bson.M{})
+	if err != nil {
+		return nil, err
+	}
+
+	err = cursor.All(ctx, &resp)
+	if err != nil {
+		return nil, err
+	}
+
+	return resp, err
+}
+
+func (c *SigningKeyColl) Create(args *models.SigningKey) error {
+	if args == nil {
+		return errors.New("nil SigningKey info")
+	}
+
+	args.CreateTime = time.Now().Unix()
+	args.UpdateTime = time.Now().Unix()
+
+	_, err := c.InsertOne(context.TODO(), args)
+
+	return err
+}
+
+func (c *SigningKeyColl) Update(id string, args *models.SigningKey) error {
+	if args == nil {
+		return errors.New("nil SigningKey info")
+	}
+
+	oid, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return err
+	}
+
+	query := bson.M{"_id": oid}
+	change := bson.M{"$set": bson.M{
+		"name":        args.Name,
+		"private_key": args.PrivateKey,
+		"password":    args.Password,
+		"public_key":  args.PublicKey,
+		"update_by":   args.UpdateBy,
+		"update_time": time.Now().Unix(),
+	}}
+
+	_, err = c.UpdateOne(context.TODO(), query, change)
+	return err
+}
+
+func (c *SigningKeyColl) Delete(id string) error {
+	oid, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return err
+	}
+
+	query := bson.M{"_id": oid}
+
+	_, err = c.DeleteOne(context.TODO(), query)
+	return err
+}
@@ -71,6 +71,11 @@ func (c *SubscriptionColl) Update(subscriber string, notifyType int, args *model
 	query := bson.M{"subscriber": subscriber, "type": notifyType}
 	change := bson.M{"$set": bson.M{
 		"pipelinestatus": args.PipelineStatus,
+		"product_names":  args.ProductNames,
+		"pipeline_names": args.PipelineNames,
+		"email":          args.Email,
+		"email_address":  args.EmailAddress,
+		"digest":         args.Digest,
 	}}
 	_, err := c.UpdateOne(context.TODO(), query, change)
 
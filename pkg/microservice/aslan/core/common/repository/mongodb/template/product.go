@@ -209,6 +209,7 @@ func (c *ProductColl) Update(productName string, args *template.Product) error {
 		"image_searching_rules": args.ImageSearchingRules,
 		"custom_tar_rule":       args.CustomTarRule,
 		"custom_image_rule":     args.CustomImageRule,
+		"max_concurrent_tasks":  args.MaxConcurrentTasks,
 	}}
 
 	_, err := c.UpdateOne(context.TODO(), query, change)
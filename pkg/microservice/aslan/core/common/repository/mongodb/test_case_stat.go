@@ -0,0 +1,154 @@
+/*
+Copyright 2021 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mongodb
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/koderover/zadig/pkg/microservice/aslan/config"
+	"github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/models"
+	mongotool "github.com/koderover/zadig/pkg/tool/mongo"
+)
+
+type TestCaseStatListOption struct {
+	TestName    string
+	FlakyOnly   bool
+	Quarantined bool
+}
+
+type TestCaseStatColl struct {
+	*mongo.Collection
+
+	coll string
+}
+
+func NewTestCaseStatColl() *TestCaseStatColl {
+	name := models.TestCaseStat{}.TableName()
+	return &TestCaseStatColl{Collection: mongotool.Database(config.MongoDatabase()).Collection(name), coll: name}
+}
+
+func (c *TestCaseStatColl) GetCollectionName() string {
+	return c.coll
+}
+
+func (c *TestCaseStatColl) EnsureIndex(ctx context.Context) error {
+	mod := mongo.IndexModel{
+		Keys: bson.D{
+			bson.E{Key: "test_name", Value: 1},
+			bson.E{Key: "classname", Value: 1},
+			bson.E{Key: "name", Value: 1},
+		},
+		Options: options.Index().SetUnique(true),
+	}
+
+	_, err := c.Indexes().CreateOne(ctx, mod)
+
+	return err
+}
+
+func (c *TestCaseStatColl) Find(testName, className, name string) (*models.TestCaseStat, error) {
+	query := bson.M{"test_name": testName, "classname": className, "name": name}
+	resp := new(models.TestCaseStat)
+	err := c.FindOne(context.TODO(), query).Decode(resp)
+	return resp, err
+}
+
+// List 按不稳定程度从高到低排列
+func (c *TestCaseStatColl) List(opt *TestCaseStatListOption) ([]*models.TestCaseStat, error) {
+	query := bson.M{"test_name": opt.TestName}
+	switch {
+	case opt.FlakyOnly:
+		query["$or"] = bson.A{bson.M{"flakiness": bson.M{"$gt": 0}}, bson.M{"quarantined": true}}
+	case opt.Quarantined:
+		query["quarantined"] = true
+	}
+
+	resp := make([]*models.TestCaseStat, 0)
+	opts := options.Find().SetSort(bson.D{{"flakiness", -1}, {"total_failure", -1}})
+	cursor, err := c.Collection.Find(context.TODO(), query, opts)
+	if err != nil {
+		return nil, err
+	}
+	err = cursor.All(context.TODO(), &resp)
+	return resp, err
+}
+
+// AppendResult 原子地把一次运行结果追加到用例的历史中, 只保留最近historySize次, 返回更新后的记录
+func (c *TestCaseStatColl) AppendResult(testName, className, name string, passed bool, duration float64, historySize int) (*models.TestCaseStat, error) {
+	counter := "total_failure"
+	if passed {
+		counter = "total_success"
+	}
+
+	query := bson.M{"test_name": testName, "classname": className, "name": name}
+	change := bson.M{
+		"$inc":  bson.M{counter: 1},
+		"$push": bson.M{"recent": bson.M{"$each": bson.A{passed}, "$slice": -historySize}},
+		"$set": bson.M{
+			"duration":    duration,
+			"update_time": time.Now().Unix(),
+		},
+	}
+	opts := options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After)
+
+	resp := new(models.TestCaseStat)
+	err := c.FindOneAndUpdate(context.TODO(), query, change, opts).Decode(resp)
+	return resp, err
+}
+
+// UpdateFlakiness 历史没有被其他任务改变时更新不稳定程度, 否则由改变历史的任务更新
+func (c *TestCaseStatColl) UpdateFlakiness(stat *models.TestCaseStat) error {
+	if stat == nil {
+		return errors.New("nil testCaseStat args")
+	}
+
+	query := bson.M{"test_name": stat.TestName, "classname": stat.ClassName, "name": stat.Name, "recent": stat.Recent}
+	change := bson.M{"$set": bson.M{"flakiness": stat.Flakiness}}
+	_, err := c.UpdateOne(context.TODO(), query, change)
+	return err
+}
+
+func (c *TestCaseStatColl) UpdateQuarantine(testName, className, name string, quarantined bool, updateBy string) error {
+	query := bson.M{"test_name": testName, "classname": className, "name": name}
+	change := bson.M{"$set": bson.M{
+		"quarantined":     quarantined,
+		"quarantined_by":  updateBy,
+		"quarantine_time": time.Now().Unix(),
+	}}
+	res, err := c.UpdateOne(context.TODO(), query, change)
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+	return nil
+}
+
+func (c *TestCaseStatColl) DeleteByTestName(testName string) error {
+	if testName == "" {
+		return nil
+	}
+	_, err := c.DeleteMany(context.TODO(), bson.M{"test_name": testName})
+	return err
+}
@@ -0,0 +1,114 @@
+/*
+Copyright 2021 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mongodb
+
+import (
+	"context"
+	"errors"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/koderover/zadig/pkg/microservice/aslan/config"
+	"github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/models"
+	mongotool "github.com/koderover/zadig/pkg/tool/mongo"
+)
+
+type TestCoverageListOption struct {
+	TestName string
+	Branch   string
+	Limit    int64
+}
+
+type TestCoverageColl struct {
+	*mongo.Collection
+
+	coll string
+}
+
+func NewTestCoverageColl() *TestCoverageColl {
+	name := models.TestCoverage{}.TableName()
+	return &TestCoverageColl{Collection: mongotool.Database(config.MongoDatabase()).Collection(name), coll: name}
+}
+
+func (c *TestCoverageColl) GetCollectionName() string {
+	return c.coll
+}
+
+func (c *TestCoverageColl) EnsureIndex(ctx context.Context) error {
+	mod := mongo.IndexModel{
+		Keys: bson.D{
+			bson.E{Key: "test_name", Value: 1},
+			bson.E{Key: "branch", Value: 1},
+			bson.E{Key: "create_time", Value: -1},
+		},
+		Options: options.Index().SetUnique(false),
+	}
+
+	_, err := c.Indexes().CreateOne(ctx, mod)
+
+	return err
+}
+
+func (c *TestCoverageColl) Create(args *models.TestCoverage) error {
+	if args == nil {
+		return errors.New("nil testCoverage args")
+	}
+
+	_, err := c.InsertOne(context.TODO(), args)
+	return err
+}
+
+// List 按时间从新到旧排列
+func (c *TestCoverageColl) List(opt *TestCoverageListOption) ([]*models.TestCoverage, error) {
+	query := bson.M{"test_name": opt.TestName}
+	if opt.Branch != "" {
+		query["branch"] = opt.Branch
+	}
+
+	opts := options.Find().SetSort(bson.D{{"create_time", -1}})
+	if opt.Limit > 0 {
+		opts.SetLimit(opt.Limit)
+	}
+
+	resp := make([]*models.TestCoverage, 0)
+	cursor, err := c.Collection.Find(context.TODO(), query, opts)
+	if err != nil {
+		return nil, err
+	}
+	err = cursor.All(context.TODO(), &resp)
+	return resp, err
+}
+
+// FindLatestOfBranch 返回分支(非PR)最近一次测试的覆盖率
+func (c *TestCoverageColl) FindLatestOfBranch(testName, branch string) (*models.TestCoverage, error) {
+	query := bson.M{"test_name": testName, "branch": branch, "pr": bson.M{"$exists": false}}
+	opts := options.FindOne().SetSort(bson.D{{"create_time", -1}})
+
+	resp := new(models.TestCoverage)
+	err := c.FindOne(context.TODO(), query, opts).Decode(resp)
+	return resp, err
+}
+
+func (c *TestCoverageColl) DeleteByTestName(testName string) error {
+	if testName == "" {
+		return nil
+	}
+	_, err := c.DeleteMany(context.TODO(), bson.M{"test_name": testName})
+	return err
+}
@@ -0,0 +1,116 @@
+/*
+Copyright 2021 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mongodb
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/koderover/zadig/pkg/microservice/aslan/config"
+	"github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/models"
+	mongotool "github.com/koderover/zadig/pkg/tool/mongo"
+)
+
+type WorkflowApprovalColl struct {
+	*mongo.Collection
+
+	coll string
+}
+
+func NewWorkflowApprovalColl() *WorkflowApprovalColl {
+	name := models.WorkflowApproval{}.TableName()
+	return &WorkflowApprovalColl{Collection: mongotool.Database(config.MongoDatabase()).Collection(name), coll: name}
+}
+
+func (c *WorkflowApprovalColl) GetCollectionName() string {
+	return c.coll
+}
+
+func (c *WorkflowApprovalColl) EnsureIndex(ctx context.Context) error {
+	mod := mongo.IndexModel{
+		Keys: bson.D{
+			bson.E{Key: "pipeline_name", Value: 1},
+			bson.E{Key: "task_id", Value: 1},
+		},
+		Options: options.Index().SetUnique(true),
+	}
+
+	_, err := c.Indexes().CreateOne(ctx, mod)
+
+	return err
+}
+
+// Upsert 创建审批记录, 任务重启时会重置已有的审批结果
+func (c *WorkflowApprovalColl) Upsert(args *models.WorkflowApproval) error {
+	query := bson.M{"pipeline_name": args.PipelineName, "task_id": args.TaskID}
+	now := time.Now().Unix()
+	args.CreateTime = now
+	args.UpdateTime = now
+
+	change := bson.M{"$set": args}
+	_, err := c.UpdateOne(context.TODO(), query, change, options.Update().SetUpsert(true))
+
+	return err
+}
+
+func (c *WorkflowApprovalColl) Find(pipelineName string, taskID int64) (*models.WorkflowApproval, error) {
+	resp := new(models.WorkflowApproval)
+	query := bson.M{"pipeline_name": pipelineName, "task_id": taskID}
+
+	err := c.FindOne(context.TODO(), query).Decode(resp)
+
+	return resp, err
+}
+
+// UpdateStatus 只有处于等待状态的审批可以被通过或者拒绝
+func (c *WorkflowApprovalColl) UpdateStatus(pipelineName string, taskID int64, status config.ApprovalStatus, approver, comment string) error {
+	now := time.Now().Unix()
+	query := bson.M{
+		"pipeline_name": pipelineName,
+		"task_id":       taskID,
+		"status":        config.ApprovalStatusWaiting,
+		"expire_time":   bson.M{"$gte": now},
+	}
+	change := bson.M{"$set": bson.M{
+		"status":      status,
+		"approver":    approver,
+		"comment":     comment,
+		"update_time": now,
+	}}
+
+	res, err := c.UpdateOne(context.TODO(), query, change)
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return fmt.Errorf("no waiting approval found for %s#%d", pipelineName, taskID)
+	}
+
+	return nil
+}
+
+func (c *WorkflowApprovalColl) DeleteByPipelineName(pipelineName string) error {
+	query := bson.M{"pipeline_name": pipelineName}
+	_, err := c.DeleteMany(context.TODO(), query)
+
+	return err
+}
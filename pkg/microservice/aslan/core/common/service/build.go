@@ -17,6 +17,7 @@ limitations under the License.
 package service
 
 import (
+	"fmt"
 	"strings"
 	"time"
 
@@ -33,6 +34,9 @@ func CreateBuild(username string, build *commonmodels.Build, log *zap.SugaredLog
 	if len(build.Name) == 0 {
 		return e.ErrCreateBuildModule.AddDesc("empty name")
 	}
+	if err := validateBuild(build); err != nil {
+		return e.ErrCreateBuildModule.AddErr(err)
+	}
 
 	build.UpdateBy = username
 	correctFields(build)
@@ -49,6 +53,9 @@ func UpdateBuild(username string, build *commonmodels.Build, log *zap.SugaredLog
 	if len(build.Name) == 0 {
 		return e.ErrUpdateBuildModule.AddDesc("empty name")
 	}
+	if err := validateBuild(build); err != nil {
+		return e.ErrUpdateBuildModule.AddErr(err)
+	}
 
 	existed, err := commonrepo.NewBuildColl().Find(&commonrepo.BuildFindOption{Name: build.Name, ProductName: build.ProductName})
 	if err == nil && existed.PreBuild != nil && build.PreBuild != nil {
@@ -78,6 +85,15 @@ func correctFields(build *commonmodels.Build) {
 	}
 	build.Caches = caches
 
+	keyFiles := make([]string, 0)
+	for _, file := range build.CacheKeyFiles {
+		file = strings.Trim(file, " /")
+		if file != "" {
+			keyFiles = append(keyFiles, file)
+		}
+	}
+	build.CacheKeyFiles = keyFiles
+
 	// trim the docker file and context
 	if build.PostBuild != nil && build.PostBuild.DockerBuild != nil {
 		build.PostBuild.DockerBuild.DockerFile = strings.Trim(build.PostBuild.DockerBuild.DockerFile, " ")
@@ -135,3 +151,18 @@ func EnsureResp(build *commonmodels.Build) {
 		}
 	}
 }
+
+func validateBuild(build *commonmodels.Build) error {
+	switch build.CacheType {
+	case "", setting.BuildCacheTypeTar, setting.BuildCacheTypeChunk:
+	default:
+		return fmt.Errorf("invalid cache type: %s", build.CacheType)
+	}
+
+	for _, step := range build.Steps {
+		if err := step.Validate(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
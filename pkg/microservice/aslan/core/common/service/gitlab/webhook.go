@@ -28,7 +28,7 @@ func (c *Client) CreateWebHook(owner, repo string) (string, error) {
 	projectHook, err := c.AddProjectHook(owner, repo, &git.Hook{
 		URL:    config.WebHookURL(),
 		Secret: gitservice.GetHookSecret(),
-		Events: []string{git.PushEvent, git.PullRequestEvent, git.BranchOrTagCreateEvent},
+		Events: []string{git.PushEvent, git.PullRequestEvent, git.BranchOrTagCreateEvent, git.ReleaseEvent, git.NoteEvent},
 	})
 	if err != nil {
 		return "", err
@@ -32,6 +32,7 @@ const sevendays int64 = 60 * 60 * 24 * 7
 
 type client struct {
 	notifyColl       *mongodb.NotifyColl
+	emailDigestColl  *mongodb.EmailDigestColl
 	pipelineColl     *mongodb.PipelineColl
 	subscriptionColl *mongodb.SubscriptionColl
 	taskColl         *mongodb.TaskColl
@@ -42,6 +43,7 @@ type client struct {
 func NewNotifyClient() *client {
 	return &client{
 		notifyColl:       mongodb.NewNotifyColl(),
+		emailDigestColl:  mongodb.NewEmailDigestColl(),
 		pipelineColl:     mongodb.NewPipelineColl(),
 		subscriptionColl: mongodb.NewSubscriptionColl(),
 		taskColl:         mongodb.NewTaskColl(),
@@ -68,6 +70,12 @@ func (c *client) CreateNotify(sender string, nf *models.Notify) error {
 			return fmt.Errorf("[%s] convert message error: %v", sender, err)
 		}
 
+		nf.Content = content
+	case config.EnvRecycle:
+		var content *models.EnvRecycleCtx
+		if err = json.Unmarshal(b, &content); err != nil {
+			return fmt.Errorf("[%s] convert env recycle error: %v", sender, err)
+		}
 		nf.Content = content
 	default:
 		return fmt.Errorf("notify type not found")
@@ -76,9 +84,32 @@ func (c *client) CreateNotify(sender string, nf *models.Notify) error {
 	if err != nil {
 		return fmt.Errorf("[%s] create Notify error: %v", sender, err)
 	}
+
+	if err := c.emailNotify(nf); err != nil {
+		log.Errorf("[%s] email notify error: %v", sender, err)
+	}
 	return nil
 }
 
+// emailNotify 站内消息和环境回收提醒同时按照接收人的订阅发送邮件
+func (c *client) emailNotify(nf *models.Notify) error {
+	var subject, content, productName string
+	var err error
+	switch ctx := nf.Content.(type) {
+	case *models.MessageCtx:
+		subject, content, err = messageEmail(ctx)
+	case *models.EnvRecycleCtx:
+		productName = ctx.ProductName
+		subject, content, err = envRecycleEmail(ctx)
+	default:
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return c.emailSubscribers(nf.Receiver, nf.Type, productName, subject, content)
+}
+
 func (c *client) PullNotify(user string) ([]*models.Notify, error) {
 	resp := make([]*models.Notify, 0)
 	notifyList, err := c.notifyColl.List(user)
@@ -197,6 +228,7 @@ func (c *client) ProccessNotify(notify *models.Notify) error {
 			logger.Infof("workflow get task #%d notify, status: %s", ctx.TaskID, ctx.Status)
 			_ = c.scmNotifyService.UpdateWebhookComment(task, logger)
 			_ = c.scmNotifyService.UpdateDiffNote(task, logger)
+			_ = c.scmNotifyService.UpdateCommitStatus(task, logger)
 		} else if ctx.Type == config.TestType {
 			logger.Infof("test get task #%d notify, status: %s", ctx.TaskID, ctx.Status)
 			_ = c.scmNotifyService.UpdateWebhookCommentForTest(task, logger)
@@ -208,8 +240,9 @@ func (c *client) ProccessNotify(notify *models.Notify) error {
 			return fmt.Errorf("SendWechatMessage err : %v", err)
 		}
 
+		var subject, content string
 		for _, receiver := range receivers {
-			subs, err := c.subscriptionColl.List(notify.Receiver)
+			subs, err := c.subscriptionColl.List(receiver)
 			if err != nil {
 				return fmt.Errorf("list subscribers error: %v", err)
 			}
@@ -219,11 +252,24 @@ func (c *client) ProccessNotify(notify *models.Notify) error {
 				Content:  ctx,
 			}
 			for _, sub := range subs {
-				if sub.Type == newNotify.Type && (sub.PipelineStatus == ctx.Status || sub.PipelineStatus == "*") {
-					if err := c.notifyColl.Create(newNotify); err != nil {
-						return fmt.Errorf("create notify error: %v", err)
+				if sub.Type != newNotify.Type || !subscriptionMatches(sub, ctx.ProductName, ctx.PipelineName, ctx.Status) {
+					continue
+				}
+				if err := c.notifyColl.Create(newNotify); err != nil {
+					return fmt.Errorf("create notify error: %v", err)
+				}
+
+				if !sub.Email {
+					continue
+				}
+				if subject == "" {
+					if subject, content, err = taskEmail(task, ctx.Status); err != nil {
+						return fmt.Errorf("render task email error: %v", err)
 					}
 				}
+				if err := c.deliverEmail(sub, subject, content); err != nil {
+					logger.Errorf("[%s] send task email error: %v", receiver, err)
+				}
 			}
 		}
 	case config.Message:
@@ -0,0 +1,239 @@
+/*
+Copyright 2021 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package notify
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	configbase "github.com/koderover/zadig/pkg/config"
+	"github.com/koderover/zadig/pkg/microservice/aslan/config"
+	"github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/models"
+	"github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/models/task"
+	"github.com/koderover/zadig/pkg/microservice/aslan/core/common/service/wechat"
+	"github.com/koderover/zadig/pkg/tool/log"
+	"github.com/koderover/zadig/pkg/tool/mail"
+)
+
+// emailLayoutTemplate 邮件的外层html, 各类通知只渲染正文部分, 汇总邮件可以直接拼接多条正文
+var emailLayoutTemplate = template.Must(template.New("layout").Parse(`<html><body style="font-family:Arial,sans-serif;font-size:14px;color:#333;">
+{{ . }}
+<p style="color:#999;font-size:12px;">此邮件由 Zadig 根据您的通知订阅自动发送</p>
+</body></html>`))
+
+var (
+	taskEmailTemplate = template.Must(template.New("task").Parse(`<h3>工作流 {{ .Task.PipelineName }} #{{ .Task.TaskID }} {{ .Status }}</h3>
+<table>
+<tr><td>项目</td><td>{{ .Task.ProductName }}</td></tr>
+<tr><td>执行用户</td><td>{{ .Task.TaskCreator }}</td></tr>
+<tr><td>状态</td><td>{{ .Status }}</td></tr>
+</table>
+<p><a href="{{ .URL }}">查看任务详情</a></p>`))
+
+	messageEmailTemplate = template.Must(template.New("message").Parse(`<h3>{{ .Title }}</h3>
+<p>{{ .Content }}</p>`))
+
+	envRecycleEmailTemplate = template.Must(template.New("envRecycle").Parse(`<h3>环境即将被回收</h3>
+<p>项目 {{ .Ctx.ProductName }} 的环境 {{ .Ctx.EnvName }} 已经 {{ .Ctx.RecycleDay }} 天没有更新, 将于 {{ .RecycleTime }} 被回收。</p>
+<p>如需保留该环境, 请在回收前更新环境或者调整回收策略。</p>
+<p><a href="{{ .URL }}">查看环境详情</a></p>`))
+
+	digestEmailTemplate = template.Must(template.New("digest").Parse(`<h3>Zadig 通知汇总</h3>
+{{ range . }}<hr/>
+<p style="color:#999;">{{ .Subject }}</p>
+{{ .Content }}
+{{ end }}`))
+)
+
+type digestEntry struct {
+	Subject string
+	Content template.HTML
+}
+
+// subscriptionMatches 检查订阅是否关注该项目/工作流的任务状态, 项目和工作流为空时不过滤
+func subscriptionMatches(sub *models.Subscription, productName, pipelineName string, status config.Status) bool {
+	if sub.PipelineStatus != "*" && sub.PipelineStatus != status {
+		return false
+	}
+	return containsOrEmpty(sub.ProductNames, productName) && containsOrEmpty(sub.PipelineNames, pipelineName)
+}
+
+func containsOrEmpty(list []string, value string) bool {
+	if len(list) == 0 {
+		return true
+	}
+	for _, v := range list {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+func emailEnabled() bool {
+	return config.SMTPHost() != ""
+}
+
+func newMailClient() *mail.Client {
+	return mail.New(&mail.Config{
+		Host:     config.SMTPHost(),
+		Port:     config.SMTPPort(),
+		Username: config.SMTPUsername(),
+		Password: config.SMTPPassword(),
+		From:     config.SMTPFrom(),
+	})
+}
+
+func renderEmail(tmpl *template.Template, data interface{}) (string, error) {
+	buffer := bytes.NewBufferString("")
+	if err := tmpl.Execute(buffer, data); err != nil {
+		return "", err
+	}
+	return buffer.String(), nil
+}
+
+// wrapEmail 为渲染好的正文加上邮件外层html
+func wrapEmail(content string) (string, error) {
+	return renderEmail(emailLayoutTemplate, template.HTML(content))
+}
+
+func taskEmail(t *task.Task, status config.Status) (string, string, error) {
+	subject := fmt.Sprintf("[Zadig] 工作流 %s #%d %s", t.PipelineName, t.TaskID, status)
+	content, err := renderEmail(taskEmailTemplate, struct {
+		Task   *task.Task
+		Status config.Status
+		URL    string
+	}{Task: t, Status: status, URL: wechat.TaskURL(t)})
+	return subject, content, err
+}
+
+func messageEmail(ctx *models.MessageCtx) (string, string, error) {
+	subject := fmt.Sprintf("[Zadig] %s", ctx.Title)
+	content, err := renderEmail(messageEmailTemplate, ctx)
+	return subject, content, err
+}
+
+func envRecycleEmail(ctx *models.EnvRecycleCtx) (string, string, error) {
+	subject := fmt.Sprintf("[Zadig] 环境 %s/%s 即将被回收", ctx.ProductName, ctx.EnvName)
+	content, err := renderEmail(envRecycleEmailTemplate, struct {
+		Ctx         *models.EnvRecycleCtx
+		RecycleTime string
+		URL         string
+	}{
+		Ctx:         ctx,
+		RecycleTime: time.Unix(ctx.RecycleTime, 0).Format("2006-01-02 15:04"),
+		URL:         fmt.Sprintf("%s/v1/projects/detail/%s/envs/detail?envName=%s", configbase.SystemAddress(), ctx.ProductName, ctx.EnvName),
+	})
+	return subject, content, err
+}
+
+// deliverEmail 按照订阅设置发送邮件, 开启汇总的订阅先保存, 由定时任务统一发送
+func (c *client) deliverEmail(sub *models.Subscription, subject, content string) error {
+	if !sub.Email || sub.EmailAddress == "" || !emailEnabled() {
+		return nil
+	}
+
+	if sub.Digest {
+		return c.emailDigestColl.Create(&models.EmailDigest{
+			Receiver:     sub.Subscriber,
+			EmailAddress: sub.EmailAddress,
+			Subject:      subject,
+			Content:      content,
+		})
+	}
+	body, err := wrapEmail(content)
+	if err != nil {
+		return err
+	}
+	return newMailClient().Send([]string{sub.EmailAddress}, subject, body)
+}
+
+// emailSubscribers 给receiver订阅了该类型消息并开启邮件的订阅发送邮件
+func (c *client) emailSubscribers(receiver string, notifyType config.NotifyType, productName, subject, content string) error {
+	if !emailEnabled() {
+		return nil
+	}
+	subs, err := c.subscriptionColl.List(receiver)
+	if err != nil {
+		return fmt.Errorf("list subscribers error: %v", err)
+	}
+	for _, sub := range subs {
+		if sub.Type != notifyType || !containsOrEmpty(sub.ProductNames, productName) {
+			continue
+		}
+		if err := c.deliverEmail(sub, subject, content); err != nil {
+			return fmt.Errorf("[%s] send email error: %v", receiver, err)
+		}
+	}
+	return nil
+}
+
+// SendEmailDigests 将汇总模式下积攒的邮件按照收件人合并发送
+func (c *client) SendEmailDigests() error {
+	if !emailEnabled() {
+		return nil
+	}
+	digests, err := c.emailDigestColl.List()
+	if err != nil {
+		return fmt.Errorf("list email digests error: %v", err)
+	}
+
+	var addresses []string
+	grouped := make(map[string][]*models.EmailDigest)
+	for _, digest := range digests {
+		if _, ok := grouped[digest.EmailAddress]; !ok {
+			addresses = append(addresses, digest.EmailAddress)
+		}
+		grouped[digest.EmailAddress] = append(grouped[digest.EmailAddress], digest)
+	}
+
+	mailClient := newMailClient()
+	for _, address := range addresses {
+		items := grouped[address]
+		// 每条通知的正文已经是渲染好的html, 汇总时不需要再转义
+		entries := make([]digestEntry, 0, len(items))
+		for _, item := range items {
+			entries = append(entries, digestEntry{Subject: item.Subject, Content: template.HTML(item.Content)})
+		}
+		content, err := renderEmail(digestEmailTemplate, entries)
+		if err == nil {
+			content, err = wrapEmail(content)
+		}
+		if err != nil {
+			log.Errorf("render email digest of %s error: %v", address, err)
+			continue
+		}
+		subject := fmt.Sprintf("[Zadig] 您有 %d 条新通知", len(items))
+		if err := mailClient.Send([]string{address}, subject, content); err != nil {
+			log.Errorf("send email digest to %s error: %v", address, err)
+			continue
+		}
+
+		ids := make([]primitive.ObjectID, 0, len(items))
+		for _, item := range items {
+			ids = append(ids, item.ID)
+		}
+		if err := c.emailDigestColl.DeleteByIDs(ids); err != nil {
+			return fmt.Errorf("delete email digests error: %v", err)
+		}
+	}
+	return nil
+}
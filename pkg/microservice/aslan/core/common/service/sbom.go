@@ -0,0 +1,143 @@
+/*
+Copyright 2021 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	commonmodels "github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/models"
+	s3service "github.com/koderover/zadig/pkg/microservice/aslan/core/common/service/s3"
+	"github.com/koderover/zadig/pkg/setting"
+	s3tool "github.com/koderover/zadig/pkg/tool/s3"
+	"github.com/koderover/zadig/pkg/util"
+)
+
+// GetTaskSBOM 下载构建任务中为镜像生成的SBOM文件, 解析出其中的软件包
+func GetTaskSBOM(storageURI, pipelineName string, taskID int64, image, format string) (*commonmodels.ArtifactSBOM, error) {
+	storage, err := s3service.NewS3StorageFromEncryptedURI(storageURI)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get s3 storage: %v", err)
+	}
+	if storage.Subfolder != "" {
+		storage.Subfolder = fmt.Sprintf("%s/%s/%d/%s", storage.Subfolder, pipelineName, taskID, setting.SBOMFileType)
+	} else {
+		storage.Subfolder = fmt.Sprintf("%s/%d/%s", pipelineName, taskID, setting.SBOMFileType)
+	}
+
+	fileName := util.GetSBOMFileName(image)
+	sbom := &commonmodels.ArtifactSBOM{
+		Format:     format,
+		FileName:   fileName,
+		ObjectKey:  storage.GetObjectPath(fileName),
+		StorageURI: storageURI,
+	}
+	content, err := DownloadSBOM(sbom)
+	if err != nil {
+		return nil, err
+	}
+	if sbom.Packages, err = ParseSBOMPackages(content); err != nil {
+		return nil, err
+	}
+	return sbom, nil
+}
+
+// DownloadSBOM 从对象存储下载SBOM文件的内容
+func DownloadSBOM(sbom *commonmodels.ArtifactSBOM) ([]byte, error) {
+	storage, err := s3service.NewS3StorageFromEncryptedURI(sbom.StorageURI)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get s3 storage: %v", err)
+	}
+	forcedPathStyle := true
+	if storage.Provider == setting.ProviderSourceAli {
+		forcedPathStyle = false
+	}
+	client, err := s3tool.NewClient(storage.Endpoint, storage.Ak, storage.Sk, storage.Insecure, forcedPathStyle)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get s3 client to download %s, error is: %v", sbom.FileName, err)
+	}
+
+	tmpfile, err := util.GenerateTmpFile()
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = os.Remove(tmpfile)
+	}()
+	if err = client.Download(storage.Bucket, sbom.ObjectKey, tmpfile); err != nil {
+		return nil, fmt.Errorf("failed to download %s %v", sbom.FileName, err)
+	}
+	return ioutil.ReadFile(tmpfile)
+}
+
+type spdxDocument struct {
+	SPDXVersion string `json:"spdxVersion"`
+	Packages    []struct {
+		Name         string `json:"name"`
+		VersionInfo  string `json:"versionInfo"`
+		ExternalRefs []struct {
+			ReferenceType    string `json:"referenceType"`
+			ReferenceLocator string `json:"referenceLocator"`
+		} `json:"externalRefs"`
+	} `json:"packages"`
+}
+
+type cycloneDXDocument struct {
+	BOMFormat  string `json:"bomFormat"`
+	Components []struct {
+		Name    string `json:"name"`
+		Version string `json:"version"`
+		PURL    string `json:"purl"`
+	} `json:"components"`
+}
+
+// ParseSBOMPackages 解析SPDX或CycloneDX格式的json文件, 返回其中的软件包
+func ParseSBOMPackages(content []byte) ([]*commonmodels.SBOMPackage, error) {
+	packages := make([]*commonmodels.SBOMPackage, 0)
+
+	spdx := &spdxDocument{}
+	if err := json.Unmarshal(content, spdx); err != nil {
+		return nil, fmt.Errorf("invalid sbom: %v", err)
+	}
+	if spdx.SPDXVersion != "" {
+		for _, p := range spdx.Packages {
+			pkg := &commonmodels.SBOMPackage{Name: p.Name, Version: p.VersionInfo}
+			for _, ref := range p.ExternalRefs {
+				if ref.ReferenceType == "purl" {
+					pkg.PURL = ref.ReferenceLocator
+					break
+				}
+			}
+			packages = append(packages, pkg)
+		}
+		return packages, nil
+	}
+
+	cyclonedx := &cycloneDXDocument{}
+	if err := json.Unmarshal(content, cyclonedx); err != nil {
+		return nil, fmt.Errorf("invalid sbom: %v", err)
+	}
+	if cyclonedx.BOMFormat != "CycloneDX" {
+		return nil, fmt.Errorf("unsupported sbom format")
+	}
+	for _, c := range cyclonedx.Components {
+		packages = append(packages, &commonmodels.SBOMPackage{Name: c.Name, Version: c.Version, PURL: c.PURL})
+	}
+	return packages, nil
+}
@@ -0,0 +1,63 @@
+/*
+Copyright 2021 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package service
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	commonmodels "github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/models"
+)
+
+func TestParseSBOMPackages(t *testing.T) {
+	spdx := `{
+  "spdxVersion": "SPDX-2.2",
+  "packages": [
+    {
+      "name": "log4j-core",
+      "versionInfo": "2.14.1",
+      "externalRefs": [
+        {"referenceType": "cpe23Type", "referenceLocator": "cpe:2.3:a:apache:log4j-core:2.14.1"},
+        {"referenceType": "purl", "referenceLocator": "pkg:maven/org.apache.logging.log4j/log4j-core@2.14.1"}
+      ]
+    },
+    {"name": "musl", "versionInfo": "1.2.2-r3"}
+  ]
+}`
+	packages, err := ParseSBOMPackages([]byte(spdx))
+	assert.Nil(t, err)
+	assert.Equal(t, []*commonmodels.SBOMPackage{
+		{Name: "log4j-core", Version: "2.14.1", PURL: "pkg:maven/org.apache.logging.log4j/log4j-core@2.14.1"},
+		{Name: "musl", Version: "1.2.2-r3"},
+	}, packages)
+
+	cyclonedx := `{
+  "bomFormat": "CycloneDX",
+  "components": [
+    {"name": "log4j-core", "version": "2.14.1", "purl": "pkg:maven/org.apache.logging.log4j/log4j-core@2.14.1"}
+  ]
+}`
+	packages, err = ParseSBOMPackages([]byte(cyclonedx))
+	assert.Nil(t, err)
+	assert.Equal(t, []*commonmodels.SBOMPackage{
+		{Name: "log4j-core", Version: "2.14.1", PURL: "pkg:maven/org.apache.logging.log4j/log4j-core@2.14.1"},
+	}, packages)
+
+	_, err = ParseSBOMPackages([]byte(`{"foo": "bar"}`))
+	assert.Error(t, err)
+}
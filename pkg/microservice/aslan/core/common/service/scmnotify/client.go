@@ -17,18 +17,23 @@ limitations under the License.
 package scmnotify
 
 import (
+	"context"
 	"fmt"
 	"strconv"
 	"strings"
 
+	"github.com/google/go-github/v35/github"
 	"github.com/pkg/errors"
 	"github.com/xanzy/go-gitlab"
 	"go.uber.org/zap"
 
 	"github.com/koderover/zadig/pkg/microservice/aslan/config"
 	"github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/models"
+	"github.com/koderover/zadig/pkg/setting"
 	"github.com/koderover/zadig/pkg/shared/codehost"
+	"github.com/koderover/zadig/pkg/tool/codehub"
 	"github.com/koderover/zadig/pkg/tool/gerrit"
+	githubtool "github.com/koderover/zadig/pkg/tool/git/github"
 	gitlabtool "github.com/koderover/zadig/pkg/tool/git/gitlab"
 	"github.com/koderover/zadig/pkg/tool/log"
 )
@@ -41,7 +46,7 @@ func NewClient() *Client {
 	return &Client{logger: log.SugaredLogger()}
 }
 
-// Comment send comment to gitlab and set comment id in notify
+// Comment send comment to gitlab, github, codehub or gerrit and set comment id in notify
 func (c *Client) Comment(notify *models.Notification) error {
 	if notify.PrID == 0 {
 		return fmt.Errorf("non pr notification not supported yet")
@@ -89,8 +94,64 @@ func (c *Client) Comment(notify *models.Notification) error {
 		if err != nil {
 			return fmt.Errorf("failed to comment gitlab due to %s/%d %v", notify.ProjectID, notify.PrID, err)
 		}
+	} else if strings.ToLower(codeHostDetail.Type) == setting.SourceFromGithub {
+		owner, repo := splitProjectID(notify.ProjectID)
+		cli := githubtool.NewClient(&githubtool.Config{AccessToken: codeHostDetail.AccessToken, Proxy: config.ProxyHTTPSAddr()})
+		if notify.CommentID == "" {
+			var ic *github.IssueComment
+			ic, err = cli.CreateIssueComment(context.Background(), owner, repo, notify.PrID, comment)
+			if err == nil {
+				notify.CommentID = strconv.FormatInt(ic.GetID(), 10)
+			}
+		} else {
+			commentID, _ := strconv.ParseInt(notify.CommentID, 10, 64)
+			_, err = cli.EditIssueComment(context.Background(), owner, repo, commentID, comment)
+		}
+
+		if err != nil {
+			return fmt.Errorf("failed to comment github due to %s/%d %v", notify.ProjectID, notify.PrID, err)
+		}
+	} else if strings.ToLower(codeHostDetail.Type) == setting.SourceFromCodeHub {
+		owner, repo := splitProjectID(notify.ProjectID)
+		cli := codehub.NewCodeHubClient(codeHostDetail.AccessKey, codeHostDetail.SecretKey, codeHostDetail.Region)
+		if notify.CommentID == "" {
+			var noteID string
+			noteID, err = cli.CreateMergeRequestNote(owner, repo, notify.PrID, comment)
+			if err == nil {
+				notify.CommentID = noteID
+			}
+		} else {
+			err = cli.UpdateMergeRequestNote(owner, repo, notify.PrID, notify.CommentID, comment)
+		}
+
+		if err != nil {
+			return fmt.Errorf("failed to comment codehub due to %s/%d %v", notify.ProjectID, notify.PrID, err)
+		}
 	} else if strings.ToLower(codeHostDetail.Type) == gerrit.CodehostTypeGerrit {
 		cli := gerrit.NewClient(codeHostDetail.Address, codeHostDetail.AccessToken)
+		// 回复评论指令的消息不带label, 以免覆盖工作流的投票
+		if notify.ErrInfo != "" {
+			if err := cli.SetReview(notify.ProjectID, notify.PrID, comment, "", "", notify.Revision); err != nil {
+				return fmt.Errorf("failed to comment gerrit due to %s/%d %v", notify.ProjectID, notify.PrID, err)
+			}
+			return nil
+		}
+
+		// gerrit的评论无法修改, 环境状态每变化一次单独发送一条消息, 不带label以免覆盖工作流的投票
+		if prTask := notify.PrTask; prTask != nil && prTask.EnvName != "" && prTask.EnvStatus != prTask.CommentedEnvStatus {
+			if e := cli.SetReview(
+				notify.ProjectID,
+				notify.PrID,
+				prTask.EnvMessage(notify.BaseURI),
+				"",
+				"",
+				notify.Revision,
+			); e != nil {
+				c.logger.Warnf("failed to set review %v %v", notify, e)
+			}
+			prTask.CommentedEnvStatus = prTask.EnvStatus
+		}
+
 		for _, task := range notify.Tasks {
 			// create task created comment
 			if !task.FirstCommented && task.Status == config.TaskStatusReady {
@@ -155,8 +216,17 @@ func (c *Client) Comment(notify *models.Notification) error {
 			}
 		}
 	} else {
-		return fmt.Errorf("non gitlab source not supported to comment")
+		return fmt.Errorf("codehost type %s not supported to comment", codeHostDetail.Type)
 	}
 
 	return nil
 }
+
+// splitProjectID 拆分owner/repo格式的projectID
+func splitProjectID(projectID string) (string, string) {
+	index := strings.LastIndex(projectID, "/")
+	if index < 0 {
+		return "", projectID
+	}
+	return projectID[:index], projectID[index+1:]
+}
@@ -0,0 +1,191 @@
+/*
+Copyright 2021 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package scmnotify
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/xanzy/go-gitlab"
+	"go.uber.org/zap"
+
+	configbase "github.com/koderover/zadig/pkg/config"
+	"github.com/koderover/zadig/pkg/microservice/aslan/config"
+	"github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/models/task"
+	"github.com/koderover/zadig/pkg/setting"
+	"github.com/koderover/zadig/pkg/shared/codehost"
+	"github.com/koderover/zadig/pkg/tool/bitbucket"
+	"github.com/koderover/zadig/pkg/tool/codehub"
+	gitlabtool "github.com/koderover/zadig/pkg/tool/git/gitlab"
+	"github.com/koderover/zadig/pkg/tool/gitea"
+)
+
+// commitStatusContext 同一个工作流的多次任务共用一个context, 代码源上只展示最新一次任务的状态
+func commitStatusContext(task *task.Task) string {
+	return fmt.Sprintf("zadig/%s", task.PipelineName)
+}
+
+func commitStatusTargetURL(task *task.Task) string {
+	return fmt.Sprintf("%s/v1/projects/detail/%s/pipelines/multi/%s/%d",
+		configbase.SystemAddress(), task.ProductName, task.PipelineName, task.TaskID)
+}
+
+// commitStatusDescription 任务运行中时附带当前正在执行的stage, 便于在代码源上查看任务进度
+func commitStatusDescription(task *task.Task, status config.TaskStatus) string {
+	desc := fmt.Sprintf("%s #%d %s", task.PipelineName, task.TaskID, strings.ToUpper(string(status)))
+	if status != config.TaskStatusRunning {
+		return desc
+	}
+	for _, stage := range task.Stages {
+		if stage.Status == config.StatusRunning {
+			return fmt.Sprintf("%s: %s", desc, stage.TaskType)
+		}
+	}
+	return desc
+}
+
+// UpdateCommitStatus 将webhook触发的工作流任务状态上报到触发任务的提交上
+func (s *Service) UpdateCommitStatus(task *task.Task, logger *zap.SugaredLogger) error {
+	triggerBy := task.TriggerBy
+	if triggerBy == nil || triggerBy.CommitID == "" || triggerBy.CodehostID == 0 {
+		return nil
+	}
+	// gerrit的投票统一由PR评论上报, 这里不处理
+	switch triggerBy.Source {
+	case setting.SourceFromGitea, setting.SourceFromBitbucket, setting.SourceFromGitlab, setting.SourceFromCodeHub:
+	default:
+		return nil
+	}
+
+	ch, err := codehost.GetCodeHostInfoByID(triggerBy.CodehostID)
+	if err != nil {
+		logger.Errorf("failed to find codehost %d: %v", triggerBy.CodehostID, err)
+		return err
+	}
+
+	status := convertTaskStatusToNotificationTaskStatus(task.Status)
+	switch triggerBy.Source {
+	case setting.SourceFromGitea:
+		err = gitea.NewClient(ch.Address, ch.AccessToken).CreateStatus(triggerBy.RepoOwner, triggerBy.RepoName, triggerBy.CommitID, &gitea.CommitStatus{
+			State:       toGiteaCommitStatus(status),
+			TargetURL:   commitStatusTargetURL(task),
+			Description: commitStatusDescription(task, status),
+			Context:     commitStatusContext(task),
+		})
+	case setting.SourceFromBitbucket:
+		err = bitbucket.NewClient(ch.Address, ch.AccessToken).CreateBuildStatus(triggerBy.CommitID, &bitbucket.BuildStatus{
+			State:       toBitbucketBuildState(status),
+			Key:         commitStatusContext(task),
+			Name:        task.PipelineName,
+			URL:         commitStatusTargetURL(task),
+			Description: commitStatusDescription(task, status),
+		})
+	case setting.SourceFromGitlab:
+		var cli *gitlabtool.Client
+		if cli, err = gitlabtool.NewClient(ch.Address, ch.AccessToken); err != nil {
+			break
+		}
+		err = setGitlabCommitStatus(cli, fmt.Sprintf("%s/%s", triggerBy.RepoOwner, triggerBy.RepoName), triggerBy.CommitID, &gitlab.SetCommitStatusOptions{
+			State:       toGitlabBuildState(status),
+			Name:        gitlab.String(commitStatusContext(task)),
+			TargetURL:   gitlab.String(commitStatusTargetURL(task)),
+			Description: gitlab.String(commitStatusDescription(task, status)),
+		})
+	case setting.SourceFromCodeHub:
+		err = codehub.NewCodeHubClient(ch.AccessKey, ch.SecretKey, ch.Region).CreateCommitStatus(triggerBy.RepoOwner, triggerBy.RepoName, triggerBy.CommitID, &codehub.CommitStatus{
+			State:       toCodehubCommitStatus(status),
+			Name:        commitStatusContext(task),
+			TargetURL:   commitStatusTargetURL(task),
+			Description: commitStatusDescription(task, status),
+		})
+	}
+	if err != nil {
+		logger.Errorf("failed to update commit status of %s/%s@%s: %v", triggerBy.RepoOwner, triggerBy.RepoName, triggerBy.CommitID, err)
+	}
+	return err
+}
+
+func toGiteaCommitStatus(status config.TaskStatus) gitea.CommitStatusState {
+	switch status {
+	case config.TaskStatusPass:
+		return gitea.CommitStatusSuccess
+	case config.TaskStatusFailed, config.TaskStatusTimeout:
+		return gitea.CommitStatusFailure
+	case config.TaskStatusCancelled:
+		return gitea.CommitStatusError
+	default:
+		return gitea.CommitStatusPending
+	}
+}
+
+func toBitbucketBuildState(status config.TaskStatus) bitbucket.BuildState {
+	switch status {
+	case config.TaskStatusPass:
+		return bitbucket.BuildStateSuccessful
+	case config.TaskStatusFailed, config.TaskStatusTimeout, config.TaskStatusCancelled:
+		return bitbucket.BuildStateFailed
+	default:
+		return bitbucket.BuildStateInProgress
+	}
+}
+
+func toGitlabBuildState(status config.TaskStatus) gitlab.BuildStateValue {
+	switch status {
+	case config.TaskStatusRunning:
+		return gitlab.Running
+	case config.TaskStatusPass:
+		return gitlab.Success
+	case config.TaskStatusFailed, config.TaskStatusTimeout:
+		return gitlab.Failed
+	case config.TaskStatusCancelled:
+		return gitlab.Canceled
+	default:
+		return gitlab.Pending
+	}
+}
+
+func toCodehubCommitStatus(status config.TaskStatus) codehub.CommitStatusState {
+	switch status {
+	case config.TaskStatusRunning:
+		return codehub.CommitStatusRunning
+	case config.TaskStatusPass:
+		return codehub.CommitStatusSuccess
+	case config.TaskStatusFailed, config.TaskStatusTimeout:
+		return codehub.CommitStatusFailed
+	case config.TaskStatusCancelled:
+		return codehub.CommitStatusCanceled
+	default:
+		return codehub.CommitStatusPending
+	}
+}
+
+// setGitlabCommitStatus gitlab不允许commit status从一个状态转换到相同的状态, 与最近一次上报的状态相同时不再上报
+func setGitlabCommitStatus(cli *gitlabtool.Client, pid, sha string, opt *gitlab.SetCommitStatusOptions) error {
+	statuses, _, err := cli.Commits.GetCommitStatuses(pid, sha, &gitlab.GetCommitStatusesOptions{Name: opt.Name})
+	if err != nil {
+		return err
+	}
+	// 不指定all时每个name只返回最新的状态
+	for _, status := range statuses {
+		if status.Name == *opt.Name && status.Status == string(opt.State) {
+			return nil
+		}
+	}
+
+	_, _, err = cli.Commits.SetCommitStatus(pid, sha, opt)
+	return err
+}
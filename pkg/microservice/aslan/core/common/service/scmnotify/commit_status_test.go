@@ -0,0 +1,116 @@
+/*
+Copyright 2021 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package scmnotify
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/xanzy/go-gitlab"
+
+	"github.com/koderover/zadig/pkg/microservice/aslan/config"
+	"github.com/koderover/zadig/pkg/tool/bitbucket"
+	"github.com/koderover/zadig/pkg/tool/codehub"
+	gitlabtool "github.com/koderover/zadig/pkg/tool/git/gitlab"
+	"github.com/koderover/zadig/pkg/tool/gitea"
+)
+
+func TestCommitStatusStates(t *testing.T) {
+	tests := []struct {
+		status    config.TaskStatus
+		gitea     gitea.CommitStatusState
+		bitbucket bitbucket.BuildState
+		gitlab    gitlab.BuildStateValue
+		codehub   codehub.CommitStatusState
+	}{
+		{status: config.TaskStatusReady, gitea: gitea.CommitStatusPending, bitbucket: bitbucket.BuildStateInProgress, gitlab: gitlab.Pending, codehub: codehub.CommitStatusPending},
+		{status: config.TaskStatusRunning, gitea: gitea.CommitStatusPending, bitbucket: bitbucket.BuildStateInProgress, gitlab: gitlab.Running, codehub: codehub.CommitStatusRunning},
+		{status: config.TaskStatusPass, gitea: gitea.CommitStatusSuccess, bitbucket: bitbucket.BuildStateSuccessful, gitlab: gitlab.Success, codehub: codehub.CommitStatusSuccess},
+		{status: config.TaskStatusFailed, gitea: gitea.CommitStatusFailure, bitbucket: bitbucket.BuildStateFailed, gitlab: gitlab.Failed, codehub: codehub.CommitStatusFailed},
+		{status: config.TaskStatusTimeout, gitea: gitea.CommitStatusFailure, bitbucket: bitbucket.BuildStateFailed, gitlab: gitlab.Failed, codehub: codehub.CommitStatusFailed},
+		{status: config.TaskStatusCancelled, gitea: gitea.CommitStatusError, bitbucket: bitbucket.BuildStateFailed, gitlab: gitlab.Canceled, codehub: codehub.CommitStatusCanceled},
+	}
+
+	for _, tt := range tests {
+		assert.Equal(t, tt.gitea, toGiteaCommitStatus(tt.status), tt.status)
+		assert.Equal(t, tt.bitbucket, toBitbucketBuildState(tt.status), tt.status)
+		assert.Equal(t, tt.gitlab, toGitlabBuildState(tt.status), tt.status)
+		assert.Equal(t, tt.codehub, toCodehubCommitStatus(tt.status), tt.status)
+	}
+}
+
+// fakeGitlabStatuses 模拟gitlab的commit status接口, 记录设置状态的请求
+type fakeGitlabStatuses struct {
+	sync.Mutex
+	statuses []*gitlab.CommitStatus
+	sets     []string
+}
+
+func (f *fakeGitlabStatuses) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.Lock()
+	defer f.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	switch r.Method {
+	case http.MethodGet:
+		_ = json.NewEncoder(w).Encode(f.statuses)
+	case http.MethodPost:
+		state := r.URL.Query().Get("state")
+		if state == "" {
+			opt := &gitlab.SetCommitStatusOptions{}
+			_ = json.NewDecoder(r.Body).Decode(opt)
+			state = string(opt.State)
+		}
+		f.sets = append(f.sets, state)
+		_ = json.NewEncoder(w).Encode(&gitlab.CommitStatus{Name: "zadig/demo", Status: state})
+	}
+}
+
+func TestSetGitlabCommitStatus(t *testing.T) {
+	tests := []struct {
+		name     string
+		latest   []*gitlab.CommitStatus
+		state    gitlab.BuildStateValue
+		wantSets []string
+	}{
+		{name: "first status", state: gitlab.Running, wantSets: []string{"running"}},
+		{name: "same state is skipped", latest: []*gitlab.CommitStatus{{Name: "zadig/demo", Status: "running"}}, state: gitlab.Running},
+		{name: "state changes", latest: []*gitlab.CommitStatus{{Name: "zadig/demo", Status: "running"}}, state: gitlab.Success, wantSets: []string{"success"}},
+		{name: "same state of another workflow", latest: []*gitlab.CommitStatus{{Name: "zadig/other", Status: "running"}}, state: gitlab.Running, wantSets: []string{"running"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := &fakeGitlabStatuses{statuses: tt.latest}
+			server := httptest.NewServer(fake)
+			defer server.Close()
+
+			cli, err := gitlabtool.NewClient(server.URL, "token")
+			assert.Nil(t, err)
+			err = setGitlabCommitStatus(cli, "group/demo", "a1b2c3", &gitlab.SetCommitStatusOptions{
+				State: tt.state,
+				Name:  gitlab.String("zadig/demo"),
+			})
+			assert.Nil(t, err)
+			assert.Equal(t, tt.wantSets, fake.sets)
+		})
+	}
+}
@@ -21,6 +21,7 @@ import (
 	"fmt"
 	"io/ioutil"
 	"os"
+	"reflect"
 	"strings"
 
 	"github.com/xanzy/go-gitlab"
@@ -30,6 +31,7 @@ import (
 	"github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/models"
 	"github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/models/task"
 	"github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/mongodb"
+	"github.com/koderover/zadig/pkg/microservice/aslan/core/common/service/base"
 	"github.com/koderover/zadig/pkg/microservice/aslan/core/common/service/s3"
 	"github.com/koderover/zadig/pkg/setting"
 	e "github.com/koderover/zadig/pkg/tool/errors"
@@ -115,6 +117,24 @@ func (s *Service) SendErrWebhookComment(
 	return notification, nil
 }
 
+// ReplyComment 回复PR评论中的指令, 回复内容不随任务状态变化, 不需要保存
+func (s *Service) ReplyComment(mainRepo *models.MainHookRepo, prID int, reply string, logger *zap.SugaredLogger) error {
+	notification := &models.Notification{
+		CodehostID: mainRepo.CodehostID,
+		PrID:       prID,
+		ProjectID:  strings.TrimLeft(mainRepo.RepoOwner+"/"+mainRepo.RepoName, "/"),
+		ErrInfo:    reply,
+		Revision:   mainRepo.Revision,
+	}
+
+	if err := s.Client.Comment(notification); err != nil {
+		logger.Errorf("failed to reply comment to %s %v", notification.ToString(), err)
+		return err
+	}
+
+	return nil
+}
+
 func convertTaskStatusToNotificationTaskStatus(status config.Status) config.TaskStatus {
 	switch status {
 	case config.StatusCreated:
@@ -199,6 +219,7 @@ func (s *Service) UpdateWebhookComment(task *task.Task, logger *zap.SugaredLogge
 				}
 				scmTask.TestReports = testReports
 			}
+			scmTask.Coverages = collectCoverages(task)
 
 			tasks = append(tasks, scmTask)
 			taskExist = true
@@ -362,6 +383,28 @@ func downloadReport(taskInfo *task.Task, fileName, testName string, logger *zap.
 	return testRepo, nil
 }
 
+// collectCoverages 从任务的测试子任务中收集覆盖率, 以及创建任务时记录的目标分支基准覆盖率
+func collectCoverages(taskInfo *task.Task) []*models.NotificationCoverage {
+	var coverages []*models.NotificationCoverage
+	for _, stage := range taskInfo.Stages {
+		if stage.TaskType != config.TaskTestingV2 {
+			continue
+		}
+		for _, subTask := range stage.SubTasks {
+			testInfo, err := base.ToTestingTask(subTask)
+			if err != nil || testInfo.Coverage == nil {
+				continue
+			}
+			coverages = append(coverages, &models.NotificationCoverage{
+				TestName:     testInfo.TestModuleName,
+				LineRate:     testInfo.Coverage.LineRate,
+				BaseLineRate: testInfo.JobCtx.BaseLineRate,
+			})
+		}
+	}
+	return coverages
+}
+
 func DownloadTestReports(taskInfo *task.Task, logger *zap.SugaredLogger) ([]*models.TestSuite, error) {
 	if taskInfo.StorageURI == "" {
 		return nil, nil
@@ -447,6 +490,7 @@ func (s *Service) UpdateWebhookCommentForTest(task *task.Task, logger *zap.Sugar
 				}
 				scmTask.TestReports = testReports
 			}
+			scmTask.Coverages = collectCoverages(task)
 
 			tasks = append(tasks, scmTask)
 			taskExist = true
@@ -521,6 +565,7 @@ func (s *Service) UpdatePipelineWebhookComment(task *task.Task, logger *zap.Suga
 				}
 				scmTask.TestReports = testReports
 			}
+			scmTask.Coverages = collectCoverages(task)
 
 			tasks = append(tasks, scmTask)
 			taskExist = true
@@ -565,24 +610,25 @@ func (s *Service) UpdateEnvAndTaskWebhookComment(workflowArgs *models.WorkflowTa
 		logger.Errorf("UpdateEnvAndTaskWebhookComment can't find notification by id %s %s", workflowArgs.NotificationID, err)
 		return err
 	}
+	//转换状态
+	prTaskInfo.EnvStatus = convertStatus(prTaskInfo.EnvStatus)
 	shouldComment := false
 	if notification.PrTask == nil {
 		shouldComment = true
 	} else {
-		shouldComment = prTaskInfo.EnvStatus != notification.PrTask.EnvStatus
+		shouldComment = prTaskInfo.EnvStatus != notification.PrTask.EnvStatus || !reflect.DeepEqual(prTaskInfo.EnvHosts, notification.PrTask.EnvHosts)
+		prTaskInfo.CommentedEnvStatus = notification.PrTask.CommentedEnvStatus
 	}
-	//转换状态
 	if shouldComment {
-		prTaskInfo.EnvStatus = convertStatus(prTaskInfo.EnvStatus)
 		notification.PrTask = prTaskInfo
-		if err = s.Coll.Upsert(notification); err != nil {
-			logger.Errorf("UpdateEnvAndTaskWebhookComment can't upsert notification by id %s", notification.ID)
-			return
-		}
-
 		if err = s.Client.Comment(notification); err != nil {
 			logger.Errorf("UpdateEnvAndTaskWebhookComment failed to comment %s, %v", notification.ToString(), err)
+		}
 
+		// 评论之后再保存, 新创建的评论ID需要一起保存
+		if err = s.Coll.Upsert(notification); err != nil {
+			logger.Errorf("UpdateEnvAndTaskWebhookComment can't upsert notification by id %s", notification.ID)
+			return
 		}
 	} else {
 		logger.Infof("UpdateEnvAndTaskWebhookComment status not changed of env %s, skip to update comment", prTaskInfo.EnvName)
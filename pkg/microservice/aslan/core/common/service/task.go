@@ -75,6 +75,7 @@ func CancelTask(userName, pipelineName string, taskID int64, typeString config.P
 			if typeString == config.WorkflowType {
 				_ = scmNotifyService.UpdateWebhookComment(t, log)
 				_ = scmNotifyService.UpdateDiffNote(t, log)
+				_ = scmNotifyService.UpdateCommitStatus(t, log)
 			} else if typeString == config.TestType {
 				_ = scmNotifyService.UpdateWebhookCommentForTest(t, log)
 			} else if typeString == config.SingleType {
@@ -123,6 +124,7 @@ func CancelTask(userName, pipelineName string, taskID int64, typeString config.P
 	if typeString == config.WorkflowType {
 		_ = scmNotifyService.UpdateWebhookComment(t, log)
 		_ = scmNotifyService.UpdateDiffNote(t, log)
+		_ = scmNotifyService.UpdateCommitStatus(t, log)
 	} else if typeString == config.TestType {
 		_ = scmNotifyService.UpdateWebhookCommentForTest(t, log)
 	} else if typeString == config.SingleType {
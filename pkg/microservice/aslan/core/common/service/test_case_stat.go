@@ -0,0 +1,141 @@
+/*
+Copyright 2021 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package service
+
+import (
+	"sort"
+
+	"go.uber.org/zap"
+
+	commonmodels "github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/models"
+	commonrepo "github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/mongodb"
+	"github.com/koderover/zadig/pkg/util"
+)
+
+// testCaseHistorySize 计算不稳定程度时使用的最近运行次数
+const testCaseHistorySize = 20
+
+// flakyMinFlips 最近的运行结果至少交替出现这么多次才认为用例不稳定, 只变化一次通常是用例被改坏或者被修复
+const flakyMinFlips = 2
+
+// RecordTestCaseResults 把一次测试的用例结果追加到各个用例的历史中, 跳过的用例不记录
+func RecordTestCaseResults(testName string, suite *commonmodels.TestSuite, log *zap.SugaredLogger) {
+	if suite == nil {
+		return
+	}
+
+	coll := commonrepo.NewTestCaseStatColl()
+	for _, tc := range suite.TestCases {
+		if tc.Skipped != nil {
+			continue
+		}
+
+		passed := tc.Failure == nil && tc.Error == nil
+		stat, err := coll.AppendResult(testName, tc.ClassName, tc.Name, passed, tc.Time, testCaseHistorySize)
+		if err != nil {
+			log.Errorf("TestCaseStat.AppendResult %s error: %v", util.GetTestCaseID(tc.ClassName, tc.Name), err)
+			continue
+		}
+
+		stat.Flakiness = TestCaseFlakiness(stat.Recent)
+		if err := coll.UpdateFlakiness(stat); err != nil {
+			log.Errorf("TestCaseStat.UpdateFlakiness %s error: %v", util.GetTestCaseID(tc.ClassName, tc.Name), err)
+		}
+	}
+}
+
+// TestCaseFlakiness 相邻两次运行结果不同的比例, 交替次数少于 flakyMinFlips 的用例为0
+func TestCaseFlakiness(recent []bool) float64 {
+	if len(recent) < 2 {
+		return 0
+	}
+	flips := 0
+	for i := 1; i < len(recent); i++ {
+		if recent[i] != recent[i-1] {
+			flips++
+		}
+	}
+	if flips < flakyMinFlips {
+		return 0
+	}
+	return float64(flips) / float64(len(recent)-1)
+}
+
+// ListQuarantinedTestCases 返回测试模块中被隔离的用例标识
+func ListQuarantinedTestCases(testName string, log *zap.SugaredLogger) []string {
+	stats, err := commonrepo.NewTestCaseStatColl().List(&commonrepo.TestCaseStatListOption{TestName: testName, Quarantined: true})
+	if err != nil {
+		log.Errorf("TestCaseStat.List %s error: %v", testName, err)
+		return nil
+	}
+
+	cases := make([]string, 0, len(stats))
+	for _, stat := range stats {
+		cases = append(cases, util.GetTestCaseID(stat.ClassName, stat.Name))
+	}
+	return cases
+}
+
+// SplitTestShardsByTiming 按照测试类最近一次的耗时把测试类分配到各个分片, 每次把耗时最长的类分给当前总耗时最短的分片
+// 没有历史记录时返回nil, 测试脚本需要根据 ZADIG_SHARD_INDEX 自行划分; 没有历史记录的新测试类同样按 ZADIG_SHARD_INDEX 划分
+func SplitTestShardsByTiming(testName string, shardCount int, log *zap.SugaredLogger) [][]string {
+	if shardCount <= 1 {
+		return nil
+	}
+
+	stats, err := commonrepo.NewTestCaseStatColl().List(&commonrepo.TestCaseStatListOption{TestName: testName})
+	if err != nil {
+		log.Errorf("TestCaseStat.List %s error: %v", testName, err)
+		return nil
+	}
+	return splitTestShards(stats, shardCount)
+}
+
+func splitTestShards(stats []*commonmodels.TestCaseStat, shardCount int) [][]string {
+	durations := make(map[string]float64)
+	for _, stat := range stats {
+		durations[stat.ClassName] += stat.Duration
+	}
+	if len(durations) == 0 {
+		return nil
+	}
+
+	classes := make([]string, 0, len(durations))
+	for class := range durations {
+		classes = append(classes, class)
+	}
+	sort.Slice(classes, func(i, j int) bool {
+		if durations[classes[i]] != durations[classes[j]] {
+			return durations[classes[i]] > durations[classes[j]]
+		}
+		return classes[i] < classes[j]
+	})
+
+	shards := make([][]string, shardCount)
+	totals := make([]float64, shardCount)
+	for _, class := range classes {
+		min := 0
+		for i := 1; i < shardCount; i++ {
+			if totals[i] < totals[min] {
+				min = i
+			}
+		}
+		shards[min] = append(shards[min], class)
+		totals[min] += durations[class]
+	}
+	return shards
+}
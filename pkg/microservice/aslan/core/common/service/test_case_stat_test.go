@@ -0,0 +1,50 @@
+/*
+Copyright 2021 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package service
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	commonmodels "github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/models"
+)
+
+func TestTestCaseFlakiness(t *testing.T) {
+	assert.Equal(t, float64(0), TestCaseFlakiness(nil))
+	assert.Equal(t, float64(0), TestCaseFlakiness([]bool{false}))
+	assert.Equal(t, float64(0), TestCaseFlakiness([]bool{true, true, true}))
+	assert.Equal(t, float64(0), TestCaseFlakiness([]bool{false, false, false}))
+	assert.Equal(t, float64(1), TestCaseFlakiness([]bool{true, false, true}))
+	assert.Equal(t, float64(0), TestCaseFlakiness([]bool{true, true, true, false, false}))
+	assert.Equal(t, 0.5, TestCaseFlakiness([]bool{true, true, false, false, true}))
+}
+
+func TestSplitTestShards(t *testing.T) {
+	assert.Nil(t, splitTestShards(nil, 2))
+
+	stats := []*commonmodels.TestCaseStat{
+		{ClassName: "a", Name: "1", Duration: 10},
+		{ClassName: "a", Name: "2", Duration: 20},
+		{ClassName: "b", Name: "1", Duration: 25},
+		{ClassName: "c", Name: "1", Duration: 5},
+		{ClassName: "d", Name: "1", Duration: 5},
+	}
+	assert.Equal(t, [][]string{{"a", "d"}, {"b", "c"}}, splitTestShards(stats, 2))
+	assert.Equal(t, [][]string{{"a"}, {"b"}, {"c", "d"}}, splitTestShards(stats, 3))
+	assert.Equal(t, [][]string{{"a"}, {"b"}, {"c"}, {"d"}, nil}, splitTestShards(stats, 5))
+}
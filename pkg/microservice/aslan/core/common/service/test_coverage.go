@@ -0,0 +1,67 @@
+/*
+Copyright 2021 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package service
+
+import (
+	"time"
+
+	"go.uber.org/zap"
+
+	commonmodels "github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/models"
+	taskmodels "github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/models/task"
+	commonrepo "github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/mongodb"
+	"github.com/koderover/zadig/pkg/types"
+)
+
+// RecordTestCoverage 保存测试任务的覆盖率, 代码信息取自测试的第一个代码库
+func RecordTestCoverage(pipelineName string, taskID int64, testInfo *taskmodels.Testing, log *zap.SugaredLogger) {
+	if testInfo.Coverage == nil {
+		return
+	}
+
+	coverage := &commonmodels.TestCoverage{
+		TestName:     testInfo.TestModuleName,
+		PipelineName: pipelineName,
+		TaskID:       taskID,
+		Coverage:     testInfo.Coverage,
+		CreateTime:   time.Now().Unix(),
+	}
+	if len(testInfo.JobCtx.Builds) > 0 {
+		repo := testInfo.JobCtx.Builds[0]
+		coverage.Branch = repo.Branch
+		coverage.PR = repo.PR
+		coverage.CommitID = repo.CommitID
+	}
+
+	if err := commonrepo.NewTestCoverageColl().Create(coverage); err != nil {
+		log.Errorf("TestCoverage.Create %s error: %v", testInfo.TestModuleName, err)
+	}
+}
+
+// GetBaseLineRate 返回基准分支最近一次测试的行覆盖率, 不需要比较或者没有记录时返回nil
+func GetBaseLineRate(testName string, policy *types.CoveragePolicy, log *zap.SugaredLogger) *float64 {
+	if policy == nil || policy.MaxDrop <= 0 || policy.BaseBranch == "" {
+		return nil
+	}
+
+	coverage, err := commonrepo.NewTestCoverageColl().FindLatestOfBranch(testName, policy.BaseBranch)
+	if err != nil {
+		log.Infof("no coverage of test %s on branch %s: %v", testName, policy.BaseBranch, err)
+		return nil
+	}
+	return &coverage.Coverage.LineRate
+}
@@ -66,6 +66,14 @@ func Delete(name, productName string, log *zap.SugaredLogger) error {
 		log.Errorf("[TestTaskStat.Delete] %s error: %v", name, err)
 	}
 
+	if err := mongodb.NewTestCaseStatColl().DeleteByTestName(name); err != nil {
+		log.Errorf("[TestCaseStat.DeleteByTestName] %s error: %v", name, err)
+	}
+
+	if err := mongodb.NewTestCoverageColl().DeleteByTestName(name); err != nil {
+		log.Errorf("[TestCoverage.DeleteByTestName] %s error: %v", name, err)
+	}
+
 	pipelineName := fmt.Sprintf("%s-%s", name, "job")
 	counterName := fmt.Sprintf(setting.TestTaskFmt, pipelineName)
 	if err := mongodb.NewCounterColl().Delete(counterName); err != nil {
@@ -52,6 +52,21 @@ func SendMessage(sender, title, content, requestID string, log *zap.SugaredLogge
 	}
 }
 
+// SendEnvRecycleWarning 环境即将被回收时提醒用户, 订阅了邮件的用户同时会收到邮件
+func SendEnvRecycleWarning(receiver string, ctx *models.EnvRecycleCtx, log *zap.SugaredLogger) {
+	nf := &models.Notify{
+		Type:       config.EnvRecycle,
+		Receiver:   receiver,
+		Content:    ctx,
+		CreateTime: time.Now().Unix(),
+		IsRead:     false,
+	}
+
+	if err := notify.NewNotifyClient().CreateNotify(receiver, nf); err != nil {
+		log.Errorf("create env recycle notify error: %v", err)
+	}
+}
+
 func SendFailedTaskMessage(username, productName, name, requestID string, workflowType config.PipelineType, err error, log *zap.SugaredLogger) {
 	title := "创建工作流任务失败"
 	perm := permission.WorkflowUpdateUUID
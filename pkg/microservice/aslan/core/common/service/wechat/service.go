@@ -27,6 +27,7 @@ import (
 
 	configbase "github.com/koderover/zadig/pkg/config"
 	"github.com/koderover/zadig/pkg/microservice/aslan/config"
+	"github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/models"
 	"github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/models/task"
 	"github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/mongodb"
 	"github.com/koderover/zadig/pkg/microservice/aslan/core/common/service/base"
@@ -44,6 +45,8 @@ const (
 	multiInfo            = "multi"
 	dingDingType         = "dingding"
 	feiShuType           = "feishu"
+	slackType            = "slack"
+	teamsType            = "teams"
 )
 
 //wechat
@@ -91,6 +94,8 @@ type Service struct {
 	proxyColl    *mongodb.ProxyColl
 	workflowColl *mongodb.WorkflowColl
 	pipelineColl *mongodb.PipelineColl
+	testingColl  *mongodb.TestingColl
+	taskColl     *mongodb.TaskColl
 }
 
 func NewWeChatClient() *Service {
@@ -98,13 +103,15 @@ func NewWeChatClient() *Service {
 		proxyColl:    mongodb.NewProxyColl(),
 		workflowColl: mongodb.NewWorkflowColl(),
 		pipelineColl: mongodb.NewPipelineColl(),
+		testingColl:  mongodb.NewTestingColl(),
+		taskColl:     mongodb.NewTaskColl(),
 	}
 }
 
 type wechatNotification struct {
 	Task        *task.Task `json:"task"`
 	BaseURI     string     `json:"base_uri"`
-	IsSingle    bool       `json:"is_single"`
+	TaskURL     string     `json:"task_url"`
 	WebHookType string     `json:"web_hook_type"`
 	TotalTime   int64      `json:"total_time"`
 	AtMobiles   []string   `json:"atMobiles"`
@@ -131,149 +138,218 @@ func (w *Service) SendMessageRequest(uri string, message interface{}) ([]byte, e
 
 func (w *Service) SendWechatMessage(task *task.Task) error {
 	var (
-		uri         = ""
-		content     = ""
-		webHookType = ""
-		atMobiles   []string
-		isAtAll     bool
+		notifyCtl *models.NotifyCtl
+		slack     *models.Slack
 	)
-	if task.Type == config.SingleType {
+	switch task.Type {
+	case config.SingleType:
 		resp, err := w.pipelineColl.Find(&mongodb.PipelineFindOption{Name: task.PipelineName})
 		if err != nil {
 			log.Errorf("Pipeline find err :%v", err)
 			return err
 		}
-		if resp.NotifyCtl == nil {
-			log.Infof("pipeline notifyCtl is not set!")
-			return nil
-		}
-		if resp.NotifyCtl.Enabled && sets.NewString(resp.NotifyCtl.NotifyTypes...).Has(string(task.Status)) {
-			webHookType = resp.NotifyCtl.WebHookType
-			if webHookType == dingDingType {
-				uri = resp.NotifyCtl.DingDingWebHook
-				atMobiles = resp.NotifyCtl.AtMobiles
-				isAtAll = resp.NotifyCtl.IsAtAll
-			} else if webHookType == feiShuType {
-				uri = resp.NotifyCtl.FeiShuWebHook
-			} else {
-				uri = resp.NotifyCtl.WeChatWebHook
-			}
-			content, err = w.createNotifyBody(&wechatNotification{
-				Task:        task,
-				BaseURI:     configbase.SystemAddress(),
-				IsSingle:    true,
-				WebHookType: webHookType,
-				TotalTime:   time.Now().Unix() - task.StartTime,
-				AtMobiles:   atMobiles,
-				IsAtAll:     isAtAll,
-			})
-			if err != nil {
-				log.Errorf("pipeline CreateNotifyBody err :%v", err)
-				return err
-			}
-		}
-	} else if task.Type == config.WorkflowType {
+		notifyCtl, slack = resp.NotifyCtl, resp.Slack
+	case config.WorkflowType:
 		resp, err := w.workflowColl.Find(task.PipelineName)
 		if err != nil {
 			log.Errorf("Workflow find err :%v", err)
 			return err
 		}
-		if resp.NotifyCtl == nil {
-			log.Infof("Workflow notifyCtl is not set!")
+		notifyCtl, slack = resp.NotifyCtl, resp.Slack
+	case config.TestType:
+		if task.TestArgs == nil {
 			return nil
 		}
-		if resp.NotifyCtl.Enabled && sets.NewString(resp.NotifyCtl.NotifyTypes...).Has(string(task.Status)) {
-			webHookType = resp.NotifyCtl.WebHookType
-			if webHookType == dingDingType {
-				uri = resp.NotifyCtl.DingDingWebHook
-				atMobiles = resp.NotifyCtl.AtMobiles
-				isAtAll = resp.NotifyCtl.IsAtAll
-			} else if webHookType == feiShuType {
-				uri = resp.NotifyCtl.FeiShuWebHook
-			} else {
-				uri = resp.NotifyCtl.WeChatWebHook
-			}
-			content, err = w.createNotifyBody(&wechatNotification{
-				Task:        task,
-				BaseURI:     configbase.SystemAddress(),
-				IsSingle:    false,
-				WebHookType: webHookType,
-				TotalTime:   time.Now().Unix() - task.StartTime,
-				AtMobiles:   atMobiles,
-				IsAtAll:     isAtAll,
-			})
-			if err != nil {
-				log.Errorf("workflow CreateNotifyBody err :%v", err)
-				return err
-			}
+		resp, err := w.testingColl.Find(task.TestArgs.TestName, task.TestArgs.ProductName)
+		if err != nil {
+			log.Errorf("Testing find err :%v", err)
+			return err
+		}
+		notifyCtl = resp.NotifyCtl
+	default:
+		return nil
+	}
+
+	// 旧的slack配置和通知配置使用同一个slack webhook时只发送一次
+	var slackSent string
+	if slack != nil && slack.Enabled && w.shouldNotifySlack(slack, task) {
+		if err := w.sendSlackMessage(slack.WebHook, newSlackTaskMessage(newWebHookPayload(task), slack.Channel, slack.Notifiers)); err != nil {
+			log.Errorf("SendSlackMessage err : %v", err)
 		}
+		slackSent = slack.WebHook
+	}
+
+	if notifyCtl == nil {
+		log.Infof("%s notifyCtl is not set!", task.PipelineName)
+		return nil
+	}
+	if !notifyCtl.Enabled {
+		return nil
+	}
+	w.sendWebHooks(notifyCtl, task)
+	if !sets.NewString(notifyCtl.NotifyTypes...).Has(string(task.Status)) {
+		return nil
+	}
+
+	var (
+		uri         = ""
+		webHookType = notifyCtl.WebHookType
+		atMobiles   []string
+		isAtAll     bool
+	)
+	switch webHookType {
+	case slackType:
+		if notifyCtl.SlackWebHook == "" || notifyCtl.SlackWebHook == slackSent {
+			return nil
+		}
+		return w.sendSlackMessage(notifyCtl.SlackWebHook, newSlackTaskMessage(newWebHookPayload(task), "", nil))
+	case teamsType:
+		if notifyCtl.TeamsWebHook == "" {
+			return nil
+		}
+		return w.sendTeamsMessage(notifyCtl.TeamsWebHook, newTeamsTaskMessage(newWebHookPayload(task)))
+	case dingDingType:
+		uri = notifyCtl.DingDingWebHook
+		atMobiles = notifyCtl.AtMobiles
+		isAtAll = notifyCtl.IsAtAll
+	case feiShuType:
+		uri = notifyCtl.FeiShuWebHook
+	default:
+		uri = notifyCtl.WeChatWebHook
+	}
+
+	content, err := w.createNotifyBody(&wechatNotification{
+		Task:        task,
+		BaseURI:     configbase.SystemAddress(),
+		TaskURL:     TaskURL(task),
+		WebHookType: webHookType,
+		TotalTime:   time.Now().Unix() - task.StartTime,
+		AtMobiles:   atMobiles,
+		IsAtAll:     isAtAll,
+	})
+	if err != nil {
+		log.Errorf("%s CreateNotifyBody err :%v", task.Type, err)
+		return err
 	}
 
 	if uri != "" && content != "" {
-		if webHookType == dingDingType {
-			message := &DingDingMessage{
-				MsgType: msgType,
-				MarkDown: &DingDingMarkDown{
-					Title: "工作流状态",
-					Text:  content,
-				},
-			}
-			if len(atMobiles) > 0 {
-				message.At = &DingDingAt{
-					AtMobiles: atMobiles,
-					IsAtAll:   false,
-				}
-			} else {
-				message.At = &DingDingAt{
-					IsAtAll: true,
-				}
-			}
+		return w.sendMessage(webHookType, uri, "工作流状态", content, atMobiles)
+	}
+	return nil
+}
 
-			_, err := w.SendMessageRequest(uri, message)
-			if err != nil {
-				log.Errorf("SendDingDingMessageRequest err : %v", err)
-				return err
-			}
+// SendApprovalMessage 通过工作流配置的通知渠道发送审批消息, 不受通知状态过滤
+func (w *Service) SendApprovalMessage(workflowName, title, content string) error {
+	resp, err := w.workflowColl.Find(workflowName)
+	if err != nil {
+		log.Errorf("Workflow find err :%v", err)
+		return err
+	}
+	if resp.NotifyCtl == nil || !resp.NotifyCtl.Enabled {
+		log.Infof("Workflow notifyCtl is not set!")
+		return nil
+	}
+
+	var (
+		uri         string
+		atMobiles   []string
+		webHookType = resp.NotifyCtl.WebHookType
+	)
+	if webHookType == dingDingType {
+		uri = resp.NotifyCtl.DingDingWebHook
+		atMobiles = resp.NotifyCtl.AtMobiles
+		if len(atMobiles) > 0 && !resp.NotifyCtl.IsAtAll {
+			content = fmt.Sprintf("%s - 相关人员：@%s \n", content, strings.Join(atMobiles, "@"))
+		}
+	} else if webHookType == feiShuType {
+		uri = resp.NotifyCtl.FeiShuWebHook
+	} else if webHookType == slackType {
+		uri = resp.NotifyCtl.SlackWebHook
+	} else if webHookType == teamsType {
+		uri = resp.NotifyCtl.TeamsWebHook
+	} else {
+		uri = resp.NotifyCtl.WeChatWebHook
+	}
+	if uri == "" {
+		return nil
+	}
 
-		} else if webHookType == feiShuType {
-			var message interface{}
-			message = &FeiShuMessage{
-				Title: "工作流状态",
+	return w.sendMessage(webHookType, uri, title, content, atMobiles)
+}
+
+func (w *Service) sendMessage(webHookType, uri, title, content string, atMobiles []string) error {
+	if webHookType == dingDingType {
+		message := &DingDingMessage{
+			MsgType: msgType,
+			MarkDown: &DingDingMarkDown{
+				Title: title,
 				Text:  content,
-			}
-			if strings.Contains(uri, "bot/v2/hook") {
-				message = &FeiShuMessageV2{
-					MsgType: "text",
-					Content: FeiShuContentV2{
-						Text: content,
-					},
-				}
-			}
-			_, err := w.SendMessageRequest(uri, message)
-			if err != nil {
-				log.Errorf("SendFeiShuMessageRequest err : %v", err)
-				return err
+			},
+		}
+		if len(atMobiles) > 0 {
+			message.At = &DingDingAt{
+				AtMobiles: atMobiles,
+				IsAtAll:   false,
 			}
 		} else {
-			message := &Messsage{
-				MsgType: msgType,
-				Text: &Text{
-					Content: content,
-				},
-			}
-			_, err := w.SendMessageRequest(uri, message)
-			if err != nil {
-				log.Errorf("SendWeChatMessageRequest err : %v", err)
-				return err
+			message.At = &DingDingAt{
+				IsAtAll: true,
 			}
 		}
 
+		_, err := w.SendMessageRequest(uri, message)
+		if err != nil {
+			log.Errorf("SendDingDingMessageRequest err : %v", err)
+			return err
+		}
+
+	} else if webHookType == feiShuType {
+		var message interface{}
+		message = &FeiShuMessage{
+			Title: title,
+			Text:  content,
+		}
+		if strings.Contains(uri, "bot/v2/hook") {
+			message = &FeiShuMessageV2{
+				MsgType: "text",
+				Content: FeiShuContentV2{
+					Text: content,
+				},
+			}
+		}
+		_, err := w.SendMessageRequest(uri, message)
+		if err != nil {
+			log.Errorf("SendFeiShuMessageRequest err : %v", err)
+			return err
+		}
+	} else if webHookType == slackType {
+		return w.sendSlackMessage(uri, &SlackMessage{Text: fmt.Sprintf("*%s*\n%s", title, content)})
+	} else if webHookType == teamsType {
+		return w.sendTeamsMessage(uri, &TeamsMessageCard{
+			Type:    teamsCardType,
+			Context: teamsCardContext,
+			Summary: title,
+			Title:   title,
+			Text:    content,
+		})
+	} else {
+		message := &Messsage{
+			MsgType: msgType,
+			Text: &Text{
+				Content: content,
+			},
+		}
+		_, err := w.SendMessageRequest(uri, message)
+		if err != nil {
+			log.Errorf("SendWeChatMessageRequest err : %v", err)
+			return err
+		}
 	}
 	return nil
 }
 
 func (w *Service) createNotifyBody(weChatNotification *wechatNotification) (content string, err error) {
-	tmplSource := "{{if eq .WebHookType \"feishu\"}}触发的工作流: {{.BaseURI}}/v1/projects/detail/{{.Task.ProductName}}/pipelines/{{ isSingle .IsSingle }}/{{.Task.PipelineName}}/{{.Task.TaskID}}{{else}}#### 触发的工作流: [{{.Task.PipelineName}}#{{.Task.TaskID}}]({{.BaseURI}}/v1/projects/detail/{{.Task.ProductName}}/pipelines/{{ isSingle .IsSingle }}/{{.Task.PipelineName}}/{{.Task.TaskID}}){{end}} \n" +
+	tmplSource := "{{if eq .WebHookType \"feishu\"}}触发的工作流: {{.TaskURL}}{{else}}#### 触发的工作流: [{{.Task.PipelineName}}#{{.Task.TaskID}}]({{.TaskURL}}){{end}} \n" +
 		"- 状态: {{if eq .WebHookType \"feishu\"}}{{.Task.Status}}{{else}}<font color=\"{{ getColor .Task.Status }}\">{{.Task.Status}}</font>{{end}} \n" +
 		"- 创建人：{{.Task.TaskCreator}} \n" +
 		"- 总运行时长：{{ .TotalTime}} 秒 \n"
@@ -309,12 +385,6 @@ func (w *Service) createNotifyBody(weChatNotification *wechatNotification) (cont
 			}
 			return markdownColorComment
 		},
-		"isSingle": func(isSingle bool) string {
-			if isSingle {
-				return singleInfo
-			}
-			return multiInfo
-		},
 	}).Parse(tmplSource))
 	buffer := bytes.NewBufferString("")
 
@@ -325,6 +395,22 @@ func (w *Service) createNotifyBody(weChatNotification *wechatNotification) (cont
 	return buffer.String(), nil
 }
 
+// TaskURL 任务详情页的地址
+func TaskURL(task *task.Task) string {
+	switch task.Type {
+	case config.SingleType:
+		return fmt.Sprintf("%s/v1/projects/detail/%s/pipelines/%s/%s/%d", configbase.SystemAddress(), task.ProductName, singleInfo, task.PipelineName, task.TaskID)
+	case config.TestType:
+		testName := task.PipelineName
+		if task.TestArgs != nil {
+			testName = task.TestArgs.TestName
+		}
+		return fmt.Sprintf("%s/v1/projects/detail/%s/test/detail/function/%s/%d", configbase.SystemAddress(), task.ProductName, testName, task.TaskID)
+	default:
+		return fmt.Sprintf("%s/v1/projects/detail/%s/pipelines/%s/%s/%d", configbase.SystemAddress(), task.ProductName, multiInfo, task.PipelineName, task.TaskID)
+	}
+}
+
 func getHTMLTestReport(task *task.Task) []string {
 	if task.Type != config.WorkflowType {
 		return nil
@@ -351,3 +437,20 @@ func getHTMLTestReport(task *task.Task) []string {
 
 	return testNames
 }
+
+type testReport struct {
+	Name string
+	URL  string
+}
+
+func testReports(task *task.Task) []*testReport {
+	var reports []*testReport
+	for _, testName := range getHTMLTestReport(task) {
+		reports = append(reports, &testReport{
+			Name: testName,
+			URL: fmt.Sprintf("%s/api/aslan/testing/report?pipelineName=%s&pipelineType=%s&taskID=%d&testName=%s",
+				configbase.SystemAddress(), task.PipelineName, task.Type, task.TaskID, testName),
+		})
+	}
+	return reports
+}
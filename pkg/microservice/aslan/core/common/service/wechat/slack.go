@@ -0,0 +1,162 @@
+/*
+Copyright 2021 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package wechat
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/koderover/zadig/pkg/microservice/aslan/config"
+	"github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/models"
+	"github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/models/task"
+	"github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/mongodb"
+	"github.com/koderover/zadig/pkg/tool/log"
+)
+
+// onchange时只在最近的若干个任务中查找上一次完成的任务
+const slackPreviousTaskLimit = 10
+
+// SlackMessage slack incoming webhook消息, 使用Block Kit展示任务结果
+type SlackMessage struct {
+	Channel string        `json:"channel,omitempty"`
+	Text    string        `json:"text"`
+	Blocks  []*SlackBlock `json:"blocks,omitempty"`
+}
+
+type SlackBlock struct {
+	Type     string       `json:"type"`
+	Text     *SlackText   `json:"text,omitempty"`
+	Fields   []*SlackText `json:"fields,omitempty"`
+	Elements []*SlackText `json:"elements,omitempty"`
+}
+
+type SlackText struct {
+	Type string `json:"type"`
+	Text string `json:"text"`
+}
+
+func slackMarkdown(text string) *SlackText {
+	return &SlackText{Type: "mrkdwn", Text: text}
+}
+
+func slackStatusEmoji(status config.Status) string {
+	switch status {
+	case config.StatusPassed:
+		return ":white_check_mark:"
+	case config.StatusFailed:
+		return ":x:"
+	case config.StatusTimeout:
+		return ":hourglass:"
+	case config.StatusCancelled:
+		return ":no_entry_sign:"
+	default:
+		return ":arrows_counterclockwise:"
+	}
+}
+
+func newSlackTaskMessage(payload *WebHookPayload, channel string, notifiers []string) *SlackMessage {
+	t := payload.Task
+	title := fmt.Sprintf("%s#%d %s", t.PipelineName, t.TaskID, t.Status)
+
+	blocks := []*SlackBlock{
+		{
+			Type: "section",
+			Text: slackMarkdown(fmt.Sprintf("%s *<%s|%s#%d>* %s", slackStatusEmoji(t.Status), payload.TaskURL, t.PipelineName, t.TaskID, t.Status)),
+		},
+		{
+			Type: "section",
+			Fields: []*SlackText{
+				slackMarkdown(fmt.Sprintf("*Project*\n%s", t.ProductName)),
+				slackMarkdown(fmt.Sprintf("*Creator*\n%s", t.TaskCreator)),
+				slackMarkdown(fmt.Sprintf("*Duration*\n%ds", payload.TotalTime)),
+			},
+		},
+	}
+
+	var stages []string
+	for _, stage := range payload.Stages {
+		stages = append(stages, fmt.Sprintf("%s %s", slackStatusEmoji(stage.Status), stage.TaskType))
+	}
+	if len(stages) > 0 {
+		blocks = append(blocks, &SlackBlock{Type: "context", Elements: []*SlackText{slackMarkdown(strings.Join(stages, "  "))}})
+	}
+
+	var reports []string
+	for _, report := range testReports(payload.task) {
+		reports = append(reports, fmt.Sprintf("<%s|%s>", report.URL, report.Name))
+	}
+	if len(reports) > 0 {
+		blocks = append(blocks, &SlackBlock{Type: "section", Text: slackMarkdown("*Test Reports*\n" + strings.Join(reports, "\n"))})
+	}
+
+	if len(notifiers) > 0 {
+		var mentions []string
+		for _, notifier := range notifiers {
+			mentions = append(mentions, fmt.Sprintf("<@%s>", notifier))
+		}
+		blocks = append(blocks, &SlackBlock{Type: "context", Elements: []*SlackText{slackMarkdown(strings.Join(mentions, " "))}})
+	}
+
+	return &SlackMessage{Channel: channel, Text: title, Blocks: blocks}
+}
+
+func (w *Service) sendSlackMessage(uri string, message *SlackMessage) error {
+	if uri == "" {
+		return nil
+	}
+	if _, err := w.SendMessageRequest(uri, message); err != nil {
+		log.Errorf("SendSlackMessageRequest err : %v", err)
+		return err
+	}
+	return nil
+}
+
+func isCompletedStatus(status config.Status) bool {
+	return status == config.StatusPassed || status == config.StatusFailed || status == config.StatusTimeout || status == config.StatusCancelled
+}
+
+// shouldNotifySlack 只通知已结束的任务, onfailure只通知失败和超时的任务, onchange只在任务结果和上一次不同时通知
+func (w *Service) shouldNotifySlack(slack *models.Slack, t *task.Task) bool {
+	if !isCompletedStatus(t.Status) {
+		return false
+	}
+
+	switch slack.NotifyType {
+	case config.SlackOnfailure:
+		return t.Status == config.StatusFailed || t.Status == config.StatusTimeout
+	case config.SlackOnChange:
+		return w.previousTaskStatus(t) != t.Status
+	default:
+		return true
+	}
+}
+
+// previousTaskStatus 上一次结束的任务的状态, 被取消的任务不计算在内
+func (w *Service) previousTaskStatus(t *task.Task) config.Status {
+	tasks, err := w.taskColl.List(&mongodb.ListTaskOption{PipelineName: t.PipelineName, Type: t.Type, Limit: slackPreviousTaskLimit})
+	if err != nil {
+		log.Errorf("list tasks of %s err: %v", t.PipelineName, err)
+		return ""
+	}
+
+	for _, pre := range tasks {
+		if pre.TaskID < t.TaskID && isCompletedStatus(pre.Status) && pre.Status != config.StatusCancelled {
+			return pre.Status
+		}
+	}
+	return ""
+}
@@ -0,0 +1,69 @@
+/*
+Copyright 2021 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package wechat
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/koderover/zadig/pkg/microservice/aslan/config"
+	"github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/models"
+	"github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/models/task"
+)
+
+func TestNewSlackTaskMessage(t *testing.T) {
+	payload := newWebHookPayload(&task.Task{
+		TaskID:       3,
+		PipelineName: "demo",
+		ProductName:  "project",
+		Type:         config.WorkflowType,
+		Status:       config.StatusFailed,
+		Stages:       []*models.Stage{{TaskType: config.TaskBuild, Status: config.StatusFailed}},
+	})
+
+	message := newSlackTaskMessage(payload, "#ci", []string{"U01"})
+	assert.Equal(t, "#ci", message.Channel)
+	assert.Equal(t, "demo#3 failed", message.Text)
+	assert.Len(t, message.Blocks, 4)
+	assert.Contains(t, message.Blocks[0].Text.Text, "|demo#3>")
+	assert.Equal(t, ":x: buildv2", message.Blocks[2].Elements[0].Text)
+	assert.Equal(t, "<@U01>", message.Blocks[3].Elements[0].Text)
+}
+
+func TestShouldNotifySlack(t *testing.T) {
+	w := &Service{}
+	onFailure := &models.Slack{NotifyType: config.SlackOnfailure}
+
+	assert.False(t, w.shouldNotifySlack(onFailure, &task.Task{Status: config.StatusRunning}))
+	assert.False(t, w.shouldNotifySlack(onFailure, &task.Task{Status: config.StatusPassed}))
+	assert.True(t, w.shouldNotifySlack(onFailure, &task.Task{Status: config.StatusTimeout}))
+	assert.True(t, w.shouldNotifySlack(&models.Slack{}, &task.Task{Status: config.StatusPassed}))
+}
+
+func TestNewTeamsTaskMessage(t *testing.T) {
+	card := newTeamsTaskMessage(newWebHookPayload(&task.Task{
+		TaskID:       3,
+		PipelineName: "demo",
+		Status:       config.StatusPassed,
+	}))
+
+	assert.Equal(t, teamsCardType, card.Type)
+	assert.Equal(t, "2EB886", card.ThemeColor)
+	assert.Equal(t, "demo#3 passed", card.Summary)
+	assert.Len(t, card.PotentialAction, 1)
+}
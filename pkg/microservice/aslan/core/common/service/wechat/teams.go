@@ -0,0 +1,129 @@
+/*
+Copyright 2021 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package wechat
+
+import (
+	"fmt"
+
+	"github.com/koderover/zadig/pkg/microservice/aslan/config"
+	"github.com/koderover/zadig/pkg/tool/log"
+)
+
+const (
+	teamsCardType    = "MessageCard"
+	teamsCardContext = "https://schema.org/extensions"
+)
+
+// TeamsMessageCard Microsoft Teams incoming webhook使用的MessageCard
+type TeamsMessageCard struct {
+	Type            string          `json:"@type"`
+	Context         string          `json:"@context"`
+	ThemeColor      string          `json:"themeColor,omitempty"`
+	Summary         string          `json:"summary"`
+	Title           string          `json:"title,omitempty"`
+	Text            string          `json:"text,omitempty"`
+	Sections        []*TeamsSection `json:"sections,omitempty"`
+	PotentialAction []*TeamsAction  `json:"potentialAction,omitempty"`
+}
+
+type TeamsSection struct {
+	ActivityTitle    string       `json:"activityTitle,omitempty"`
+	ActivitySubtitle string       `json:"activitySubtitle,omitempty"`
+	Facts            []*TeamsFact `json:"facts,omitempty"`
+	Text             string       `json:"text,omitempty"`
+	Markdown         bool         `json:"markdown"`
+}
+
+type TeamsFact struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+type TeamsAction struct {
+	Type    string         `json:"@type"`
+	Name    string         `json:"name"`
+	Targets []*TeamsTarget `json:"targets"`
+}
+
+type TeamsTarget struct {
+	OS  string `json:"os"`
+	URI string `json:"uri"`
+}
+
+func teamsThemeColor(status config.Status) string {
+	switch status {
+	case config.StatusPassed:
+		return "2EB886"
+	case config.StatusFailed:
+		return "D00000"
+	case config.StatusTimeout, config.StatusCancelled:
+		return "DAA038"
+	default:
+		return "439FE0"
+	}
+}
+
+func newTeamsTaskMessage(payload *WebHookPayload) *TeamsMessageCard {
+	t := payload.Task
+	title := fmt.Sprintf("%s#%d %s", t.PipelineName, t.TaskID, t.Status)
+
+	section := &TeamsSection{
+		ActivityTitle:    title,
+		ActivitySubtitle: t.ProductName,
+		Facts: []*TeamsFact{
+			{Name: "Status", Value: string(t.Status)},
+			{Name: "Creator", Value: t.TaskCreator},
+			{Name: "Duration", Value: fmt.Sprintf("%ds", payload.TotalTime)},
+		},
+		Markdown: true,
+	}
+	for _, stage := range payload.Stages {
+		section.Facts = append(section.Facts, &TeamsFact{Name: string(stage.TaskType), Value: string(stage.Status)})
+	}
+
+	card := &TeamsMessageCard{
+		Type:       teamsCardType,
+		Context:    teamsCardContext,
+		ThemeColor: teamsThemeColor(t.Status),
+		Summary:    title,
+		Sections:   []*TeamsSection{section},
+		PotentialAction: []*TeamsAction{{
+			Type:    "OpenUri",
+			Name:    "View Task",
+			Targets: []*TeamsTarget{{OS: "default", URI: payload.TaskURL}},
+		}},
+	}
+	for _, report := range testReports(payload.task) {
+		card.PotentialAction = append(card.PotentialAction, &TeamsAction{
+			Type:    "OpenUri",
+			Name:    report.Name,
+			Targets: []*TeamsTarget{{OS: "default", URI: report.URL}},
+		})
+	}
+	return card
+}
+
+func (w *Service) sendTeamsMessage(uri string, message *TeamsMessageCard) error {
+	if uri == "" {
+		return nil
+	}
+	if _, err := w.SendMessageRequest(uri, message); err != nil {
+		log.Errorf("SendTeamsMessageRequest err : %v", err)
+		return err
+	}
+	return nil
+}
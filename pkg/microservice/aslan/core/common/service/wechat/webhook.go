@@ -0,0 +1,240 @@
+/*
+Copyright 2021 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package wechat
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"text/template"
+	"time"
+
+	"k8s.io/apimachinery/pkg/util/sets"
+
+	"github.com/koderover/zadig/pkg/microservice/aslan/config"
+	"github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/models"
+	"github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/models/task"
+	"github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/mongodb"
+	"github.com/koderover/zadig/pkg/setting"
+	"github.com/koderover/zadig/pkg/tool/httpclient"
+	"github.com/koderover/zadig/pkg/tool/log"
+)
+
+const (
+	webHookEventHeader     = "X-Zadig-Event"
+	webHookSignatureHeader = "X-Zadig-Signature"
+	defaultWebHookTemplate = "{{ toJSON . }}"
+)
+
+// WebHookPayload 渲染webhook请求体模板时使用的数据, 请求会发送到外部地址, 只包含任务的概要信息
+type WebHookPayload struct {
+	Event     string         `json:"event"`
+	TaskURL   string         `json:"task_url"`
+	TotalTime int64          `json:"total_time"`
+	Task      *TaskSummary   `json:"task"`
+	Stages    []*StageResult `json:"stages"`
+
+	// task 原始任务, 包含ConfigPayload等敏感信息, 不能渲染到请求体中
+	task *task.Task
+}
+
+type TaskSummary struct {
+	TaskID       int64               `json:"task_id"`
+	ProductName  string              `json:"product_name"`
+	PipelineName string              `json:"pipeline_name"`
+	Type         config.PipelineType `json:"type"`
+	Status       config.Status       `json:"status"`
+	TaskCreator  string              `json:"task_creator"`
+	TaskRevoker  string              `json:"task_revoker,omitempty"`
+	CreateTime   int64               `json:"create_time"`
+	StartTime    int64               `json:"start_time"`
+	EndTime      int64               `json:"end_time"`
+}
+
+type StageResult struct {
+	TaskType config.TaskType  `json:"type"`
+	Status   config.Status    `json:"status"`
+	SubTasks []*SubTaskResult `json:"sub_tasks"`
+}
+
+type SubTaskResult struct {
+	Name   string        `json:"name"`
+	Status config.Status `json:"status"`
+	Error  string        `json:"error,omitempty"`
+}
+
+// ValidateWebHooks 保存工作流时检查webhook配置和模板
+func ValidateWebHooks(hooks []*models.WebHookNotify) error {
+	for _, hook := range hooks {
+		if hook.URL == "" {
+			return fmt.Errorf("url of webhook %s can not be empty", hook.Name)
+		}
+		if _, err := parseWebHookTemplate(hook.Template); err != nil {
+			return fmt.Errorf("invalid template of webhook %s: %v", hook.Name, err)
+		}
+	}
+	return nil
+}
+
+// MaskWebHookSecrets 返回工作流时隐藏webhook的签名密钥
+func MaskWebHookSecrets(notifyCtl *models.NotifyCtl) {
+	if notifyCtl == nil {
+		return
+	}
+	for _, hook := range notifyCtl.WebHooks {
+		if hook.Secret != "" {
+			hook.Secret = setting.MaskValue
+		}
+	}
+}
+
+// EnsureWebHookSecrets 保存工作流时, 密钥仍为掩码的webhook使用已保存的同名webhook的密钥
+func EnsureWebHookSecrets(existed, notifyCtl *models.NotifyCtl) {
+	if notifyCtl == nil {
+		return
+	}
+
+	secrets := make(map[string]string)
+	if existed != nil {
+		for _, hook := range existed.WebHooks {
+			secrets[hook.Name] = hook.Secret
+		}
+	}
+	for _, hook := range notifyCtl.WebHooks {
+		if hook.Secret == setting.MaskValue {
+			hook.Secret = secrets[hook.Name]
+		}
+	}
+}
+
+func parseWebHookTemplate(tmplSource string) (*template.Template, error) {
+	if tmplSource == "" {
+		tmplSource = defaultWebHookTemplate
+	}
+	return template.New("webhook").Funcs(template.FuncMap{
+		"toJSON": func(v interface{}) (string, error) {
+			b, err := json.Marshal(v)
+			return string(b), err
+		},
+	}).Parse(tmplSource)
+}
+
+func newWebHookPayload(task *task.Task) *WebHookPayload {
+	payload := &WebHookPayload{
+		Event:     string(task.Status),
+		TaskURL:   TaskURL(task),
+		TotalTime: time.Now().Unix() - task.StartTime,
+		Task: &TaskSummary{
+			TaskID:       task.TaskID,
+			ProductName:  task.ProductName,
+			PipelineName: task.PipelineName,
+			Type:         task.Type,
+			Status:       task.Status,
+			TaskCreator:  task.TaskCreator,
+			TaskRevoker:  task.TaskRevoker,
+			CreateTime:   task.CreateTime,
+			StartTime:    task.StartTime,
+			EndTime:      task.EndTime,
+		},
+		task: task,
+	}
+
+	for _, stage := range task.Stages {
+		result := &StageResult{TaskType: stage.TaskType, Status: stage.Status}
+		for name, subTask := range stage.SubTasks {
+			status, _ := subTask["status"].(string)
+			errMsg, _ := subTask["error"].(string)
+			result.SubTasks = append(result.SubTasks, &SubTaskResult{Name: name, Status: config.Status(status), Error: errMsg})
+		}
+		sort.Slice(result.SubTasks, func(i, j int) bool { return result.SubTasks[i].Name < result.SubTasks[j].Name })
+		payload.Stages = append(payload.Stages, result)
+	}
+	return payload
+}
+
+func renderWebHookBody(hook *models.WebHookNotify, payload *WebHookPayload) ([]byte, error) {
+	tmpl, err := parseWebHookTemplate(hook.Template)
+	if err != nil {
+		return nil, err
+	}
+
+	buffer := bytes.NewBufferString("")
+	if err := tmpl.Execute(buffer, payload); err != nil {
+		return nil, err
+	}
+	return buffer.Bytes(), nil
+}
+
+// signWebHookBody 使用secret对请求体做HMAC-SHA256签名, 接收方可以据此校验请求来源
+func signWebHookBody(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// sendWebHooks 按照每个webhook订阅的任务状态发送通知, 单个webhook发送失败不影响其他通知
+func (w *Service) sendWebHooks(notifyCtl *models.NotifyCtl, task *task.Task) {
+	var payload *WebHookPayload
+	for _, hook := range notifyCtl.WebHooks {
+		events := hook.Events
+		if len(events) == 0 {
+			events = notifyCtl.NotifyTypes
+		}
+		if !sets.NewString(events...).Has(string(task.Status)) {
+			continue
+		}
+
+		if payload == nil {
+			payload = newWebHookPayload(task)
+		}
+		if err := w.sendWebHook(hook, payload); err != nil {
+			log.Errorf("send webhook %s of %s:%d err: %v", hook.Name, task.PipelineName, task.TaskID, err)
+		}
+	}
+}
+
+func (w *Service) sendWebHook(hook *models.WebHookNotify, payload *WebHookPayload) error {
+	body, err := renderWebHookBody(hook, payload)
+	if err != nil {
+		return err
+	}
+
+	c := httpclient.New()
+	proxies, _ := w.proxyColl.List(&mongodb.ProxyArgs{})
+	if len(proxies) != 0 && proxies[0].EnableApplicationProxy {
+		c.SetProxy(proxies[0].GetProxyURL())
+	}
+
+	rfs := []httpclient.RequestFunc{
+		httpclient.SetHeader("Content-Type", "application/json"),
+		httpclient.SetHeader(webHookEventHeader, payload.Event),
+	}
+	for k, v := range hook.Headers {
+		rfs = append(rfs, httpclient.SetHeader(k, v))
+	}
+	if hook.Secret != "" {
+		rfs = append(rfs, httpclient.SetHeader(webHookSignatureHeader, signWebHookBody(hook.Secret, body)))
+	}
+	rfs = append(rfs, httpclient.SetBody(body))
+
+	_, err = c.Post(hook.URL, rfs...)
+	return err
+}
@@ -0,0 +1,91 @@
+/*
+Copyright 2021 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package wechat
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/koderover/zadig/pkg/microservice/aslan/config"
+	"github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/models"
+	"github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/models/task"
+	"github.com/koderover/zadig/pkg/setting"
+)
+
+func TestRenderWebHookBody(t *testing.T) {
+	payload := newWebHookPayload(&task.Task{
+		TaskID:       1,
+		PipelineName:  "demo",
+		Status:        config.StatusFailed,
+		ConfigPayload: &models.ConfigPayload{APIToken: "api-token"},
+		Stages: []*models.Stage{{
+			TaskType: config.TaskBuild,
+			Status:   config.StatusFailed,
+			SubTasks: map[string]map[string]interface{}{
+				"b": {"status": "passed"},
+				"a": {"status": "failed", "error": "exit 1"},
+			},
+		}},
+	})
+
+	body, err := renderWebHookBody(&models.WebHookNotify{
+		Template: `{"text":"{{.Task.PipelineName}}#{{.Task.TaskID}} {{.Event}}{{range .Stages}}{{range .SubTasks}} {{.Name}}:{{.Status}}{{end}}{{end}}"}`,
+	}, payload)
+	assert.NoError(t, err)
+	assert.Equal(t, `{"text":"demo#1 failed a:failed b:passed"}`, string(body))
+
+	body, err = renderWebHookBody(&models.WebHookNotify{}, payload)
+	assert.NoError(t, err)
+	assert.Contains(t, string(body), `"event":"failed"`)
+	assert.NotContains(t, string(body), "api-token")
+
+	_, err = renderWebHookBody(&models.WebHookNotify{Template: "{{.Unknown}}"}, payload)
+	assert.Error(t, err)
+}
+
+func TestSignWebHookBody(t *testing.T) {
+	body := []byte(`{"event":"passed"}`)
+	mac := hmac.New(sha256.New, []byte("secret"))
+	mac.Write(body)
+
+	assert.Equal(t, "sha256="+hex.EncodeToString(mac.Sum(nil)), signWebHookBody("secret", body))
+}
+
+func TestValidateWebHooks(t *testing.T) {
+	assert.NoError(t, ValidateWebHooks([]*models.WebHookNotify{{Name: "bot", URL: "http://bot"}}))
+	assert.Error(t, ValidateWebHooks([]*models.WebHookNotify{{Name: "bot"}}))
+	assert.Error(t, ValidateWebHooks([]*models.WebHookNotify{{Name: "bot", URL: "http://bot", Template: "{{ .Task"}}))
+}
+
+func TestWebHookSecrets(t *testing.T) {
+	existed := &models.NotifyCtl{WebHooks: []*models.WebHookNotify{{Name: "ci", Secret: "s3cret"}}}
+	MaskWebHookSecrets(existed)
+	assert.Equal(t, setting.MaskValue, existed.WebHooks[0].Secret)
+
+	existed.WebHooks[0].Secret = "s3cret"
+	updated := &models.NotifyCtl{WebHooks: []*models.WebHookNotify{
+		{Name: "ci", Secret: setting.MaskValue},
+		{Name: "new", Secret: "another"},
+	}}
+	EnsureWebHookSecrets(existed, updated)
+	assert.Equal(t, "s3cret", updated.WebHooks[0].Secret)
+	assert.Equal(t, "another", updated.WebHooks[1].Secret)
+}
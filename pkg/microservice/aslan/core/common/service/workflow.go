@@ -112,6 +112,10 @@ func DeleteWorkflow(workflowName, requestID string, isDeletingProductTmpl bool,
 		log.Errorf("PipelineTaskV2.DeleteByPipelineName error: %v", err)
 	}
 
+	if err := mongodb.NewWorkflowApprovalColl().DeleteByPipelineName(workflowName); err != nil {
+		log.Errorf("WorkflowApproval.DeleteByPipelineName error: %v", err)
+	}
+
 	if deliveryVersions, err := mongodb.NewDeliveryVersionColl().Find(&mongodb.DeliveryVersionArgs{OrgID: 1, WorkflowName: workflowName}); err == nil {
 		for _, deliveryVersion := range deliveryVersions {
 			if err := mongodb.NewDeliveryVersionColl().Delete(deliveryVersion.ID.Hex()); err != nil {
@@ -175,7 +179,7 @@ func ProcessWebhook(updatedHooks, currentHooks interface{}, name string, logger
 			}
 
 			switch ch.Type {
-			case setting.SourceFromGithub, setting.SourceFromGitlab, setting.SourceFromCodeHub:
+			case setting.SourceFromGithub, setting.SourceFromGitlab, setting.SourceFromCodeHub, setting.SourceFromGitea, setting.SourceFromBitbucket:
 				err = webhook.NewClient().RemoveWebHook(&webhook.TaskOption{
 					Name:    wh.name,
 					Owner:   wh.owner,
@@ -209,7 +213,7 @@ func ProcessWebhook(updatedHooks, currentHooks interface{}, name string, logger
 			}
 
 			switch ch.Type {
-			case setting.SourceFromGithub, setting.SourceFromGitlab, setting.SourceFromCodeHub:
+			case setting.SourceFromGithub, setting.SourceFromGitlab, setting.SourceFromCodeHub, setting.SourceFromGitea, setting.SourceFromBitbucket:
 				err = webhook.NewClient().AddWebHook(&webhook.TaskOption{
 					Name:    wh.name,
 					Owner:   wh.owner,
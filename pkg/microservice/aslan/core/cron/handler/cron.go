@@ -38,6 +38,13 @@ func CleanConfigmapCronJob(c *gin.Context) {
 	cronservice.CleanConfigmapCronJob(ctx.Logger)
 }
 
+func EmailDigestCronJob(c *gin.Context) {
+	ctx := internalhandler.NewContext(c)
+	defer func() { internalhandler.JSONResponse(c, ctx) }()
+
+	ctx.Err = cronservice.EmailDigestCronJob(ctx.Logger)
+}
+
 // param type: cronjob的执行内容类型
 // param name: 当type为workflow的时候 代表workflow名称， 当type为test的时候，为test名称
 type DisableCronjobReq struct {
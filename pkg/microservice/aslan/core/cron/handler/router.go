@@ -34,6 +34,7 @@ func (*Router) Inject(router *gin.RouterGroup) {
 	{
 		cron.GET("/cleanjob", CleanJobCronJob)
 		cron.GET("/cleanconfigmap", CleanConfigmapCronJob)
+		cron.GET("/emaildigest", EmailDigestCronJob)
 	}
 
 	cronjob := router.Group("cronjob")
@@ -27,7 +27,9 @@ import (
 
 	"github.com/koderover/zadig/pkg/microservice/aslan/config"
 	commonservice "github.com/koderover/zadig/pkg/microservice/aslan/core/common/service"
+	"github.com/koderover/zadig/pkg/microservice/aslan/core/common/service/notify"
 	"github.com/koderover/zadig/pkg/setting"
+	e "github.com/koderover/zadig/pkg/tool/errors"
 	krkubeclient "github.com/koderover/zadig/pkg/tool/kube/client"
 	"github.com/koderover/zadig/pkg/tool/kube/getter"
 	"github.com/koderover/zadig/pkg/tool/kube/updater"
@@ -59,6 +61,15 @@ func CleanConfigmapCronJob(log *zap.SugaredLogger) {
 	log.Infof("finnish clean configmap...")
 }
 
+// EmailDigestCronJob 发送汇总模式下积攒的通知邮件
+func EmailDigestCronJob(log *zap.SugaredLogger) error {
+	if err := notify.NewNotifyClient().SendEmailDigests(); err != nil {
+		log.Errorf("send email digests error: %v", err)
+		return e.ErrInternalError.AddErr(err)
+	}
+	return nil
+}
+
 func cleanJob(namespace string, selector labels.Selector, client client.Client, log *zap.SugaredLogger) {
 	jobList, err := getter.ListJobs(namespace, selector, client)
 	if err != nil {
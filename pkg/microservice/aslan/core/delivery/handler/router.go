@@ -35,6 +35,12 @@ func (*Router) Inject(router *gin.RouterGroup) {
 		deliveryArtifact.POST("", CreateDeliveryArtifacts)
 		deliveryArtifact.POST("/:id", UpdateDeliveryArtifact)
 		deliveryArtifact.POST("/:id/activities", CreateDeliveryActivities)
+		deliveryArtifact.GET("/:id/sbom", DownloadDeliveryArtifactSBOM)
+	}
+
+	deliverySBOM := router.Group("sbom")
+	{
+		deliverySBOM.GET("/packages", SearchSBOMPackages)
 	}
 
 	deliveryProduct := router.Group("products")
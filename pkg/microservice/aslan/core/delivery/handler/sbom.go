@@ -0,0 +1,59 @@
+/*
+Copyright 2021 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package handler
+
+import (
+	"fmt"
+
+	"github.com/gin-gonic/gin"
+
+	deliveryservice "github.com/koderover/zadig/pkg/microservice/aslan/core/delivery/service"
+	internalhandler "github.com/koderover/zadig/pkg/shared/handler"
+	e "github.com/koderover/zadig/pkg/tool/errors"
+)
+
+func DownloadDeliveryArtifactSBOM(c *gin.Context) {
+	ctx := internalhandler.NewContext(c)
+	defer func() { internalhandler.JSONResponse(c, ctx) }()
+
+	id := c.Param("id")
+	if id == "" {
+		ctx.Err = e.ErrInvalidParam.AddDesc("id can't be empty!")
+		return
+	}
+
+	resp, fileName, err := deliveryservice.DownloadDeliveryArtifactSBOM(id, ctx.Logger)
+	if err != nil {
+		ctx.Err = err
+		return
+	}
+	c.Writer.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, fileName))
+	c.Data(200, "application/json", resp)
+}
+
+func SearchSBOMPackages(c *gin.Context) {
+	ctx := internalhandler.NewContext(c)
+	defer func() { internalhandler.JSONResponse(c, ctx) }()
+
+	name := c.Query("name")
+	if name == "" {
+		ctx.Err = e.ErrInvalidParam.AddDesc("name can't be empty!")
+		return
+	}
+
+	ctx.Resp, ctx.Err = deliveryservice.SearchSBOMPackages(name, c.Query("version"), ctx.Logger)
+}
@@ -0,0 +1,139 @@
+/*
+Copyright 2021 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package service
+
+import (
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.uber.org/zap"
+
+	commonmodels "github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/models"
+	commonrepo "github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/mongodb"
+	commonservice "github.com/koderover/zadig/pkg/microservice/aslan/core/common/service"
+	e "github.com/koderover/zadig/pkg/tool/errors"
+)
+
+func DownloadDeliveryArtifactSBOM(id string, log *zap.SugaredLogger) ([]byte, string, error) {
+	artifact, err := commonrepo.NewDeliveryArtifactColl().Get(&commonrepo.DeliveryArtifactArgs{ID: id})
+	if err != nil {
+		log.Errorf("get deliveryArtifact error: %v", err)
+		return nil, "", e.ErrFindArtifact
+	}
+	if artifact.SBOM == nil {
+		return nil, "", e.ErrDownloadSBOM.AddDesc("no sbom is generated for the artifact")
+	}
+
+	content, err := commonservice.DownloadSBOM(artifact.SBOM)
+	if err != nil {
+		log.Errorf("download sbom of artifact %s error: %v", id, err)
+		return nil, "", e.ErrDownloadSBOM.AddErr(err)
+	}
+	return content, artifact.SBOM.FileName, nil
+}
+
+type SBOMPackageRelease struct {
+	ID          string `json:"id"`
+	Version     string `json:"version"`
+	ProductName string `json:"productName"`
+	ServiceName string `json:"serviceName"`
+}
+
+// SBOMPackageSearchResult 包含指定软件包的镜像以及使用了该镜像的版本
+type SBOMPackageSearchResult struct {
+	ArtifactID string                      `json:"artifact_id"`
+	Image      string                      `json:"image"`
+	Packages   []*commonmodels.SBOMPackage `json:"packages"`
+	Releases   []*SBOMPackageRelease       `json:"releases"`
+}
+
+// SearchSBOMPackages 查询SBOM中包含指定软件包的镜像, 以及交付中心中使用了这些镜像的版本
+func SearchSBOMPackages(name, version string, log *zap.SugaredLogger) ([]*SBOMPackageSearchResult, error) {
+	artifacts, err := commonrepo.NewDeliveryArtifactColl().ListBySBOMPackage(name, version)
+	if err != nil {
+		log.Errorf("list artifacts by sbom package error: %v", err)
+		return nil, e.ErrSearchSBOMPackages.AddErr(err)
+	}
+	resp := make([]*SBOMPackageSearchResult, 0, len(artifacts))
+	if len(artifacts) == 0 {
+		return resp, nil
+	}
+
+	images := make([]string, 0, len(artifacts))
+	for _, artifact := range artifacts {
+		images = append(images, artifact.Image)
+	}
+	builds, err := commonrepo.NewDeliveryBuildColl().ListByImageNames(images)
+	if err != nil {
+		log.Errorf("list delivery builds by images error: %v", err)
+		return nil, e.ErrSearchSBOMPackages.AddErr(err)
+	}
+
+	releaseIDs := make([]primitive.ObjectID, 0, len(builds))
+	for _, build := range builds {
+		releaseIDs = append(releaseIDs, build.ReleaseID)
+	}
+	versions := make(map[primitive.ObjectID]*commonmodels.DeliveryVersion)
+	if len(releaseIDs) > 0 {
+		deliveryVersions, err := commonrepo.NewDeliveryVersionColl().ListByIDs(releaseIDs)
+		if err != nil {
+			log.Errorf("list delivery versions error: %v", err)
+			return nil, e.ErrSearchSBOMPackages.AddErr(err)
+		}
+		for _, v := range deliveryVersions {
+			versions[v.ID] = v
+		}
+	}
+
+	for _, artifact := range artifacts {
+		result := &SBOMPackageSearchResult{
+			ArtifactID: artifact.ID.Hex(),
+			Image:      artifact.Image,
+			Packages:   matchSBOMPackages(artifact.SBOM.Packages, name, version),
+			Releases:   make([]*SBOMPackageRelease, 0),
+		}
+		for _, build := range builds {
+			v, ok := versions[build.ReleaseID]
+			if !ok || build.ImageName != artifact.Image {
+				continue
+			}
+			result.Releases = append(result.Releases, &SBOMPackageRelease{
+				ID:          v.ID.Hex(),
+				Version:     v.Version,
+				ProductName: v.ProductName,
+				ServiceName: build.ServiceName,
+			})
+		}
+		resp = append(resp, result)
+	}
+	return resp, nil
+}
+
+// matchSBOMPackages 返回与查询条件匹配的软件包, 规则与数据库查询一致
+func matchSBOMPackages(packages []*commonmodels.SBOMPackage, name, version string) []*commonmodels.SBOMPackage {
+	matched := make([]*commonmodels.SBOMPackage, 0)
+	for _, p := range packages {
+		if !strings.Contains(strings.ToLower(p.Name), strings.ToLower(name)) {
+			continue
+		}
+		if !strings.HasPrefix(p.Version, version) {
+			continue
+		}
+		matched = append(matched, p)
+	}
+	return matched
+}
@@ -38,6 +38,9 @@ import (
 
 var DefaultCleanWhiteList = []string{"spockadmin"}
 
+// recycleWarningAhead 环境回收前多久发送提醒
+const recycleWarningAhead int64 = 60 * 60 * 24
+
 func CleanProductCronJob(requestID string, log *zap.SugaredLogger) {
 
 	log.Info("[CleanProductCronJob] started ...")
@@ -60,6 +63,12 @@ func CleanProductCronJob(requestID string, log *zap.SugaredLogger) {
 			continue
 		}
 
+		// 回收前一天提醒一次, 环境更新后重新计算
+		recycleTime := product.UpdateTime + int64(60*60*24*product.RecycleDay)
+		if left := recycleTime - time.Now().Unix(); left > 0 && left <= recycleWarningAhead && product.RecycleWarnTime < product.UpdateTime {
+			sendEnvRecycleWarning(product, recycleTime, log)
+		}
+
 		if time.Now().Unix()-product.UpdateTime > int64(60*60*24*product.RecycleDay) {
 			title := "系统清理产品信息"
 			content := fmt.Sprintf("环境 [%s] 已经连续%d天没有使用, 系统已自动删除该环境, 如有需要请重新创建。", product.EnvName, product.RecycleDay)
@@ -85,6 +94,24 @@ func CleanProductCronJob(requestID string, log *zap.SugaredLogger) {
 	}
 }
 
+func sendEnvRecycleWarning(product *commonmodels.Product, recycleTime int64, log *zap.SugaredLogger) {
+	ctx := &commonmodels.EnvRecycleCtx{
+		ProductName: product.ProductName,
+		EnvName:     product.EnvName,
+		RecycleDay:  product.RecycleDay,
+		RecycleTime: recycleTime,
+	}
+	poetryClient := poetry.New(config.PoetryAPIServer(), config.PoetryAPIRootKey())
+	users, _ := poetryClient.ListProductPermissionUsers("", "", log)
+	for _, user := range users {
+		commonservice.SendEnvRecycleWarning(user, ctx, log)
+	}
+
+	if err := commonrepo.NewProductColl().UpdateRecycleWarnTime(product.EnvName, product.ProductName, time.Now().Unix()); err != nil {
+		log.Errorf("[%s][P:%s] update recycle warn time error: %v", product.EnvName, product.ProductName, err)
+	}
+}
+
 func GetInitProduct(productTmplName string, log *zap.SugaredLogger) (*commonmodels.Product, error) {
 	ret := &commonmodels.Product{}
 
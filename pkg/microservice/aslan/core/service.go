@@ -158,15 +158,21 @@ func initDatabase() {
 		commonrepo.NewRenderSetColl(),
 		commonrepo.NewS3StorageColl(),
 		commonrepo.NewServiceColl(),
+		commonrepo.NewSigningKeyColl(),
 		commonrepo.NewStrategyColl(),
 		commonrepo.NewStatsColl(),
 		commonrepo.NewSubscriptionColl(),
 		commonrepo.NewTaskColl(),
 		commonrepo.NewTestTaskStatColl(),
+		commonrepo.NewTestCaseStatColl(),
+		commonrepo.NewTestCoverageColl(),
+		commonrepo.NewPrEnvColl(),
 		commonrepo.NewTestingColl(),
 		commonrepo.NewWebHookColl(),
 		commonrepo.NewWebHookUserColl(),
 		commonrepo.NewWorkflowColl(),
+		commonrepo.NewWorkflowApprovalColl(),
+		commonrepo.NewEmailDigestColl(),
 		commonrepo.NewWorkflowStatColl(),
 		commonrepo.NewWorkLoadsStatColl(),
 		commonrepo.NewServicesInExternalEnvColl(),
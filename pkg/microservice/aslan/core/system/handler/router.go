@@ -161,6 +161,15 @@ func (*Router) Inject(router *gin.RouterGroup) {
 		privateKey.DELETE("/:id", gin2.RequireSuperAdminAuth, gin2.UpdateOperationLogStatus, DeletePrivateKey)
 	}
 
+	signingKey := router.Group("signingKey")
+	{
+		signingKey.GET("", ListSigningKeys)
+		signingKey.GET("/:id", GetSigningKey)
+		signingKey.POST("", gin2.RequireSuperAdminAuth, gin2.UpdateOperationLogStatus, CreateSigningKey)
+		signingKey.PUT("/:id", gin2.RequireSuperAdminAuth, gin2.UpdateOperationLogStatus, UpdateSigningKey)
+		signingKey.DELETE("/:id", gin2.RequireSuperAdminAuth, gin2.UpdateOperationLogStatus, DeleteSigningKey)
+	}
+
 	notification := router.Group("notification")
 	{
 		notification.GET("", PullNotify)
@@ -0,0 +1,105 @@
+/*
+Copyright 2021 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package handler
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+
+	commonmodels "github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/models"
+	"github.com/koderover/zadig/pkg/microservice/aslan/core/system/service"
+	internalhandler "github.com/koderover/zadig/pkg/shared/handler"
+	e "github.com/koderover/zadig/pkg/tool/errors"
+	"github.com/koderover/zadig/pkg/tool/log"
+)
+
+func ListSigningKeys(c *gin.Context) {
+	ctx := internalhandler.NewContext(c)
+	defer func() { internalhandler.JSONResponse(c, ctx) }()
+
+	ctx.Resp, ctx.Err = service.ListSigningKeys(ctx.Logger)
+}
+
+func GetSigningKey(c *gin.Context) {
+	ctx := internalhandler.NewContext(c)
+	defer func() { internalhandler.JSONResponse(c, ctx) }()
+
+	ctx.Resp, ctx.Err = service.GetSigningKey(c.Param("id"), ctx.Logger)
+}
+
+func CreateSigningKey(c *gin.Context) {
+	ctx := internalhandler.NewContext(c)
+	defer func() { internalhandler.JSONResponse(c, ctx) }()
+
+	args := new(commonmodels.SigningKey)
+	data, err := c.GetRawData()
+	if err != nil {
+		log.Errorf("CreateSigningKey c.GetRawData() err : %v", err)
+	}
+	if err = json.Unmarshal(data, args); err != nil {
+		log.Errorf("CreateSigningKey json.Unmarshal err : %v", err)
+	}
+	internalhandler.InsertOperationLog(c, ctx.Username, "", "新增", "资源管理-镜像签名密钥", fmt.Sprintf("name:%s", args.Name), "", ctx.Logger)
+
+	c.Request.Body = ioutil.NopCloser(bytes.NewBuffer(data))
+
+	if err := c.ShouldBindWith(&args, binding.JSON); err != nil {
+		ctx.Err = e.ErrInvalidParam.AddDesc("invalid SigningKey args")
+		return
+	}
+	args.UpdateBy = ctx.Username
+
+	ctx.Err = service.CreateSigningKey(args, ctx.Logger)
+}
+
+func UpdateSigningKey(c *gin.Context) {
+	ctx := internalhandler.NewContext(c)
+	defer func() { internalhandler.JSONResponse(c, ctx) }()
+
+	args := new(commonmodels.SigningKey)
+	data, err := c.GetRawData()
+	if err != nil {
+		log.Errorf("UpdateSigningKey c.GetRawData() err : %v", err)
+	}
+	if err = json.Unmarshal(data, args); err != nil {
+		log.Errorf("UpdateSigningKey json.Unmarshal err : %v", err)
+	}
+	internalhandler.InsertOperationLog(c, ctx.Username, "", "更新", "资源管理-镜像签名密钥", fmt.Sprintf("name:%s", args.Name), "", ctx.Logger)
+
+	c.Request.Body = ioutil.NopCloser(bytes.NewBuffer(data))
+
+	if err := c.ShouldBindWith(&args, binding.JSON); err != nil {
+		ctx.Err = e.ErrInvalidParam.AddDesc("invalid SigningKey args")
+		return
+	}
+	args.UpdateBy = ctx.Username
+
+	ctx.Err = service.UpdateSigningKey(c.Param("id"), args, ctx.Logger)
+}
+
+func DeleteSigningKey(c *gin.Context) {
+	ctx := internalhandler.NewContext(c)
+	defer func() { internalhandler.JSONResponse(c, ctx) }()
+
+	internalhandler.InsertOperationLog(c, ctx.Username, "", "删除", "资源管理-镜像签名密钥", fmt.Sprintf("id:%s", c.Param("id")), "", ctx.Logger)
+	ctx.Err = service.DeleteSigningKey(c.Param("id"), ctx.Logger)
+}
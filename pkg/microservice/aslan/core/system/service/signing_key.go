@@ -0,0 +1,116 @@
+/*
+Copyright 2021 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package service
+
+import (
+	"go.uber.org/zap"
+
+	commonmodels "github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/models"
+	commonrepo "github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/mongodb"
+	e "github.com/koderover/zadig/pkg/tool/errors"
+)
+
+// ListSigningKeys 列表中不返回私钥和密码
+func ListSigningKeys(log *zap.SugaredLogger) ([]*commonmodels.SigningKey, error) {
+	resp, err := commonrepo.NewSigningKeyColl().List()
+	if err != nil {
+		log.Errorf("SigningKey.List error: %v", err)
+		return resp, e.ErrListSigningKeys
+	}
+	for _, key := range resp {
+		key.PrivateKey = ""
+		key.Password = ""
+	}
+	return resp, nil
+}
+
+func GetSigningKey(id string, log *zap.SugaredLogger) (*commonmodels.SigningKey, error) {
+	resp, err := commonrepo.NewSigningKeyColl().Find(id)
+	if err != nil {
+		log.Errorf("SigningKey.Find %s error: %v", id, err)
+		return resp, e.ErrGetSigningKey
+	}
+	resp.PrivateKey = ""
+	resp.Password = ""
+	return resp, nil
+}
+
+func CreateSigningKey(args *commonmodels.SigningKey, log *zap.SugaredLogger) error {
+	if args.Name == "" || args.PrivateKey == "" || args.PublicKey == "" {
+		return e.ErrCreateSigningKey.AddDesc("name, private_key and public_key are required")
+	}
+
+	keys, err := commonrepo.NewSigningKeyColl().List()
+	if err != nil {
+		log.Errorf("SigningKey.List error: %v", err)
+		return e.ErrCreateSigningKey
+	}
+	for _, key := range keys {
+		if key.Name == args.Name {
+			return e.ErrCreateSigningKey.AddDesc("Name already exists")
+		}
+	}
+
+	if err := commonrepo.NewSigningKeyColl().Create(args); err != nil {
+		log.Errorf("SigningKey.Create error: %v", err)
+		return e.ErrCreateSigningKey
+	}
+	return nil
+}
+
+// UpdateSigningKey 私钥和密码为空时保留原值
+func UpdateSigningKey(id string, args *commonmodels.SigningKey, log *zap.SugaredLogger) error {
+	old, err := commonrepo.NewSigningKeyColl().Find(id)
+	if err != nil {
+		log.Errorf("SigningKey.Find %s error: %v", id, err)
+		return e.ErrUpdateSigningKey
+	}
+	if args.PrivateKey == "" {
+		args.PrivateKey = old.PrivateKey
+		if args.Password == "" {
+			args.Password = old.Password
+		}
+	}
+
+	if err := commonrepo.NewSigningKeyColl().Update(id, args); err != nil {
+		log.Errorf("SigningKey.Update %s error: %v", id, err)
+		return e.ErrUpdateSigningKey
+	}
+	return nil
+}
+
+func DeleteSigningKey(id string, log *zap.SugaredLogger) error {
+	// 检查该密钥是否被工作流引用
+	workflows, err := commonrepo.NewWorkflowColl().List(&commonrepo.ListWorkflowOption{})
+	if err != nil {
+		log.Errorf("Workflow.List error: %v", err)
+		return e.ErrDeleteSigningKey
+	}
+	for _, workflow := range workflows {
+		if workflow.SignatureKeyID == id ||
+			(workflow.DistributeStage != nil && workflow.DistributeStage.Signing != nil && workflow.DistributeStage.Signing.KeyID == id) {
+			log.Errorf("SigningKey has been used by workflow, signing key id:%s, workflow name:%s", id, workflow.Name)
+			return e.ErrDeleteUsedSigningKey
+		}
+	}
+
+	if err := commonrepo.NewSigningKeyColl().Delete(id); err != nil {
+		log.Errorf("SigningKey.Delete %s error: %v", id, err)
+		return e.ErrDeleteSigningKey
+	}
+	return nil
+}
@@ -0,0 +1,82 @@
+/*
+Copyright 2021 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package handler
+
+import (
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/koderover/zadig/pkg/microservice/aslan/core/workflow/service/workflow"
+	internalhandler "github.com/koderover/zadig/pkg/shared/handler"
+	e "github.com/koderover/zadig/pkg/tool/errors"
+)
+
+type createApprovalArgs struct {
+	PipelineName string `json:"pipeline_name"`
+	TaskID       int64  `json:"task_id"`
+}
+
+// CreateWorkflowApproval 审批stage开始时由warpdrive调用
+func CreateWorkflowApproval(c *gin.Context) {
+	ctx := internalhandler.NewContext(c)
+	defer func() { internalhandler.JSONResponse(c, ctx) }()
+
+	args := new(createApprovalArgs)
+	if err := c.BindJSON(args); err != nil {
+		ctx.Err = e.ErrInvalidParam.AddDesc(err.Error())
+		return
+	}
+	if args.PipelineName == "" || args.TaskID == 0 {
+		ctx.Err = e.ErrInvalidParam.AddDesc("pipeline name and task id can not be empty")
+		return
+	}
+
+	ctx.Err = workflow.CreateWorkflowApproval(args.PipelineName, args.TaskID, ctx.Logger)
+}
+
+func GetWorkflowApproval(c *gin.Context) {
+	ctx := internalhandler.NewContext(c)
+	defer func() { internalhandler.JSONResponse(c, ctx) }()
+
+	taskID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		ctx.Err = e.ErrInvalidParam.AddDesc("invalid task id")
+		return
+	}
+
+	ctx.Resp, ctx.Err = workflow.GetWorkflowApproval(c.Param("name"), taskID, ctx.Logger)
+}
+
+func ApproveWorkflowTask(c *gin.Context) {
+	ctx := internalhandler.NewContext(c)
+	defer func() { internalhandler.JSONResponse(c, ctx) }()
+	internalhandler.InsertOperationLog(c, ctx.Username, c.GetString("productName"), "审批", "工作流-task", c.Param("name"), "", ctx.Logger)
+
+	taskID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		ctx.Err = e.ErrInvalidParam.AddDesc("invalid task id")
+		return
+	}
+	args := new(workflow.ApproveArgs)
+	if err := c.BindJSON(args); err != nil {
+		ctx.Err = e.ErrInvalidParam.AddDesc(err.Error())
+		return
+	}
+
+	ctx.Err = workflow.ApproveWorkflowTask(ctx.Username, c.Param("name"), taskID, args, ctx.Logger)
+}
@@ -0,0 +1,66 @@
+/*
+Copyright 2021 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package handler
+
+import (
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/koderover/zadig/pkg/microservice/aslan/core/workflow/service/workflow"
+	internalhandler "github.com/koderover/zadig/pkg/shared/handler"
+	e "github.com/koderover/zadig/pkg/tool/errors"
+)
+
+type updateTaskPriorityReq struct {
+	Priority int `json:"priority"`
+}
+
+// UpdateWorkflowTaskPriority 调整排队中任务的优先级
+func UpdateWorkflowTaskPriority(c *gin.Context) {
+	ctx := internalhandler.NewContext(c)
+	defer func() { internalhandler.JSONResponse(c, ctx) }()
+	internalhandler.InsertOperationLog(c, ctx.Username, c.GetString("productName"), "更新", "工作流-task-优先级", c.Param("name"), "", ctx.Logger)
+
+	taskID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		ctx.Err = e.ErrInvalidParam.AddDesc("invalid task id")
+		return
+	}
+	args := new(updateTaskPriorityReq)
+	if err := c.BindJSON(args); err != nil {
+		ctx.Err = e.ErrInvalidParam.AddDesc(err.Error())
+		return
+	}
+
+	ctx.Err = workflow.UpdateQueuedTaskPriority(c.Param("name"), taskID, args.Priority, ctx.User.ID, ctx.User.IsSuperUser, ctx.Logger)
+}
+
+// BumpWorkflowTask 将排队中的任务提到队首
+func BumpWorkflowTask(c *gin.Context) {
+	ctx := internalhandler.NewContext(c)
+	defer func() { internalhandler.JSONResponse(c, ctx) }()
+	internalhandler.InsertOperationLog(c, ctx.Username, c.GetString("productName"), "置顶", "工作流-task", c.Param("name"), "", ctx.Logger)
+
+	taskID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		ctx.Err = e.ErrInvalidParam.AddDesc("invalid task id")
+		return
+	}
+
+	ctx.Err = workflow.BumpQueuedTask(c.Param("name"), taskID, ctx.User.ID, ctx.User.IsSuperUser, ctx.Logger)
+}
@@ -130,6 +130,18 @@ func (*Router) Inject(router *gin.RouterGroup) {
 		workflowtask.GET("/id/:id/pipelines/:name", GetWorkflowTask)
 		workflowtask.POST("/id/:id/pipelines/:name/restart", GetWorkflowTaskProductNameByTask, gin2.IsHavePermission([]string{permission.WorkflowTaskUUID}, permission.ContextKeyType), gin2.UpdateOperationLogStatus, RestartWorkflowTask)
 		workflowtask.DELETE("/id/:id/pipelines/:name", GetWorkflowTaskProductNameByTask, gin2.IsHavePermission([]string{permission.WorkflowTaskUUID}, permission.ContextKeyType), gin2.UpdateOperationLogStatus, CancelWorkflowTaskV2)
+		workflowtask.POST("/id/:id/pipelines/:name/approval", GetWorkflowTaskProductNameByTask, gin2.UpdateOperationLogStatus, ApproveWorkflowTask)
+		workflowtask.PUT("/id/:id/pipelines/:name/priority", GetWorkflowTaskProductNameByTask, gin2.IsHavePermission([]string{permission.WorkflowTaskUUID}, permission.ContextKeyType), gin2.UpdateOperationLogStatus, UpdateWorkflowTaskPriority)
+		workflowtask.POST("/id/:id/pipelines/:name/bump", GetWorkflowTaskProductNameByTask, gin2.IsHavePermission([]string{permission.WorkflowTaskUUID}, permission.ContextKeyType), gin2.UpdateOperationLogStatus, BumpWorkflowTask)
+	}
+
+	// ---------------------------------------------------------------------------------------
+	// 工作流审批接口, 供warpdrive调用
+	// ---------------------------------------------------------------------------------------
+	approval := router.Group("approval")
+	{
+		approval.POST("", gin2.RequireRootAPIKey, CreateWorkflowApproval)
+		approval.GET("/id/:id/pipelines/:name", gin2.RequireRootAPIKey, GetWorkflowApproval)
 	}
 
 	serviceTask := router.Group("servicetask")
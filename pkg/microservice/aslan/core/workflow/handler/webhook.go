@@ -27,7 +27,9 @@ import (
 
 	"github.com/koderover/zadig/pkg/microservice/aslan/core/workflow/service/webhook"
 	internalhandler "github.com/koderover/zadig/pkg/shared/handler"
+	"github.com/koderover/zadig/pkg/tool/bitbucket"
 	"github.com/koderover/zadig/pkg/tool/codehub"
+	"github.com/koderover/zadig/pkg/tool/gitea"
 )
 
 // @Router /workflow/webhook [POST]
@@ -44,8 +46,13 @@ func ProcessWebHook(c *gin.Context) {
 		ctx.Err = err
 		return
 	}
-	if github.WebHookType(c.Request) != "" {
+	// gitea同时会带上X-GitHub-Event请求头, 需要在github之前判断
+	if gitea.HookEventType(c.Request) != "" {
+		ctx.Err = webhook.ProcessGiteaHook(payload, c.Request, ctx.RequestID, ctx.Logger)
+	} else if github.WebHookType(c.Request) != "" {
 		ctx.Err = processGithub(payload, c.Request, ctx.RequestID, ctx.Logger)
+	} else if bitbucket.HookEventType(c.Request) != "" {
+		ctx.Err = webhook.ProcessBitbucketHook(payload, c.Request, ctx.RequestID, ctx.Logger)
 	} else if gitlab.HookEventType(c.Request) != "" {
 		ctx.Err = webhook.ProcessGitlabHook(payload, c.Request, ctx.RequestID, ctx.Logger)
 	} else if codehub.HookEventType(c.Request) != "" {
@@ -0,0 +1,112 @@
+/*
+Copyright 2021 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package webhook
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/hashicorp/go-multierror"
+	"go.uber.org/zap"
+
+	commonmodels "github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/models"
+	commonrepo "github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/mongodb"
+	gitservice "github.com/koderover/zadig/pkg/microservice/aslan/core/common/service/git"
+	"github.com/koderover/zadig/pkg/setting"
+	"github.com/koderover/zadig/pkg/tool/bitbucket"
+)
+
+// bitbucketRefChangeEvent 一次推送可能同时更新多个分支或tag, 每个变更单独触发工作流
+type bitbucketRefChangeEvent struct {
+	Repository *bitbucket.Repository
+	Change     *bitbucket.RefChange
+}
+
+func ProcessBitbucketHook(payload []byte, req *http.Request, requestID string, log *zap.SugaredLogger) error {
+	eventType := bitbucket.HookEventType(req)
+	if eventType == bitbucket.EventTypeDiagnosticsPing {
+		return nil
+	}
+
+	if err := bitbucket.ValidatePayload(req, payload, gitservice.GetHookSecret()); err != nil {
+		return err
+	}
+
+	event, err := bitbucket.ParseHook(eventType, payload)
+	if err != nil {
+		log.Warnf("unexpected event type: %s", eventType)
+		return nil
+	}
+
+	forwardedProto := req.Header.Get("X-Forwarded-Proto")
+	forwardedHost := req.Header.Get("X-Forwarded-Host")
+	baseURI := fmt.Sprintf("%s://%s", forwardedProto, forwardedHost)
+
+	errorList := &multierror.Error{}
+	switch event := event.(type) {
+	case *bitbucket.RefsChangedEvent:
+		if event.Repository == nil || event.Repository.Project == nil {
+			return nil
+		}
+		if event.Actor != nil {
+			webhookUser := &commonmodels.WebHookUser{
+				Domain:    forwardedHost,
+				UserName:  event.Actor.DisplayName,
+				Email:     event.Actor.EmailAddress,
+				Source:    setting.SourceFromBitbucket,
+				CreatedAt: time.Now().Unix(),
+			}
+			commonrepo.NewWebHookUserColl().Upsert(webhookUser)
+		}
+
+		for _, change := range event.Changes {
+			if change.Ref == nil || change.Type == bitbucket.ChangeTypeDelete {
+				continue
+			}
+			refChange := &bitbucketRefChangeEvent{Repository: event.Repository, Change: change}
+			if err = TriggerWorkflowByBitbucketEvent(refChange, baseURI, requestID, log); err != nil {
+				errorList = multierror.Append(errorList, err)
+			}
+		}
+	case *bitbucket.PullRequestEvent:
+		pr := event.PullRequest
+		if pr == nil || pr.ToRef == nil || pr.ToRef.Repository == nil || pr.ToRef.Repository.Project == nil {
+			return nil
+		}
+		// pull request合并, 拒绝或删除之后回收为其创建的环境
+		if closed := bitbucketClosedPullRequest(event); closed != nil {
+			recyclePrEnvsAsync(closed, requestID, log)
+			return nil
+		}
+
+		if err = TriggerWorkflowByBitbucketEvent(event, baseURI, requestID, log); err != nil {
+			errorList = multierror.Append(errorList, err)
+		}
+	}
+
+	return errorList.ErrorOrNil()
+}
+
+// bitbucketClosedPullRequest pull request合并, 拒绝或删除时返回需要回收环境的pull request
+func bitbucketClosedPullRequest(event *bitbucket.PullRequestEvent) *closedPullRequest {
+	if !event.IsClosed() {
+		return nil
+	}
+	pr := event.PullRequest
+	return &closedPullRequest{Source: setting.SourceFromBitbucket, RepoOwner: pr.ToRef.Repository.Project.Key, RepoName: pr.ToRef.Repository.Slug, PrID: pr.ID}
+}
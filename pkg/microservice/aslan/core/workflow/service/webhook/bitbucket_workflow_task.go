@@ -0,0 +1,288 @@
+/*
+Copyright 2021 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package webhook
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/hashicorp/go-multierror"
+	"go.uber.org/zap"
+
+	"github.com/koderover/zadig/pkg/microservice/aslan/config"
+	commonmodels "github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/models"
+	commonrepo "github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/mongodb"
+	"github.com/koderover/zadig/pkg/microservice/aslan/core/common/service/scmnotify"
+	workflowservice "github.com/koderover/zadig/pkg/microservice/aslan/core/workflow/service/workflow"
+	"github.com/koderover/zadig/pkg/setting"
+	"github.com/koderover/zadig/pkg/shared/codehost"
+	"github.com/koderover/zadig/pkg/tool/bitbucket"
+	"github.com/koderover/zadig/pkg/types"
+	"github.com/koderover/zadig/pkg/types/permission"
+)
+
+// bitbucketEmptyCommitID 新建分支时变更的fromHash
+const bitbucketEmptyCommitID = "0000000000000000000000000000000000000000"
+
+type bitbucketPushEventMatcher struct {
+	log      *zap.SugaredLogger
+	workflow *commonmodels.Workflow
+	event    *bitbucketRefChangeEvent
+}
+
+func (bpem *bitbucketPushEventMatcher) Match(hookRepo *commonmodels.MainHookRepo) (bool, error) {
+	ev := bpem.event
+	if hookRepo.RepoOwner != ev.Repository.Project.Key || hookRepo.RepoName != ev.Repository.Slug {
+		return false, nil
+	}
+	if !EventConfigured(hookRepo, config.HookEventPush) || !matchHookBranch(hookRepo, ev.Change.Ref.DisplayID) {
+		return false, nil
+	}
+
+	hookRepo.Branch = ev.Change.Ref.DisplayID
+	since := ev.Change.FromHash
+	if since == bitbucketEmptyCommitID {
+		since = ""
+	}
+	changedFiles, err := findChangedFilesOfBitbucketCommits(hookRepo, since, ev.Change.ToHash)
+	if err != nil {
+		bpem.log.Warnf("failed to get changes of %s in %s/%s: %v", ev.Change.RefID, hookRepo.RepoOwner, hookRepo.RepoName, err)
+		return false, err
+	}
+
+	return MatchChanges(hookRepo, changedFiles), nil
+}
+
+func (bpem *bitbucketPushEventMatcher) UpdateTaskArgs(
+	product *commonmodels.Product, args *commonmodels.WorkflowTaskArgs, hookRepo *commonmodels.MainHookRepo, requestID string,
+) *commonmodels.WorkflowTaskArgs {
+	factory := &workflowArgsFactory{
+		workflow: bpem.workflow,
+		reqID:    requestID,
+	}
+
+	factory.Update(product, args, &types.Repository{
+		CodehostID: hookRepo.CodehostID,
+		RepoName:   hookRepo.RepoName,
+		RepoOwner:  hookRepo.RepoOwner,
+		Branch:     hookRepo.Branch,
+	})
+
+	return args
+}
+
+type bitbucketPullRequestEventMatcher struct {
+	log      *zap.SugaredLogger
+	workflow *commonmodels.Workflow
+	event    *bitbucket.PullRequestEvent
+}
+
+func (bprem *bitbucketPullRequestEventMatcher) Match(hookRepo *commonmodels.MainHookRepo) (bool, error) {
+	pr := bprem.event.PullRequest
+	if hookRepo.RepoOwner != pr.ToRef.Repository.Project.Key || hookRepo.RepoName != pr.ToRef.Repository.Slug {
+		return false, nil
+	}
+	if !EventConfigured(hookRepo, config.HookEventPr) || !matchHookBranch(hookRepo, pr.ToRef.DisplayID) {
+		return false, nil
+	}
+
+	hookRepo.Branch = pr.ToRef.DisplayID
+	ch, err := codehost.GetCodeHostInfoByID(hookRepo.CodehostID)
+	if err != nil {
+		return false, fmt.Errorf("failed to find codehost %d: %v", hookRepo.CodehostID, err)
+	}
+	changedFiles, err := bitbucket.NewClient(ch.Address, ch.AccessToken).ListPullRequestFiles(hookRepo.RepoOwner, hookRepo.RepoName, pr.ID)
+	if err != nil {
+		bprem.log.Warnf("failed to get changes of pull request %d in %s/%s: %v", pr.ID, hookRepo.RepoOwner, hookRepo.RepoName, err)
+		return false, err
+	}
+	bprem.log.Debugf("succeed to get %d changes in pull request event", len(changedFiles))
+
+	return MatchChanges(hookRepo, changedFiles), nil
+}
+
+func (bprem *bitbucketPullRequestEventMatcher) UpdateTaskArgs(
+	product *commonmodels.Product, args *commonmodels.WorkflowTaskArgs, hookRepo *commonmodels.MainHookRepo, requestID string,
+) *commonmodels.WorkflowTaskArgs {
+	factory := &workflowArgsFactory{
+		workflow: bprem.workflow,
+		reqID:    requestID,
+	}
+
+	args = factory.Update(product, args, &types.Repository{
+		CodehostID: hookRepo.CodehostID,
+		RepoName:   hookRepo.RepoName,
+		RepoOwner:  hookRepo.RepoOwner,
+		Branch:     hookRepo.Branch,
+		PR:         bprem.event.PullRequest.ID,
+	})
+
+	return args
+}
+
+func findChangedFilesOfBitbucketCommits(hookRepo *commonmodels.MainHookRepo, since, until string) ([]string, error) {
+	ch, err := codehost.GetCodeHostInfoByID(hookRepo.CodehostID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find codehost %d: %v", hookRepo.CodehostID, err)
+	}
+	return bitbucket.NewClient(ch.Address, ch.AccessToken).ListChangedFiles(hookRepo.RepoOwner, hookRepo.RepoName, since, until)
+}
+
+func createBitbucketEventMatcher(
+	event interface{}, workflow *commonmodels.Workflow, log *zap.SugaredLogger,
+) gitEventMatcher {
+	switch evt := event.(type) {
+	case *bitbucketRefChangeEvent:
+		if evt.Change.Ref.Type == bitbucket.RefTypeTag {
+			return &tagEventMatcher{
+				workflow:  workflow,
+				log:       log,
+				eventType: config.HookEventTag,
+				repoPath:  evt.Repository.Project.Key + "/" + evt.Repository.Slug,
+				tag:       evt.Change.Ref.DisplayID,
+			}
+		}
+		return &bitbucketPushEventMatcher{
+			workflow: workflow,
+			log:      log,
+			event:    evt,
+		}
+	case *bitbucket.PullRequestEvent:
+		return &bitbucketPullRequestEventMatcher{
+			workflow: workflow,
+			log:      log,
+			event:    evt,
+		}
+	}
+
+	return nil
+}
+
+func TriggerWorkflowByBitbucketEvent(event interface{}, baseURI, requestID string, log *zap.SugaredLogger) error {
+	workflowList, err := commonrepo.NewWorkflowColl().List(&commonrepo.ListWorkflowOption{})
+	if err != nil {
+		log.Errorf("failed to list workflow %v", err)
+		return err
+	}
+
+	mErr := &multierror.Error{}
+	var notification *commonmodels.Notification
+
+	for _, workflow := range workflowList {
+		if workflow.HookCtl == nil || !workflow.HookCtl.Enabled {
+			continue
+		}
+
+		log.Debugf("find %d hooks in workflow %s", len(workflow.HookCtl.Items), workflow.Name)
+		for _, item := range workflow.HookCtl.Items {
+			if item.WorkflowArgs == nil {
+				continue
+			}
+
+			matcher := createBitbucketEventMatcher(event, workflow, log)
+			if matcher == nil {
+				continue
+			}
+
+			matches, err := matcher.Match(item.MainRepo)
+			if err != nil {
+				mErr = multierror.Append(mErr, err)
+				continue
+			}
+			if !matches {
+				log.Debugf("event not matches %v", item.MainRepo)
+				continue
+			}
+
+			log.Infof("event match hook %v of %s", item.MainRepo, workflow.Name)
+			namespace := strings.Split(item.WorkflowArgs.Namespace, ",")[0]
+			opt := &commonrepo.ProductFindOptions{Name: workflow.ProductTmplName, EnvName: namespace}
+			var prod *commonmodels.Product
+			if prod, err = commonrepo.NewProductColl().Find(opt); err != nil {
+				log.Warnf("can't find environment %s-%s", item.WorkflowArgs.Namespace, workflow.ProductTmplName)
+				continue
+			}
+
+			isPullRequest := false
+			prID := 0
+			var mergeRequestID, commitID string
+			switch ev := event.(type) {
+			case *bitbucket.PullRequestEvent:
+				isPullRequest = true
+				prID = ev.PullRequest.ID
+				mergeRequestID = strconv.Itoa(prID)
+				if ev.PullRequest.FromRef != nil {
+					commitID = ev.PullRequest.FromRef.LatestCommit
+				}
+
+				// 如果是pull request，且该webhook触发器配置了自动取消，
+				// 则需要确认该pull request在本次commit之前的commit触发的任务是否处理完，没有处理完则取消掉。
+				autoCancelOpt := &AutoCancelOpt{
+					MergeRequestID: mergeRequestID,
+					CommitID:       commitID,
+					TaskType:       config.WorkflowType,
+					MainRepo:       item.MainRepo,
+					WorkflowArgs:   item.WorkflowArgs,
+				}
+				if err := AutoCancelTask(autoCancelOpt, log); err != nil {
+					log.Errorf("failed to auto cancel workflow task when receive event due to %v ", err)
+					mErr = multierror.Append(mErr, err)
+				}
+
+				// 基于基准环境创建临时环境时, 在pull request下评论环境和任务的状态
+				if item.WorkflowArgs.BaseNamespace != "" && notification == nil {
+					notification, _ = scmnotify.NewService().SendInitWebhookComment(
+						item.MainRepo, prID, baseURI, false, false, log,
+					)
+				}
+			case *bitbucketRefChangeEvent:
+				// commit status需要上报到触发任务的提交上
+				commitID = ev.Change.ToHash
+			}
+
+			if notification != nil && item.WorkflowArgs.BaseNamespace != "" {
+				item.WorkflowArgs.NotificationID = notification.ID.Hex()
+			}
+
+			args := matcher.UpdateTaskArgs(prod, item.WorkflowArgs, item.MainRepo, requestID)
+			args.MergeRequestID = mergeRequestID
+			args.CommitID = commitID
+			args.Source = setting.SourceFromBitbucket
+			args.CodehostID = item.MainRepo.CodehostID
+			args.RepoOwner = item.MainRepo.RepoOwner
+			args.RepoName = item.MainRepo.RepoName
+
+			if item.WorkflowArgs.BaseNamespace != "" && isPullRequest {
+				go func(args *commonmodels.WorkflowTaskArgs, prID int) {
+					if err := CreateEnvAndTaskByPR(args, prID, requestID, log); err != nil {
+						log.Errorf("CreateEnvAndTaskByPR err:%v", err)
+					}
+				}(args, prID)
+			} else {
+				if resp, err := workflowservice.CreateWorkflowTask(args, setting.WebhookTaskCreator, permission.AnonymousUserID, false, log); err != nil {
+					log.Errorf("failed to create workflow task when receive event due to %v ", err)
+					mErr = multierror.Append(mErr, err)
+				} else {
+					log.Infof("succeed to create task %v", resp)
+				}
+			}
+		}
+	}
+
+	return mErr.ErrorOrNil()
+}
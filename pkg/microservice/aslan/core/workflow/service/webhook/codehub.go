@@ -72,6 +72,12 @@ func ProcessCodehubHook(payload []byte, req *http.Request, requestID string, log
 		if err = updateServiceTemplateByCodehubPushEvent(pushEvent, log); err != nil {
 			errorList = multierror.Append(errorList, err)
 		}
+	case *codehub.MergeEvent:
+		// merge request关闭或合并之后回收为其创建的环境
+		if pr := codehubClosedMergeRequest(event); pr != nil {
+			recyclePrEnvsAsync(pr, requestID, log)
+			return nil
+		}
 	}
 
 	//产品工作流webhook
@@ -176,3 +182,12 @@ func SyncServiceTemplateFromCodehub(service *commonmodels.Service, log *zap.Suga
 	log.Infof("End of sync service template %s from codehub path %s", service.ServiceName, service.SrcPath)
 	return nil
 }
+
+// codehubClosedMergeRequest merge request关闭或合并时返回需要回收环境的merge request
+func codehubClosedMergeRequest(event *codehub.MergeEvent) *closedPullRequest {
+	if event.ObjectAttributes.State != "closed" && event.ObjectAttributes.State != "merged" {
+		return nil
+	}
+	repoOwner, repoName := splitRepoPath(event.ObjectAttributes.Target.PathWithNamespace)
+	return &closedPullRequest{Source: setting.SourceFromCodeHub, RepoOwner: repoOwner, RepoName: repoName, PrID: event.ObjectAttributes.IID}
+}
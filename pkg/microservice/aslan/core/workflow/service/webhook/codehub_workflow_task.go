@@ -26,6 +26,7 @@ import (
 	"github.com/koderover/zadig/pkg/microservice/aslan/config"
 	commonmodels "github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/models"
 	commonrepo "github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/mongodb"
+	"github.com/koderover/zadig/pkg/microservice/aslan/core/common/service/scmnotify"
 	workflowservice "github.com/koderover/zadig/pkg/microservice/aslan/core/workflow/service/workflow"
 	"github.com/koderover/zadig/pkg/setting"
 	"github.com/koderover/zadig/pkg/tool/codehub"
@@ -121,6 +122,18 @@ func createCodehubEventMatcher(
 			event:    evt,
 			workflow: workflow,
 		}
+	case *codehub.TagPushEvent:
+		tag, isTag := getTagFromRef(evt.Ref)
+		if !isTag || strings.Trim(evt.After, "0") == "" {
+			return nil
+		}
+		return &tagEventMatcher{
+			workflow:  workflow,
+			log:       log,
+			eventType: config.HookEventTag,
+			repoPath:  evt.Project.PathWithNamespace,
+			tag:       tag,
+		}
 	}
 
 	return nil
@@ -135,6 +148,8 @@ func TriggerWorkflowByCodehubEvent(event interface{}, baseURI, requestID string,
 	}
 
 	mErr := &multierror.Error{}
+	var notification *commonmodels.Notification
+
 	for _, workflow := range workflowList {
 		if workflow.HookCtl == nil || !workflow.HookCtl.Enabled {
 			continue
@@ -172,8 +187,13 @@ func TriggerWorkflowByCodehubEvent(event interface{}, baseURI, requestID string,
 				continue
 			}
 
+			isMergeRequest := false
+			prID := 0
 			var mergeRequestID, commitID string
 			if ev, isPr := event.(*codehub.MergeEvent); isPr {
+				isMergeRequest = true
+				prID = ev.ObjectAttributes.IID
+
 				// 如果是merge request，且该webhook触发器配置了自动取消，
 				// 则需要确认该merge request在本次commit之前的commit触发的任务是否处理完，没有处理完则取消掉。
 				mergeRequestID = strconv.Itoa(ev.ObjectAttributes.IID)
@@ -190,6 +210,20 @@ func TriggerWorkflowByCodehubEvent(event interface{}, baseURI, requestID string,
 					log.Errorf("failed to auto cancel workflow task when receive event %v due to %v ", event, err)
 					mErr = multierror.Append(mErr, err)
 				}
+
+				// 基于基准环境创建临时环境时, 在merge request下评论环境和任务的状态
+				if item.WorkflowArgs.BaseNamespace != "" && notification == nil {
+					notification, _ = scmnotify.NewService().SendInitWebhookComment(
+						item.MainRepo, prID, baseURI, false, false, log,
+					)
+				}
+			} else if ev, isPush := event.(*codehub.PushEvent); isPush {
+				// push触发的任务将状态上报到本次push的最新提交
+				commitID = ev.After
+			}
+
+			if notification != nil && item.WorkflowArgs.BaseNamespace != "" {
+				item.WorkflowArgs.NotificationID = notification.ID.Hex()
 			}
 
 			args := matcher.UpdateTaskArgs(prod, item.WorkflowArgs, item.MainRepo, requestID)
@@ -200,11 +234,19 @@ func TriggerWorkflowByCodehubEvent(event interface{}, baseURI, requestID string,
 			args.RepoOwner = item.MainRepo.RepoOwner
 			args.RepoName = item.MainRepo.RepoName
 			// 3. create task with args
-			if resp, err := workflowservice.CreateWorkflowTask(args, setting.WebhookTaskCreator, permission.AnonymousUserID, false, log); err != nil {
-				log.Errorf("failed to create workflow task when receive push event %v due to %v ", event, err)
-				mErr = multierror.Append(mErr, err)
+			if item.WorkflowArgs.BaseNamespace != "" && isMergeRequest {
+				go func(args *commonmodels.WorkflowTaskArgs, prID int) {
+					if err := CreateEnvAndTaskByPR(args, prID, requestID, log); err != nil {
+						log.Errorf("CreateEnvAndTaskByPR err:%v", err)
+					}
+				}(args, prID)
 			} else {
-				log.Infof("succeed to create task %v", resp)
+				if resp, err := workflowservice.CreateWorkflowTask(args, setting.WebhookTaskCreator, permission.AnonymousUserID, false, log); err != nil {
+					log.Errorf("failed to create workflow task when receive push event %v due to %v ", event, err)
+					mErr = multierror.Append(mErr, err)
+				} else {
+					log.Infof("succeed to create task %v", resp)
+				}
 			}
 		}
 	}
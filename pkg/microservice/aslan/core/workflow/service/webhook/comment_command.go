@@ -0,0 +1,515 @@
+/*
+Copyright 2021 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package webhook
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/xanzy/go-gitlab"
+	"go.uber.org/zap"
+
+	"github.com/koderover/zadig/pkg/microservice/aslan/config"
+	commonmodels "github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/models"
+	commonrepo "github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/mongodb"
+	commonservice "github.com/koderover/zadig/pkg/microservice/aslan/core/common/service"
+	"github.com/koderover/zadig/pkg/microservice/aslan/core/common/service/scmnotify"
+	workflowservice "github.com/koderover/zadig/pkg/microservice/aslan/core/workflow/service/workflow"
+	"github.com/koderover/zadig/pkg/setting"
+	"github.com/koderover/zadig/pkg/shared/codehost"
+	"github.com/koderover/zadig/pkg/shared/poetry"
+	"github.com/koderover/zadig/pkg/tool/gerrit"
+	githubtool "github.com/koderover/zadig/pkg/tool/git/github"
+	gitlabtool "github.com/koderover/zadig/pkg/tool/git/gitlab"
+	"github.com/koderover/zadig/pkg/types"
+	"github.com/koderover/zadig/pkg/types/permission"
+)
+
+const (
+	commentCommandPrefix = "/zadig"
+
+	commentCommandRun    = "run"
+	commentCommandRetest = "retest"
+	commentCommandCancel = "cancel"
+)
+
+const commentCommandUsage = "支持的指令: `/zadig run <workflow>` 触发工作流, `/zadig retest [workflow]` 重新执行失败的任务, `/zadig cancel [workflow]` 取消正在执行的任务"
+
+// commentCommand PR评论中的指令, 格式为 /zadig <action> [workflow]
+type commentCommand struct {
+	Action       string
+	WorkflowName string
+}
+
+func (c *commentCommand) String() string {
+	return strings.TrimSpace(fmt.Sprintf("%s %s %s", commentCommandPrefix, c.Action, c.WorkflowName))
+}
+
+// parseCommentCommand 只处理评论中第一条以/zadig开头的指令
+func parseCommentCommand(body string) *commentCommand {
+	for _, line := range strings.Split(body, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 0 || fields[0] != commentCommandPrefix {
+			continue
+		}
+
+		cmd := &commentCommand{}
+		if len(fields) > 1 {
+			cmd.Action = strings.ToLower(fields[1])
+		}
+		if len(fields) > 2 {
+			cmd.WorkflowName = fields[2]
+		}
+		return cmd
+	}
+
+	return nil
+}
+
+// prCommentEvent 各代码源PR评论事件中处理指令需要的信息
+type prCommentEvent struct {
+	Source    string
+	RepoOwner string
+	RepoName  string
+	// Branch PR的目标分支, 为空时不校验
+	Branch   string
+	PrID     int
+	CommitID string
+	// Revision gerrit评论时需要指定patchset
+	Revision string
+	// Commenter 评论者在代码源的用户名
+	Commenter string
+	// CommenterID gitlab评论者的用户ID
+	CommenterID int
+	// CommenterEmail gerrit评论事件中带有评论者的邮箱
+	CommenterEmail string
+	Body           string
+}
+
+// commentHookItem 配置了评论所在代码库PR触发器的工作流
+type commentHookItem struct {
+	workflow *commonmodels.Workflow
+	item     *commonmodels.WorkflowHook
+}
+
+// ProcessCommentCommand 执行PR评论中的指令, 并将执行结果回复到PR中
+func ProcessCommentCommand(ev *prCommentEvent, baseURI, requestID string, log *zap.SugaredLogger) error {
+	cmd := parseCommentCommand(ev.Body)
+	if cmd == nil {
+		return nil
+	}
+
+	items, err := findCommentHookItems(ev)
+	if err != nil {
+		log.Errorf("failed to find workflows for comment command %s, err: %v", cmd, err)
+		return err
+	}
+	// 代码库没有配置任何工作流触发器时不回复
+	if len(items) == 0 {
+		log.Infof("no workflow hook configured for %s/%s, ignore comment command %s", ev.RepoOwner, ev.RepoName, cmd)
+		return nil
+	}
+
+	var reply string
+	user, err := resolveCommenter(ev, items[0].item.MainRepo.CodehostID, log)
+	if err == nil {
+		reply, err = executeCommentCommand(cmd, ev, user, items, baseURI, requestID, log)
+	}
+	if err != nil {
+		log.Warnf("failed to execute comment command %s from %s, err: %v", cmd, ev.Commenter, err)
+		reply = fmt.Sprintf("指令 `%s` 执行失败: %v", cmd, err)
+	}
+
+	return scmnotify.NewService().ReplyComment(commentNotifyRepo(items[0].item.MainRepo, ev), ev.PrID, reply, log)
+}
+
+func executeCommentCommand(cmd *commentCommand, ev *prCommentEvent, user string, items []*commentHookItem, baseURI, requestID string, log *zap.SugaredLogger) (string, error) {
+	switch cmd.Action {
+	case commentCommandRun:
+		return runWorkflowByComment(cmd, ev, user, items, baseURI, requestID, log)
+	case commentCommandRetest:
+		return retestWorkflowByComment(cmd, ev, user, requestID, log)
+	case commentCommandCancel:
+		return cancelWorkflowByComment(cmd, ev, user, log)
+	default:
+		return commentCommandUsage, nil
+	}
+}
+
+func runWorkflowByComment(cmd *commentCommand, ev *prCommentEvent, user string, items []*commentHookItem, baseURI, requestID string, log *zap.SugaredLogger) (string, error) {
+	if cmd.WorkflowName == "" {
+		return "", fmt.Errorf("请指定工作流名称, 例如 `/zadig run <workflow>`")
+	}
+
+	var hookItem *commentHookItem
+	for _, item := range items {
+		if item.workflow.Name == cmd.WorkflowName {
+			hookItem = item
+			break
+		}
+	}
+	if hookItem == nil {
+		return "", fmt.Errorf("工作流 %s 没有配置该代码库的PR触发器", cmd.WorkflowName)
+	}
+
+	workflow, item := hookItem.workflow, hookItem.item
+	if err := authorizeCommenter(user, workflow.ProductTmplName, log); err != nil {
+		return "", err
+	}
+
+	namespace := strings.Split(item.WorkflowArgs.Namespace, ",")[0]
+	opt := &commonrepo.ProductFindOptions{Name: workflow.ProductTmplName, EnvName: namespace}
+	prod, err := commonrepo.NewProductColl().Find(opt)
+	if err != nil {
+		return "", fmt.Errorf("环境 %s 不存在", namespace)
+	}
+
+	branch := item.MainRepo.Branch
+	if ev.Branch != "" {
+		branch = ev.Branch
+	}
+
+	factory := &workflowArgsFactory{
+		workflow: workflow,
+		reqID:    requestID,
+	}
+	args := factory.Update(prod, item.WorkflowArgs, &types.Repository{
+		CodehostID: item.MainRepo.CodehostID,
+		RepoName:   item.MainRepo.RepoName,
+		RepoOwner:  item.MainRepo.RepoOwner,
+		Branch:     branch,
+		PR:         ev.PrID,
+	})
+	args.WorkflowTaskCreator = user
+	args.MergeRequestID = strconv.Itoa(ev.PrID)
+	args.CommitID = ev.CommitID
+	args.Source = ev.Source
+	args.CodehostID = item.MainRepo.CodehostID
+	args.RepoOwner = item.MainRepo.RepoOwner
+	args.RepoName = item.MainRepo.RepoName
+
+	notification, _ := scmnotify.NewService().SendInitWebhookComment(
+		commentNotifyRepo(item.MainRepo, ev), ev.PrID, baseURI, false, false, log,
+	)
+	if notification != nil {
+		args.NotificationID = notification.ID.Hex()
+	}
+
+	if args.BaseNamespace != "" {
+		go func(args *commonmodels.WorkflowTaskArgs, prID int) {
+			if err := CreateEnvAndTaskByPR(args, prID, requestID, log); err != nil {
+				log.Errorf("CreateEnvAndTaskByPR err:%v", err)
+			}
+		}(args, ev.PrID)
+		return fmt.Sprintf("正在为工作流 %s 创建环境, 环境就绪后开始执行任务", workflow.Name), nil
+	}
+
+	resp, err := workflowservice.CreateWorkflowTask(args, user, permission.AnonymousUserID, false, log)
+	if err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("已触发工作流 %s, 任务 #%d", resp.PipelineName, resp.TaskID), nil
+}
+
+// retestWorkflowByComment 重新执行该PR触发的每个工作流中最近一次失败、超时或者被取消的任务
+func retestWorkflowByComment(cmd *commentCommand, ev *prCommentEvent, user, requestID string, log *zap.SugaredLogger) (string, error) {
+	tasks, err := commonrepo.NewTaskColl().List(&commonrepo.ListTaskOption{
+		Type:           config.WorkflowType,
+		Source:         ev.Source,
+		MergeRequestID: strconv.Itoa(ev.PrID),
+		NeedTriggerBy:  true,
+		Detail:         true,
+	})
+	if err != nil {
+		return "", fmt.Errorf("查询任务失败: %v", err)
+	}
+
+	latestTasks := make([]*commonrepo.TaskPreview, 0)
+	seen := make(map[string]bool)
+	for _, task := range tasks {
+		if task.WorkflowArgs == nil || !matchCommentTrigger(task.TriggerBy, ev) {
+			continue
+		}
+		if cmd.WorkflowName != "" && task.PipelineName != cmd.WorkflowName {
+			continue
+		}
+		// 任务按照创建时间倒序, 只保留每个工作流最近一次的任务
+		if seen[task.PipelineName] {
+			continue
+		}
+		seen[task.PipelineName] = true
+		if task.Status == config.StatusFailed || task.Status == config.StatusTimeout || task.Status == config.StatusCancelled {
+			latestTasks = append(latestTasks, task)
+		}
+	}
+	if len(latestTasks) == 0 {
+		return "没有需要重新执行的任务", nil
+	}
+
+	authorized := make(map[string]error)
+	messages := make([]string, 0, len(latestTasks))
+	for _, task := range latestTasks {
+		if _, ok := authorized[task.ProductName]; !ok {
+			authorized[task.ProductName] = authorizeCommenter(user, task.ProductName, log)
+		}
+		if err := authorized[task.ProductName]; err != nil {
+			messages = append(messages, fmt.Sprintf("工作流 %s: %v", task.PipelineName, err))
+			continue
+		}
+
+		args := task.WorkflowArgs
+		args.ReqID = requestID
+		args.WorkflowTaskCreator = user
+		// 通过评论重新执行的任务使用默认优先级, 不继承原任务由项目管理员设置的优先级
+		args.Priority = 0
+		resp, err := workflowservice.CreateWorkflowTask(args, user, permission.AnonymousUserID, false, log)
+		if err != nil {
+			log.Errorf("failed to retest workflow %s task %d, err: %v", task.PipelineName, task.TaskID, err)
+			messages = append(messages, fmt.Sprintf("工作流 %s: 重新执行任务 #%d 失败: %v", task.PipelineName, task.TaskID, err))
+			continue
+		}
+		messages = append(messages, fmt.Sprintf("工作流 %s: 已重新执行任务 #%d, 新任务 #%d", task.PipelineName, task.TaskID, resp.TaskID))
+	}
+
+	return strings.Join(messages, "\n"), nil
+}
+
+// cancelWorkflowByComment 取消该PR触发的所有未完成的工作流任务
+func cancelWorkflowByComment(cmd *commentCommand, ev *prCommentEvent, user string, log *zap.SugaredLogger) (string, error) {
+	tasks, err := commonrepo.NewTaskColl().FindTodoTasks()
+	if err != nil {
+		return "", fmt.Errorf("查询任务失败: %v", err)
+	}
+
+	authorized := make(map[string]error)
+	messages := make([]string, 0)
+	for _, task := range tasks {
+		if task.Type != config.WorkflowType || task.TriggerBy == nil || !matchCommentTrigger(task.TriggerBy, ev) {
+			continue
+		}
+		if cmd.WorkflowName != "" && task.PipelineName != cmd.WorkflowName {
+			continue
+		}
+
+		if _, ok := authorized[task.ProductName]; !ok {
+			authorized[task.ProductName] = authorizeCommenter(user, task.ProductName, log)
+		}
+		if err := authorized[task.ProductName]; err != nil {
+			messages = append(messages, fmt.Sprintf("工作流 %s: %v", task.PipelineName, err))
+			continue
+		}
+
+		if err := commonservice.CancelTask(user, task.PipelineName, task.TaskID, task.Type, task.ReqID, log); err != nil {
+			log.Errorf("failed to cancel workflow %s task %d, err: %v", task.PipelineName, task.TaskID, err)
+			messages = append(messages, fmt.Sprintf("工作流 %s: 取消任务 #%d 失败: %v", task.PipelineName, task.TaskID, err))
+			continue
+		}
+		messages = append(messages, fmt.Sprintf("工作流 %s: 已取消任务 #%d", task.PipelineName, task.TaskID))
+	}
+	if len(messages) == 0 {
+		return "没有正在执行的任务", nil
+	}
+
+	return strings.Join(messages, "\n"), nil
+}
+
+// findCommentHookItems 查找配置了评论所在代码库PR触发器的工作流, gerrit没有repo owner, 只按照代码库名称匹配
+func findCommentHookItems(ev *prCommentEvent) ([]*commentHookItem, error) {
+	workflows, err := commonrepo.NewWorkflowColl().List(&commonrepo.ListWorkflowOption{})
+	if err != nil {
+		return nil, err
+	}
+
+	prEvent := config.HookEventPr
+	if ev.Source == setting.SourceFromGerrit {
+		prEvent = config.HookEventType(patchsetCreatedEventType)
+	}
+
+	items := make([]*commentHookItem, 0)
+	for _, workflow := range workflows {
+		if workflow.HookCtl == nil || !workflow.HookCtl.Enabled {
+			continue
+		}
+		for _, item := range workflow.HookCtl.Items {
+			if item.WorkflowArgs == nil || item.MainRepo == nil {
+				continue
+			}
+			hookRepo := item.MainRepo
+			if hookRepo.Source != ev.Source || hookRepo.RepoName != ev.RepoName {
+				continue
+			}
+			if ev.RepoOwner != "" && hookRepo.RepoOwner != ev.RepoOwner {
+				continue
+			}
+			if !EventConfigured(hookRepo, prEvent) || !matchCommentBranch(hookRepo, ev.Branch) {
+				continue
+			}
+			items = append(items, &commentHookItem{workflow: workflow, item: item})
+		}
+	}
+
+	return items, nil
+}
+
+func matchCommentBranch(hookRepo *commonmodels.MainHookRepo, branch string) bool {
+	if branch == "" {
+		return true
+	}
+	return matchHookBranch(hookRepo, branch)
+}
+
+func matchCommentTrigger(triggerBy *commonmodels.TriggerBy, ev *prCommentEvent) bool {
+	if triggerBy == nil {
+		return false
+	}
+	if ev.RepoOwner != "" && triggerBy.RepoOwner != ev.RepoOwner {
+		return false
+	}
+	return triggerBy.Source == ev.Source &&
+		triggerBy.RepoName == ev.RepoName &&
+		triggerBy.MergeRequestID == strconv.Itoa(ev.PrID)
+}
+
+// commentNotifyRepo 回复评论使用的代码库信息, gerrit has no repo owner
+func commentNotifyRepo(hookRepo *commonmodels.MainHookRepo, ev *prCommentEvent) *commonmodels.MainHookRepo {
+	notifyRepo := *hookRepo
+	if ev.Source == setting.SourceFromGerrit {
+		notifyRepo.RepoOwner = ""
+		notifyRepo.Revision = ev.Revision
+	}
+	return &notifyRepo
+}
+
+// resolveCommenter 校验评论者拥有代码库的写权限, 并通过代码源账号的邮箱找到对应的zadig用户
+func resolveCommenter(ev *prCommentEvent, codehostID int, log *zap.SugaredLogger) (string, error) {
+	if ev.Commenter == "" {
+		return "", fmt.Errorf("无法识别评论者")
+	}
+
+	detail, err := codehost.GetCodehostDetail(codehostID)
+	if err != nil {
+		log.Errorf("failed to get codehost %d, err: %v", codehostID, err)
+		return "", fmt.Errorf("查询代码源信息失败")
+	}
+
+	var email string
+	switch ev.Source {
+	case setting.SourceFromGithub:
+		email, err = verifyGithubCommenter(detail, ev)
+	case setting.SourceFromGitlab:
+		email, err = verifyGitlabCommenter(detail, ev)
+	case setting.SourceFromGerrit:
+		email, err = verifyGerritCommenter(detail, ev)
+	default:
+		return "", fmt.Errorf("代码源 %s 不支持评论指令", ev.Source)
+	}
+	if err != nil {
+		return "", err
+	}
+	if email == "" {
+		return "", fmt.Errorf("无法获取用户 %s 在代码源的邮箱, 请设置公开邮箱", ev.Commenter)
+	}
+
+	poetryClient := poetry.New(config.PoetryAPIServer(), config.PoetryAPIRootKey())
+	user, err := poetryClient.GetUserByEmail(email, log)
+	if err != nil {
+		return "", fmt.Errorf("查询用户 %s 失败", ev.Commenter)
+	}
+	if user == nil {
+		return "", fmt.Errorf("用户 %s 没有关联的zadig账号", ev.Commenter)
+	}
+
+	return user.Name, nil
+}
+
+func verifyGithubCommenter(detail *codehost.Detail, ev *prCommentEvent) (string, error) {
+	ctx := context.Background()
+	gc := githubtool.NewClient(&githubtool.Config{AccessToken: detail.OauthToken, Proxy: config.ProxyHTTPSAddr()})
+	level, _, err := gc.Repositories.GetPermissionLevel(ctx, ev.RepoOwner, ev.RepoName, ev.Commenter)
+	if err != nil {
+		return "", fmt.Errorf("查询用户 %s 的代码库权限失败: %v", ev.Commenter, err)
+	}
+	if level.GetPermission() != "admin" && level.GetPermission() != "write" {
+		return "", fmt.Errorf("用户 %s 没有代码库 %s/%s 的写权限", ev.Commenter, ev.RepoOwner, ev.RepoName)
+	}
+
+	user, _, err := gc.Users.Get(ctx, ev.Commenter)
+	if err != nil {
+		return "", fmt.Errorf("查询用户 %s 失败: %v", ev.Commenter, err)
+	}
+	return user.GetEmail(), nil
+}
+
+func verifyGitlabCommenter(detail *codehost.Detail, ev *prCommentEvent) (string, error) {
+	if ev.CommenterID == 0 {
+		return "", fmt.Errorf("无法识别评论者")
+	}
+	client, err := gitlabtool.NewClient(detail.Address, detail.OauthToken)
+	if err != nil {
+		return "", err
+	}
+
+	pid := fmt.Sprintf("%s/%s", ev.RepoOwner, ev.RepoName)
+	member, _, err := client.ProjectMembers.GetInheritedProjectMember(pid, ev.CommenterID)
+	if err != nil {
+		return "", fmt.Errorf("用户 %s 不是代码库 %s 的成员", ev.Commenter, pid)
+	}
+	if member.AccessLevel < gitlab.DeveloperPermissions {
+		return "", fmt.Errorf("用户 %s 没有代码库 %s 的写权限", ev.Commenter, pid)
+	}
+
+	user, _, err := client.Users.GetUser(ev.CommenterID, gitlab.GetUsersOptions{})
+	if err != nil {
+		return "", fmt.Errorf("查询用户 %s 失败: %v", ev.Commenter, err)
+	}
+	// 管理员token可以获取到用户的主邮箱, 否则只能获取到公开邮箱
+	if user.Email != "" {
+		return user.Email, nil
+	}
+	return user.PublicEmail, nil
+}
+
+func verifyGerritCommenter(detail *codehost.Detail, ev *prCommentEvent) (string, error) {
+	cli := gerrit.NewClient(detail.Address, detail.OauthToken)
+	canPush, err := cli.CanPush(ev.RepoName, ev.Branch, ev.Commenter)
+	if err != nil {
+		return "", fmt.Errorf("查询用户 %s 的代码库权限失败: %v", ev.Commenter, err)
+	}
+	if !canPush {
+		return "", fmt.Errorf("用户 %s 没有代码库 %s 的写权限", ev.Commenter, ev.RepoName)
+	}
+	return ev.CommenterEmail, nil
+}
+
+// authorizeCommenter 评论者对应的zadig用户需要拥有该项目执行工作流的权限
+func authorizeCommenter(user, productName string, log *zap.SugaredLogger) error {
+	poetryClient := poetry.New(config.PoetryAPIServer(), config.PoetryAPIRootKey())
+	users, err := poetryClient.ListProductPermissionUsers(productName, permission.WorkflowTaskUUID, log)
+	if err != nil {
+		return fmt.Errorf("查询项目 %s 的权限失败", productName)
+	}
+	for _, name := range users {
+		if name == user {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("用户 %s 没有项目 %s 执行工作流的权限", user, productName)
+}
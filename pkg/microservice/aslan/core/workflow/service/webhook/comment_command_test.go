@@ -0,0 +1,40 @@
+/*
+Copyright 2021 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package webhook
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseCommentCommand(t *testing.T) {
+	assert.Nil(t, parseCommentCommand("LGTM"))
+	assert.Nil(t, parseCommentCommand("please run /zadig retest"))
+
+	cmd := parseCommentCommand("Patch Set 2:\n\n/zadig RUN my-workflow\n/zadig cancel")
+	if assert.NotNil(t, cmd) {
+		assert.Equal(t, commentCommandRun, cmd.Action)
+		assert.Equal(t, "my-workflow", cmd.WorkflowName)
+	}
+
+	cmd = parseCommentCommand("  /zadig retest  ")
+	if assert.NotNil(t, cmd) {
+		assert.Equal(t, commentCommandRetest, cmd.Action)
+		assert.Empty(t, cmd.WorkflowName)
+	}
+}
@@ -24,6 +24,7 @@ import (
 	"net/http"
 	"os"
 	"path"
+	"strconv"
 	"strings"
 
 	"github.com/hashicorp/go-multierror"
@@ -44,6 +45,8 @@ import (
 
 const (
 	changeMergedEventType    = "change-merged"
+	changeAbandonedEventType = "change-abandoned"
+	commentAddedEventType    = "comment-added"
 	patchsetCreatedEventType = "patchset-created"
 )
 
@@ -72,6 +75,36 @@ func ProcessGerritHook(payload []byte, req *http.Request, requestID string, log
 		}
 	}
 
+	// change合并或者放弃之后回收为其创建的环境
+	if gerritTypeEventObj.Type == changeMergedEventType || gerritTypeEventObj.Type == changeAbandonedEventType {
+		changeEvent := new(changeMergedEvent)
+		if err := json.Unmarshal(payload, changeEvent); err != nil {
+			log.Errorf("processGerritHook json.Unmarshal err : %v", err)
+		} else if pr := gerritClosedChange(changeEvent); pr != nil {
+			recyclePrEnvsAsync(pr, requestID, log)
+		}
+	}
+
+	// change评论中的指令
+	if gerritTypeEventObj.Type == commentAddedEventType {
+		commentEvent := new(commentAddedEvent)
+		if err := json.Unmarshal(payload, commentEvent); err != nil {
+			log.Errorf("processGerritHook json.Unmarshal err : %v", err)
+			return fmt.Errorf("this event is not supported")
+		}
+		return ProcessCommentCommand(&prCommentEvent{
+			Source:         setting.SourceFromGerrit,
+			RepoName:       commentEvent.Change.Project,
+			Branch:         commentEvent.Change.Branch,
+			PrID:           commentEvent.Change.Number,
+			CommitID:       strconv.Itoa(commentEvent.PatchSet.Number),
+			Revision:       commentEvent.PatchSet.Revision,
+			Commenter:      commentEvent.Author.Username,
+			CommenterEmail: commentEvent.Author.Email,
+			Body:           commentEvent.Comment,
+		}, baseURI, requestID, log)
+	}
+
 	return TriggerWorkflowByGerritEvent(gerritTypeEventObj, payload, req.RequestURI, baseURI, req.Header.Get("X-Forwarded-Host"), requestID, log)
 }
 
@@ -318,3 +351,11 @@ func ensureServiceTmpl(userName string, args *commonmodels.Service, log *zap.Sug
 
 	return nil
 }
+
+// gerritClosedChange change合并或者放弃时返回需要回收环境的change, gerrit没有仓库owner
+func gerritClosedChange(event *changeMergedEvent) *closedPullRequest {
+	if event.Type != changeMergedEventType && event.Type != changeAbandonedEventType {
+		return nil
+	}
+	return &closedPullRequest{Source: setting.SourceFromGerrit, RepoName: event.Change.Project, PrID: event.Change.Number}
+}
@@ -156,6 +156,18 @@ type SubmitterInfo struct {
 	Username string `json:"username"`
 }
 
+type commentAddedEvent struct {
+	Author         AuthorInfo    `json:"author"`
+	Comment        string        `json:"comment"`
+	PatchSet       PatchSetInfo  `json:"patchSet"`
+	Change         ChangeInfo    `json:"change"`
+	Project        ProjectInfo   `json:"project"`
+	RefName        string        `json:"refName"`
+	ChangeKey      ChangeKeyInfo `json:"changeKey"`
+	Type           string        `json:"type"`
+	EventCreatedOn int           `json:"eventCreatedOn"`
+}
+
 type gerritEventMatcher interface {
 	Match(*commonmodels.MainHookRepo) (bool, error)
 	UpdateTaskArgs(*commonmodels.Product, *commonmodels.WorkflowTaskArgs, *commonmodels.MainHookRepo, string) *commonmodels.WorkflowTaskArgs
@@ -332,8 +344,10 @@ func TriggerWorkflowByGerritEvent(event *gerritTypeEvent, body []byte, uri, base
 						// add webHook user
 						addWebHookUser(matcher, domain)
 
+						prID := 0
 						var mergeRequestID, commitID string
 						if m, ok := matcher.(*gerritPatchsetCreatedEventMatcher); ok {
+							prID = m.Event.Change.Number
 							mergeRequestID = strconv.Itoa(m.Event.Change.Number)
 							commitID = strconv.Itoa(m.Event.PatchSet.Number)
 
@@ -382,7 +396,13 @@ func TriggerWorkflowByGerritEvent(event *gerritTypeEvent, body []byte, uri, base
 						workflowArgs.RepoOwner = item.MainRepo.RepoOwner
 						workflowArgs.RepoName = item.MainRepo.RepoName
 
-						if resp, err := workflowservice.CreateWorkflowTask(workflowArgs, setting.WebhookTaskCreator, permission.AnonymousUserID, false, log); err != nil {
+						if item.WorkflowArgs.BaseNamespace != "" && prID > 0 {
+							go func(args *commonmodels.WorkflowTaskArgs, prID int) {
+								if err := CreateEnvAndTaskByPR(args, prID, requestID, log); err != nil {
+									log.Errorf("TriggerWorkflowByGerritEvent CreateEnvAndTaskByPR err:%v", err)
+								}
+							}(workflowArgs, prID)
+						} else if resp, err := workflowservice.CreateWorkflowTask(workflowArgs, setting.WebhookTaskCreator, permission.AnonymousUserID, false, log); err != nil {
 							log.Errorf("TriggerWorkflowByGerritEvent failed to create workflow task when receive push event %v due to %v ", event, err)
 							errorList = multierror.Append(errorList, err)
 						} else {
@@ -0,0 +1,101 @@
+/*
+Copyright 2021 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package webhook
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/hashicorp/go-multierror"
+	"go.uber.org/zap"
+
+	commonmodels "github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/models"
+	commonrepo "github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/mongodb"
+	gitservice "github.com/koderover/zadig/pkg/microservice/aslan/core/common/service/git"
+	"github.com/koderover/zadig/pkg/setting"
+	"github.com/koderover/zadig/pkg/tool/gitea"
+)
+
+const (
+	giteaPullRequestOpened       = "opened"
+	giteaPullRequestReopened     = "reopened"
+	giteaPullRequestSynchronized = "synchronized"
+	giteaPullRequestClosed       = "closed"
+)
+
+func ProcessGiteaHook(payload []byte, req *http.Request, requestID string, log *zap.SugaredLogger) error {
+	if err := gitea.ValidatePayload(req, payload, gitservice.GetHookSecret()); err != nil {
+		return err
+	}
+
+	eventType := gitea.HookEventType(req)
+	event, err := gitea.ParseHook(eventType, payload)
+	if err != nil {
+		log.Warnf("unexpected event type: %s", eventType)
+		return nil
+	}
+
+	forwardedProto := req.Header.Get("X-Forwarded-Proto")
+	forwardedHost := req.Header.Get("X-Forwarded-Host")
+	baseURI := fmt.Sprintf("%s://%s", forwardedProto, forwardedHost)
+
+	errorList := &multierror.Error{}
+	switch event := event.(type) {
+	case *gitea.PushEvent:
+		if event.IsDeleted() {
+			return nil
+		}
+		if len(event.Commits) > 0 && event.Commits[0].Author != nil {
+			webhookUser := &commonmodels.WebHookUser{
+				Domain:    forwardedHost,
+				UserName:  event.Commits[0].Author.Name,
+				Email:     event.Commits[0].Author.Email,
+				Source:    setting.SourceFromGitea,
+				CreatedAt: time.Now().Unix(),
+			}
+			commonrepo.NewWebHookUserColl().Upsert(webhookUser)
+		}
+	case *gitea.PullRequestEvent:
+		if event.PullRequest == nil || event.Repository == nil {
+			return nil
+		}
+		// pull request关闭或合并之后回收为其创建的环境
+		if pr := giteaClosedPullRequest(event); pr != nil {
+			recyclePrEnvsAsync(pr, requestID, log)
+			return nil
+		}
+		if event.Action != giteaPullRequestOpened && event.Action != giteaPullRequestReopened && event.Action != giteaPullRequestSynchronized {
+			return nil
+		}
+	}
+
+	if err = TriggerWorkflowByGiteaEvent(event, baseURI, requestID, log); err != nil {
+		errorList = multierror.Append(errorList, err)
+	}
+
+	return errorList.ErrorOrNil()
+}
+
+// giteaClosedPullRequest pull request关闭或合并时返回需要回收环境的pull request
+func giteaClosedPullRequest(event *gitea.PullRequestEvent) *closedPullRequest {
+	if event.Action != giteaPullRequestClosed {
+		return nil
+	}
+	repoOwner, repoName := splitRepoPath(event.Repository.FullName)
+	return &closedPullRequest{Source: setting.SourceFromGitea, RepoOwner: repoOwner, RepoName: repoName, PrID: event.PullRequest.Index}
+}
@@ -418,6 +418,11 @@ func ProcessGithubWebHook(payload []byte, req *http.Request, requestID string, l
 
 	switch et := event.(type) {
 	case *github.PullRequestEvent:
+		// pull request关闭或合并之后回收为其创建的环境
+		if pr := githubClosedPullRequest(et); pr != nil {
+			recyclePrEnvsAsync(pr, requestID, log)
+			return nil
+		}
 		if *et.Action != "opened" && *et.Action != "synchronize" {
 			return nil
 		}
@@ -452,6 +457,36 @@ func ProcessGithubWebHook(payload []byte, req *http.Request, requestID string, l
 			log.Infof("pushEventToPipelineTasks error: %v", err)
 			return e.ErrGithubWebHook.AddErr(err)
 		}
+
+	case *github.ReleaseEvent:
+		if et.GetAction() != "published" {
+			return nil
+		}
+
+		err = TriggerWorkflowByGithubEvent(et, baseURI, deliveryID, requestID, log)
+		if err != nil {
+			log.Errorf("releaseEventToWorkflowTasks error: %v", err)
+			return e.ErrGithubWebHook.AddErr(err)
+		}
+
+	case *github.IssueCommentEvent:
+		// 只处理pull request下新增的评论
+		if et.GetAction() != "created" || !et.GetIssue().IsPullRequest() {
+			return nil
+		}
+
+		commentEvent := &prCommentEvent{
+			Source:    setting.SourceFromGithub,
+			RepoOwner: et.GetRepo().GetOwner().GetLogin(),
+			RepoName:  et.GetRepo().GetName(),
+			PrID:      et.GetIssue().GetNumber(),
+			Commenter: et.GetComment().GetUser().GetLogin(),
+			Body:      et.GetComment().GetBody(),
+		}
+		if err = ProcessCommentCommand(commentEvent, baseURI, requestID, log); err != nil {
+			log.Errorf("ProcessCommentCommand error: %v", err)
+			return e.ErrGithubWebHook.AddErr(err)
+		}
 	}
 	return nil
 }
@@ -653,3 +688,16 @@ func SyncServiceTemplateFromGithub(service *commonmodels.Service, latestCommitID
 	log.Infof("End of sync service template %s from github path %s", service.ServiceName, service.SrcPath)
 	return nil
 }
+
+// githubClosedPullRequest pull request关闭或合并时返回需要回收环境的pull request, 合并的pull request也是closed
+func githubClosedPullRequest(event *github.PullRequestEvent) *closedPullRequest {
+	if event.GetAction() != "closed" {
+		return nil
+	}
+	return &closedPullRequest{
+		Source:    setting.SourceFromGithub,
+		RepoOwner: event.GetRepo().GetOwner().GetLogin(),
+		RepoName:  event.GetRepo().GetName(),
+		PrID:      event.GetPullRequest().GetNumber(),
+	}
+}
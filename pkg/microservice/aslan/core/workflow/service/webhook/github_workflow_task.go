@@ -31,6 +31,7 @@ import (
 	commonmodels "github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/models"
 	commonrepo "github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/mongodb"
 	git "github.com/koderover/zadig/pkg/microservice/aslan/core/common/service/github"
+	"github.com/koderover/zadig/pkg/microservice/aslan/core/common/service/scmnotify"
 	workflowservice "github.com/koderover/zadig/pkg/microservice/aslan/core/workflow/service/workflow"
 	"github.com/koderover/zadig/pkg/setting"
 	"github.com/koderover/zadig/pkg/shared/codehost"
@@ -228,6 +229,18 @@ func createGithubEventMatcher(
 ) gitEventMatcher {
 	switch evt := event.(type) {
 	case *github.PushEvent:
+		if tag, isTag := getTagFromRef(evt.GetRef()); isTag {
+			if evt.GetDeleted() {
+				return nil
+			}
+			return &tagEventMatcher{
+				workflow:  workflow,
+				log:       log,
+				eventType: config.HookEventTag,
+				repoPath:  evt.GetRepo().GetFullName(),
+				tag:       tag,
+			}
+		}
 		return &githubPushEventMatcher{
 			workflow: workflow,
 			log:      log,
@@ -240,6 +253,14 @@ func createGithubEventMatcher(
 			event:    evt,
 			workflow: workflow,
 		}
+	case *github.ReleaseEvent:
+		return &tagEventMatcher{
+			workflow:  workflow,
+			log:       log,
+			eventType: config.HookEventRelease,
+			repoPath:  evt.GetRepo().GetFullName(),
+			tag:       evt.GetRelease().GetTagName(),
+		}
 	}
 
 	return nil
@@ -257,6 +278,8 @@ func TriggerWorkflowByGithubEvent(event interface{}, baseURI, deliveryID, reques
 		return findChangedFilesOfPullRequest(pullRequestEvent, codehostId)
 	}
 
+	var notification *commonmodels.Notification
+
 	for _, workflow := range workflowList {
 		if workflow.HookCtl != nil && workflow.HookCtl.Enabled {
 			log.Debugf("find %d hooks in workflow %s", len(workflow.HookCtl.Items), workflow.Name)
@@ -282,9 +305,14 @@ func TriggerWorkflowByGithubEvent(event interface{}, baseURI, deliveryID, reques
 						continue
 					}
 
+					isPullRequest := false
+					prID := 0
 					var mergeRequestID, commitID string
 					var hookPayload *commonmodels.HookPayload
 					if ev, isPr := event.(*github.PullRequestEvent); isPr {
+						isPullRequest = true
+						prID = ev.GetPullRequest().GetNumber()
+
 						// 如果是merge request，且该webhook触发器配置了自动取消，
 						// 则需要确认该merge request在本次commit之前的commit触发的任务是否处理完，没有处理完则取消掉。
 						if ev.PullRequest != nil && ev.PullRequest.Number != nil && ev.PullRequest.Head != nil && ev.PullRequest.Head.SHA != nil {
@@ -312,6 +340,17 @@ func TriggerWorkflowByGithubEvent(event interface{}, baseURI, deliveryID, reques
 							IsPr:       true,
 							DeliveryID: deliveryID,
 						}
+
+						// 基于基准环境创建临时环境时, 在pull request下评论环境和任务的状态
+						if item.WorkflowArgs.BaseNamespace != "" && notification == nil {
+							notification, _ = scmnotify.NewService().SendInitWebhookComment(
+								item.MainRepo, prID, baseURI, false, false, log,
+							)
+						}
+					}
+
+					if notification != nil && item.WorkflowArgs.BaseNamespace != "" {
+						item.WorkflowArgs.NotificationID = notification.ID.Hex()
 					}
 
 					args := matcher.UpdateTaskArgs(prod, item.WorkflowArgs, item.MainRepo, requestID)
@@ -324,11 +363,19 @@ func TriggerWorkflowByGithubEvent(event interface{}, baseURI, deliveryID, reques
 					args.HookPayload = hookPayload
 
 					// 3. create task with args
-					if resp, err := workflowservice.CreateWorkflowTask(args, setting.WebhookTaskCreator, permission.AnonymousUserID, false, log); err != nil {
-						log.Errorf("failed to create workflow task when receive push event due to %v ", err)
-						mErr = multierror.Append(mErr, err)
+					if item.WorkflowArgs.BaseNamespace != "" && isPullRequest {
+						go func(args *commonmodels.WorkflowTaskArgs, prID int) {
+							if err := CreateEnvAndTaskByPR(args, prID, requestID, log); err != nil {
+								log.Errorf("CreateEnvAndTaskByPR err:%v", err)
+							}
+						}(args, prID)
 					} else {
-						log.Infof("succeed to create task %v", resp)
+						if resp, err := workflowservice.CreateWorkflowTask(args, setting.WebhookTaskCreator, permission.AnonymousUserID, false, log); err != nil {
+							log.Errorf("failed to create workflow task when receive push event due to %v ", err)
+							mErr = multierror.Append(mErr, err)
+						} else {
+							log.Infof("succeed to create task %v", resp)
+						}
 					}
 				} else {
 					log.Debugf("event not matches %v", item.MainRepo)
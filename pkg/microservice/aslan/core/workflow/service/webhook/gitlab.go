@@ -68,6 +68,9 @@ func ProcessGitlabHook(payload []byte, req *http.Request, requestID string, log
 	var eventPush *EventPush
 	var pushEvent *gitlab.PushEvent
 	var mergeEvent *gitlab.MergeEvent
+	// tagEvent tag推送或者release发布事件
+	var tagEvent interface{}
+	var commentEvent *prCommentEvent
 	var errorList = &multierror.Error{}
 
 	switch event.(type) {
@@ -78,6 +81,13 @@ func ProcessGitlabHook(payload []byte, req *http.Request, requestID string, log
 			event = ev
 			eventType = gitlab.EventTypePush
 		}
+	case *gitlab.TagPushSystemEvent:
+		if ev, err := gitlab.ParseWebhook(gitlab.EventTypeTagPush, payload); err != nil {
+			errorList = multierror.Append(errorList, err)
+		} else {
+			event = ev
+			eventType = gitlab.EventTypeTagPush
+		}
 	case *gitlab.MergeEvent:
 		if eventType == gitlab.EventTypeSystemHook {
 			eventType = gitlab.EventTypeMergeRequest
@@ -99,6 +109,30 @@ func ProcessGitlabHook(payload []byte, req *http.Request, requestID string, log
 		pushEvent = event
 	case *gitlab.MergeEvent:
 		mergeEvent = event
+		// merge request关闭或合并之后回收为其创建的环境
+		if pr := gitlabClosedMergeRequest(event); pr != nil {
+			recyclePrEnvsAsync(pr, requestID, log)
+		}
+	case *gitlab.TagEvent, *gitlab.ReleaseEvent:
+		tagEvent = event
+	case *gitlab.MergeCommentEvent:
+		// 系统自动生成的评论不处理
+		if !event.ObjectAttributes.System {
+			repoOwner, repoName := splitRepoPath(event.Project.PathWithNamespace)
+			commentEvent = &prCommentEvent{
+				Source:      setting.SourceFromGitlab,
+				RepoOwner:   repoOwner,
+				RepoName:    repoName,
+				Branch:      event.MergeRequest.TargetBranch,
+				PrID:        event.MergeRequest.IID,
+				CommitID:    event.MergeRequest.LastCommit.ID,
+				CommenterID: event.ObjectAttributes.AuthorID,
+				Body:        event.ObjectAttributes.Note,
+			}
+			if event.User != nil {
+				commentEvent.Commenter = event.User.Username
+			}
+		}
 	}
 	//触发更新服务模板webhook
 	if eventPush != nil {
@@ -180,6 +214,28 @@ func ProcessGitlabHook(payload []byte, req *http.Request, requestID string, log
 		}()
 	}
 
+	if tagEvent != nil {
+		//多服务工作流webhook
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err = TriggerWorkflowByGitlabEvent(tagEvent, baseURI, requestID, log); err != nil {
+				errorList = multierror.Append(errorList, err)
+			}
+		}()
+	}
+
+	if commentEvent != nil {
+		//merge request评论中的指令
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err = ProcessCommentCommand(commentEvent, baseURI, requestID, log); err != nil {
+				errorList = multierror.Append(errorList, err)
+			}
+		}()
+	}
+
 	wg.Wait()
 
 	return errorList.ErrorOrNil()
@@ -356,3 +412,12 @@ func SyncServiceTemplateFromGitlab(service *commonmodels.Service, log *zap.Sugar
 	log.Infof("End of sync service template %s from gitlab path %s", service.ServiceName, service.SrcPath)
 	return nil
 }
+
+// gitlabClosedMergeRequest merge request关闭或合并时返回需要回收环境的merge request
+func gitlabClosedMergeRequest(event *gitlab.MergeEvent) *closedPullRequest {
+	if event.ObjectAttributes.State != "closed" && event.ObjectAttributes.State != "merged" {
+		return nil
+	}
+	repoOwner, repoName := splitRepoPath(event.Project.PathWithNamespace)
+	return &closedPullRequest{Source: setting.SourceFromGitlab, RepoOwner: repoOwner, RepoName: repoName, PrID: event.ObjectAttributes.IID}
+}
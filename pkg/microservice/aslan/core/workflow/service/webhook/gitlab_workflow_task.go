@@ -21,8 +21,6 @@ import (
 	"regexp"
 	"strconv"
 	"strings"
-	"sync"
-	"time"
 
 	"github.com/hashicorp/go-multierror"
 	"github.com/xanzy/go-gitlab"
@@ -31,9 +29,7 @@ import (
 	"github.com/koderover/zadig/pkg/microservice/aslan/config"
 	commonmodels "github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/models"
 	commonrepo "github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/mongodb"
-	commonservice "github.com/koderover/zadig/pkg/microservice/aslan/core/common/service"
 	"github.com/koderover/zadig/pkg/microservice/aslan/core/common/service/scmnotify"
-	environmentservice "github.com/koderover/zadig/pkg/microservice/aslan/core/environment/service"
 	workflowservice "github.com/koderover/zadig/pkg/microservice/aslan/core/workflow/service/workflow"
 	"github.com/koderover/zadig/pkg/setting"
 	"github.com/koderover/zadig/pkg/shared/codehost"
@@ -42,7 +38,6 @@ import (
 	"github.com/koderover/zadig/pkg/tool/log"
 	"github.com/koderover/zadig/pkg/types"
 	"github.com/koderover/zadig/pkg/types/permission"
-	"github.com/koderover/zadig/pkg/util"
 )
 
 type gitlabMergeRequestDiffFunc func(event *gitlab.MergeEvent, id int) ([]string, error)
@@ -122,6 +117,30 @@ func createGitlabEventMatcher(
 			event:    evt,
 			workflow: workflow,
 		}
+	case *gitlab.TagEvent:
+		tag, isTag := getTagFromRef(evt.Ref)
+		// 删除tag时after为全0
+		if !isTag || strings.Trim(evt.After, "0") == "" {
+			return nil
+		}
+		return &tagEventMatcher{
+			workflow:  workflow,
+			log:       log,
+			eventType: config.HookEventTag,
+			repoPath:  evt.Project.PathWithNamespace,
+			tag:       tag,
+		}
+	case *gitlab.ReleaseEvent:
+		if evt.Action != "create" {
+			return nil
+		}
+		return &tagEventMatcher{
+			workflow:  workflow,
+			log:       log,
+			eventType: config.HookEventRelease,
+			repoPath:  evt.Project.PathWithNamespace,
+			tag:       evt.Tag,
+		}
 	}
 
 	return nil
@@ -284,6 +303,9 @@ func TriggerWorkflowByGitlabEvent(event interface{}, baseURI, requestID string,
 					// 初始化 gitlab diff_note
 					InitDiffNote(ev, item.MainRepo, log)
 				}
+			} else if ev, isPush := event.(*gitlab.PushEvent); isPush {
+				// push触发的任务将状态上报到本次push的最新提交
+				commitID = ev.After
 			}
 
 			if notification != nil {
@@ -432,150 +454,3 @@ func InitDiffNote(ev *gitlab.MergeEvent, mainRepo *commonmodels.MainHookRepo, lo
 
 	return nil
 }
-
-var mutex sync.Mutex
-
-// CreateEnvAndTaskByPR 根据pr触发创建环境、使用工作流更新该创建的环境、根据环境删除策略删除环境
-func CreateEnvAndTaskByPR(workflowArgs *commonmodels.WorkflowTaskArgs, prID int, requestID string, log *zap.SugaredLogger) error {
-	//获取基准环境的详细信息
-	opt := &commonrepo.ProductFindOptions{Name: workflowArgs.ProductTmplName, EnvName: workflowArgs.BaseNamespace}
-	baseProduct, err := commonrepo.NewProductColl().Find(opt)
-	if err != nil {
-		return fmt.Errorf("CreateEnvAndTaskByPR Product Find err:%v", err)
-	}
-
-	mutex.Lock()
-	defer func() {
-		mutex.Unlock()
-	}()
-	if baseProduct.Render != nil {
-		if renderSet, _ := commonrepo.NewRenderSetColl().Find(&commonrepo.RenderSetFindOption{Name: baseProduct.Render.Name, Revision: baseProduct.Render.Revision}); renderSet != nil {
-			baseProduct.Vars = renderSet.KVs
-		}
-	}
-
-	envName := fmt.Sprintf("%s-%d-%s%s", "pr", prID, util.GetRandomNumString(3), util.GetRandomString(3))
-	util.Clear(&baseProduct.ID)
-	baseProduct.Namespace = commonservice.GetProductEnvNamespace(envName, workflowArgs.ProductTmplName, "")
-	baseProduct.UpdateBy = setting.SystemUser
-	baseProduct.EnvName = envName
-	err = environmentservice.CreateProduct(setting.SystemUser, requestID, baseProduct, log)
-	if err != nil {
-		return fmt.Errorf("CreateEnvAndTaskByPR CreateProduct err:%v", err)
-	}
-
-	timeoutSeconds := config.ServiceStartTimeout()
-	//等待环境创建
-	if err = WaitEnvCreate(timeoutSeconds, envName, workflowArgs, log); err != nil {
-		return err
-	}
-
-	workflowArgs.Namespace = envName
-	taskResp, err := workflowservice.CreateWorkflowTask(workflowArgs, setting.WebhookTaskCreator, permission.AnonymousUserID, false, log)
-	if err != nil {
-		return fmt.Errorf("CreateEnvAndTaskByPR CreateWorkflowTask err：%v ", err)
-	}
-
-	taskStatus := ""
-	for {
-		taskInfo, err := commonrepo.NewTaskColl().Find(taskResp.TaskID, taskResp.PipelineName, config.WorkflowType)
-		if err != nil {
-			log.Errorf("CreateEnvAndTaskByPR PipelineTask find err:%v ", err)
-			time.Sleep(time.Second)
-			continue
-		}
-
-		if taskInfo.Status == config.StatusFailed || taskInfo.Status == config.StatusPassed || taskInfo.Status == config.StatusTimeout || taskInfo.Status == config.StatusCancelled {
-			taskStatus = string(taskInfo.Status)
-			break
-		} else {
-			time.Sleep(time.Second)
-		}
-	}
-	//按照用户设置的环境回收策略进行环境回收
-	if workflowArgs.EnvRecyclePolicy == setting.EnvRecyclePolicyAlways || (workflowArgs.EnvRecyclePolicy == setting.EnvRecyclePolicyTaskStatus && taskStatus == string(config.StatusPassed)) {
-		err = commonservice.DeleteProduct(setting.SystemUser, envName, workflowArgs.ProductTmplName, requestID, log)
-		if err != nil {
-			log.Errorf("CreateEnvAndTaskByPR DeleteProduct err:%v ", err)
-			return err
-		}
-		//等待环境删除
-		if err = WaitEnvDelete(timeoutSeconds, envName, workflowArgs, log); err != nil {
-			return err
-		}
-	}
-
-	return nil
-}
-
-func WaitEnvCreate(timeoutSeconds int, envName string, workflowArgs *commonmodels.WorkflowTaskArgs, log *zap.SugaredLogger) error {
-	timeout := false
-	go func() {
-		<-time.After(time.Duration(timeoutSeconds) * time.Second)
-		timeout = true
-	}()
-
-	for {
-		if timeout {
-			return fmt.Errorf("WaitEnvCreate %s wait create envName:%s timeout in %d seconds", workflowArgs.ProductTmplName, envName, timeoutSeconds)
-		}
-
-		productResp, err := environmentservice.GetProduct(setting.SystemUser, envName, workflowArgs.ProductTmplName, log)
-		if err != nil {
-			log.Errorf("WaitEnvCreate Product find err:%v ", err)
-			time.Sleep(time.Second)
-			continue
-		}
-		prTaskInfo := &commonmodels.PrTaskInfo{
-			ProductName:      workflowArgs.ProductTmplName,
-			EnvStatus:        productResp.Status,
-			EnvName:          envName,
-			EnvRecyclePolicy: workflowArgs.EnvRecyclePolicy,
-		}
-
-		if err = scmnotify.NewService().UpdateEnvAndTaskWebhookComment(workflowArgs, prTaskInfo, log); err != nil {
-			log.Errorf("WaitEnvCreate create product UpdateEnvAndTaskWebhookComment err:%v", err)
-		}
-
-		if productResp.Status == setting.PodRunning || productResp.Status == setting.PodUnstable || productResp.Status == setting.ClusterUnknown {
-			break
-		} else {
-			time.Sleep(time.Second)
-		}
-	}
-	return nil
-}
-
-func WaitEnvDelete(timeoutSeconds int, envName string, workflowArgs *commonmodels.WorkflowTaskArgs, log *zap.SugaredLogger) error {
-	timeout := false
-	go func() {
-		<-time.After(time.Duration(timeoutSeconds) * time.Second)
-		timeout = true
-	}()
-	for {
-		if timeout {
-			return fmt.Errorf("WaitEnvDelete %s wait delete envName:%s timeout in %d seconds", workflowArgs.ProductTmplName, envName, timeoutSeconds)
-		}
-
-		prTaskInfo := &commonmodels.PrTaskInfo{
-			ProductName:      workflowArgs.ProductTmplName,
-			EnvName:          envName,
-			EnvRecyclePolicy: workflowArgs.EnvRecyclePolicy,
-		}
-		productResp, err := environmentservice.GetProduct(setting.SystemUser, envName, workflowArgs.ProductTmplName, log)
-		if err != nil {
-			log.Errorf("WaitEnvDelete GetProduct err:%v ", err)
-			prTaskInfo.EnvStatus = "Completed"
-			if err = scmnotify.NewService().UpdateEnvAndTaskWebhookComment(workflowArgs, prTaskInfo, log); err != nil {
-				log.Errorf("WaitEnvDelete delete product UpdateEnvAndTaskWebhookComment1 err:%v", err)
-			}
-			break
-		}
-		prTaskInfo.EnvStatus = productResp.Status
-		if err = scmnotify.NewService().UpdateEnvAndTaskWebhookComment(workflowArgs, prTaskInfo, log); err != nil {
-			log.Errorf("WaitEnvDelete delete product UpdateEnvAndTaskWebhookComment2 err:%v", err)
-		}
-		time.Sleep(time.Second)
-	}
-	return nil
-}
@@ -0,0 +1,396 @@
+/*
+Copyright 2021 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package webhook
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/go-multierror"
+	"go.uber.org/zap"
+	"k8s.io/apimachinery/pkg/util/sets"
+
+	"github.com/koderover/zadig/pkg/microservice/aslan/config"
+	commonmodels "github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/models"
+	"github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/models/task"
+	commonrepo "github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/mongodb"
+	commonservice "github.com/koderover/zadig/pkg/microservice/aslan/core/common/service"
+	"github.com/koderover/zadig/pkg/microservice/aslan/core/common/service/scmnotify"
+	environmentservice "github.com/koderover/zadig/pkg/microservice/aslan/core/environment/service"
+	workflowservice "github.com/koderover/zadig/pkg/microservice/aslan/core/workflow/service/workflow"
+	"github.com/koderover/zadig/pkg/setting"
+	"github.com/koderover/zadig/pkg/types/permission"
+	"github.com/koderover/zadig/pkg/util"
+)
+
+var mutex sync.Mutex
+
+// CreateEnvAndTaskByPR 根据pr触发创建环境、使用工作流更新该创建的环境、根据环境删除策略删除环境
+func CreateEnvAndTaskByPR(workflowArgs *commonmodels.WorkflowTaskArgs, prID int, requestID string, log *zap.SugaredLogger) error {
+	envName, err := findOrCreatePrEnv(workflowArgs, prID, requestID, log)
+	if err != nil {
+		return err
+	}
+
+	timeoutSeconds := config.ServiceStartTimeout()
+	//等待环境创建
+	if err = WaitEnvCreate(timeoutSeconds, envName, workflowArgs, log); err != nil {
+		return err
+	}
+
+	workflowArgs.Namespace = envName
+	taskResp, err := workflowservice.CreateWorkflowTask(workflowArgs, setting.WebhookTaskCreator, permission.AnonymousUserID, false, log)
+	if err != nil {
+		return fmt.Errorf("CreateEnvAndTaskByPR CreateWorkflowTask err：%v ", err)
+	}
+
+	taskStatus := ""
+	for {
+		taskInfo, err := commonrepo.NewTaskColl().Find(taskResp.TaskID, taskResp.PipelineName, config.WorkflowType)
+		if err != nil {
+			log.Errorf("CreateEnvAndTaskByPR PipelineTask find err:%v ", err)
+			time.Sleep(time.Second)
+			continue
+		}
+
+		if taskFinished(taskInfo.Status) {
+			taskStatus = string(taskInfo.Status)
+			break
+		} else {
+			time.Sleep(time.Second)
+		}
+	}
+	//按照用户设置的环境回收策略进行环境回收
+	if workflowArgs.EnvRecyclePolicy == setting.EnvRecyclePolicyAlways || (workflowArgs.EnvRecyclePolicy == setting.EnvRecyclePolicyTaskStatus && taskStatus == string(config.StatusPassed)) {
+		return recyclePrEnv(timeoutSeconds, envName, workflowArgs, requestID, log)
+	}
+
+	return nil
+}
+
+// findOrCreatePrEnv 同一个PR的后续提交复用之前创建并且没有回收的环境, 没有时根据基准环境创建新的环境
+func findOrCreatePrEnv(workflowArgs *commonmodels.WorkflowTaskArgs, prID int, requestID string, log *zap.SugaredLogger) (string, error) {
+	mutex.Lock()
+	defer func() {
+		mutex.Unlock()
+	}()
+
+	// 每次回收的环境在任务结束后就会删除, 不能给同一个PR的其他任务复用
+	if workflowArgs.EnvRecyclePolicy != setting.EnvRecyclePolicyAlways {
+		if envName, ok := reusablePrEnv(workflowArgs, prID, log); ok {
+			return envName, nil
+		}
+	}
+
+	//获取基准环境的详细信息
+	opt := &commonrepo.ProductFindOptions{Name: workflowArgs.ProductTmplName, EnvName: workflowArgs.BaseNamespace}
+	baseProduct, err := commonrepo.NewProductColl().Find(opt)
+	if err != nil {
+		return "", fmt.Errorf("CreateEnvAndTaskByPR Product Find err:%v", err)
+	}
+
+	if baseProduct.Render != nil {
+		if renderSet, _ := commonrepo.NewRenderSetColl().Find(&commonrepo.RenderSetFindOption{Name: baseProduct.Render.Name, Revision: baseProduct.Render.Revision}); renderSet != nil {
+			baseProduct.Vars = renderSet.KVs
+		}
+	}
+
+	envName := fmt.Sprintf("%s-%d-%s%s", "pr", prID, util.GetRandomNumString(3), util.GetRandomString(3))
+	util.Clear(&baseProduct.ID)
+	baseProduct.Namespace = commonservice.GetProductEnvNamespace(envName, workflowArgs.ProductTmplName, "")
+	baseProduct.UpdateBy = setting.SystemUser
+	baseProduct.EnvName = envName
+	err = environmentservice.CreateProduct(setting.SystemUser, requestID, baseProduct, log)
+	if err != nil {
+		return "", fmt.Errorf("CreateEnvAndTaskByPR CreateProduct err:%v", err)
+	}
+
+	prEnv := &commonmodels.PrEnv{
+		ProductName:      workflowArgs.ProductTmplName,
+		EnvName:          envName,
+		BaseEnvName:      workflowArgs.BaseNamespace,
+		WorkflowName:     workflowArgs.WorkflowName,
+		EnvRecyclePolicy: workflowArgs.EnvRecyclePolicy,
+		Source:           workflowArgs.Source,
+		CodehostID:       workflowArgs.CodehostID,
+		RepoOwner:        workflowArgs.RepoOwner,
+		RepoName:         workflowArgs.RepoName,
+		PrID:             prID,
+		NotificationID:   workflowArgs.NotificationID,
+		CreateTime:       time.Now().Unix(),
+	}
+	if err = commonrepo.NewPrEnvColl().Create(prEnv); err != nil {
+		log.Errorf("failed to create pr env %s: %v", envName, err)
+	}
+
+	return envName, nil
+}
+
+// reusablePrEnv 查找同一个PR在工作流中创建并且仍然存在的环境, 每次回收的环境除外
+func reusablePrEnv(workflowArgs *commonmodels.WorkflowTaskArgs, prID int, log *zap.SugaredLogger) (string, bool) {
+	prEnvs, err := commonrepo.NewPrEnvColl().List(&commonrepo.PrEnvFindOption{
+		CodehostID:   workflowArgs.CodehostID,
+		RepoOwner:    workflowArgs.RepoOwner,
+		RepoName:     workflowArgs.RepoName,
+		PrID:         prID,
+		WorkflowName: workflowArgs.WorkflowName,
+	})
+	if err != nil {
+		log.Errorf("failed to list envs of pr %d: %v", prID, err)
+		return "", false
+	}
+
+	for _, prEnv := range prEnvs {
+		if prEnv.EnvRecyclePolicy == setting.EnvRecyclePolicyAlways {
+			continue
+		}
+		if _, err = commonrepo.NewProductColl().Find(&commonrepo.ProductFindOptions{Name: prEnv.ProductName, EnvName: prEnv.EnvName}); err == nil {
+			log.Infof("reuse env %s of pr %d in workflow %s", prEnv.EnvName, prID, workflowArgs.WorkflowName)
+			return prEnv.EnvName, true
+		}
+		// 环境已经被手动删除
+		if err = commonrepo.NewPrEnvColl().Delete(prEnv.ProductName, prEnv.EnvName); err != nil {
+			log.Errorf("failed to delete pr env %s: %v", prEnv.EnvName, err)
+		}
+	}
+	return "", false
+}
+
+// RecyclePrEnvs PR关闭或合并之后回收为该PR创建的环境, 回收策略为每次保留的环境除外
+func RecyclePrEnvs(source, repoOwner, repoName string, prID int, requestID string, log *zap.SugaredLogger) error {
+	prEnvs, err := commonrepo.NewPrEnvColl().List(&commonrepo.PrEnvFindOption{
+		Source:    source,
+		RepoOwner: repoOwner,
+		RepoName:  repoName,
+		PrID:      prID,
+	})
+	if err != nil {
+		return fmt.Errorf("RecyclePrEnvs list pr env err:%v", err)
+	}
+
+	mErr := &multierror.Error{}
+	for _, prEnv := range prEnvs {
+		if prEnv.EnvRecyclePolicy == setting.EnvRecyclePolicyNever {
+			continue
+		}
+
+		log.Infof("recycle env %s of pr %d in %s/%s", prEnv.EnvName, prID, repoOwner, repoName)
+		// 先取消还在使用该环境的任务, 等待任务结束后再删除环境
+		if err = cancelPrEnvTasks(prEnv, config.ServiceStartTimeout(), requestID, log); err != nil {
+			mErr = multierror.Append(mErr, err)
+			continue
+		}
+		workflowArgs := &commonmodels.WorkflowTaskArgs{
+			ProductTmplName:  prEnv.ProductName,
+			EnvRecyclePolicy: prEnv.EnvRecyclePolicy,
+			NotificationID:   prEnv.NotificationID,
+		}
+		if err = recyclePrEnv(config.ServiceStartTimeout(), prEnv.EnvName, workflowArgs, requestID, log); err != nil {
+			mErr = multierror.Append(mErr, err)
+		}
+	}
+
+	return mErr.ErrorOrNil()
+}
+
+// cancelPrEnvTasks 取消工作流中部署到该环境并且没有结束的任务, 等待任务结束
+func cancelPrEnvTasks(prEnv *commonmodels.PrEnv, timeoutSeconds int, requestID string, log *zap.SugaredLogger) error {
+	todoTasks, err := commonrepo.NewTaskColl().FindTodoTasks()
+	if err != nil {
+		return fmt.Errorf("failed to list running tasks: %v", err)
+	}
+
+	tasks := prEnvTasks(todoTasks, prEnv)
+	for _, t := range tasks {
+		log.Infof("cancel task %s:%d running in env %s", t.PipelineName, t.TaskID, prEnv.EnvName)
+		if err := commonservice.CancelTask(setting.WebhookTaskCreator, t.PipelineName, t.TaskID, t.Type, requestID, log); err != nil {
+			log.Warnf("failed to cancel task %s:%d: %v", t.PipelineName, t.TaskID, err)
+		}
+	}
+
+	deadline := time.Now().Add(time.Duration(timeoutSeconds) * time.Second)
+	for _, t := range tasks {
+		for {
+			taskInfo, err := commonrepo.NewTaskColl().Find(t.TaskID, t.PipelineName, t.Type)
+			if err != nil || taskFinished(taskInfo.Status) {
+				break
+			}
+			if time.Now().After(deadline) {
+				return fmt.Errorf("timeout waiting for task %s:%d running in env %s", t.PipelineName, t.TaskID, prEnv.EnvName)
+			}
+			time.Sleep(time.Second)
+		}
+	}
+	return nil
+}
+
+// prEnvTasks 返回工作流中部署到该环境的任务
+func prEnvTasks(tasks []*task.Task, prEnv *commonmodels.PrEnv) []*task.Task {
+	var resp []*task.Task
+	for _, t := range tasks {
+		if t.Type != config.WorkflowType || t.PipelineName != prEnv.WorkflowName || t.ProductName != prEnv.ProductName {
+			continue
+		}
+		if t.WorkflowArgs != nil && t.WorkflowArgs.Namespace == prEnv.EnvName {
+			resp = append(resp, t)
+		}
+	}
+	return resp
+}
+
+func taskFinished(status config.Status) bool {
+	return status == config.StatusFailed || status == config.StatusPassed || status == config.StatusTimeout || status == config.StatusCancelled
+}
+
+// closedPullRequest PR关闭或合并时需要回收环境的PR
+type closedPullRequest struct {
+	Source    string
+	RepoOwner string
+	RepoName  string
+	PrID      int
+}
+
+// recyclePrEnvsAsync 等待环境删除耗时较长, 不阻塞webhook请求
+func recyclePrEnvsAsync(pr *closedPullRequest, requestID string, log *zap.SugaredLogger) {
+	go func() {
+		if err := RecyclePrEnvs(pr.Source, pr.RepoOwner, pr.RepoName, pr.PrID, requestID, log); err != nil {
+			log.Errorf("failed to recycle envs of pr %d in %s/%s: %v", pr.PrID, pr.RepoOwner, pr.RepoName, err)
+		}
+	}()
+}
+
+// splitRepoPath 拆分owner/repo格式的仓库路径
+func splitRepoPath(repoPath string) (string, string) {
+	index := strings.LastIndex(repoPath, "/")
+	if index < 0 {
+		return "", repoPath
+	}
+	return repoPath[:index], repoPath[index+1:]
+}
+
+func recyclePrEnv(timeoutSeconds int, envName string, workflowArgs *commonmodels.WorkflowTaskArgs, requestID string, log *zap.SugaredLogger) error {
+	err := commonservice.DeleteProduct(setting.SystemUser, envName, workflowArgs.ProductTmplName, requestID, log)
+	if err != nil {
+		log.Errorf("CreateEnvAndTaskByPR DeleteProduct err:%v ", err)
+		return err
+	}
+	//等待环境删除
+	if err = WaitEnvDelete(timeoutSeconds, envName, workflowArgs, log); err != nil {
+		return err
+	}
+
+	return commonrepo.NewPrEnvColl().Delete(workflowArgs.ProductTmplName, envName)
+}
+
+func WaitEnvCreate(timeoutSeconds int, envName string, workflowArgs *commonmodels.WorkflowTaskArgs, log *zap.SugaredLogger) error {
+	timeout := false
+	go func() {
+		<-time.After(time.Duration(timeoutSeconds) * time.Second)
+		timeout = true
+	}()
+
+	for {
+		if timeout {
+			return fmt.Errorf("WaitEnvCreate %s wait create envName:%s timeout in %d seconds", workflowArgs.ProductTmplName, envName, timeoutSeconds)
+		}
+
+		productResp, err := environmentservice.GetProduct(setting.SystemUser, envName, workflowArgs.ProductTmplName, log)
+		if err != nil {
+			log.Errorf("WaitEnvCreate Product find err:%v ", err)
+			time.Sleep(time.Second)
+			continue
+		}
+		prTaskInfo := &commonmodels.PrTaskInfo{
+			ProductName:      workflowArgs.ProductTmplName,
+			EnvStatus:        productResp.Status,
+			EnvName:          envName,
+			EnvRecyclePolicy: workflowArgs.EnvRecyclePolicy,
+		}
+
+		ready := productResp.Status == setting.PodRunning || productResp.Status == setting.PodUnstable || productResp.Status == setting.ClusterUnknown
+		if ready {
+			prTaskInfo.EnvHosts = listEnvHosts(workflowArgs.ProductTmplName, envName, log)
+		}
+
+		if err = scmnotify.NewService().UpdateEnvAndTaskWebhookComment(workflowArgs, prTaskInfo, log); err != nil {
+			log.Errorf("WaitEnvCreate create product UpdateEnvAndTaskWebhookComment err:%v", err)
+		}
+
+		if ready {
+			break
+		} else {
+			time.Sleep(time.Second)
+		}
+	}
+	return nil
+}
+
+func WaitEnvDelete(timeoutSeconds int, envName string, workflowArgs *commonmodels.WorkflowTaskArgs, log *zap.SugaredLogger) error {
+	timeout := false
+	go func() {
+		<-time.After(time.Duration(timeoutSeconds) * time.Second)
+		timeout = true
+	}()
+	for {
+		if timeout {
+			return fmt.Errorf("WaitEnvDelete %s wait delete envName:%s timeout in %d seconds", workflowArgs.ProductTmplName, envName, timeoutSeconds)
+		}
+
+		prTaskInfo := &commonmodels.PrTaskInfo{
+			ProductName:      workflowArgs.ProductTmplName,
+			EnvName:          envName,
+			EnvRecyclePolicy: workflowArgs.EnvRecyclePolicy,
+		}
+		productResp, err := environmentservice.GetProduct(setting.SystemUser, envName, workflowArgs.ProductTmplName, log)
+		if err != nil {
+			log.Errorf("WaitEnvDelete GetProduct err:%v ", err)
+			prTaskInfo.EnvStatus = "Completed"
+			if err = scmnotify.NewService().UpdateEnvAndTaskWebhookComment(workflowArgs, prTaskInfo, log); err != nil {
+				log.Errorf("WaitEnvDelete delete product UpdateEnvAndTaskWebhookComment1 err:%v", err)
+			}
+			break
+		}
+		prTaskInfo.EnvStatus = productResp.Status
+		if err = scmnotify.NewService().UpdateEnvAndTaskWebhookComment(workflowArgs, prTaskInfo, log); err != nil {
+			log.Errorf("WaitEnvDelete delete product UpdateEnvAndTaskWebhookComment2 err:%v", err)
+		}
+		time.Sleep(time.Second)
+	}
+	return nil
+}
+
+// listEnvHosts 获取环境中ingress配置的访问地址
+func listEnvHosts(productName, envName string, log *zap.SugaredLogger) []string {
+	serviceGroups, _, err := environmentservice.ListGroups("", envName, productName, 0, 0, log)
+	if err != nil {
+		log.Warnf("failed to list services of env %s: %v", envName, err)
+		return nil
+	}
+
+	hosts := sets.NewString()
+	for _, serviceGroup := range serviceGroups {
+		if serviceGroup.Ingress == nil {
+			continue
+		}
+		for _, hostInfo := range serviceGroup.Ingress.HostInfo {
+			hosts.Insert(fmt.Sprintf("http://%s", hostInfo.Host))
+		}
+	}
+	return hosts.List()
+}
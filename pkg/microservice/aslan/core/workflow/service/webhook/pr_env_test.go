@@ -0,0 +1,168 @@
+/*
+Copyright 2021 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package webhook
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/google/go-github/v35/github"
+	"github.com/stretchr/testify/assert"
+	"github.com/xanzy/go-gitlab"
+
+	"github.com/koderover/zadig/pkg/microservice/aslan/config"
+	commonmodels "github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/models"
+	"github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/models/task"
+	"github.com/koderover/zadig/pkg/setting"
+	"github.com/koderover/zadig/pkg/tool/bitbucket"
+	"github.com/koderover/zadig/pkg/tool/codehub"
+	"github.com/koderover/zadig/pkg/tool/gitea"
+)
+
+func TestSplitRepoPath(t *testing.T) {
+	tests := []struct {
+		repoPath  string
+		repoOwner string
+		repoName  string
+	}{
+		{repoPath: "koderover/zadig", repoOwner: "koderover", repoName: "zadig"},
+		{repoPath: "group/subgroup/project", repoOwner: "group/subgroup", repoName: "project"},
+		{repoPath: "project", repoOwner: "", repoName: "project"},
+		{repoPath: "", repoOwner: "", repoName: ""},
+	}
+
+	for _, tt := range tests {
+		repoOwner, repoName := splitRepoPath(tt.repoPath)
+		assert.Equal(t, tt.repoOwner, repoOwner, tt.repoPath)
+		assert.Equal(t, tt.repoName, repoName, tt.repoPath)
+	}
+}
+
+func unmarshalEvent(t *testing.T, payload string, event interface{}) {
+	if err := json.Unmarshal([]byte(payload), event); err != nil {
+		t.Fatalf("invalid payload %s: %v", payload, err)
+	}
+}
+
+func TestGitlabClosedMergeRequest(t *testing.T) {
+	for _, state := range []string{"closed", "merged"} {
+		event := new(gitlab.MergeEvent)
+		unmarshalEvent(t, `{"project": {"path_with_namespace": "group/sub/app"}, "object_attributes": {"iid": 7, "state": "`+state+`"}}`, event)
+		assert.Equal(t, &closedPullRequest{Source: setting.SourceFromGitlab, RepoOwner: "group/sub", RepoName: "app", PrID: 7}, gitlabClosedMergeRequest(event), state)
+	}
+
+	event := new(gitlab.MergeEvent)
+	unmarshalEvent(t, `{"project": {"path_with_namespace": "group/app"}, "object_attributes": {"iid": 7, "state": "opened"}}`, event)
+	assert.Nil(t, gitlabClosedMergeRequest(event))
+}
+
+func TestGithubClosedPullRequest(t *testing.T) {
+	// 合并的pull request的action也是closed
+	event := new(github.PullRequestEvent)
+	unmarshalEvent(t, `{"action": "closed", "number": 3, "pull_request": {"number": 3, "merged": true}, "repository": {"name": "zadig", "owner": {"login": "koderover"}}}`, event)
+	assert.Equal(t, &closedPullRequest{Source: setting.SourceFromGithub, RepoOwner: "koderover", RepoName: "zadig", PrID: 3}, githubClosedPullRequest(event))
+
+	for _, action := range []string{"opened", "synchronize", "reopened"} {
+		event := new(github.PullRequestEvent)
+		unmarshalEvent(t, `{"action": "`+action+`", "pull_request": {"number": 3}, "repository": {"name": "zadig", "owner": {"login": "koderover"}}}`, event)
+		assert.Nil(t, githubClosedPullRequest(event), action)
+	}
+}
+
+func TestCodehubClosedMergeRequest(t *testing.T) {
+	for _, state := range []string{"closed", "merged"} {
+		event := new(codehub.MergeEvent)
+		unmarshalEvent(t, `{"object_attributes": {"iid": 12, "state": "`+state+`", "target": {"path_with_namespace": "team/app"}}}`, event)
+		assert.Equal(t, &closedPullRequest{Source: setting.SourceFromCodeHub, RepoOwner: "team", RepoName: "app", PrID: 12}, codehubClosedMergeRequest(event), state)
+	}
+
+	event := new(codehub.MergeEvent)
+	unmarshalEvent(t, `{"object_attributes": {"iid": 12, "state": "opened", "target": {"path_with_namespace": "team/app"}}}`, event)
+	assert.Nil(t, codehubClosedMergeRequest(event))
+}
+
+func TestGiteaClosedPullRequest(t *testing.T) {
+	event := new(gitea.PullRequestEvent)
+	unmarshalEvent(t, `{"action": "closed", "pull_request": {"number": 5, "merged": true}, "repository": {"full_name": "org/app"}}`, event)
+	assert.Equal(t, &closedPullRequest{Source: setting.SourceFromGitea, RepoOwner: "org", RepoName: "app", PrID: 5}, giteaClosedPullRequest(event))
+
+	event = new(gitea.PullRequestEvent)
+	unmarshalEvent(t, `{"action": "synchronized", "pull_request": {"number": 5}, "repository": {"full_name": "org/app"}}`, event)
+	assert.Nil(t, giteaClosedPullRequest(event))
+}
+
+func TestBitbucketClosedPullRequest(t *testing.T) {
+	const pr = `"pullRequest": {"id": 9, "toRef": {"repository": {"slug": "app", "project": {"key": "PRJ"}}}}`
+	for _, key := range []string{"pr:merged", "pr:declined", "pr:deleted"} {
+		event := new(bitbucket.PullRequestEvent)
+		unmarshalEvent(t, `{"eventKey": "`+key+`", `+pr+`}`, event)
+		assert.Equal(t, &closedPullRequest{Source: setting.SourceFromBitbucket, RepoOwner: "PRJ", RepoName: "app", PrID: 9}, bitbucketClosedPullRequest(event), key)
+	}
+
+	event := new(bitbucket.PullRequestEvent)
+	unmarshalEvent(t, `{"eventKey": "pr:opened", `+pr+`}`, event)
+	assert.Nil(t, bitbucketClosedPullRequest(event))
+}
+
+func TestGerritClosedChange(t *testing.T) {
+	for _, eventType := range []string{changeMergedEventType, changeAbandonedEventType} {
+		event := new(changeMergedEvent)
+		unmarshalEvent(t, `{"type": "`+eventType+`", "change": {"project": "platform/app", "number": 42}}`, event)
+		assert.Equal(t, &closedPullRequest{Source: setting.SourceFromGerrit, RepoName: "platform/app", PrID: 42}, gerritClosedChange(event), eventType)
+	}
+
+	event := new(changeMergedEvent)
+	unmarshalEvent(t, `{"type": "patchset-created", "change": {"project": "platform/app", "number": 42}}`, event)
+	assert.Nil(t, gerritClosedChange(event))
+}
+
+func TestPrEnvTasks(t *testing.T) {
+	prEnv := &commonmodels.PrEnv{ProductName: "demo", EnvName: "pr-1-abc", WorkflowName: "demo-workflow"}
+	newTask := func(taskID int64, pipelineName string, pipelineType config.PipelineType, namespace string) *task.Task {
+		return &task.Task{
+			TaskID:       taskID,
+			ProductName:  "demo",
+			PipelineName: pipelineName,
+			Type:         pipelineType,
+			WorkflowArgs: &commonmodels.WorkflowTaskArgs{Namespace: namespace},
+		}
+	}
+
+	tasks := []*task.Task{
+		newTask(1, "demo-workflow", config.WorkflowType, "pr-1-abc"),
+		newTask(2, "demo-workflow", config.WorkflowType, "dev"),
+		newTask(3, "other-workflow", config.WorkflowType, "pr-1-abc"),
+		newTask(4, "demo-workflow", config.TestType, "pr-1-abc"),
+		{TaskID: 5, ProductName: "demo", PipelineName: "demo-workflow", Type: config.WorkflowType},
+		newTask(6, "demo-workflow", config.WorkflowType, "pr-1-abc"),
+	}
+
+	var taskIDs []int64
+	for _, t := range prEnvTasks(tasks, prEnv) {
+		taskIDs = append(taskIDs, t.TaskID)
+	}
+	assert.Equal(t, []int64{1, 6}, taskIDs)
+}
+
+func TestTaskFinished(t *testing.T) {
+	for _, status := range []config.Status{config.StatusPassed, config.StatusFailed, config.StatusTimeout, config.StatusCancelled} {
+		assert.True(t, taskFinished(status), status)
+	}
+	for _, status := range []config.Status{config.StatusWaiting, config.StatusQueued, config.StatusCreated, config.StatusRunning, config.StatusBlocked} {
+		assert.False(t, taskFinished(status), status)
+	}
+}
@@ -0,0 +1,100 @@
+/*
+Copyright 2021 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package webhook
+
+import (
+	"path"
+	"strings"
+
+	"go.uber.org/zap"
+
+	"github.com/koderover/zadig/pkg/microservice/aslan/config"
+	commonmodels "github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/models"
+	"github.com/koderover/zadig/pkg/types"
+)
+
+const tagRefPrefix = "refs/tags/"
+
+// tagEventMatcher tag推送和release发布事件, 各个代码源共用
+type tagEventMatcher struct {
+	log       *zap.SugaredLogger
+	workflow  *commonmodels.Workflow
+	eventType config.HookEventType
+	// repoPath owner/repo格式的仓库路径
+	repoPath string
+	tag      string
+}
+
+func (tem *tagEventMatcher) Match(hookRepo *commonmodels.MainHookRepo) (bool, error) {
+	if (hookRepo.RepoOwner + "/" + hookRepo.RepoName) != tem.repoPath {
+		return false, nil
+	}
+	if !EventConfigured(hookRepo, tem.eventType) {
+		return false, nil
+	}
+
+	return MatchTag(hookRepo, tem.tag), nil
+}
+
+func (tem *tagEventMatcher) UpdateTaskArgs(
+	product *commonmodels.Product, args *commonmodels.WorkflowTaskArgs, hookRepo *commonmodels.MainHookRepo, requestID string,
+) *commonmodels.WorkflowTaskArgs {
+	factory := &workflowArgsFactory{
+		workflow: tem.workflow,
+		reqID:    requestID,
+	}
+
+	args = factory.Update(product, args, &types.Repository{
+		CodehostID: hookRepo.CodehostID,
+		RepoName:   hookRepo.RepoName,
+		RepoOwner:  hookRepo.RepoOwner,
+		Tag:        tem.tag,
+	})
+
+	// 使用tag作为交付版本号, 任务成功之后自动创建版本
+	versionArgs := &commonmodels.VersionArgs{}
+	if args.VersionArgs != nil {
+		versionArgs.Desc = args.VersionArgs.Desc
+		versionArgs.Labels = args.VersionArgs.Labels
+	}
+	versionArgs.Enabled = true
+	versionArgs.Version = tem.tag
+	args.VersionArgs = versionArgs
+
+	return args
+}
+
+// MatchTag tag格式为空时匹配所有tag, 否则按照通配符(例如 v*.*.*)匹配
+func MatchTag(m *commonmodels.MainHookRepo, tag string) bool {
+	if tag == "" {
+		return false
+	}
+	if m.TagPattern == "" {
+		return true
+	}
+
+	matched, err := path.Match(m.TagPattern, tag)
+	return err == nil && matched
+}
+
+// getTagFromRef 返回 refs/tags/ 开头的ref对应的tag
+func getTagFromRef(ref string) (string, bool) {
+	if !strings.HasPrefix(ref, tagRefPrefix) {
+		return "", false
+	}
+	return strings.TrimPrefix(ref, tagRefPrefix), true
+}
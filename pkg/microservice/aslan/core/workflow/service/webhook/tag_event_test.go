@@ -0,0 +1,35 @@
+/*
+Copyright 2021 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package webhook
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	commonmodels "github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/models"
+)
+
+func TestMatchTag(t *testing.T) {
+	assert.True(t, MatchTag(&commonmodels.MainHookRepo{}, "v1.0.0"))
+	assert.False(t, MatchTag(&commonmodels.MainHookRepo{}, ""))
+
+	repo := &commonmodels.MainHookRepo{TagPattern: "v*.*.*"}
+	assert.True(t, MatchTag(repo, "v1.2.3"))
+	assert.False(t, MatchTag(repo, "v1.2"))
+	assert.False(t, MatchTag(repo, "release-1.2.3"))
+}
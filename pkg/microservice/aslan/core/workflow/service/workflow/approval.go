@@ -0,0 +1,176 @@
+/*
+Copyright 2021 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package workflow
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+	"k8s.io/apimachinery/pkg/util/sets"
+
+	configbase "github.com/koderover/zadig/pkg/config"
+	"github.com/koderover/zadig/pkg/microservice/aslan/config"
+	commonmodels "github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/models"
+	"github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/models/task"
+	commonrepo "github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/mongodb"
+	commonservice "github.com/koderover/zadig/pkg/microservice/aslan/core/common/service"
+	"github.com/koderover/zadig/pkg/microservice/aslan/core/common/service/base"
+	"github.com/koderover/zadig/pkg/microservice/aslan/core/common/service/wechat"
+	e "github.com/koderover/zadig/pkg/tool/errors"
+)
+
+const (
+	// approvalSubTaskKey 审批stage只有一个subtask
+	approvalSubTaskKey = "approval"
+	// defaultApprovalTimeout 默认等待审批的时间, 单位为分钟
+	defaultApprovalTimeout = 60 * 24
+)
+
+type ApproveArgs struct {
+	Approve bool   `json:"approve"`
+	Comment string `json:"comment"`
+}
+
+func validateApprovalStage(stage *commonmodels.ApprovalStage) error {
+	if stage == nil || !stage.Enabled {
+		return nil
+	}
+	if len(stage.Approvers) == 0 {
+		return fmt.Errorf("approvers of approval stage can not be empty")
+	}
+	if stage.Timeout < 0 {
+		return fmt.Errorf("invalid approval timeout: %d", stage.Timeout)
+	}
+	return nil
+}
+
+func approvalStageToSubTask(stage *commonmodels.ApprovalStage) (map[string]interface{}, error) {
+	timeout := stage.Timeout
+	if timeout == 0 {
+		timeout = defaultApprovalTimeout
+	}
+	approvalTask := &task.Approval{
+		TaskType:    config.TaskApproval,
+		Enabled:     true,
+		Approvers:   stage.Approvers,
+		Description: stage.Description,
+		Timeout:     timeout,
+	}
+	return approvalTask.ToSubTask()
+}
+
+// CreateWorkflowApproval 审批stage开始执行时由warpdrive调用, 创建审批记录并通知审批人
+// 审批人等信息只从任务的审批subtask中获取, 不使用请求中的数据
+func CreateWorkflowApproval(pipelineName string, taskID int64, log *zap.SugaredLogger) error {
+	pt, approvalTask, err := getApprovalSubTask(pipelineName, taskID)
+	if err != nil {
+		log.Errorf("failed to find approval sub task of %s#%d: %v", pipelineName, taskID, err)
+		return e.ErrCreateApproval.AddErr(err)
+	}
+
+	timeout := approvalTask.Timeout
+	if timeout == 0 {
+		timeout = defaultApprovalTimeout
+	}
+	approval := &commonmodels.WorkflowApproval{
+		PipelineName: pt.PipelineName,
+		TaskID:       pt.TaskID,
+		ProductName:  pt.ProductName,
+		Approvers:    approvalTask.Approvers,
+		Description:  approvalTask.Description,
+		Status:       config.ApprovalStatusWaiting,
+		ExpireTime:   time.Now().Unix() + int64(timeout*60),
+	}
+	if err := commonrepo.NewWorkflowApprovalColl().Upsert(approval); err != nil {
+		log.Errorf("WorkflowApproval.Upsert %s#%d error: %v", pipelineName, taskID, err)
+		return e.ErrCreateApproval.AddErr(err)
+	}
+
+	notifyApprovers(approval, log)
+	return nil
+}
+
+func getApprovalSubTask(pipelineName string, taskID int64) (*task.Task, *task.Approval, error) {
+	pt, err := commonrepo.NewTaskColl().Find(taskID, pipelineName, config.WorkflowType)
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, stage := range pt.Stages {
+		if stage.TaskType != config.TaskApproval {
+			continue
+		}
+		if subTask, ok := stage.SubTasks[approvalSubTaskKey]; ok {
+			approvalTask, err := base.ToApprovalTask(subTask)
+			return pt, approvalTask, err
+		}
+	}
+	return nil, nil, fmt.Errorf("approval stage not found")
+}
+
+func GetWorkflowApproval(pipelineName string, taskID int64, log *zap.SugaredLogger) (*commonmodels.WorkflowApproval, error) {
+	approval, err := commonrepo.NewWorkflowApprovalColl().Find(pipelineName, taskID)
+	if err != nil {
+		log.Errorf("WorkflowApproval.Find %s#%d error: %v", pipelineName, taskID, err)
+		return nil, e.ErrGetApproval.AddErr(err)
+	}
+	return approval, nil
+}
+
+// ApproveWorkflowTask 审批人通过或者拒绝工作流任务, warpdrive轮询到结果后继续或者终止任务
+func ApproveWorkflowTask(username, pipelineName string, taskID int64, args *ApproveArgs, log *zap.SugaredLogger) error {
+	approval, err := GetWorkflowApproval(pipelineName, taskID, log)
+	if err != nil {
+		return err
+	}
+	if !sets.NewString(approval.Approvers...).Has(username) {
+		return e.ErrNotApprover
+	}
+
+	status := config.ApprovalStatusRejected
+	if args.Approve {
+		status = config.ApprovalStatusApproved
+	}
+	if err := commonrepo.NewWorkflowApprovalColl().UpdateStatus(pipelineName, taskID, status, username, args.Comment); err != nil {
+		log.Errorf("WorkflowApproval.UpdateStatus %s#%d error: %v", pipelineName, taskID, err)
+		return e.ErrApprove.AddErr(err)
+	}
+
+	log.Infof("workflow task %s#%d is %s by %s", pipelineName, taskID, status, username)
+	return nil
+}
+
+func notifyApprovers(approval *commonmodels.WorkflowApproval, log *zap.SugaredLogger) {
+	title := fmt.Sprintf("工作流 %s#%d 等待审批", approval.PipelineName, approval.TaskID)
+	url := fmt.Sprintf("%s/v1/projects/detail/%s/pipelines/multi/%s/%d", configbase.SystemAddress(), approval.ProductName, approval.PipelineName, approval.TaskID)
+	content := fmt.Sprintf("#### %s \n- 审批说明：%s \n- 审批人：%s \n- 截止时间：%s \n- 详情：[%s](%s) \n",
+		title,
+		approval.Description,
+		strings.Join(approval.Approvers, ", "),
+		time.Unix(approval.ExpireTime, 0).Format("2006-01-02 15:04:05"),
+		url, url,
+	)
+
+	for _, approver := range approval.Approvers {
+		commonservice.SendMessage(approver, title, content, "", log)
+	}
+
+	if err := wechat.NewWeChatClient().SendApprovalMessage(approval.PipelineName, title, content); err != nil {
+		log.Errorf("SendApprovalMessage %s#%d error: %v", approval.PipelineName, approval.TaskID, err)
+	}
+}
@@ -0,0 +1,54 @@
+/*
+Copyright 2021 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package workflow
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/koderover/zadig/pkg/microservice/aslan/config"
+	commonmodels "github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/models"
+	"github.com/koderover/zadig/pkg/microservice/aslan/core/common/service/base"
+)
+
+var _ = Describe("Testing approval", func() {
+
+	Context("validateApprovalStage", func() {
+		It("should be passed when approval stage is disabled", func() {
+			Expect(validateApprovalStage(nil)).ShouldNot(HaveOccurred())
+			Expect(validateApprovalStage(&commonmodels.ApprovalStage{})).ShouldNot(HaveOccurred())
+		})
+		It("should raise error when approvers are empty", func() {
+			err := validateApprovalStage(&commonmodels.ApprovalStage{Enabled: true})
+			Expect(err).Should(HaveOccurred())
+		})
+	})
+
+	Context("approvalStageToSubTask", func() {
+		It("should use default timeout", func() {
+			subTask, err := approvalStageToSubTask(&commonmodels.ApprovalStage{Enabled: true, Approvers: []string{"admin"}})
+			Expect(err).ShouldNot(HaveOccurred())
+
+			approval, err := base.ToApprovalTask(subTask)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(approval.TaskType).To(Equal(config.TaskApproval))
+			Expect(approval.Enabled).To(BeTrue())
+			Expect(approval.Approvers).To(Equal([]string{"admin"}))
+			Expect(approval.Timeout).To(Equal(defaultApprovalTimeout))
+		})
+	})
+})
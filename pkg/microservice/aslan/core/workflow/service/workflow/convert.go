@@ -30,7 +30,7 @@ import (
 )
 
 func ConvertQueueToTask(queueTask *commonmodels.Queue) *task.Task {
-	return &task.Task{
+	t := &task.Task{
 		TaskID:          queueTask.TaskID,
 		ProductName:     queueTask.ProductName,
 		PipelineName:    queueTask.PipelineName,
@@ -72,11 +72,15 @@ func ConvertQueueToTask(queueTask *commonmodels.Queue) *task.Task {
 		Features:        queueTask.Features,
 		IsRestart:       queueTask.IsRestart,
 		StorageEndpoint: queueTask.StorageEndpoint,
+		DAGEnabled:      queueTask.DAGEnabled,
+		Priority:        queueTask.Priority,
 	}
+	t.RollbackOnFailure = queueTask.RollbackOnFailure
+	return t
 }
 
 func ConvertTaskToQueue(task *task.Task) *commonmodels.Queue {
-	return &commonmodels.Queue{
+	queue := &commonmodels.Queue{
 		TaskID:          task.TaskID,
 		ProductName:     task.ProductName,
 		PipelineName:    task.PipelineName,
@@ -118,7 +122,11 @@ func ConvertTaskToQueue(task *task.Task) *commonmodels.Queue {
 		Features:        task.Features,
 		IsRestart:       task.IsRestart,
 		StorageEndpoint: task.StorageEndpoint,
+		DAGEnabled:      task.DAGEnabled,
+		Priority:        task.Priority,
 	}
+	queue.RollbackOnFailure = task.RollbackOnFailure
+	return queue
 }
 
 type JenkinsBuildOption struct {
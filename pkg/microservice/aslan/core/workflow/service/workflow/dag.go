@@ -0,0 +1,147 @@
+/*
+Copyright 2021 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package workflow
+
+import (
+	"fmt"
+
+	"github.com/koderover/zadig/pkg/microservice/aslan/config"
+	commonmodels "github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/models"
+	"github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/models/task"
+)
+
+// defaultStageDependencies 工作流开启DAG但没有自定义依赖时使用的stage依赖关系
+// 部署/安全扫描/分发只等待同一个服务组件的构建, 测试等待全部构建和部署完成, 部署还需要等待审批通过
+var defaultStageDependencies = map[config.TaskType][]*commonmodels.StageDependency{
+	config.TaskApproval: {
+		{TaskType: config.TaskBuild},
+		{TaskType: config.TaskJenkinsBuild},
+	},
+	config.TaskDeploy: {
+		{TaskType: config.TaskBuild, SameTarget: true},
+		{TaskType: config.TaskJenkinsBuild, SameTarget: true},
+		{TaskType: config.TaskApproval},
+	},
+	config.TaskSecurity: {
+		{TaskType: config.TaskBuild, SameTarget: true},
+		{TaskType: config.TaskJenkinsBuild, SameTarget: true},
+	},
+	config.TaskDistributeToS3: {
+		{TaskType: config.TaskBuild, SameTarget: true},
+	},
+	config.TaskTestingV2: {
+		{TaskType: config.TaskBuild},
+		{TaskType: config.TaskJenkinsBuild},
+		{TaskType: config.TaskDeploy},
+	},
+	config.TaskReleaseImage: {
+		{TaskType: config.TaskBuild, SameTarget: true},
+		{TaskType: config.TaskJenkinsBuild, SameTarget: true},
+		{TaskType: config.TaskSecurity, SameTarget: true},
+		{TaskType: config.TaskTestingV2},
+	},
+}
+
+// setStageDependencies 根据工作流的DAG配置设置任务中各个stage的依赖, 依赖不存在的stage会被忽略
+func setStageDependencies(pt *task.Task, dag *commonmodels.WorkflowDAG) {
+	if dag == nil || !dag.Enabled {
+		return
+	}
+
+	dependencies := make(map[config.TaskType][]*commonmodels.StageDependency)
+	for taskType, deps := range defaultStageDependencies {
+		dependencies[taskType] = deps
+	}
+	for _, stage := range dag.Stages {
+		dependencies[stage.TaskType] = stage.DependsOn
+	}
+
+	existing := make(map[config.TaskType]bool)
+	for _, stage := range pt.Stages {
+		existing[stage.TaskType] = true
+	}
+
+	for _, stage := range pt.Stages {
+		stage.DependsOn = nil
+		if stage.AfterAll {
+			continue
+		}
+		for _, dep := range dependencies[stage.TaskType] {
+			if existing[dep.TaskType] && dep.TaskType != stage.TaskType {
+				stage.DependsOn = append(stage.DependsOn, dep)
+			}
+		}
+	}
+	pt.DAGEnabled = true
+}
+
+// validateWorkflowDAG 检查自定义的stage依赖关系中是否存在环
+func validateWorkflowDAG(dag *commonmodels.WorkflowDAG) error {
+	if dag == nil || !dag.Enabled {
+		return nil
+	}
+
+	dependencies := make(map[config.TaskType][]config.TaskType)
+	for taskType, deps := range defaultStageDependencies {
+		for _, dep := range deps {
+			dependencies[taskType] = append(dependencies[taskType], dep.TaskType)
+		}
+	}
+	custom := make(map[config.TaskType]bool)
+	for _, stage := range dag.Stages {
+		if custom[stage.TaskType] {
+			return fmt.Errorf("duplicated dag stage found: %s", stage.TaskType)
+		}
+		custom[stage.TaskType] = true
+
+		dependencies[stage.TaskType] = nil
+		for _, dep := range stage.DependsOn {
+			if dep.TaskType == stage.TaskType {
+				return fmt.Errorf("stage %s can not depend on itself", stage.TaskType)
+			}
+			dependencies[stage.TaskType] = append(dependencies[stage.TaskType], dep.TaskType)
+		}
+	}
+
+	// 0: 未访问, 1: 访问中, 2: 已完成
+	visited := make(map[config.TaskType]int)
+	var visit func(taskType config.TaskType) error
+	visit = func(taskType config.TaskType) error {
+		switch visited[taskType] {
+		case 1:
+			return fmt.Errorf("circular dependency found at stage %s", taskType)
+		case 2:
+			return nil
+		}
+		visited[taskType] = 1
+		for _, dep := range dependencies[taskType] {
+			if err := visit(dep); err != nil {
+				return err
+			}
+		}
+		visited[taskType] = 2
+		return nil
+	}
+
+	for taskType := range dependencies {
+		if err := visit(taskType); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,79 @@
+/*
+Copyright 2021 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package workflow
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/koderover/zadig/pkg/microservice/aslan/config"
+	commonmodels "github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/models"
+	"github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/models/task"
+)
+
+var _ = Describe("Testing dag", func() {
+
+	Context("validateWorkflowDAG", func() {
+		It("should be passed for default dependencies", func() {
+			err := validateWorkflowDAG(&commonmodels.WorkflowDAG{Enabled: true})
+			Expect(err).ShouldNot(HaveOccurred())
+		})
+		It("should raise error for circular dependencies", func() {
+			err := validateWorkflowDAG(&commonmodels.WorkflowDAG{
+				Enabled: true,
+				Stages: []*commonmodels.DAGStage{
+					{TaskType: config.TaskBuild, DependsOn: []*commonmodels.StageDependency{{TaskType: config.TaskTestingV2}}},
+				},
+			})
+			Expect(err).Should(HaveOccurred())
+		})
+		It("should raise error for self dependency", func() {
+			err := validateWorkflowDAG(&commonmodels.WorkflowDAG{
+				Enabled: true,
+				Stages: []*commonmodels.DAGStage{
+					{TaskType: config.TaskBuild, DependsOn: []*commonmodels.StageDependency{{TaskType: config.TaskBuild}}},
+				},
+			})
+			Expect(err).Should(HaveOccurred())
+		})
+	})
+
+	Context("setStageDependencies", func() {
+		It("should only keep dependencies on existing stages", func() {
+			pt := &task.Task{
+				Stages: []*commonmodels.Stage{
+					{TaskType: config.TaskBuild},
+					{TaskType: config.TaskDeploy},
+					{TaskType: config.TaskTestingV2},
+				},
+			}
+			setStageDependencies(pt, &commonmodels.WorkflowDAG{Enabled: true})
+
+			Expect(pt.DAGEnabled).To(BeTrue())
+			Expect(pt.Stages[0].DependsOn).To(BeEmpty())
+			Expect(pt.Stages[1].DependsOn).To(Equal([]*commonmodels.StageDependency{{TaskType: config.TaskBuild, SameTarget: true}}))
+			Expect(pt.Stages[2].DependsOn).To(HaveLen(2))
+		})
+		It("should do nothing when dag is disabled", func() {
+			pt := &task.Task{Stages: []*commonmodels.Stage{{TaskType: config.TaskDeploy}}}
+			setStageDependencies(pt, nil)
+
+			Expect(pt.DAGEnabled).To(BeFalse())
+			Expect(pt.Stages[0].DependsOn).To(BeNil())
+		})
+	})
+})
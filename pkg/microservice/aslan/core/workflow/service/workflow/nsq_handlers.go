@@ -45,6 +45,7 @@ import (
 	"github.com/koderover/zadig/pkg/microservice/aslan/core/common/service/notify"
 	"github.com/koderover/zadig/pkg/microservice/aslan/core/common/service/registry"
 	"github.com/koderover/zadig/pkg/microservice/aslan/core/common/service/s3"
+	"github.com/koderover/zadig/pkg/microservice/aslan/core/common/service/scmnotify"
 	"github.com/koderover/zadig/pkg/setting"
 	"github.com/koderover/zadig/pkg/shared/poetry"
 	"github.com/koderover/zadig/pkg/tool/git/gitlab"
@@ -160,6 +161,14 @@ func (h *TaskAckHandler) handle(message *nsq.Message) error {
 		return nil
 	}
 
+	// 任务运行过程中stage状态变化时更新代码源上的commit status, 任务结束时的状态由通知统一上报
+	// 同步上报并且在上报前确认任务没有结束, 以免运行中的状态覆盖最终状态
+	if pt.Type == config.WorkflowType && pt.Status == config.StatusRunning && isStageStatusChanged(taskInColl, pt) {
+		if latest, err := h.ptColl.Find(pt.TaskID, pt.PipelineName, pt.Type); err == nil && !isTaskFinished(latest.Status) {
+			_ = scmnotify.NewService().UpdateCommitStatus(pt, h.log)
+		}
+	}
+
 	// 如果任务完成：成功、失败、超时
 	if pt.Status == config.StatusPassed || pt.Status == config.StatusFailed || pt.Status == config.StatusTimeout {
 		h.log.Infof("%s:%d:%v task done", pt.PipelineName, pt.TaskID, pt.Status)
@@ -197,7 +206,8 @@ func (h *TaskAckHandler) handle(message *nsq.Message) error {
 	}
 
 	for _, deploy := range deploys {
-		if deploy.Enabled && !pt.ResetImage {
+		// 回滚成功的部署没有生效, 环境中仍然是原来的镜像
+		if deploy.Enabled && !pt.ResetImage && !deploy.RolledBack() {
 			if err := h.updateProductImageByNs(deploy.Namespace, deploy.ProductName, deploy.ServiceName, deploy.ContainerName, deploy.Image); err != nil {
 				h.log.Errorf("updateProductImage %v error: %v", deploy, err)
 				continue
@@ -215,6 +225,36 @@ func (h *TaskAckHandler) handle(message *nsq.Message) error {
 	return nil
 }
 
+func isTaskFinished(status config.Status) bool {
+	return status == config.StatusPassed || status == config.StatusFailed || status == config.StatusTimeout || status == config.StatusCancelled
+}
+
+// isStageStatusChanged 任务状态或者任一stage的状态发生变化时返回true
+func isStageStatusChanged(oldTask, newTask *task.Task) bool {
+	if oldTask.Status != newTask.Status || len(oldTask.Stages) != len(newTask.Stages) {
+		return true
+	}
+	for i, stage := range newTask.Stages {
+		if oldTask.Stages[i].Status != stage.Status {
+			return true
+		}
+	}
+	return false
+}
+
+// imageSBOMFormat 返回构建镜像时生成的SBOM格式, 未生成时返回空
+func imageSBOMFormat(buildInfo *task.Build) string {
+	if buildInfo.JobCtx.DockerBuildCtx != nil && buildInfo.JobCtx.DockerBuildCtx.SBOMFormat != "" {
+		return buildInfo.JobCtx.DockerBuildCtx.SBOMFormat
+	}
+	for _, step := range buildInfo.JobCtx.Steps {
+		if step.Type == setting.BuildStepDockerBuild && step.DockerBuild != nil && step.DockerBuild.SBOMFormat != "" {
+			return step.DockerBuild.SBOMFormat
+		}
+	}
+	return ""
+}
+
 func (h *TaskAckHandler) uploadTaskData(pt *task.Task) error {
 	deliveryArtifacts := make([]*commonmodels.DeliveryArtifact, 0)
 	if pt.Type == config.WorkflowType {
@@ -323,6 +363,14 @@ func (h *TaskAckHandler) uploadTaskData(pt *task.Task) error {
 									}
 								}
 							}
+							if format := imageSBOMFormat(buildInfo); format != "" {
+								sbom, err := commonservice.GetTaskSBOM(pt.StorageURI, pt.PipelineName, pt.TaskID, image, format)
+								if err != nil {
+									h.log.Errorf("uploadTaskData GetTaskSBOM err:%v", err)
+								} else {
+									deliveryArtifact.SBOM = sbom
+								}
+							}
 							deliveryArtifactArray = append(deliveryArtifactArray, deliveryArtifact)
 						}
 						for _, deliveryArtifact := range deliveryArtifactArray {
@@ -432,6 +480,8 @@ func (h *TaskAckHandler) uploadTaskData(pt *task.Task) error {
 							continue
 						}
 
+						commonservice.RecordTestCoverage(pt.PipelineName, pt.TaskID, testInfo, h.log)
+
 						if testInfo.JobCtx.TestType == setting.FunctionTestType {
 							testTaskStat, _ = h.TestTaskStatColl.FindTestTaskStat(&commonrepo.TestTaskStatOption{Name: testInfo.TestModuleName})
 							if testTaskStat == nil {
@@ -483,6 +533,7 @@ func (h *TaskAckHandler) uploadTaskData(pt *task.Task) error {
 							if totalCaseNum != 0 {
 								testTaskStat.TestCaseNum = totalCaseNum
 							}
+							commonservice.RecordTestCaseResults(testInfo.TestModuleName, testReport, h.log)
 							testTaskStat.TotalDuration += testInfo.EndTime - testInfo.StartTime
 						}
 
@@ -605,6 +656,8 @@ func (h *TaskAckHandler) uploadTaskData(pt *task.Task) error {
 						continue
 					}
 
+					commonservice.RecordTestCoverage(pt.PipelineName, pt.TaskID, testInfo, h.log)
+
 					if testInfo.JobCtx.TestType == setting.FunctionTestType {
 						isNew := false
 						testTaskStat, _ := h.TestTaskStatColl.FindTestTaskStat(&commonrepo.TestTaskStatOption{Name: testInfo.TestModuleName})
@@ -655,6 +708,7 @@ func (h *TaskAckHandler) uploadTaskData(pt *task.Task) error {
 						if totalCaseNum != 0 {
 							testTaskStat.TestCaseNum = totalCaseNum
 						}
+						commonservice.RecordTestCaseResults(testInfo.TestModuleName, testReport, h.log)
 						testTaskStat.TotalDuration += testInfo.EndTime - testInfo.StartTime
 
 						if taskStatus == config.StatusPassed {
@@ -20,8 +20,10 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"strconv"
 	"time"
 
+	appsv1 "k8s.io/api/apps/v1"
 	"k8s.io/apimachinery/pkg/util/sets"
 
 	configbase "github.com/koderover/zadig/pkg/config"
@@ -114,6 +116,7 @@ func PendingTasks() []*task.Task {
 			tasks = append(tasks, t)
 		}
 	}
+	sortQueuedTasks(tasks, nil)
 	return tasks
 }
 
@@ -237,6 +240,7 @@ func Push(pt *task.Task) error {
 	if pt == nil {
 		return errors.New("nil task")
 	}
+	pt.Priority = taskPriority(pt)
 
 	if !pt.MultiRun {
 		opt := &commonrepo.ListQueueOption{
@@ -292,7 +296,8 @@ func PipelineTaskSender() {
 
 		//c.checkAgents()
 		if hasAgentAvaiable() {
-			t, err := NextWaitingTask()
+			scheduler := newTaskScheduler()
+			t, err := NextWaitingTask(scheduler)
 			if err != nil {
 				// no waiting task found
 				blockTasks, err := BlockedTaskQueue()
@@ -300,26 +305,30 @@ func PipelineTaskSender() {
 					//no blocked task found
 					continue
 				}
-				for _, blockTask := range blockTasks {
-					if hasAgentAvaiable() {
-						runningTasksMap := make(map[string]bool)
-						for _, running := range RunningAndQueuedTasks() {
-							runningTasksMap[running.PipelineName] = true
-						}
+				// 每调度一个任务后重新排序, 保证同优先级的任务在项目之间轮转
+				for len(blockTasks) > 0 {
+					blockTask, ok := scheduler.next(blockTasks)
+					if !ok || !hasAgentAvaiable() {
+						break
+					}
+					blockTasks = removeTask(blockTasks, blockTask)
 
-						if _, ok := runningTasksMap[blockTask.PipelineName]; ok {
-							//判断相同的工作流是否有相同的服务需要更新
-							if !ParallelRunningAndQueuedTasks(blockTask) {
-								continue
-							}
-						}
-						// update agent and queue
-						if err := updateAgentAndQueue(blockTask); err != nil {
+					runningTasksMap := make(map[string]bool)
+					for _, running := range RunningAndQueuedTasks() {
+						runningTasksMap[running.PipelineName] = true
+					}
+
+					if _, ok := runningTasksMap[blockTask.PipelineName]; ok {
+						//判断相同的工作流是否有相同的服务需要更新
+						if !ParallelRunningAndQueuedTasks(blockTask) {
 							continue
 						}
-					} else {
-						break
 					}
+					// update agent and queue
+					if err := updateAgentAndQueue(blockTask); err != nil {
+						continue
+					}
+					scheduler.started(blockTask)
 				}
 				continue
 			}
@@ -331,6 +340,15 @@ func PipelineTaskSender() {
 	}
 }
 
+func removeTask(tasks []*task.Task, t *task.Task) []*task.Task {
+	for i := range tasks {
+		if tasks[i] == t {
+			return append(tasks[:i], tasks[i+1:]...)
+		}
+	}
+	return tasks
+}
+
 func hasAgentAvaiable() bool {
 	kubeClient := krkubeclient.Client()
 	deployment, _, err := getter.GetDeployment(config.Namespace(), configbase.WarpDriveServiceName(), kubeClient)
@@ -339,7 +357,22 @@ func hasAgentAvaiable() bool {
 		return false
 	}
 
-	return len(RunningAndQueuedTasks()) < int(deployment.Status.ReadyReplicas)
+	return len(RunningAndQueuedTasks()) < int(deployment.Status.ReadyReplicas)*warpDriveTaskSlots(deployment)
+}
+
+// warpDriveTaskSlots 每个warpdrive实例可以同时执行的任务数, 与warpdrive读取的WD_TASK_SLOTS保持一致, 未配置时为1
+func warpDriveTaskSlots(deployment *appsv1.Deployment) int {
+	for _, container := range deployment.Spec.Template.Spec.Containers {
+		for _, env := range container.Env {
+			if env.Name != setting.WarpDriveTaskSlots {
+				continue
+			}
+			if slots, err := strconv.Atoi(env.Value); err == nil && slots > 0 {
+				return slots
+			}
+		}
+	}
+	return 1
 }
 
 func RunningAndQueuedTasks() []*task.Task {
@@ -370,23 +403,27 @@ func ListTasks() []*task.Task {
 	return tasks
 }
 
-// NextWaitingTask 查询下一个等待的task
-func NextWaitingTask() (*task.Task, error) {
+// NextWaitingTask 查询下一个等待的task, 按照优先级和项目并发上限选择
+func NextWaitingTask(scheduler *taskScheduler) (*task.Task, error) {
 	opt := &commonrepo.ListQueueOption{
 		Status: config.StatusWaiting,
 	}
 
-	tasks, err := commonrepo.NewQueueColl().List(opt)
+	queues, err := commonrepo.NewQueueColl().List(opt)
 	if err != nil {
 		return nil, err
 	}
 
-	for _, t := range tasks {
-		if t.AgentID == "" {
-			return ConvertQueueToTask(t), nil
+	tasks := make([]*task.Task, 0, len(queues))
+	for _, queue := range queues {
+		if queue.AgentID == "" {
+			tasks = append(tasks, ConvertQueueToTask(queue))
 		}
 	}
 
+	if t, ok := scheduler.next(tasks); ok {
+		return t, nil
+	}
 	return nil, errors.New("no waiting task found")
 }
 
@@ -0,0 +1,46 @@
+/*
+Copyright 2021 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package workflow
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+
+	"github.com/koderover/zadig/pkg/setting"
+)
+
+var _ = Describe("Testing pipeline controller", func() {
+
+	Context("warpDriveTaskSlots", func() {
+		newDeployment := func(env ...corev1.EnvVar) *appsv1.Deployment {
+			deployment := &appsv1.Deployment{}
+			deployment.Spec.Template.Spec.Containers = []corev1.Container{{Name: "warpdrive", Env: env}}
+			return deployment
+		}
+
+		It("should read the slots from the warpdrive container", func() {
+			Expect(warpDriveTaskSlots(newDeployment(corev1.EnvVar{Name: setting.WarpDriveTaskSlots, Value: "4"}))).To(Equal(4))
+		})
+		It("should fall back to one slot", func() {
+			Expect(warpDriveTaskSlots(newDeployment())).To(Equal(1))
+			Expect(warpDriveTaskSlots(newDeployment(corev1.EnvVar{Name: setting.WarpDriveTaskSlots, Value: "0"}))).To(Equal(1))
+			Expect(warpDriveTaskSlots(newDeployment(corev1.EnvVar{Name: setting.WarpDriveTaskSlots, Value: "x"}))).To(Equal(1))
+		})
+	})
+})
@@ -59,6 +59,7 @@ func (q *Queue) Push(pt *task.Task) error {
 	if pt == nil {
 		return errors.New("nil task")
 	}
+	pt.Priority = taskPriority(pt)
 
 	if !pt.MultiRun {
 		opt := &commonrepo.ListQueueOption{
@@ -431,6 +431,9 @@ func RestartPipelineTaskV2(userName string, taskID int64, pipelineName string, t
 								}
 							}
 							buildInfo.JobCtx.Caches = newBuildInfo.Caches
+							buildInfo.JobCtx.CacheType = newBuildInfo.CacheType
+							buildInfo.JobCtx.CacheKeyFiles = newBuildInfo.CacheKeyFiles
+							buildInfo.JobCtx.Steps = buildTaskSteps(newBuildInfo.Steps, buildInfo.JobCtx.Image)
 							// 设置 build 安装脚本
 							buildInfo.InstallCtx, err = buildInstallCtx(buildInfo.InstallItems)
 							if err != nil {
@@ -573,6 +576,15 @@ func TestArgsToTestSubtask(args *commonmodels.TestTaskArgs, pt *task.Task, log *
 
 	testTask.JobCtx.TestResultPath = testModule.TestResultPath
 	testTask.JobCtx.TestThreshold = testModule.Threshold
+	testTask.JobCtx.QuarantinedCases = commonservice.ListQuarantinedTestCases(testModule.Name, log)
+	testTask.JobCtx.CoverageReportPath = testModule.CoverageReportPath
+	testTask.JobCtx.CoverageFormat = testModule.CoverageFormat
+	testTask.JobCtx.CoveragePolicy = testModule.CoveragePolicy
+	testTask.JobCtx.BaseLineRate = commonservice.GetBaseLineRate(testModule.Name, testModule.CoveragePolicy, log)
+	testTask.JobCtx.ShardCount = testModule.ShardCount
+	if testModule.ShardStrategy == setting.TestShardByTiming {
+		testTask.JobCtx.TestShards = commonservice.SplitTestShardsByTiming(testModule.Name, testModule.ShardCount, log)
+	}
 	testTask.JobCtx.Caches = testModule.Caches
 	testTask.JobCtx.ArtifactPaths = testModule.ArtifactPaths
 	if testTask.Registries == nil {
@@ -47,7 +47,9 @@ import (
 	"github.com/koderover/zadig/pkg/setting"
 	"github.com/koderover/zadig/pkg/shared/codehost"
 	"github.com/koderover/zadig/pkg/shared/kube/wrapper"
+	"github.com/koderover/zadig/pkg/tool/bitbucket"
 	e "github.com/koderover/zadig/pkg/tool/errors"
+	"github.com/koderover/zadig/pkg/tool/gitea"
 	"github.com/koderover/zadig/pkg/tool/kube/getter"
 	"github.com/koderover/zadig/pkg/tool/log"
 	"github.com/koderover/zadig/pkg/types"
@@ -361,6 +363,14 @@ func setBuildInfo(build *types.Repository, log *zap.SugaredLogger) {
 				}
 			}
 		}
+	} else if codeHostInfo.Type == codehost.GiteaProvider {
+		if build.CommitID == "" {
+			setGiteaBuildInfo(gitea.NewClient(codeHostInfo.Address, codeHostInfo.AccessToken), build, log)
+		}
+	} else if codeHostInfo.Type == codehost.BitbucketProvider {
+		if build.CommitID == "" {
+			setBitbucketBuildInfo(bitbucket.NewClient(codeHostInfo.Address, codeHostInfo.AccessToken), build, log)
+		}
 	} else {
 		gitCli := git.NewClient(codeHostInfo.AccessToken, config.ProxyHTTPSAddr())
 		//// 需要后端自动获取Branch当前Commit，并填写到build中
@@ -428,6 +438,75 @@ func setBuildInfo(build *types.Repository, log *zap.SugaredLogger) {
 
 }
 
+func setGiteaBuildInfo(client *gitea.Client, build *types.Repository, log *zap.SugaredLogger) {
+	if build.PR > 0 {
+		pr, err := client.GetPullRequest(build.RepoOwner, build.RepoName, build.PR)
+		if err != nil || pr.Head == nil {
+			log.Warnf("gitea setBuildInfo failed, use build %+v %v", build, err)
+			return
+		}
+		build.CommitID = pr.Head.Sha
+		build.CommitMessage = pr.Title
+		if pr.Poster != nil {
+			build.AuthorName = pr.Poster.UserName
+		}
+	} else if build.Tag != "" {
+		tags, err := client.ListTags(build.RepoOwner, build.RepoName)
+		if err != nil {
+			log.Warnf("gitea setBuildInfo failed, use build %+v %v", build, err)
+			return
+		}
+		for _, tag := range tags {
+			if tag.Name == build.Tag && tag.Commit != nil {
+				build.CommitID = tag.Commit.SHA
+				build.CommitMessage = tag.Message
+				return
+			}
+		}
+	} else if build.Branch != "" {
+		branch, err := client.GetBranch(build.RepoOwner, build.RepoName, build.Branch)
+		if err != nil || branch.Commit == nil {
+			log.Warnf("gitea setBuildInfo failed, use build %+v %v", build, err)
+			return
+		}
+		build.CommitID = branch.Commit.ID
+		build.CommitMessage = branch.Commit.Message
+		if branch.Commit.Author != nil {
+			build.AuthorName = branch.Commit.Author.Name
+		}
+	}
+}
+
+// setBitbucketBuildInfo bitbucket server的分支和tag中只有commit id, 没有提交信息
+func setBitbucketBuildInfo(client *bitbucket.Client, build *types.Repository, log *zap.SugaredLogger) {
+	if build.PR > 0 {
+		pr, err := client.GetPullRequest(build.RepoOwner, build.RepoName, build.PR)
+		if err != nil || pr.FromRef == nil {
+			log.Warnf("bitbucket setBuildInfo failed, use build %+v %v", build, err)
+			return
+		}
+		build.CommitID = pr.FromRef.LatestCommit
+		build.CommitMessage = pr.Title
+		if pr.Author != nil && pr.Author.User != nil {
+			build.AuthorName = pr.Author.User.Name
+		}
+	} else if build.Tag != "" {
+		tag, err := client.GetTag(build.RepoOwner, build.RepoName, build.Tag)
+		if err != nil {
+			log.Warnf("bitbucket setBuildInfo failed, use build %+v %v", build, err)
+			return
+		}
+		build.CommitID = tag.LatestCommit
+	} else if build.Branch != "" {
+		branch, err := client.GetBranch(build.RepoOwner, build.RepoName, build.Branch)
+		if err != nil {
+			log.Warnf("bitbucket setBuildInfo failed, use build %+v %v", build, err)
+			return
+		}
+		build.CommitID = branch.LatestCommit
+	}
+}
+
 // 根据传入的build arg设置build参数
 func setBuildFromArg(build, buildArg *types.Repository) {
 	// 单pr编译
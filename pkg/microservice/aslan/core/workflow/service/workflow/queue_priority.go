@@ -0,0 +1,204 @@
+/*
+Copyright 2021 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package workflow
+
+import (
+	"fmt"
+	"sort"
+
+	"go.uber.org/zap"
+
+	"github.com/koderover/zadig/pkg/microservice/aslan/config"
+	"github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/models/task"
+	commonrepo "github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/mongodb"
+	templaterepo "github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/mongodb/template"
+	"github.com/koderover/zadig/pkg/setting"
+	"github.com/koderover/zadig/pkg/shared/poetry"
+	e "github.com/koderover/zadig/pkg/tool/errors"
+)
+
+// taskPriority 未指定优先级的任务按照触发方式确定优先级: 手动 > webhook > 定时
+func taskPriority(t *task.Task) int {
+	if t.Priority > 0 {
+		return t.Priority
+	}
+	switch t.TaskCreator {
+	case setting.CronTaskCreator:
+		return config.TaskPriorityCron
+	case setting.WebhookTaskCreator:
+		return config.TaskPriorityWebhook
+	default:
+		return config.TaskPriorityManual
+	}
+}
+
+// sortQueuedTasks 按照调度顺序排序: 优先级高的任务优先, 优先级相同时正在运行任务少的项目优先, 最后按照创建时间
+func sortQueuedTasks(tasks []*task.Task, running map[string]int) {
+	sort.SliceStable(tasks, func(i, j int) bool {
+		pi, pj := taskPriority(tasks[i]), taskPriority(tasks[j])
+		if pi != pj {
+			return pi > pj
+		}
+		ri, rj := running[tasks[i].ProductName], running[tasks[j].ProductName]
+		if ri != rj {
+			return ri < rj
+		}
+		return tasks[i].CreateTime < tasks[j].CreateTime
+	})
+}
+
+// taskScheduler 记录一轮调度中各项目正在运行的任务数和项目的并发上限
+type taskScheduler struct {
+	running map[string]int
+	limits  map[string]int
+}
+
+func newTaskScheduler() *taskScheduler {
+	s := &taskScheduler{
+		running: make(map[string]int),
+		limits:  make(map[string]int),
+	}
+	for _, t := range RunningAndQueuedTasks() {
+		s.running[t.ProductName]++
+	}
+	return s
+}
+
+func (s *taskScheduler) limit(productName string) int {
+	if limit, ok := s.limits[productName]; ok {
+		return limit
+	}
+
+	limit := 0
+	if product, err := templaterepo.NewProductColl().Find(productName); err == nil {
+		limit = product.MaxConcurrentTasks
+	}
+	s.limits[productName] = limit
+	return limit
+}
+
+// allowed 项目正在运行的任务数达到上限后, 该项目的任务继续排队
+func (s *taskScheduler) allowed(t *task.Task) bool {
+	limit := s.limit(t.ProductName)
+	return limit <= 0 || s.running[t.ProductName] < limit
+}
+
+// next 返回当前可以调度的优先级最高的任务
+func (s *taskScheduler) next(tasks []*task.Task) (*task.Task, bool) {
+	sortQueuedTasks(tasks, s.running)
+	for _, t := range tasks {
+		if s.allowed(t) {
+			return t, true
+		}
+	}
+	return nil, false
+}
+
+func (s *taskScheduler) started(t *task.Task) {
+	s.running[t.ProductName]++
+}
+
+// clampTaskPriority 将优先级限制在[TaskPriorityMin, TaskPriorityMax]范围内, 0表示按照触发方式设置
+func clampTaskPriority(priority int) int {
+	if priority <= 0 {
+		return 0
+	}
+	if priority < config.TaskPriorityMin {
+		return config.TaskPriorityMin
+	}
+	if priority > config.TaskPriorityMax {
+		return config.TaskPriorityMax
+	}
+	return priority
+}
+
+// checkTaskPriority 高于手动触发默认优先级的任务会插队, 只有项目管理员可以设置
+func checkTaskPriority(productName string, priority, userID int, superUser bool, log *zap.SugaredLogger) error {
+	if priority <= config.TaskPriorityManual || isProjectAdmin(productName, userID, superUser, log) {
+		return nil
+	}
+	return fmt.Errorf("只有项目管理员可以设置高于%d的优先级", config.TaskPriorityManual)
+}
+
+func isProjectAdmin(productName string, userID int, superUser bool, log *zap.SugaredLogger) bool {
+	if superUser {
+		return true
+	}
+
+	poetryCtl := poetry.New(config.PoetryAPIServer(), config.PoetryAPIRootKey())
+	productNameMap, err := poetryCtl.GetUserProject(userID, log)
+	if err != nil {
+		log.Errorf("GetUserProject error: %v", err)
+		return false
+	}
+	for _, roleID := range productNameMap[productName] {
+		if roleID == setting.RoleOwnerID {
+			return true
+		}
+	}
+	return false
+}
+
+// UpdateQueuedTaskPriority 调整排队中任务的优先级
+func UpdateQueuedTaskPriority(pipelineName string, taskID int64, priority, userID int, superUser bool, log *zap.SugaredLogger) error {
+	if priority <= 0 {
+		return e.ErrUpdateTaskPriority.AddDesc("priority must be greater than 0")
+	}
+	priority = clampTaskPriority(priority)
+
+	var queued *task.Task
+	for _, t := range ListTasks() {
+		if t.PipelineName == pipelineName && t.TaskID == taskID {
+			queued = t
+			break
+		}
+	}
+	if queued == nil {
+		return e.ErrUpdateTaskPriority.AddDesc("task is not in queue")
+	}
+	if err := checkTaskPriority(queued.ProductName, priority, userID, superUser, log); err != nil {
+		return e.ErrUpdateTaskPriority.AddErr(err)
+	}
+
+	if err := commonrepo.NewQueueColl().UpdatePriority(pipelineName, taskID, priority); err != nil {
+		log.Errorf("UpdatePriority %s:%d error: %v", pipelineName, taskID, err)
+		return e.ErrUpdateTaskPriority.AddErr(err)
+	}
+	return nil
+}
+
+// BumpQueuedTask 将排队中的任务提到队首, 优先级不低于队列中其他任务的最高优先级加一, 不超过TaskPriorityMax
+func BumpQueuedTask(pipelineName string, taskID int64, userID int, superUser bool, log *zap.SugaredLogger) error {
+	priority := 0
+	for _, t := range ListTasks() {
+		if t.Status != config.StatusWaiting && t.Status != config.StatusBlocked {
+			continue
+		}
+		p := taskPriority(t)
+		if t.PipelineName != pipelineName || t.TaskID != taskID {
+			p++
+		}
+		if p > priority {
+			priority = p
+		}
+	}
+	if priority == 0 {
+		return e.ErrUpdateTaskPriority.AddDesc("no waiting or blocked task found")
+	}
+
+	return UpdateQueuedTaskPriority(pipelineName, taskID, priority, userID, superUser, log)
+}
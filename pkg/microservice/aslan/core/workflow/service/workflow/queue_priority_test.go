@@ -0,0 +1,100 @@
+/*
+Copyright 2021 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package workflow
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/koderover/zadig/pkg/microservice/aslan/config"
+	"github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/models/task"
+	"github.com/koderover/zadig/pkg/setting"
+)
+
+var _ = Describe("Testing queue priority", func() {
+
+	Context("taskPriority", func() {
+		It("should use the priority of the trigger by default", func() {
+			Expect(taskPriority(&task.Task{TaskCreator: setting.CronTaskCreator})).To(Equal(config.TaskPriorityCron))
+			Expect(taskPriority(&task.Task{TaskCreator: setting.WebhookTaskCreator})).To(Equal(config.TaskPriorityWebhook))
+			Expect(taskPriority(&task.Task{TaskCreator: "admin"})).To(Equal(config.TaskPriorityManual))
+		})
+		It("should keep the specified priority", func() {
+			Expect(taskPriority(&task.Task{TaskCreator: setting.CronTaskCreator, Priority: config.TaskPriorityHotfix})).To(Equal(config.TaskPriorityHotfix))
+		})
+	})
+
+	Context("clampTaskPriority", func() {
+		It("should keep the priority in range", func() {
+			Expect(clampTaskPriority(-1)).To(Equal(0))
+			Expect(clampTaskPriority(0)).To(Equal(0))
+			Expect(clampTaskPriority(config.TaskPriorityHotfix)).To(Equal(config.TaskPriorityHotfix))
+			Expect(clampTaskPriority(1000)).To(Equal(config.TaskPriorityMax))
+		})
+	})
+
+	Context("checkTaskPriority", func() {
+		It("should allow the default priorities for everyone", func() {
+			Expect(checkTaskPriority("a", config.TaskPriorityManual, 1, false, nil)).To(Succeed())
+			Expect(checkTaskPriority("a", 0, 1, false, nil)).To(Succeed())
+		})
+		It("should allow super users to jump the queue", func() {
+			Expect(checkTaskPriority("a", config.TaskPriorityHotfix, 1, true, nil)).To(Succeed())
+		})
+	})
+
+	Context("sortQueuedTasks", func() {
+		It("should sort by priority, running tasks of project and create time", func() {
+			tasks := []*task.Task{
+				{TaskID: 1, ProductName: "a", TaskCreator: setting.CronTaskCreator, CreateTime: 1},
+				{TaskID: 2, ProductName: "a", TaskCreator: "admin", CreateTime: 2},
+				{TaskID: 3, ProductName: "b", TaskCreator: "admin", CreateTime: 3},
+				{TaskID: 4, ProductName: "a", TaskCreator: "admin", CreateTime: 4, Priority: config.TaskPriorityHotfix},
+				{TaskID: 5, ProductName: "b", TaskCreator: "admin", CreateTime: 0},
+			}
+			sortQueuedTasks(tasks, map[string]int{"a": 2})
+
+			var ids []int64
+			for _, t := range tasks {
+				ids = append(ids, t.TaskID)
+			}
+			Expect(ids).To(Equal([]int64{4, 5, 3, 2, 1}))
+		})
+	})
+
+	Context("taskScheduler", func() {
+		It("should skip projects reaching the concurrency limit", func() {
+			s := &taskScheduler{
+				running: map[string]int{"a": 1},
+				limits:  map[string]int{"a": 1, "b": 0},
+			}
+			tasks := []*task.Task{
+				{TaskID: 1, ProductName: "a", Priority: config.TaskPriorityHotfix},
+				{TaskID: 2, ProductName: "b", TaskCreator: setting.CronTaskCreator},
+			}
+
+			t, ok := s.next(tasks)
+			Expect(ok).To(BeTrue())
+			Expect(t.TaskID).To(Equal(int64(2)))
+
+			s.running["a"] = 0
+			t, ok = s.next(tasks)
+			Expect(ok).To(BeTrue())
+			Expect(t.TaskID).To(Equal(int64(1)))
+		})
+	})
+})
@@ -0,0 +1,69 @@
+/*
+Copyright 2021 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package workflow
+
+import (
+	"fmt"
+
+	"github.com/koderover/zadig/pkg/microservice/aslan/config"
+	commonmodels "github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/models"
+	"github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/models/task"
+)
+
+const maxRetryAttempts = 10
+
+// validateRetryPolicies 检查工作流配置的重试策略, 只允许重试失败和超时的subtask
+func validateRetryPolicies(policies []*commonmodels.StageRetryPolicy) error {
+	for _, p := range policies {
+		if p.Policy == nil {
+			return fmt.Errorf("retry policy of stage %s can not be empty", p.TaskType)
+		}
+		if p.Policy.MaxAttempts < 1 || p.Policy.MaxAttempts > maxRetryAttempts {
+			return fmt.Errorf("max attempts of stage %s should be between 1 and %d", p.TaskType, maxRetryAttempts)
+		}
+		if p.Policy.Backoff < 0 {
+			return fmt.Errorf("invalid retry backoff of stage %s: %d", p.TaskType, p.Policy.Backoff)
+		}
+		for _, status := range p.Policy.RetryOn {
+			if status != config.StatusFailed && status != config.StatusTimeout {
+				return fmt.Errorf("can not retry stage %s on status %s", p.TaskType, status)
+			}
+		}
+	}
+	return nil
+}
+
+// setStageRetryPolicies 把工作流配置的重试策略设置到任务中对应的stage上, 指定了服务组件的策略优先
+func setStageRetryPolicies(pt *task.Task, policies []*commonmodels.StageRetryPolicy) {
+	for _, stage := range pt.Stages {
+		for _, p := range policies {
+			if p.TaskType != stage.TaskType {
+				continue
+			}
+			if len(p.Modules) == 0 {
+				stage.RetryPolicy = p.Policy
+				continue
+			}
+			if stage.ModuleRetryPolicies == nil {
+				stage.ModuleRetryPolicies = make(map[string]*commonmodels.RetryPolicy)
+			}
+			for _, module := range p.Modules {
+				stage.ModuleRetryPolicies[module] = p.Policy
+			}
+		}
+	}
+}
@@ -0,0 +1,72 @@
+/*
+Copyright 2021 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package workflow
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/koderover/zadig/pkg/microservice/aslan/config"
+	commonmodels "github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/models"
+	"github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/models/task"
+)
+
+var _ = Describe("Testing retry", func() {
+
+	Context("validateRetryPolicies", func() {
+		It("should be passed for valid policies", func() {
+			err := validateRetryPolicies([]*commonmodels.StageRetryPolicy{
+				{TaskType: config.TaskBuild, Policy: &commonmodels.RetryPolicy{MaxAttempts: 3, Backoff: 10, RetryOn: []config.Status{config.StatusTimeout}}},
+			})
+			Expect(err).ShouldNot(HaveOccurred())
+		})
+		It("should raise error for invalid max attempts", func() {
+			err := validateRetryPolicies([]*commonmodels.StageRetryPolicy{
+				{TaskType: config.TaskBuild, Policy: &commonmodels.RetryPolicy{MaxAttempts: 0}},
+			})
+			Expect(err).Should(HaveOccurred())
+		})
+		It("should raise error for unsupported status", func() {
+			err := validateRetryPolicies([]*commonmodels.StageRetryPolicy{
+				{TaskType: config.TaskDeploy, Policy: &commonmodels.RetryPolicy{MaxAttempts: 2, RetryOn: []config.Status{config.StatusCancelled}}},
+			})
+			Expect(err).Should(HaveOccurred())
+		})
+	})
+
+	Context("setStageRetryPolicies", func() {
+		It("should set stage and module policies", func() {
+			stagePolicy := &commonmodels.RetryPolicy{MaxAttempts: 2}
+			modulePolicy := &commonmodels.RetryPolicy{MaxAttempts: 3}
+			pt := &task.Task{
+				Stages: []*commonmodels.Stage{
+					{TaskType: config.TaskBuild},
+					{TaskType: config.TaskDeploy},
+				},
+			}
+			setStageRetryPolicies(pt, []*commonmodels.StageRetryPolicy{
+				{TaskType: config.TaskBuild, Policy: stagePolicy},
+				{TaskType: config.TaskBuild, Modules: []string{"aslan"}, Policy: modulePolicy},
+			})
+
+			Expect(pt.Stages[0].RetryPolicy).To(Equal(stagePolicy))
+			Expect(pt.Stages[0].ModuleRetryPolicies).To(HaveKeyWithValue("aslan", modulePolicy))
+			Expect(pt.Stages[1].RetryPolicy).To(BeNil())
+			Expect(pt.Stages[1].ModuleRetryPolicies).To(BeNil())
+		})
+	})
+})
@@ -0,0 +1,56 @@
+/*
+Copyright 2021 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package workflow
+
+import (
+	"fmt"
+	"strings"
+
+	commonmodels "github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/models"
+	"github.com/koderover/zadig/pkg/util"
+)
+
+// validateSecurityStage 检查安全扫描的准入策略, 漏洞级别统一为扫描结果中使用的大小写
+func validateSecurityStage(stage *commonmodels.SecurityStage) error {
+	if stage == nil || stage.Policy == nil {
+		return nil
+	}
+
+	counts := make(map[string]int, len(stage.Policy.MaxSeverityCount))
+	for key, count := range stage.Policy.MaxSeverityCount {
+		severity, ok := util.NormalizeVulnerabilitySeverity(key)
+		if !ok {
+			return fmt.Errorf("unknown vulnerability severity: %s", key)
+		}
+		if count < 0 {
+			return fmt.Errorf("invalid max count of %s vulnerabilities: %d", key, count)
+		}
+		if _, ok := counts[severity]; ok {
+			return fmt.Errorf("duplicated vulnerability severity: %s", key)
+		}
+		counts[severity] = count
+	}
+	stage.Policy.MaxSeverityCount = counts
+
+	for _, v := range stage.Policy.AllowList {
+		if v == nil || strings.TrimSpace(v.Name) == "" {
+			return fmt.Errorf("name of allowed vulnerability can not be empty")
+		}
+		v.Name = strings.TrimSpace(v.Name)
+	}
+	return nil
+}
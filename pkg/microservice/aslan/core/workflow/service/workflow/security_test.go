@@ -0,0 +1,57 @@
+/*
+Copyright 2021 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package workflow
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	commonmodels "github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/models"
+)
+
+var _ = Describe("Testing security policy", func() {
+
+	Context("validateSecurityStage", func() {
+		It("should be passed when policy is not set", func() {
+			Expect(validateSecurityStage(nil)).ShouldNot(HaveOccurred())
+			Expect(validateSecurityStage(&commonmodels.SecurityStage{Enabled: true})).ShouldNot(HaveOccurred())
+		})
+		It("should normalize severity keys", func() {
+			stage := &commonmodels.SecurityStage{
+				Enabled: true,
+				Policy: &commonmodels.SecurityPolicy{
+					MaxSeverityCount: map[string]int{"critical": 0, " HIGH ": 10},
+					AllowList:        []*commonmodels.AllowedVulnerability{{Name: " CVE-2021-3711 "}},
+				},
+			}
+			Expect(validateSecurityStage(stage)).ShouldNot(HaveOccurred())
+			Expect(stage.Policy.MaxSeverityCount).Should(Equal(map[string]int{"Critical": 0, "High": 10}))
+			Expect(stage.Policy.AllowList[0].Name).Should(Equal("CVE-2021-3711"))
+		})
+		It("should raise error when policy is invalid", func() {
+			for _, policy := range []*commonmodels.SecurityPolicy{
+				{MaxSeverityCount: map[string]int{"Critical": -1}},
+				{MaxSeverityCount: map[string]int{"Severe": 0}},
+				{MaxSeverityCount: map[string]int{"high": 1, "High": 2}},
+				{AllowList: []*commonmodels.AllowedVulnerability{{Name: " "}}},
+			} {
+				err := validateSecurityStage(&commonmodels.SecurityStage{Enabled: true, Policy: policy})
+				Expect(err).Should(HaveOccurred())
+			}
+		})
+	})
+})
@@ -32,6 +32,7 @@ import (
 	"github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/mongodb/template"
 	commonservice "github.com/koderover/zadig/pkg/microservice/aslan/core/common/service"
 	"github.com/koderover/zadig/pkg/microservice/aslan/core/common/service/webhook"
+	"github.com/koderover/zadig/pkg/microservice/aslan/core/common/service/wechat"
 	"github.com/koderover/zadig/pkg/setting"
 	"github.com/koderover/zadig/pkg/shared/poetry"
 	e "github.com/koderover/zadig/pkg/tool/errors"
@@ -256,6 +257,7 @@ func FindWorkflow(workflowName string, log *zap.SugaredLogger) (*commonmodels.Wo
 		}
 		resp.Schedules = &schedule
 	}
+	wechat.MaskWebHookSecrets(resp.NotifyCtl)
 	return resp, nil
 }
 
@@ -362,6 +364,28 @@ func CreateWorkflow(workflow *commonmodels.Workflow, log *zap.SugaredLogger) err
 		return e.ErrUpsertWorkflow.AddDesc(err.Error())
 	}
 
+	if err := validateWorkflowDAG(workflow.DAG); err != nil {
+		return e.ErrUpsertWorkflow.AddDesc(err.Error())
+	}
+
+	if err := validateApprovalStage(workflow.ApprovalStage); err != nil {
+		return e.ErrUpsertWorkflow.AddDesc(err.Error())
+	}
+
+	if err := validateSecurityStage(workflow.SecurityStage); err != nil {
+		return e.ErrUpsertWorkflow.AddDesc(err.Error())
+	}
+
+	if err := validateRetryPolicies(workflow.RetryPolicies); err != nil {
+		return e.ErrUpsertWorkflow.AddDesc(err.Error())
+	}
+
+	if workflow.NotifyCtl != nil {
+		if err := wechat.ValidateWebHooks(workflow.NotifyCtl.WebHooks); err != nil {
+			return e.ErrUpsertWorkflow.AddDesc(err.Error())
+		}
+	}
+
 	err = commonservice.ProcessWebhook(workflow.HookCtl.Items, nil, webhook.WorkflowPrefix+workflow.Name, log)
 	if err != nil {
 		log.Errorf("Failed to process webhook, err: %s", err)
@@ -430,6 +454,29 @@ func UpdateWorkflow(workflow *commonmodels.Workflow, log *zap.SugaredLogger) err
 		return e.ErrUpsertWorkflow.AddDesc(err.Error())
 	}
 
+	if err := validateWorkflowDAG(workflow.DAG); err != nil {
+		return e.ErrUpsertWorkflow.AddDesc(err.Error())
+	}
+
+	if err := validateApprovalStage(workflow.ApprovalStage); err != nil {
+		return e.ErrUpsertWorkflow.AddDesc(err.Error())
+	}
+
+	if err := validateSecurityStage(workflow.SecurityStage); err != nil {
+		return e.ErrUpsertWorkflow.AddDesc(err.Error())
+	}
+
+	if err := validateRetryPolicies(workflow.RetryPolicies); err != nil {
+		return e.ErrUpsertWorkflow.AddDesc(err.Error())
+	}
+
+	if workflow.NotifyCtl != nil {
+		wechat.EnsureWebHookSecrets(currentWorkflow.NotifyCtl, workflow.NotifyCtl)
+		if err := wechat.ValidateWebHooks(workflow.NotifyCtl.WebHooks); err != nil {
+			return e.ErrUpsertWorkflow.AddDesc(err.Error())
+		}
+	}
+
 	err = commonservice.ProcessWebhook(workflow.HookCtl.Items, currentWorkflow.HookCtl.Items, webhook.WorkflowPrefix+workflow.Name, log)
 	if err != nil {
 		log.Errorf("Failed to process webhook, err: %s", err)
@@ -517,6 +564,7 @@ func ListWorkflows(queryType string, productName string, userID int, log *zap.Su
 		workflow.IsFavorite = IsFavoriteWorkflow(workflow, favorites)
 
 		workflow.TotalDuration, workflow.TotalNum, workflow.TotalSuccess = findWorkflowStat(workflow, workflowStats)
+		wechat.MaskWebHookSecrets(workflow.NotifyCtl)
 	}
 
 	return workflows, nil
@@ -597,6 +645,7 @@ func ListAllWorkflows(testName string, userID int, superUser bool, log *zap.Suga
 				}
 			}
 		}
+		wechat.MaskWebHookSecrets(workflow.NotifyCtl)
 		workflows = append(workflows, workflow)
 	}
 	return workflows, nil
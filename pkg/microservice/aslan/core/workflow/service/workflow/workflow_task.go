@@ -470,6 +470,11 @@ func CreateWorkflowTask(args *commonmodels.WorkflowTaskArgs, taskCreator string,
 		return nil, e.ErrCreateTask.AddDesc("该工作流绑定的环境您没有更新环境或者环境管理权限,不能执行该工作流!")
 	}
 
+	args.Priority = clampTaskPriority(args.Priority)
+	if err := checkTaskPriority(workflow.ProductTmplName, args.Priority, userID, superUser, log); err != nil {
+		return nil, e.ErrCreateTask.AddErr(err)
+	}
+
 	var env *commonmodels.Product
 	if args.Namespace != "" {
 		// 处理namespace，避免开头或者结尾出现多余的逗号
@@ -518,6 +523,11 @@ func CreateWorkflowTask(args *commonmodels.WorkflowTaskArgs, taskCreator string,
 	configPayload.IgnoreCache = args.IgnoreCache
 	configPayload.ResetCache = args.ResetCache
 
+	if configPayload.SigningKeys, err = getWorkflowSigningKeys(workflow); err != nil {
+		log.Errorf("getWorkflowSigningKeys workflow name:[%s] err:%v", workflow.Name, err)
+		return nil, e.ErrCreateTask.AddErr(err)
+	}
+
 	distributeS3StoreURL, defaultS3StoreURL, err := getDefaultAndDestS3StoreURL(workflow, log)
 	if err != nil {
 		log.Errorf("getDefaultAndDestS3StoreUrl workflow name:[%s] err:%v", workflow.Name, err)
@@ -598,6 +608,7 @@ func CreateWorkflowTask(args *commonmodels.WorkflowTaskArgs, taskCreator string,
 						workflow.DistributeStage.JumpBoxHost,
 						distributeS3StoreURL,
 						distribute,
+						workflow.DistributeStage.Signing,
 					)
 					if err != nil {
 						log.Errorf("distrbiute stages to subtasks error: %v", err)
@@ -619,7 +630,7 @@ func CreateWorkflowTask(args *commonmodels.WorkflowTaskArgs, taskCreator string,
 		}
 
 		if workflow.SecurityStage != nil && workflow.SecurityStage.Enabled {
-			securityTask, err := addSecurityToSubTasks()
+			securityTask, err := addSecurityToSubTasks(workflow.SecurityStage)
 			if err != nil {
 				log.Errorf("add security task error: %v", err)
 				return nil, e.ErrCreateTask.AddErr(err)
@@ -697,6 +708,15 @@ func CreateWorkflowTask(args *commonmodels.WorkflowTaskArgs, taskCreator string,
 		AddSubtaskToStage(&stages, testSubTask, testTask.TestModuleName)
 	}
 
+	if workflow.ApprovalStage != nil && workflow.ApprovalStage.Enabled {
+		approvalSubTask, err := approvalStageToSubTask(workflow.ApprovalStage)
+		if err != nil {
+			log.Errorf("workflow_task approvalStageToSubTask err:%v", err)
+			return nil, e.ErrCreateTask.AddDesc(err.Error())
+		}
+		AddSubtaskToStage(&stages, approvalSubTask, approvalSubTaskKey)
+	}
+
 	sort.Sort(ByStageKind(stages))
 	triggerBy := &commonmodels.TriggerBy{
 		CodehostID:     args.CodehostID,
@@ -721,11 +741,15 @@ func CreateWorkflowTask(args *commonmodels.WorkflowTaskArgs, taskCreator string,
 		StorageURI:    defaultS3StoreURL,
 		ResetImage:    workflow.ResetImage,
 		TriggerBy:     triggerBy,
+		Priority:      args.Priority,
 	}
+	task.RollbackOnFailure = workflow.RollbackOnFailure
 
 	if len(task.Stages) <= 0 {
 		return nil, e.ErrCreateTask.AddDesc(e.PipelineSubTaskNotFoundErrMsg)
 	}
+	setStageDependencies(task, workflow.DAG)
+	setStageRetryPolicies(task, workflow.RetryPolicies)
 
 	endpoint := fmt.Sprintf("%s-%s:9000", config.Namespace(), ClusterStorageEP)
 
@@ -749,6 +773,7 @@ func CreateWorkflowTask(args *commonmodels.WorkflowTaskArgs, taskCreator string,
 	}
 
 	_ = scmnotify.NewService().UpdateWebhookComment(task, log)
+	_ = scmnotify.NewService().UpdateCommitStatus(task, log)
 	resp := &CreateTaskResp{PipelineName: args.WorkflowName, TaskID: nextTaskID}
 	return resp, nil
 }
@@ -1099,9 +1124,26 @@ func deployEnvToSubTasks(env commonmodels.DeployEnv, prodEnv *commonmodels.Produ
 	deployTask.ServiceName = envList[0]
 	deployTask.ContainerName = envList[1]
 
+	if env.Strategy != nil {
+		if err := env.Strategy.Validate(); err != nil {
+			return nil, err
+		}
+		if env.Strategy.Type != "" && env.Strategy.Type != setting.DeployStrategyRolling && env.Type != setting.K8SDeployType {
+			return nil, fmt.Errorf("deploy strategy %s is not supported by %s service", env.Strategy.Type, env.Type)
+		}
+	}
+
+	for _, verification := range env.Verifications {
+		if err := verification.Validate(); err != nil {
+			return nil, err
+		}
+	}
+	deployTask.Verifications = env.Verifications
+
 	switch env.Type {
 	case setting.K8SDeployType:
 		deployTask.ServiceType = setting.K8SDeployType
+		deployTask.Strategy = env.Strategy
 		return deployTask.ToSubTask()
 	case setting.HelmDeployType:
 		deployTask.ServiceType = setting.HelmDeployType
@@ -1140,6 +1182,38 @@ func resetImageTaskToSubTask(env commonmodels.DeployEnv, prodEnv *commonmodels.P
 	}
 }
 
+// getWorkflowSigningKeys 查找工作流镜像签名和部署前验证用到的密钥
+func getWorkflowSigningKeys(workflow *commonmodels.Workflow) ([]*commonmodels.SigningKey, error) {
+	return selectWorkflowSigningKeys(workflow, func(id string) (*commonmodels.SigningKey, error) {
+		return commonrepo.NewSigningKeyColl().Find(id)
+	})
+}
+
+// selectWorkflowSigningKeys 镜像签名需要私钥和密码, 只用于部署前验证的密钥只下发公钥
+func selectWorkflowSigningKeys(workflow *commonmodels.Workflow, find func(id string) (*commonmodels.SigningKey, error)) ([]*commonmodels.SigningKey, error) {
+	signingIDs := sets.NewString()
+	if workflow.DistributeStage != nil && workflow.DistributeStage.Enabled && workflow.DistributeStage.Signing != nil {
+		signingIDs.Insert(workflow.DistributeStage.Signing.KeyID)
+	}
+	ids := sets.NewString(signingIDs.List()...)
+	if workflow.SignatureKeyID != "" {
+		ids.Insert(workflow.SignatureKeyID)
+	}
+
+	keys := make([]*commonmodels.SigningKey, 0, ids.Len())
+	for _, id := range ids.List() {
+		key, err := find(id)
+		if err != nil {
+			return nil, fmt.Errorf("failed to find signing key %s: %v", id, err)
+		}
+		if !signingIDs.Has(id) {
+			key = &commonmodels.SigningKey{ID: key.ID, Name: key.Name, PublicKey: key.PublicKey}
+		}
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
 func artifactToSubTasks(name, image string) (map[string]interface{}, error) {
 	artifactTask := task.Artifact{TaskType: config.TaskArtifact, Enabled: true}
 	artifactTask.Name = name
@@ -1148,7 +1222,7 @@ func artifactToSubTasks(name, image string) (map[string]interface{}, error) {
 	return artifactTask.ToSubTask()
 }
 
-func formatDistributeSubtasks(releaseImages []commonmodels.RepoImage, imageRepo, jumpboxHost, destStorageURL string, distribute *commonmodels.ProductDistribute) ([]map[string]interface{}, error) {
+func formatDistributeSubtasks(releaseImages []commonmodels.RepoImage, imageRepo, jumpboxHost, destStorageURL string, distribute *commonmodels.ProductDistribute, signing *commonmodels.ImageSigning) ([]map[string]interface{}, error) {
 	var resp []map[string]interface{}
 
 	if distribute.ImageDistribute {
@@ -1157,6 +1231,7 @@ func formatDistributeSubtasks(releaseImages []commonmodels.RepoImage, imageRepo,
 			Enabled:   true,
 			ImageRepo: imageRepo,
 			Releases:  releaseImages,
+			Signing:   signing,
 		}
 		subtask, err := t.ToSubTask()
 		if err != nil {
@@ -1208,8 +1283,15 @@ func AddJiraSubTask(moduleName, target, serviceName, productName string, log *za
 	return jira.ToSubTask()
 }
 
-func addSecurityToSubTasks() (map[string]interface{}, error) {
-	securityTask := task.Security{TaskType: config.TaskSecurity, Enabled: true}
+func addSecurityToSubTasks(stage *commonmodels.SecurityStage) (map[string]interface{}, error) {
+	securityTask := task.Security{
+		TaskType:     config.TaskSecurity,
+		Enabled:      true,
+		Policy:       stage.Policy,
+		Scanner:      stage.Scanner,
+		ScannerImage: stage.ScannerImage,
+		ScannerEnvs:  stage.ScannerEnvs,
+	}
 	return securityTask.ToSubTask()
 }
 
@@ -1269,6 +1351,15 @@ func testArgsToSubtask(args *commonmodels.WorkflowTaskArgs, pt *task.Task, log *
 
 		testTask.JobCtx.ArtifactPaths = testModule.ArtifactPaths
 		testTask.JobCtx.TestThreshold = testModule.Threshold
+		testTask.JobCtx.QuarantinedCases = commonservice.ListQuarantinedTestCases(testModule.Name, log)
+		testTask.JobCtx.CoverageReportPath = testModule.CoverageReportPath
+		testTask.JobCtx.CoverageFormat = testModule.CoverageFormat
+		testTask.JobCtx.CoveragePolicy = testModule.CoveragePolicy
+		testTask.JobCtx.BaseLineRate = commonservice.GetBaseLineRate(testModule.Name, testModule.CoveragePolicy, log)
+		testTask.JobCtx.ShardCount = testModule.ShardCount
+		if testModule.ShardStrategy == setting.TestShardByTiming {
+			testTask.JobCtx.TestShards = commonservice.SplitTestShardsByTiming(testModule.Name, testModule.ShardCount, log)
+		}
 		testTask.JobCtx.Caches = testModule.Caches
 		testTask.JobCtx.TestResultPath = testModule.TestResultPath
 		testTask.JobCtx.TestReportPath = testModule.TestReportPath
@@ -1351,6 +1442,11 @@ func CreateArtifactWorkflowTask(args *commonmodels.WorkflowTaskArgs, taskCreator
 		return nil, e.ErrCreateTask.AddDesc("该工作流绑定的环境您没有更新环境或者环境管理权限,不能执行该工作流")
 	}
 
+	args.Priority = clampTaskPriority(args.Priority)
+	if err := checkTaskPriority(workflow.ProductTmplName, args.Priority, userID, superUser, log); err != nil {
+		return nil, e.ErrCreateTask.AddErr(err)
+	}
+
 	var env *commonmodels.Product
 	if args.Namespace != "" {
 		// 查找要部署的环境
@@ -1386,6 +1482,11 @@ func CreateArtifactWorkflowTask(args *commonmodels.WorkflowTaskArgs, taskCreator
 	configPayload.IgnoreCache = args.IgnoreCache
 	configPayload.ResetCache = args.ResetCache
 
+	if configPayload.SigningKeys, err = getWorkflowSigningKeys(workflow); err != nil {
+		log.Errorf("getWorkflowSigningKeys workflow name:[%s] err:%v", workflow.Name, err)
+		return nil, e.ErrCreateTask.AddErr(err)
+	}
+
 	distributeS3StoreURL, defaultS3StoreURL, err := getDefaultAndDestS3StoreURL(workflow, log)
 	if err != nil {
 		log.Errorf("getDefaultAndDestS3StoreUrl workflow name:[%s] err:%v", workflow.Name, err)
@@ -1466,6 +1567,7 @@ func CreateArtifactWorkflowTask(args *commonmodels.WorkflowTaskArgs, taskCreator
 						workflow.DistributeStage.JumpBoxHost,
 						distributeS3StoreURL,
 						distribute,
+						workflow.DistributeStage.Signing,
 					)
 					if err != nil {
 						log.Errorf("distrbiute stages to subtasks error: %v", err)
@@ -1477,7 +1579,7 @@ func CreateArtifactWorkflowTask(args *commonmodels.WorkflowTaskArgs, taskCreator
 		}
 
 		if workflow.SecurityStage != nil && workflow.SecurityStage.Enabled {
-			securityTask, err := addSecurityToSubTasks()
+			securityTask, err := addSecurityToSubTasks(workflow.SecurityStage)
 			if err != nil {
 				log.Errorf("add security task error: %v", err)
 				return nil, err
@@ -1554,6 +1656,15 @@ func CreateArtifactWorkflowTask(args *commonmodels.WorkflowTaskArgs, taskCreator
 		AddSubtaskToStage(&stages, testSubTask, testTask.TestModuleName)
 	}
 
+	if workflow.ApprovalStage != nil && workflow.ApprovalStage.Enabled {
+		approvalSubTask, err := approvalStageToSubTask(workflow.ApprovalStage)
+		if err != nil {
+			log.Errorf("workflow_task approvalStageToSubTask err:%v", err)
+			return nil, e.ErrCreateTask.AddDesc(err.Error())
+		}
+		AddSubtaskToStage(&stages, approvalSubTask, approvalSubTaskKey)
+	}
+
 	sort.Sort(ByStageKind(stages))
 	triggerBy := &commonmodels.TriggerBy{
 		Source:         args.Source,
@@ -1575,11 +1686,16 @@ func CreateArtifactWorkflowTask(args *commonmodels.WorkflowTaskArgs, taskCreator
 		StorageURI:    defaultS3StoreURL,
 		ResetImage:    workflow.ResetImage,
 		TriggerBy:     triggerBy,
+		Priority:      args.Priority,
 	}
+	task.RollbackOnFailure = workflow.RollbackOnFailure
+	task.SignatureKeyID = workflow.SignatureKeyID
 
 	if len(task.Stages) <= 0 {
 		return nil, e.ErrCreateTask.AddDesc(e.PipelineSubTaskNotFoundErrMsg)
 	}
+	setStageDependencies(task, workflow.DAG)
+	setStageRetryPolicies(task, workflow.RetryPolicies)
 
 	if env != nil {
 		task.Services = env.Services
@@ -1599,6 +1715,7 @@ func CreateArtifactWorkflowTask(args *commonmodels.WorkflowTaskArgs, taskCreator
 	}
 
 	_ = scmnotify.NewService().UpdateWebhookComment(task, log)
+	_ = scmnotify.NewService().UpdateCommitStatus(task, log)
 	resp := &CreateTaskResp{PipelineName: args.WorkflowName, TaskID: nextTaskID}
 	return resp, nil
 }
@@ -1764,6 +1881,7 @@ func BuildModuleToSubTasks(args *commonmodels.BuildModuleArgs, log *zap.SugaredL
 				WorkDir:    module.PostBuild.DockerBuild.WorkDir,
 				DockerFile: module.PostBuild.DockerBuild.DockerFile,
 				BuildArgs:  module.PostBuild.DockerBuild.BuildArgs,
+				SBOMFormat: module.PostBuild.DockerBuild.SBOMFormat,
 			}
 		}
 
@@ -1778,6 +1896,9 @@ func BuildModuleToSubTasks(args *commonmodels.BuildModuleArgs, log *zap.SugaredL
 		}
 
 		build.JobCtx.Caches = module.Caches
+		build.JobCtx.Steps = buildTaskSteps(module.Steps, "")
+		build.JobCtx.CacheType = module.CacheType
+		build.JobCtx.CacheKeyFiles = module.CacheKeyFiles
 
 		if args.FileName != "" {
 			build.ArtifactInfo = &task.ArtifactInfo{
@@ -1904,6 +2025,11 @@ func ensurePipelineTask(pt *task.Task, envName string, log *zap.SugaredLogger) e
 				if t.JobCtx.DockerBuildCtx != nil {
 					t.JobCtx.DockerBuildCtx.ImageName = t.JobCtx.Image
 				}
+				for _, step := range t.JobCtx.Steps {
+					if step.DockerBuild != nil {
+						step.DockerBuild.ImageName = t.JobCtx.Image
+					}
+				}
 
 				if t.JobCtx.FileArchiveCtx != nil {
 					//t.JobCtx.FileArchiveCtx.FileName = t.ServiceName
@@ -2260,16 +2386,18 @@ func AddSubtaskToStage(stages *[]*commonmodels.Stage, subTask map[string]interfa
 
 	for _, stage := range *stages {
 		if stage.TaskType == subTaskPre.TaskType {
+			key := target
 			// deploy task 同一个组件可能有多个部署目标
 			if subTaskPre.TaskType == config.TaskDeploy || subTaskPre.TaskType == config.TaskResetImage {
 				if _, ok := stage.SubTasks[target]; ok {
-					stage.SubTasks[target+"_"+nextTargetID(stage.SubTasks, target)] = subTask
-				} else {
-					stage.SubTasks[target] = subTask
+					key = target + "_" + nextTargetID(stage.SubTasks, target)
 				}
-			} else {
-				stage.SubTasks[target] = subTask
 			}
+			stage.SubTasks[key] = subTask
+			if stage.SubTaskTargets == nil {
+				stage.SubTaskTargets = make(map[string]string)
+			}
+			stage.SubTaskTargets[key] = target
 			stageFound = true
 			break
 		}
@@ -2277,9 +2405,10 @@ func AddSubtaskToStage(stages *[]*commonmodels.Stage, subTask map[string]interfa
 
 	if !stageFound {
 		stage := &commonmodels.Stage{
-			TaskType:    subTaskPre.TaskType,
-			SubTasks:    map[string]map[string]interface{}{target: subTask},
-			RunParallel: true,
+			TaskType:       subTaskPre.TaskType,
+			SubTasks:       map[string]map[string]interface{}{target: subTask},
+			SubTaskTargets: map[string]string{target: target},
+			RunParallel:    true,
 		}
 
 		if subTaskPre.TaskType == config.TaskResetImage {
@@ -2303,3 +2432,34 @@ func nextTargetID(subTasks map[string]map[string]interface{}, target string) str
 
 	return strconv.Itoa(count)
 }
+
+// buildTaskSteps 将构建模块中配置的步骤转换为任务中的步骤, 镜像名称和构建后的镜像一致
+func buildTaskSteps(steps []*commonmodels.Step, image string) []*task.Step {
+	resp := make([]*task.Step, 0, len(steps))
+	for _, step := range steps {
+		taskStep := &task.Step{
+			Name:       step.Name,
+			Type:       step.Type,
+			When:       step.When,
+			Timeout:    step.Timeout,
+			Envs:       step.Envs,
+			Scripts:    step.Scripts,
+			Paths:      step.Paths,
+			Dest:       step.Dest,
+			ResultPath: step.ResultPath,
+		}
+		if step.DockerBuild != nil {
+			taskStep.DockerBuild = &task.DockerBuildCtx{
+				Source:     step.DockerBuild.Source,
+				TemplateID: step.DockerBuild.TemplateID,
+				WorkDir:    step.DockerBuild.WorkDir,
+				DockerFile: step.DockerBuild.DockerFile,
+				BuildArgs:  step.DockerBuild.BuildArgs,
+				ImageName:  image,
+				SBOMFormat: step.DockerBuild.SBOMFormat,
+			}
+		}
+		resp = append(resp, taskStep)
+	}
+	return resp
+}
@@ -0,0 +1,73 @@
+/*
+Copyright 2021 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package workflow
+
+import (
+	"fmt"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	commonmodels "github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/models"
+)
+
+var _ = Describe("Testing workflow signing keys", func() {
+
+	signKey := &commonmodels.SigningKey{ID: primitive.NewObjectID(), Name: "sign", PrivateKey: "sign-private", Password: "sign-password", PublicKey: "sign-public"}
+	verifyKey := &commonmodels.SigningKey{ID: primitive.NewObjectID(), Name: "verify", PrivateKey: "verify-private", Password: "verify-password", PublicKey: "verify-public"}
+	find := func(id string) (*commonmodels.SigningKey, error) {
+		for _, key := range []*commonmodels.SigningKey{signKey, verifyKey} {
+			if key.ID.Hex() == id {
+				return key, nil
+			}
+		}
+		return nil, fmt.Errorf("not found")
+	}
+	distribute := func(enabled bool, keyID string) *commonmodels.DistributeStage {
+		return &commonmodels.DistributeStage{Enabled: enabled, Signing: &commonmodels.ImageSigning{KeyID: keyID}}
+	}
+
+	Context("selectWorkflowSigningKeys", func() {
+		It("should return no key when signing and verification are not enabled", func() {
+			keys, err := selectWorkflowSigningKeys(&commonmodels.Workflow{DistributeStage: distribute(false, signKey.ID.Hex())}, find)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(keys).Should(BeEmpty())
+		})
+		It("should only ship the public key for verification", func() {
+			keys, err := selectWorkflowSigningKeys(&commonmodels.Workflow{SignatureKeyID: verifyKey.ID.Hex()}, find)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(keys).Should(Equal([]*commonmodels.SigningKey{{ID: verifyKey.ID, Name: "verify", PublicKey: "verify-public"}}))
+		})
+		It("should ship the private key for image signing", func() {
+			workflow := &commonmodels.Workflow{DistributeStage: distribute(true, signKey.ID.Hex()), SignatureKeyID: verifyKey.ID.Hex()}
+			keys, err := selectWorkflowSigningKeys(workflow, find)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(keys).Should(ConsistOf(signKey, &commonmodels.SigningKey{ID: verifyKey.ID, Name: "verify", PublicKey: "verify-public"}))
+		})
+		It("should ship the private key once when the same key signs and verifies", func() {
+			workflow := &commonmodels.Workflow{DistributeStage: distribute(true, signKey.ID.Hex()), SignatureKeyID: signKey.ID.Hex()}
+			keys, err := selectWorkflowSigningKeys(workflow, find)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(keys).Should(Equal([]*commonmodels.SigningKey{signKey}))
+		})
+		It("should raise error when key is not found", func() {
+			_, err := selectWorkflowSigningKeys(&commonmodels.Workflow{SignatureKeyID: "missing"}, find)
+			Expect(err).Should(HaveOccurred())
+		})
+	})
+})
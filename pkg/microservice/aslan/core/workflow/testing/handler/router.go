@@ -54,6 +54,9 @@ func (*Router) Inject(router *gin.RouterGroup) {
 		tester.GET("", ListTestModules)
 		tester.GET("/:name", GetTestModule)
 		tester.DELETE("/:name", gin2.IsHavePermission([]string{permission.TestDeleteUUID}, permission.QueryType), gin2.UpdateOperationLogStatus, DeleteTestModule)
+		tester.GET("/:name/flaky", ListFlakyTestCases)
+		tester.GET("/:name/coverage", ListTestCoverages)
+		tester.PUT("/:name/quarantine", gin2.IsHavePermission([]string{permission.TestManageUUID}, permission.QueryType), gin2.UpdateOperationLogStatus, QuarantineTestCase)
 	}
 
 	testStat := router.Group("teststat")
@@ -0,0 +1,53 @@
+/*
+Copyright 2021 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package handler
+
+import (
+	"fmt"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/koderover/zadig/pkg/microservice/aslan/core/workflow/testing/service"
+	internalhandler "github.com/koderover/zadig/pkg/shared/handler"
+	e "github.com/koderover/zadig/pkg/tool/errors"
+)
+
+func ListFlakyTestCases(c *gin.Context) {
+	ctx := internalhandler.NewContext(c)
+	defer func() { internalhandler.JSONResponse(c, ctx) }()
+
+	ctx.Resp, ctx.Err = service.ListFlakyTestCases(c.Param("name"), ctx.Logger)
+}
+
+func QuarantineTestCase(c *gin.Context) {
+	ctx := internalhandler.NewContext(c)
+	defer func() { internalhandler.JSONResponse(c, ctx) }()
+
+	args := new(service.QuarantineTestCaseArgs)
+	if err := c.ShouldBindJSON(args); err != nil {
+		ctx.Err = e.ErrInvalidParam.AddDesc("invalid QuarantineTestCase args")
+		return
+	}
+
+	action := "隔离用例"
+	if !args.Quarantined {
+		action = "取消隔离用例"
+	}
+	internalhandler.InsertOperationLog(c, ctx.Username, c.Query("productName"), action, "项目管理-测试", fmt.Sprintf("%s:%s.%s", c.Param("name"), args.ClassName, args.Name), "", ctx.Logger)
+
+	ctx.Err = service.QuarantineTestCase(c.Param("name"), args, ctx.Username, ctx.Logger)
+}
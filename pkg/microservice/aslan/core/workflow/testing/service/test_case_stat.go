@@ -0,0 +1,54 @@
+/*
+Copyright 2021 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package service
+
+import (
+	"go.uber.org/zap"
+
+	commonmodels "github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/models"
+	commonrepo "github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/mongodb"
+	e "github.com/koderover/zadig/pkg/tool/errors"
+)
+
+type QuarantineTestCaseArgs struct {
+	ClassName   string `json:"classname"`
+	Name        string `json:"name"`
+	Quarantined bool   `json:"quarantined"`
+}
+
+// ListFlakyTestCases 列出测试模块中不稳定或者已经被隔离的用例, 按不稳定程度从高到低排列
+func ListFlakyTestCases(testName string, log *zap.SugaredLogger) ([]*commonmodels.TestCaseStat, error) {
+	stats, err := commonrepo.NewTestCaseStatColl().List(&commonrepo.TestCaseStatListOption{TestName: testName, FlakyOnly: true})
+	if err != nil {
+		log.Errorf("TestCaseStat.List %s error: %v", testName, err)
+		return nil, e.ErrListTestCaseStats.AddErr(err)
+	}
+	return stats, nil
+}
+
+// QuarantineTestCase 隔离或者取消隔离测试用例, 被隔离的用例失败时不会导致测试任务失败
+func QuarantineTestCase(testName string, args *QuarantineTestCaseArgs, username string, log *zap.SugaredLogger) error {
+	if args.Name == "" {
+		return e.ErrQuarantineTestCase.AddDesc("empty test case name")
+	}
+
+	if err := commonrepo.NewTestCaseStatColl().UpdateQuarantine(testName, args.ClassName, args.Name, args.Quarantined, username); err != nil {
+		log.Errorf("TestCaseStat.UpdateQuarantine %s/%s.%s error: %v", testName, args.ClassName, args.Name, err)
+		return e.ErrQuarantineTestCase.AddErr(err)
+	}
+	return nil
+}
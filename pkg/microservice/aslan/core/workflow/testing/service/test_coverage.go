@@ -0,0 +1,41 @@
+/*
+Copyright 2021 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package service
+
+import (
+	"go.uber.org/zap"
+
+	commonmodels "github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/models"
+	commonrepo "github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/mongodb"
+	e "github.com/koderover/zadig/pkg/tool/errors"
+)
+
+const defaultCoverageTrendLimit = 30
+
+// ListTestCoverages 列出测试模块最近的覆盖率记录, 按时间从新到旧排列, 用于展示覆盖率趋势
+func ListTestCoverages(testName, branch string, limit int64, log *zap.SugaredLogger) ([]*commonmodels.TestCoverage, error) {
+	if limit <= 0 {
+		limit = defaultCoverageTrendLimit
+	}
+
+	coverages, err := commonrepo.NewTestCoverageColl().List(&commonrepo.TestCoverageListOption{TestName: testName, Branch: branch, Limit: limit})
+	if err != nil {
+		log.Errorf("TestCoverage.List %s error: %v", testName, err)
+		return nil, e.ErrListTestCoverage.AddErr(err)
+	}
+	return coverages, nil
+}
@@ -32,6 +32,7 @@ import (
 	commonservice "github.com/koderover/zadig/pkg/microservice/aslan/core/common/service"
 	"github.com/koderover/zadig/pkg/microservice/aslan/core/common/service/nsq"
 	"github.com/koderover/zadig/pkg/microservice/aslan/core/common/service/s3"
+	"github.com/koderover/zadig/pkg/microservice/aslan/core/common/service/wechat"
 	workflowservice "github.com/koderover/zadig/pkg/microservice/aslan/core/workflow/service/workflow"
 	"github.com/koderover/zadig/pkg/setting"
 	e "github.com/koderover/zadig/pkg/tool/errors"
@@ -43,6 +44,14 @@ func CreateTesting(username string, testing *commonmodels.Testing, log *zap.Suga
 	if len(testing.Name) == 0 {
 		return e.ErrCreateTestModule.AddDesc("empty Name")
 	}
+	if testing.NotifyCtl != nil {
+		if err := wechat.ValidateWebHooks(testing.NotifyCtl.WebHooks); err != nil {
+			return e.ErrCreateTestModule.AddDesc(err.Error())
+		}
+	}
+	if err := validateTestShard(testing); err != nil {
+		return e.ErrCreateTestModule.AddDesc(err.Error())
+	}
 
 	err := HandleCronjob(testing, log)
 	if err != nil {
@@ -59,6 +68,28 @@ func CreateTesting(username string, testing *commonmodels.Testing, log *zap.Suga
 	return nil
 }
 
+func validateTestShard(testing *commonmodels.Testing) error {
+	if testing.ShardCount < 0 {
+		return fmt.Errorf("invalid shard count %d", testing.ShardCount)
+	}
+	switch testing.ShardStrategy {
+	case "", setting.TestShardByIndex, setting.TestShardByTiming:
+	default:
+		return fmt.Errorf("unsupported shard strategy %s", testing.ShardStrategy)
+	}
+	if testing.ShardCount <= 1 {
+		return nil
+	}
+	// 各个分片的性能测试结果和覆盖率报告无法合并
+	if testing.TestType == setting.PerformanceTest {
+		return fmt.Errorf("test sharding is not supported for performance test")
+	}
+	if testing.CoverageReportPath != "" {
+		return fmt.Errorf("coverage report is not supported when test sharding is enabled")
+	}
+	return nil
+}
+
 func HandleCronjob(testing *commonmodels.Testing, log *zap.SugaredLogger) error {
 	testSchedule := testing.Schedules
 
@@ -96,6 +127,14 @@ func UpdateTesting(username string, testing *commonmodels.Testing, log *zap.Suga
 	if len(testing.Name) == 0 {
 		return e.ErrUpdateTestModule.AddDesc("empty Name")
 	}
+	if testing.NotifyCtl != nil {
+		if err := wechat.ValidateWebHooks(testing.NotifyCtl.WebHooks); err != nil {
+			return e.ErrUpdateTestModule.AddDesc(err.Error())
+		}
+	}
+	if err := validateTestShard(testing); err != nil {
+		return e.ErrUpdateTestModule.AddDesc(err.Error())
+	}
 
 	err := HandleCronjob(testing, log)
 	if err != nil {
@@ -106,6 +145,9 @@ func UpdateTesting(username string, testing *commonmodels.Testing, log *zap.Suga
 	if err == nil && existed.PreTest != nil && testing.PreTest != nil {
 		commonservice.EnsureSecretEnvs(existed.PreTest.Envs, testing.PreTest.Envs)
 	}
+	if err == nil {
+		wechat.EnsureWebHookSecrets(existed.NotifyCtl, testing.NotifyCtl)
+	}
 
 	testing.UpdateBy = username
 	testing.UpdateTime = time.Now().Unix()
@@ -204,6 +246,9 @@ func ListAllWorkflows(testName string, log *zap.SugaredLogger) ([]*commonmodels.
 		log.Errorf("Workflow.List error: %v", err)
 		return nil, e.ErrListWorkflow.AddDesc(err.Error())
 	}
+	for _, workflow := range workflows {
+		wechat.MaskWebHookSecrets(workflow.NotifyCtl)
+	}
 
 	return workflows, nil
 }
@@ -245,6 +290,7 @@ func GetTesting(name, productName string, log *zap.SugaredLogger) (*commonmodels
 	}
 
 	workflowservice.EnsureTestingResp(resp)
+	wechat.MaskWebHookSecrets(resp.NotifyCtl)
 
 	return resp, nil
 }
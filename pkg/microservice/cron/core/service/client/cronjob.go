@@ -67,6 +67,17 @@ func (c *Client) TriggerCleanProducts(log *zap.SugaredLogger) error {
 	return err
 }
 
+// TriggerEmailDigest ...
+func (c *Client) TriggerEmailDigest(log *zap.SugaredLogger) error {
+	url := fmt.Sprintf("%s/cron/cron/emaildigest", c.APIBase)
+	log.Info("start send email digests..")
+	err := c.sendRequest(url)
+	if err != nil {
+		log.Errorf("trigger send email digests error :%v", err)
+	}
+	return err
+}
+
 // RunPipelineTask ...
 func (c *Client) RunPipelineTask(args *service.TaskArgs, log *zap.SugaredLogger) error {
 	url := fmt.Sprintf("%s/workflow/v2/tasks", c.APIBase)
@@ -73,6 +73,8 @@ const (
 	SystemCapacityGC = "SystemCapacityGC"
 	//InitHealthCheckScheduler
 	InitHealthCheckScheduler = "InitHealthCheckScheduler"
+	// EmailDigestScheduler 定时发送汇总的通知邮件
+	EmailDigestScheduler = "EmailDigestScheduler"
 
 	// FreestyleType 自由编排工作流
 	freestyleType = "freestyle"
@@ -157,6 +159,8 @@ func (c *CronClient) Init() {
 	c.InitPullSonarStatScheduler()
 	// 定时初始化健康检查
 	c.InitHealthCheckScheduler()
+	// 定时发送汇总的通知邮件
+	c.InitEmailDigestScheduler()
 }
 
 func getFeatures() (string, error) {
@@ -189,6 +193,16 @@ func (c *CronClient) InitCleanProductScheduler() {
 	c.Schedulers[CleanProductScheduler].Start()
 }
 
+// InitEmailDigestScheduler ...
+func (c *CronClient) InitEmailDigestScheduler() {
+
+	c.Schedulers[EmailDigestScheduler] = gocron.NewScheduler()
+
+	c.Schedulers[EmailDigestScheduler].Every(1).Hour().Do(c.AslanCli.TriggerEmailDigest, c.log)
+
+	c.Schedulers[EmailDigestScheduler].Start()
+}
+
 // InitJobScheduler ...
 func (c *CronClient) InitJobScheduler() {
 
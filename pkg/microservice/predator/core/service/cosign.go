@@ -0,0 +1,85 @@
+/*
+Copyright 2021 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package service
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path"
+	"strings"
+
+	"github.com/koderover/zadig/pkg/microservice/predator/config"
+	"github.com/koderover/zadig/pkg/tool/log"
+)
+
+const (
+	cosignExe = "/usr/local/bin/cosign"
+	// cosignPrivateKeyEnv 由warpdrive从Secret注入, cosign直接从环境变量读取 COSIGN_PASSWORD
+	cosignPrivateKeyEnv = "COSIGN_PRIVATE_KEY"
+)
+
+// cosignSign e.g. cosign sign --key cosign.key image:tag
+func cosignSign(keyFile, fullImage string) *exec.Cmd {
+	return exec.Command(cosignExe, "sign", "--key", keyFile, fullImage)
+}
+
+// cosignAttest e.g. cosign attest --key cosign.key --predicate provenance.json --type custom image:tag
+func cosignAttest(keyFile, predicateFile, fullImage string) *exec.Cmd {
+	return exec.Command(cosignExe, "attest", "--key", keyFile, "--predicate", predicateFile, "--type", "custom", fullImage)
+}
+
+// signImage 对已推送的镜像签名, 有构建来源证明时一并附加
+// cosign复用推送镜像时写入的docker配置访问镜像仓库
+func (p *Predator) signImage(fullImage string) error {
+	key := os.Getenv(cosignPrivateKeyEnv)
+	if key == "" {
+		return fmt.Errorf("%s is not set", cosignPrivateKeyEnv)
+	}
+
+	dir, err := ioutil.TempDir("", "cosign")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+
+	keyFile := path.Join(dir, "cosign.key")
+	if err := ioutil.WriteFile(keyFile, []byte(key), 0600); err != nil {
+		return err
+	}
+
+	cmds := []*exec.Cmd{cosignSign(keyFile, fullImage)}
+	if p.Ctx.Signing.Provenance != "" {
+		predicateFile := path.Join(dir, "provenance.json")
+		if err := ioutil.WriteFile(predicateFile, []byte(p.Ctx.Signing.Provenance), 0600); err != nil {
+			return err
+		}
+		cmds = append(cmds, cosignAttest(keyFile, predicateFile, fullImage))
+	}
+
+	for _, cmd := range cmds {
+		cmd.Env = append(os.Environ(), "DOCKER_CONFIG="+path.Join(config.Home(), ".docker"))
+		cmd.Stdout = os.Stdout
+		cmd.Stderr = os.Stderr
+		log.Info(strings.Join(cmd.Args, " "))
+		if err := cmd.Run(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
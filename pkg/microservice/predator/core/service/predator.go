@@ -23,6 +23,13 @@ type Context struct {
 	DockerBuildCtx *DockerBuildCtx `yaml:"build_ctx"`
 	OnSetup        string          `yaml:"setup,omitempty"`
 	ReleaseImages  []RepoImage     `yaml:"release_images"`
+	Signing        *ImageSigning   `yaml:"signing,omitempty"`
+}
+
+// ImageSigning 推送镜像后使用cosign签名, 加密私钥和密码从环境变量 COSIGN_PRIVATE_KEY 和 COSIGN_PASSWORD 读取
+// Provenance: 构建来源证明(JSON), 不为空时作为attestation附加到镜像
+type ImageSigning struct {
+	Provenance string `yaml:"provenance,omitempty"`
 }
 
 type RepoImage struct {
@@ -116,6 +116,12 @@ func (p *Predator) AfterExec() error {
 		if err := cmd.Run(); err != nil {
 			return cmd.Run()
 		}
+
+		if p.Ctx.Signing != nil {
+			if err := p.signImage(image.Name); err != nil {
+				return fmt.Errorf("failed to sign image %s: %v", image.Name, err)
+			}
+		}
 	}
 
 	return nil
@@ -28,6 +28,12 @@ const (
 	// ProviderCodehub
 	ProviderCodehub = "codehub"
 
+	// ProviderGitea
+	ProviderGitea = "gitea"
+
+	// ProviderBitbucket bitbucket server
+	ProviderBitbucket = "bitbucket"
+
 	//	Oauth prefix
 	OauthTokenPrefix = "oauth2"
 
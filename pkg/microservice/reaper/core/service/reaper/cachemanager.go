@@ -178,16 +178,21 @@ func (gcm *TarCacheManager) Unarchive(source, dest string) error {
 }
 
 func (gcm *TarCacheManager) getS3Storage() (*s3.S3, error) {
+	return getCacheS3Storage(gcm.StorageURI, gcm.PipelineName, gcm.ServiceName, "cache")
+}
+
+// getCacheS3Storage 缓存保存在 pipeline/service/folder 目录下
+func getCacheS3Storage(storageURI, pipelineName, serviceName, folder string) (*s3.S3, error) {
 	var err error
 	var store *s3.S3
-	if store, err = s3.NewS3StorageFromEncryptedURI(gcm.StorageURI); err != nil {
-		log.Errorf("Archive failed to create s3 storage %s", gcm.StorageURI)
+	if store, err = s3.NewS3StorageFromEncryptedURI(storageURI); err != nil {
+		log.Errorf("Archive failed to create s3 storage %s", storageURI)
 		return nil, err
 	}
 	if store.Subfolder != "" {
-		store.Subfolder = fmt.Sprintf("%s/%s/%s/%s", store.Subfolder, gcm.PipelineName, gcm.ServiceName, "cache")
+		store.Subfolder = fmt.Sprintf("%s/%s/%s/%s", store.Subfolder, pipelineName, serviceName, folder)
 	} else {
-		store.Subfolder = fmt.Sprintf("%s/%s/%s", gcm.PipelineName, gcm.ServiceName, "cache")
+		store.Subfolder = fmt.Sprintf("%s/%s/%s", pipelineName, serviceName, folder)
 	}
 	return store, nil
 }
@@ -0,0 +1,678 @@
+/*
+Copyright 2021 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package reaper
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"golang.org/x/sync/errgroup"
+
+	"github.com/koderover/zadig/pkg/setting"
+	"github.com/koderover/zadig/pkg/tool/log"
+	s3tool "github.com/koderover/zadig/pkg/tool/s3"
+)
+
+const (
+	// cacheChunkSize 大文件按照固定大小切分, 每个分块单独存储
+	cacheChunkSize = 4 << 20
+	// cacheTransferConcurrency 同时上传或者下载的分块数
+	cacheTransferConcurrency = 8
+	// latestCacheKey 没有lock文件时使用的缓存key, 同时指向最近一次构建的缓存
+	latestCacheKey = "latest"
+)
+
+// defaultCacheKeyFiles 未配置时用来计算缓存key的lock文件, 代码库clone在工作目录的下一级
+var defaultCacheKeyFiles = []string{
+	"go.sum", "*/go.sum",
+	"package-lock.json", "*/package-lock.json",
+	"yarn.lock", "*/yarn.lock",
+	"pom.xml", "*/pom.xml",
+}
+
+// chunkStore 保存缓存分块和清单文件
+type chunkStore interface {
+	Exists(key string) (bool, error)
+	Upload(src, key string) error
+	Download(key, dest string) error
+}
+
+// cacheManifest 记录一次缓存包含的文件以及每个文件对应的分块
+type cacheManifest struct {
+	Key   string       `json:"key"`
+	Files []*cacheFile `json:"files"`
+}
+
+type cacheFile struct {
+	Path   string      `json:"path"`
+	Mode   os.FileMode `json:"mode"`
+	Size   int64       `json:"size,omitempty"`
+	Link   string      `json:"link,omitempty"`
+	Chunks []string    `json:"chunks,omitempty"`
+}
+
+// chunkRef 分块在本地文件中的位置, 上传时从这里读取
+type chunkRef struct {
+	path   string
+	offset int64
+	size   int64
+}
+
+// ChunkCacheManager 将缓存文件按内容哈希分块存储在s3上, 构建结束后只上传发生变化的分块,
+// 缓存清单按照lock文件计算出的key保存, 依赖不变时可以恢复完全一致的缓存
+type ChunkCacheManager struct {
+	StorageURI   string
+	PipelineName string
+	ServiceName  string
+	// Paths 需要缓存的路径, 为空时缓存整个工作目录
+	Paths []string
+	// KeyFiles 用来计算缓存key的lock文件, 支持通配符
+	KeyFiles []string
+
+	store       chunkStore
+	restoredKey string
+	// knownChunks 恢复缓存时已经确认存在的分块, 归档时不需要再检查
+	knownChunks map[string]bool
+}
+
+func NewChunkCacheManager(storageURI, pipelineName, serviceName string, paths, keyFiles []string) *ChunkCacheManager {
+	return &ChunkCacheManager{
+		StorageURI:   storageURI,
+		PipelineName: pipelineName,
+		ServiceName:  serviceName,
+		Paths:        paths,
+		KeyFiles:     keyFiles,
+		knownChunks:  make(map[string]bool),
+	}
+}
+
+// RestoredKey 返回最近一次恢复的缓存key
+func (cm *ChunkCacheManager) RestoredKey() string {
+	return cm.restoredKey
+}
+
+// CacheKey 根据工作目录下的lock文件内容计算缓存key, 找不到lock文件时返回latest
+func (cm *ChunkCacheManager) CacheKey(workspace string) (string, error) {
+	patterns := cm.KeyFiles
+	if len(patterns) == 0 {
+		patterns = defaultCacheKeyFiles
+	}
+
+	files := make(map[string]bool)
+	for _, pattern := range patterns {
+		matches, err := filepath.Glob(filepath.Join(workspace, pattern))
+		if err != nil {
+			return "", fmt.Errorf("invalid cache key file %s: %v", pattern, err)
+		}
+		for _, match := range matches {
+			if info, err := os.Stat(match); err == nil && info.Mode().IsRegular() {
+				files[match] = true
+			}
+		}
+	}
+	if len(files) == 0 {
+		return latestCacheKey, nil
+	}
+
+	sorted := make([]string, 0, len(files))
+	for file := range files {
+		sorted = append(sorted, file)
+	}
+	sort.Strings(sorted)
+
+	h := sha256.New()
+	for _, file := range sorted {
+		rel, _ := filepath.Rel(workspace, file)
+		_, _ = io.WriteString(h, filepath.ToSlash(rel)+"\x00")
+		content, err := ioutil.ReadFile(file)
+		if err != nil {
+			return "", err
+		}
+		_, _ = h.Write(content)
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+func (cm *ChunkCacheManager) Archive(source, dest string) error {
+	store, err := cm.getStore()
+	if err != nil {
+		return err
+	}
+
+	key, err := cm.CacheKey(source)
+	if err != nil {
+		return err
+	}
+
+	manifest, chunks, err := cm.buildManifest(source)
+	if err != nil {
+		log.Errorf("failed to build cache manifest %v", err)
+		return err
+	}
+	manifest.Key = key
+
+	uploaded, err := cm.uploadChunks(store, chunks)
+	if err != nil {
+		log.Errorf("failed to upload cache chunks %v", err)
+		return err
+	}
+	log.Infof("%d files cached with key %s, %d of %d chunks uploaded", len(manifest.Files), key, uploaded, len(chunks))
+
+	temp, err := ioutil.TempFile("", "*manifest.json")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = os.Remove(temp.Name())
+	}()
+	if err := json.NewEncoder(temp).Encode(manifest); err != nil {
+		_ = temp.Close()
+		return err
+	}
+	_ = temp.Close()
+
+	keys := []string{manifestObjectKey(key)}
+	if key != latestCacheKey {
+		keys = append(keys, manifestObjectKey(latestCacheKey))
+	}
+	for _, k := range keys {
+		if err := store.Upload(temp.Name(), k); err != nil {
+			log.Errorf("failed to upload cache manifest %s: %v", k, err)
+			return err
+		}
+	}
+	return nil
+}
+
+func (cm *ChunkCacheManager) Unarchive(source, dest string) error {
+	store, err := cm.getStore()
+	if err != nil {
+		return err
+	}
+
+	key, err := cm.CacheKey(dest)
+	if err != nil {
+		return err
+	}
+
+	manifest, err := cm.downloadManifest(store, key)
+	if err != nil && key != latestCacheKey && cm.restoredKey == "" {
+		// 依赖有变化时先使用最近一次的缓存, 大部分分块仍然可以复用
+		log.Infof("cache of key %s is not found, fallback to the latest cache", key)
+		manifest, err = cm.downloadManifest(store, latestCacheKey)
+	}
+	if err != nil {
+		return fmt.Errorf("cache not found: %v", err)
+	}
+
+	if err := cm.restore(store, manifest, dest); err != nil {
+		return err
+	}
+	cm.restoredKey = manifest.Key
+	return nil
+}
+
+// buildManifest 遍历需要缓存的文件, 计算每个分块的哈希
+func (cm *ChunkCacheManager) buildManifest(source string) (*cacheManifest, map[string]*chunkRef, error) {
+	roots := []string{source}
+	if len(cm.Paths) > 0 {
+		roots = roots[:0]
+		for _, p := range cm.Paths {
+			matches, err := filepath.Glob(filepath.Join(source, p))
+			if err != nil {
+				log.Warningf("%s: %v", p, err)
+				continue
+			}
+			roots = append(roots, matches...)
+		}
+	}
+
+	manifest := &cacheManifest{}
+	chunks := make(map[string]*chunkRef)
+	visited := make(map[string]bool)
+	for _, root := range roots {
+		err := filepath.Walk(root, func(file string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			rel, err := filepath.Rel(source, file)
+			if err != nil || rel == "." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+				return nil
+			}
+			rel = filepath.ToSlash(rel)
+			if visited[rel] {
+				return nil
+			}
+			visited[rel] = true
+
+			entry := &cacheFile{Path: rel, Mode: info.Mode()}
+			switch {
+			case info.IsDir():
+			case info.Mode()&os.ModeSymlink != 0:
+				if entry.Link, err = os.Readlink(file); err != nil {
+					return err
+				}
+			case info.Mode().IsRegular():
+				entry.Size = info.Size()
+				if entry.Chunks, err = hashChunks(file, chunks); err != nil {
+					return err
+				}
+			default:
+				// socket, 设备文件等不需要缓存
+				return nil
+			}
+			manifest.Files = append(manifest.Files, entry)
+			return nil
+		})
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	return manifest, chunks, nil
+}
+
+// hashChunks 按照固定大小切分文件并计算每个分块的sha256, 新出现的分块记录到chunks中
+func hashChunks(file string, chunks map[string]*chunkRef) ([]string, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var hashes []string
+	var offset int64
+	buf := make([]byte, cacheChunkSize)
+	for {
+		n, err := io.ReadFull(f, buf)
+		if n > 0 {
+			sum := sha256.Sum256(buf[:n])
+			hash := hex.EncodeToString(sum[:])
+			hashes = append(hashes, hash)
+			if chunks != nil {
+				if _, ok := chunks[hash]; !ok {
+					chunks[hash] = &chunkRef{path: file, offset: offset, size: int64(n)}
+				}
+			}
+			offset += int64(n)
+		}
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return hashes, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+// uploadChunks 上传存储中还不存在的分块, 返回实际上传的数量
+func (cm *ChunkCacheManager) uploadChunks(store chunkStore, chunks map[string]*chunkRef) (int, error) {
+	var mu sync.Mutex
+	uploaded := 0
+	err := cm.forEachChunk(chunkHashes(chunks), func(hash string) error {
+		mu.Lock()
+		known := cm.knownChunks[hash]
+		mu.Unlock()
+		if known {
+			return nil
+		}
+
+		key := chunkObjectKey(hash)
+		exists, err := store.Exists(key)
+		if err != nil {
+			return err
+		}
+		if !exists {
+			if err := uploadChunk(store, chunks[hash], key); err != nil {
+				return err
+			}
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+		cm.knownChunks[hash] = true
+		if !exists {
+			uploaded++
+		}
+		return nil
+	})
+	return uploaded, err
+}
+
+func uploadChunk(store chunkStore, ref *chunkRef, key string) error {
+	if info, err := os.Stat(ref.path); err == nil && ref.offset == 0 && ref.size == info.Size() {
+		return store.Upload(ref.path, key)
+	}
+
+	src, err := os.Open(ref.path)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	temp, err := ioutil.TempFile("", "*chunk")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = os.Remove(temp.Name())
+	}()
+	_, err = io.Copy(temp, io.NewSectionReader(src, ref.offset, ref.size))
+	_ = temp.Close()
+	if err != nil {
+		return err
+	}
+	return store.Upload(temp.Name(), key)
+}
+
+func (cm *ChunkCacheManager) downloadManifest(store chunkStore, key string) (*cacheManifest, error) {
+	temp, err := ioutil.TempFile("", "*manifest.json")
+	if err != nil {
+		return nil, err
+	}
+	_ = temp.Close()
+	defer func() {
+		_ = os.Remove(temp.Name())
+	}()
+
+	if err := store.Download(manifestObjectKey(key), temp.Name()); err != nil {
+		return nil, err
+	}
+	content, err := ioutil.ReadFile(temp.Name())
+	if err != nil {
+		return nil, err
+	}
+	manifest := &cacheManifest{}
+	if err := json.Unmarshal(content, manifest); err != nil {
+		return nil, fmt.Errorf("invalid cache manifest %s: %v", key, err)
+	}
+	return manifest, nil
+}
+
+// restore 根据清单恢复文件, 本地内容已经一致的文件不会重新下载
+func (cm *ChunkCacheManager) restore(store chunkStore, manifest *cacheManifest, dest string) error {
+	if err := os.MkdirAll(dest, os.ModePerm); err != nil {
+		return err
+	}
+
+	root, err := filepath.EvalSymlinks(dest)
+	if err != nil {
+		return err
+	}
+
+	var files []*cacheFile
+	needed := make(map[string]*chunkRef)
+	for _, file := range manifest.Files {
+		target, err := restorePath(dest, file.Path)
+		if err != nil {
+			return err
+		}
+		if err := checkParentLinks(root, dest, target); err != nil {
+			return err
+		}
+
+		switch {
+		case file.Mode.IsDir():
+			if err := os.MkdirAll(target, file.Mode.Perm()|0700); err != nil {
+				return err
+			}
+		case file.Link != "":
+			if err := checkLinkTarget(dest, target, file.Link); err != nil {
+				return err
+			}
+			if err := os.MkdirAll(filepath.Dir(target), os.ModePerm); err != nil {
+				return err
+			}
+			_ = os.RemoveAll(target)
+			if err := os.Symlink(file.Link, target); err != nil {
+				return err
+			}
+		default:
+			for _, hash := range file.Chunks {
+				cm.knownChunks[hash] = true
+			}
+			if fileMatches(target, file) {
+				continue
+			}
+			for _, hash := range file.Chunks {
+				needed[hash] = nil
+			}
+			files = append(files, file)
+		}
+	}
+	if len(files) == 0 {
+		return nil
+	}
+
+	chunkDir, err := ioutil.TempDir("", "reaper-chunks")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = os.RemoveAll(chunkDir)
+	}()
+
+	err = cm.forEachChunk(chunkHashes(needed), func(hash string) error {
+		return store.Download(chunkObjectKey(hash), filepath.Join(chunkDir, hash))
+	})
+	if err != nil {
+		return fmt.Errorf("failed to download cache chunks: %v", err)
+	}
+
+	for _, file := range files {
+		target, _ := restorePath(dest, file.Path)
+		// 清单中后面的符号链接可能改变了父目录的指向, 写入前需要重新检查
+		if err := checkParentLinks(root, dest, target); err != nil {
+			return err
+		}
+		if err := assembleFile(target, file, chunkDir); err != nil {
+			return err
+		}
+	}
+	log.Infof("%d cached files restored, %d chunks downloaded", len(files), len(needed))
+	return nil
+}
+
+func assembleFile(target string, file *cacheFile, chunkDir string) error {
+	if err := os.MkdirAll(filepath.Dir(target), os.ModePerm); err != nil {
+		return err
+	}
+	_ = os.RemoveAll(target)
+
+	out, err := os.OpenFile(target, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, file.Mode.Perm())
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	for _, hash := range file.Chunks {
+		chunk, err := os.Open(filepath.Join(chunkDir, hash))
+		if err != nil {
+			return err
+		}
+		_, err = io.Copy(out, chunk)
+		_ = chunk.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// fileMatches 检查本地文件是否和缓存中的内容一致
+func fileMatches(target string, file *cacheFile) bool {
+	info, err := os.Lstat(target)
+	if err != nil || !info.Mode().IsRegular() || info.Size() != file.Size {
+		return false
+	}
+	hashes, err := hashChunks(target, nil)
+	if err != nil || len(hashes) != len(file.Chunks) {
+		return false
+	}
+	for i := range hashes {
+		if hashes[i] != file.Chunks[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// restorePath 清单中的路径不能超出工作目录
+func restorePath(dest, rel string) (string, error) {
+	target := filepath.Join(dest, filepath.FromSlash(rel))
+	if !isSubPath(dest, target) {
+		return "", fmt.Errorf("invalid cache file path: %s", rel)
+	}
+	return target, nil
+}
+
+// checkLinkTarget 符号链接指向的路径不能超出工作目录
+func checkLinkTarget(dest, target, link string) error {
+	resolved := link
+	if !filepath.IsAbs(link) {
+		resolved = filepath.Join(filepath.Dir(target), link)
+	}
+	if !isSubPath(dest, resolved) {
+		return fmt.Errorf("invalid cache link %s -> %s", target, link)
+	}
+	return nil
+}
+
+// checkParentLinks 解析target各级父目录中已经存在的符号链接, 避免通过符号链接写到工作目录之外
+// root为解析过符号链接的dest
+func checkParentLinks(root, dest, target string) error {
+	rel, err := filepath.Rel(dest, filepath.Dir(target))
+	if err != nil {
+		return err
+	}
+	if rel == "." {
+		return nil
+	}
+
+	current := dest
+	for _, part := range strings.Split(rel, string(filepath.Separator)) {
+		current = filepath.Join(current, part)
+		info, err := os.Lstat(current)
+		if os.IsNotExist(err) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if info.Mode()&os.ModeSymlink == 0 {
+			continue
+		}
+		resolved, err := filepath.EvalSymlinks(current)
+		if err != nil || !isSubPath(root, resolved) {
+			return fmt.Errorf("invalid cache file path %s: parent %s links outside of workspace", target, current)
+		}
+	}
+	return nil
+}
+
+func isSubPath(dir, path string) bool {
+	r, err := filepath.Rel(dir, path)
+	return err == nil && r != ".." && !strings.HasPrefix(r, ".."+string(filepath.Separator))
+}
+
+func (cm *ChunkCacheManager) forEachChunk(hashes []string, fn func(hash string) error) error {
+	ch := make(chan string)
+	g := new(errgroup.Group)
+	for i := 0; i < cacheTransferConcurrency; i++ {
+		g.Go(func() error {
+			var err error
+			for hash := range ch {
+				if err == nil {
+					err = fn(hash)
+				}
+			}
+			return err
+		})
+	}
+	for _, hash := range hashes {
+		ch <- hash
+	}
+	close(ch)
+	return g.Wait()
+}
+
+func chunkHashes(chunks map[string]*chunkRef) []string {
+	hashes := make([]string, 0, len(chunks))
+	for hash := range chunks {
+		hashes = append(hashes, hash)
+	}
+	sort.Strings(hashes)
+	return hashes
+}
+
+func chunkObjectKey(hash string) string {
+	return path.Join("chunks", hash[:2], hash)
+}
+
+func manifestObjectKey(key string) string {
+	return path.Join("manifests", key+".json")
+}
+
+func (cm *ChunkCacheManager) getStore() (chunkStore, error) {
+	if cm.store != nil {
+		return cm.store, nil
+	}
+
+	store, err := getCacheS3Storage(cm.StorageURI, cm.PipelineName, cm.ServiceName, "chunk-cache")
+	if err != nil {
+		return nil, err
+	}
+	forcedPathStyle := true
+	if store.Provider == setting.ProviderSourceAli {
+		forcedPathStyle = false
+	}
+	s3client, err := s3tool.NewClient(store.Endpoint, store.Ak, store.Sk, store.Insecure, forcedPathStyle)
+	if err != nil {
+		log.Errorf("failed to create s3 client, error is: %+v", err)
+		return nil, err
+	}
+	cm.store = &s3ChunkStore{client: s3client, bucket: store.Bucket, objectPath: store.GetObjectPath}
+	return cm.store, nil
+}
+
+type s3ChunkStore struct {
+	client     *s3tool.Client
+	bucket     string
+	objectPath func(name string) string
+}
+
+func (s *s3ChunkStore) Exists(key string) (bool, error) {
+	return s.client.Exists(s.bucket, s.objectPath(key))
+}
+
+func (s *s3ChunkStore) Upload(src, key string) error {
+	return s.client.Upload(s.bucket, src, s.objectPath(key))
+}
+
+func (s *s3ChunkStore) Download(key, dest string) error {
+	return s.client.Download(s.bucket, s.objectPath(key), dest)
+}
@@ -0,0 +1,186 @@
+/*
+Copyright 2021 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package reaper
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// localChunkStore 用本地目录代替s3
+type localChunkStore struct {
+	dir string
+
+	mu      sync.Mutex
+	uploads []string
+}
+
+func (s *localChunkStore) Exists(key string) (bool, error) {
+	_, err := os.Stat(filepath.Join(s.dir, key))
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func (s *localChunkStore) Upload(src, key string) error {
+	s.mu.Lock()
+	s.uploads = append(s.uploads, key)
+	s.mu.Unlock()
+
+	content, err := ioutil.ReadFile(src)
+	if err != nil {
+		return err
+	}
+	dest := filepath.Join(s.dir, key)
+	if err := os.MkdirAll(filepath.Dir(dest), os.ModePerm); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(dest, content, 0644)
+}
+
+func (s *localChunkStore) Download(key, dest string) error {
+	content, err := ioutil.ReadFile(filepath.Join(s.dir, key))
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(dest, content, 0644)
+}
+
+func (s *localChunkStore) reset() {
+	s.uploads = nil
+}
+
+func writeFile(t *testing.T, path string, content []byte) {
+	assert.Nil(t, os.MkdirAll(filepath.Dir(path), os.ModePerm))
+	assert.Nil(t, ioutil.WriteFile(path, content, 0644))
+}
+
+func newTestChunkCacheManager(store chunkStore, paths []string) *ChunkCacheManager {
+	cm := NewChunkCacheManager("", "pipeline", "service", paths, nil)
+	cm.store = store
+	return cm
+}
+
+func TestChunkCacheManager_ArchiveAndUnarchive(t *testing.T) {
+	storeDir, err := ioutil.TempDir(os.TempDir(), "chunks")
+	assert.Nil(t, err)
+	defer os.RemoveAll(storeDir)
+	workspace, err := ioutil.TempDir(os.TempDir(), "reaper")
+	assert.Nil(t, err)
+	defer os.RemoveAll(workspace)
+
+	big := bytes.Repeat([]byte("0123456789abcdef"), cacheChunkSize/8)
+	writeFile(t, filepath.Join(workspace, "app", "go.sum"), []byte("module v1.0.0 h1:abc"))
+	writeFile(t, filepath.Join(workspace, "app", "vendor", "a.go"), []byte("package a"))
+	writeFile(t, filepath.Join(workspace, "app", "vendor", "big.bin"), big)
+	writeFile(t, filepath.Join(workspace, "app", "main.go"), []byte("package main"))
+	assert.Nil(t, os.Symlink("a.go", filepath.Join(workspace, "app", "vendor", "link.go")))
+
+	store := &localChunkStore{dir: storeDir}
+	cm := newTestChunkCacheManager(store, []string{"app/vendor"})
+	assert.Nil(t, cm.Archive(workspace, ""))
+	// a.go + big.bin 切成的两个相同分块只上传一次, 加上两份清单
+	assert.Len(t, store.uploads, 4)
+
+	key, err := cm.CacheKey(workspace)
+	assert.Nil(t, err)
+	assert.NotEqual(t, latestCacheKey, key)
+
+	// 内容没有变化时不需要重新上传分块
+	store.reset()
+	cm = newTestChunkCacheManager(store, []string{"app/vendor"})
+	assert.Nil(t, cm.Archive(workspace, ""))
+	assert.Len(t, store.uploads, 2)
+
+	restored, err := ioutil.TempDir(os.TempDir(), "reaper")
+	assert.Nil(t, err)
+	defer os.RemoveAll(restored)
+
+	cm = newTestChunkCacheManager(store, []string{"app/vendor"})
+	assert.Nil(t, cm.Unarchive("", restored))
+	assert.Equal(t, key, cm.RestoredKey())
+
+	content, err := ioutil.ReadFile(filepath.Join(restored, "app", "vendor", "big.bin"))
+	assert.Nil(t, err)
+	assert.Equal(t, big, content)
+	link, err := os.Readlink(filepath.Join(restored, "app", "vendor", "link.go"))
+	assert.Nil(t, err)
+	assert.Equal(t, "a.go", link)
+	_, err = os.Stat(filepath.Join(restored, "app", "main.go"))
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestChunkCacheManager_RestoreOutsideWorkspace(t *testing.T) {
+	workspace, err := ioutil.TempDir(os.TempDir(), "reaper")
+	assert.Nil(t, err)
+	defer os.RemoveAll(workspace)
+	outside, err := ioutil.TempDir(os.TempDir(), "outside")
+	assert.Nil(t, err)
+	defer os.RemoveAll(outside)
+
+	cm := NewChunkCacheManager("", "pipeline", "service", nil, nil)
+	for _, manifest := range []*cacheManifest{
+		{Files: []*cacheFile{{Path: "evil", Link: outside}}},
+		{Files: []*cacheFile{{Path: "app/evil", Link: "../../outside"}}},
+	} {
+		assert.NotNil(t, cm.restore(&localChunkStore{}, manifest, workspace))
+	}
+
+	// 工作目录中已有指向外部的符号链接时, 不能通过它写入文件
+	assert.Nil(t, os.Symlink(outside, filepath.Join(workspace, "escape")))
+	manifest := &cacheManifest{Files: []*cacheFile{{Path: "escape/a.go", Mode: 0644, Chunks: []string{"hash"}}}}
+	assert.NotNil(t, cm.restore(&localChunkStore{}, manifest, workspace))
+	_, err = os.Stat(filepath.Join(outside, "a.go"))
+	assert.True(t, os.IsNotExist(err))
+
+	// 工作目录内部的符号链接可以正常恢复
+	manifest = &cacheManifest{Files: []*cacheFile{{Path: "app/link.go", Link: "a.go"}}}
+	assert.Nil(t, cm.restore(&localChunkStore{}, manifest, workspace))
+}
+
+func TestChunkCacheManager_CacheKey(t *testing.T) {
+	workspace, err := ioutil.TempDir(os.TempDir(), "reaper")
+	assert.Nil(t, err)
+	defer os.RemoveAll(workspace)
+
+	cm := NewChunkCacheManager("", "pipeline", "service", nil, nil)
+	key, err := cm.CacheKey(workspace)
+	assert.Nil(t, err)
+	assert.Equal(t, latestCacheKey, key)
+
+	writeFile(t, filepath.Join(workspace, "web", "package-lock.json"), []byte(`{"lockfileVersion": 1}`))
+	first, err := cm.CacheKey(workspace)
+	assert.Nil(t, err)
+	assert.NotEqual(t, latestCacheKey, first)
+
+	writeFile(t, filepath.Join(workspace, "web", "package-lock.json"), []byte(`{"lockfileVersion": 2}`))
+	second, err := cm.CacheKey(workspace)
+	assert.Nil(t, err)
+	assert.NotEqual(t, first, second)
+
+	cm.KeyFiles = []string{"web/yarn.lock"}
+	key, err = cm.CacheKey(workspace)
+	assert.Nil(t, err)
+	assert.Equal(t, latestCacheKey, key)
+}
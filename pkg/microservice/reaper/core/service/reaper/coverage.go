@@ -0,0 +1,253 @@
+/*
+Copyright 2021 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package reaper
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/koderover/zadig/pkg/tool/log"
+	"github.com/koderover/zadig/pkg/types"
+)
+
+// archiveCoverageReport 解析覆盖率报告, 把汇总结果上传到当前任务的测试目录, 原始报告上传到coverage目录
+func (r *Reaper) archiveCoverageReport() error {
+	if r.Ctx.Archive == nil {
+		return nil
+	}
+
+	coveragePath := r.Ctx.GinkgoTest.CoveragePath
+	if !filepath.IsAbs(coveragePath) {
+		coveragePath = filepath.Join(r.ActiveWorkspace, coveragePath)
+	}
+
+	files, err := coverageFiles(coveragePath)
+	if err != nil || len(files) == 0 {
+		log.Warningf("no coverage report is found in %s: %v", coveragePath, err)
+		return nil
+	}
+
+	summary, err := parseCoverageReports(r.Ctx.GinkgoTest.CoverageFormat, files)
+	if err != nil {
+		return err
+	}
+	log.Infof("%s coverage: line %.2f%%, branch %.2f%%", summary.Format, summary.LineRate, summary.BranchRate)
+
+	data, err := json.Marshal(summary)
+	if err != nil {
+		return err
+	}
+	tmpFile, err := ioutil.TempFile("", "coverage")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = os.Remove(tmpFile.Name())
+	}()
+	if _, err := tmpFile.Write(data); err != nil {
+		_ = tmpFile.Close()
+		return err
+	}
+	_ = tmpFile.Close()
+
+	if err := r.uploadTaskFile(tmpFile.Name(), "test", types.CoverageReportFile(r.Ctx.Archive.File)); err != nil {
+		return err
+	}
+	for _, file := range files {
+		if err := r.uploadTaskFile(file, "coverage", filepath.Base(file)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// coverageFiles 覆盖率报告路径为目录时返回目录下的所有文件
+func coverageFiles(path string) ([]string, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return []string{path}, nil
+	}
+
+	var files []string
+	err = filepath.Walk(path, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() {
+			files = append(files, p)
+		}
+		return nil
+	})
+	return files, err
+}
+
+// parseCoverageReports 解析并合并同一格式的多个覆盖率报告
+func parseCoverageReports(format string, files []string) (*types.CoverageSummary, error) {
+	var parse func([]byte, *types.CoverageSummary) error
+	switch format {
+	case types.CoverageFormatCobertura:
+		parse = parseCobertura
+	case types.CoverageFormatJaCoCo:
+		parse = parseJaCoCo
+	case types.CoverageFormatLCOV:
+		parse = parseLCOV
+	case types.CoverageFormatGo:
+		parse = newGoCoverParser()
+	default:
+		return nil, fmt.Errorf("unsupported coverage format: %s", format)
+	}
+
+	summary := &types.CoverageSummary{Format: format}
+	for _, file := range files {
+		data, err := ioutil.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		if err := parse(data, summary); err != nil {
+			return nil, fmt.Errorf("failed to parse %s coverage report %s: %v", format, file, err)
+		}
+	}
+	summary.UpdateRates()
+	return summary, nil
+}
+
+type coberturaReport struct {
+	LinesCovered    int `xml:"lines-covered,attr"`
+	LinesValid      int `xml:"lines-valid,attr"`
+	BranchesCovered int `xml:"branches-covered,attr"`
+	BranchesValid   int `xml:"branches-valid,attr"`
+}
+
+func parseCobertura(data []byte, summary *types.CoverageSummary) error {
+	report := new(coberturaReport)
+	if err := xml.Unmarshal(data, report); err != nil {
+		return err
+	}
+	summary.LinesCovered += report.LinesCovered
+	summary.LinesValid += report.LinesValid
+	summary.BranchesCovered += report.BranchesCovered
+	summary.BranchesValid += report.BranchesValid
+	return nil
+}
+
+type jacocoReport struct {
+	// 只解析report下的汇总计数
+	Counters []struct {
+		Type    string `xml:"type,attr"`
+		Missed  int    `xml:"missed,attr"`
+		Covered int    `xml:"covered,attr"`
+	} `xml:"counter"`
+}
+
+func parseJaCoCo(data []byte, summary *types.CoverageSummary) error {
+	report := new(jacocoReport)
+	if err := xml.Unmarshal(data, report); err != nil {
+		return err
+	}
+	for _, counter := range report.Counters {
+		switch counter.Type {
+		case "LINE":
+			summary.LinesCovered += counter.Covered
+			summary.LinesValid += counter.Covered + counter.Missed
+		case "BRANCH":
+			summary.BranchesCovered += counter.Covered
+			summary.BranchesValid += counter.Covered + counter.Missed
+		}
+	}
+	return nil
+}
+
+// parseLCOV 累加每个源文件的 LF/LH/BRF/BRH 记录
+func parseLCOV(data []byte, summary *types.CoverageSummary) error {
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		i := strings.Index(line, ":")
+		if i < 0 {
+			continue
+		}
+		var field *int
+		switch line[:i] {
+		case "LF":
+			field = &summary.LinesValid
+		case "LH":
+			field = &summary.LinesCovered
+		case "BRF":
+			field = &summary.BranchesValid
+		case "BRH":
+			field = &summary.BranchesCovered
+		default:
+			continue
+		}
+		n, err := strconv.Atoi(line[i+1:])
+		if err != nil {
+			return fmt.Errorf("invalid line %q", line)
+		}
+		*field += n
+	}
+	return scanner.Err()
+}
+
+// newGoCoverParser 按语句统计Go cover profile, 多个profile中的同一代码块只计算一次
+func newGoCoverParser() func([]byte, *types.CoverageSummary) error {
+	blocks := make(map[string]bool)
+	return func(data []byte, summary *types.CoverageSummary) error {
+		scanner := bufio.NewScanner(bytes.NewReader(data))
+		for scanner.Scan() {
+			line := strings.TrimSpace(scanner.Text())
+			if line == "" || strings.HasPrefix(line, "mode:") {
+				continue
+			}
+			// e.g. github.com/koderover/zadig/pkg/util/strings.go:25.50,29.2 2 1
+			fields := strings.Fields(line)
+			if len(fields) != 3 {
+				return fmt.Errorf("invalid line %q", line)
+			}
+			stmts, err := strconv.Atoi(fields[1])
+			if err != nil {
+				return fmt.Errorf("invalid line %q", line)
+			}
+			count, err := strconv.Atoi(fields[2])
+			if err != nil {
+				return fmt.Errorf("invalid line %q", line)
+			}
+
+			covered, seen := blocks[fields[0]]
+			if !seen {
+				summary.LinesValid += stmts
+			}
+			if count > 0 && !covered {
+				summary.LinesCovered += stmts
+				blocks[fields[0]] = true
+			} else if !seen {
+				blocks[fields[0]] = false
+			}
+		}
+		return scanner.Err()
+	}
+}
@@ -0,0 +1,93 @@
+/*
+Copyright 2021 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package reaper
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/koderover/zadig/pkg/types"
+)
+
+func writeCoverageFile(t *testing.T, dir, name, content string) string {
+	file := filepath.Join(dir, name)
+	assert.Nil(t, ioutil.WriteFile(file, []byte(content), 0644))
+	return file
+}
+
+func TestParseCoverageReports(t *testing.T) {
+	dir, err := ioutil.TempDir("", "coverage")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	cobertura := writeCoverageFile(t, dir, "cobertura.xml", `<?xml version="1.0" ?>
+<!DOCTYPE coverage SYSTEM "http://cobertura.sourceforge.net/xml/coverage-04.dtd">
+<coverage line-rate="0.75" branch-rate="0.5" lines-covered="30" lines-valid="40" branches-covered="5" branches-valid="10" version="5.5">
+</coverage>`)
+	jacoco := writeCoverageFile(t, dir, "jacoco.xml", `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<!DOCTYPE report PUBLIC "-//JACOCO//DTD Report 1.1//EN" "report.dtd">
+<report name="demo">
+  <package name="demo"><counter type="LINE" missed="100" covered="100"/></package>
+  <counter type="INSTRUCTION" missed="10" covered="90"/>
+  <counter type="BRANCH" missed="3" covered="1"/>
+  <counter type="LINE" missed="2" covered="6"/>
+</report>`)
+	lcov := writeCoverageFile(t, dir, "lcov.info", `TN:
+SF:src/a.js
+LF:10
+LH:5
+BRF:4
+BRH:1
+end_of_record
+SF:src/b.js
+LF:10
+LH:10
+end_of_record
+`)
+	goCover1 := writeCoverageFile(t, dir, "a.out", `mode: set
+example.com/a/a.go:3.20,5.2 2 1
+example.com/a/a.go:7.20,9.2 3 0
+`)
+	goCover2 := writeCoverageFile(t, dir, "b.out", `mode: set
+example.com/a/a.go:7.20,9.2 3 1
+example.com/a/b.go:3.20,5.2 5 0
+`)
+
+	cases := []struct {
+		format   string
+		files    []string
+		expected *types.CoverageSummary
+	}{
+		{types.CoverageFormatCobertura, []string{cobertura}, &types.CoverageSummary{LineRate: 75, BranchRate: 50, LinesCovered: 30, LinesValid: 40, BranchesCovered: 5, BranchesValid: 10}},
+		{types.CoverageFormatJaCoCo, []string{jacoco}, &types.CoverageSummary{LineRate: 75, BranchRate: 25, LinesCovered: 6, LinesValid: 8, BranchesCovered: 1, BranchesValid: 4}},
+		{types.CoverageFormatLCOV, []string{lcov}, &types.CoverageSummary{LineRate: 75, BranchRate: 25, LinesCovered: 15, LinesValid: 20, BranchesCovered: 1, BranchesValid: 4}},
+		{types.CoverageFormatGo, []string{goCover1, goCover2}, &types.CoverageSummary{LineRate: 50, LinesCovered: 5, LinesValid: 10}},
+	}
+	for _, c := range cases {
+		summary, err := parseCoverageReports(c.format, c.files)
+		assert.Nil(t, err, c.format)
+		c.expected.Format = c.format
+		assert.Equal(t, c.expected, summary, c.format)
+	}
+
+	_, err = parseCoverageReports("unknown", []string{lcov})
+	assert.Error(t, err)
+}
@@ -18,6 +18,7 @@ package reaper
 
 import (
 	"bufio"
+	"context"
 	"encoding/base64"
 	"fmt"
 	"net/url"
@@ -41,7 +42,7 @@ func (r *Reaper) RunGitGc(folder string) error {
 	return cmd.Run()
 }
 
-func (r *Reaper) runGitCmds() error {
+func (r *Reaper) runGitCmds(ctx context.Context) error {
 
 	envs := r.getUserEnvs()
 
@@ -134,7 +135,10 @@ func (r *Reaper) runGitCmds() error {
 		if !c.DisableTrace {
 			log.Infof("%s", strings.Join(c.Cmd.Args, " "))
 		}
-		if err := c.Cmd.Run(); err != nil {
+		if err := runCommand(ctx, c.Cmd); err != nil {
+			if ctx.Err() != nil {
+				return err
+			}
 			if c.IgnoreError {
 				continue
 			}
@@ -192,6 +196,21 @@ func (r *Reaper) buildGitCommands(repo *meta.Repo) []*c.Command {
 			Cmd:          c.RemoteAdd(repo.RemoteName, fmt.Sprintf("%s://%s:%s@%s/%s/%s.git", u.Scheme, user, repo.Password, u.Host, repo.Owner, repo.Name)),
 			DisableTrace: true,
 		})
+	} else if repo.Source == meta.ProviderGitea {
+		u, _ := url.Parse(repo.Address)
+		cmds = append(cmds, &c.Command{
+			Cmd:          c.RemoteAdd(repo.RemoteName, r.Ctx.Git.OAuthCloneURL(repo.Source, repo.OauthToken, u.Host, repo.Owner, repo.Name, u.Scheme)),
+			DisableTrace: true,
+		})
+	} else if repo.Source == meta.ProviderBitbucket {
+		// bitbucket server的http clone地址为 /scm/{project key}/{repo slug}.git
+		u, _ := url.Parse(repo.Address)
+		u.Path = fmt.Sprintf("%s/scm/%s/%s.git", strings.TrimSuffix(u.Path, "/"), strings.ToLower(repo.Owner), repo.Name)
+		u.User = url.UserPassword(repo.User, repo.OauthToken)
+		cmds = append(cmds, &c.Command{
+			Cmd:          c.RemoteAdd(repo.RemoteName, u.String()),
+			DisableTrace: true,
+		})
 	} else {
 		// github
 		cmds = append(cmds, &c.Command{Cmd: c.RemoteAdd(repo.RemoteName, r.Ctx.Git.HTTPSCloneURL(repo.Source, repo.OauthToken, repo.Owner, repo.Name)), DisableTrace: true})
@@ -18,6 +18,7 @@ package reaper
 
 import (
 	"bytes"
+	"context"
 	"fmt"
 	"io"
 	"io/ioutil"
@@ -74,12 +75,19 @@ func NewReaper() (*Reaper, error) {
 
 	reaper := &Reaper{
 		Ctx: ctx,
-		cm:  NewTarCacheManager(ctx.StorageURI, ctx.PipelineName, ctx.ServiceName),
+		cm:  newCacheManager(ctx),
 	}
 
 	return reaper, nil
 }
 
+func newCacheManager(ctx *meta.Context) CacheManager {
+	if ctx.CacheType == setting.BuildCacheTypeChunk {
+		return NewChunkCacheManager(ctx.StorageURI, ctx.PipelineName, ctx.ServiceName, ctx.Caches, ctx.CacheKeyFiles)
+	}
+	return NewTarCacheManager(ctx.StorageURI, ctx.PipelineName, ctx.ServiceName)
+}
+
 func (r *Reaper) GetCacheFile() string {
 	return filepath.Join(r.Ctx.Workspace, "reaper.tar.gz")
 }
@@ -97,7 +105,8 @@ func (r *Reaper) CompressCache(storageURI string) error {
 		log.Errorf("EnsureActiveWorkspace err:%v", err)
 		return err
 	}
-	if len(r.Ctx.Caches) > 0 {
+	// 分块缓存自己处理自定义的缓存目录
+	if len(r.Ctx.Caches) > 0 && r.Ctx.CacheType != setting.BuildCacheTypeChunk {
 		log.Infof("custom caches will be cached: %v", r.Ctx.Caches)
 		if err := r.archiveCustomCaches(r.ActiveWorkspace, r.GetCacheFile(), r.Ctx.Caches); err != nil {
 			return err
@@ -133,6 +142,29 @@ func (r *Reaper) DecompressCache() error {
 	return nil
 }
 
+// restoreKeyedCache lock文件在代码拉取之后才存在, 如果据此算出的缓存key和之前恢复的不同, 重新恢复该key对应的缓存,
+// 只有配置了自定义缓存目录时才会恢复, 避免覆盖刚拉取的代码
+func (r *Reaper) restoreKeyedCache() {
+	cm, ok := r.cm.(*ChunkCacheManager)
+	if !ok || len(r.Ctx.Caches) == 0 || r.Ctx.CleanWorkspace || r.Ctx.ResetCache {
+		return
+	}
+
+	key, err := cm.CacheKey(r.ActiveWorkspace)
+	if err != nil {
+		log.Warningf("failed to compute cache key: %v", err)
+		return
+	}
+	if key == latestCacheKey || key == cm.RestoredKey() {
+		return
+	}
+
+	log.Infof("restoring caches of key %s ...", key)
+	if err := cm.Unarchive(r.GetCacheFile(), r.ActiveWorkspace); err != nil {
+		log.Infof("no cache is found for key %s: %v", key, err)
+	}
+}
+
 func (r *Reaper) EnsureActiveWorkspace(workspace string) error {
 	if workspace == "" {
 		tempWorkspace, err := ioutil.TempDir(os.TempDir(), "reaper")
@@ -305,7 +337,7 @@ func (r *Reaper) dockerCommands() []*exec.Cmd {
 	return cmds
 }
 
-func (r *Reaper) runDockerBuild() error {
+func (r *Reaper) runDockerBuild(ctx context.Context) error {
 	if r.Ctx.DockerBuildCtx != nil {
 		err := r.prepareDockerfile()
 		if err != nil {
@@ -321,10 +353,17 @@ func (r *Reaper) runDockerBuild() error {
 			c.Stderr = os.Stderr
 			c.Dir = r.ActiveWorkspace
 			c.Env = envs
-			if err := c.Run(); err != nil {
+			if err := runCommand(ctx, c); err != nil {
 				return err
 			}
 		}
+
+		// 镜像已经构建成功, 生成SBOM失败不影响构建结果
+		if r.Ctx.DockerBuildCtx.SBOMFormat != "" {
+			if err := r.generateSBOM(ctx); err != nil {
+				log.Warnf("failed to generate sbom: %v", err)
+			}
+		}
 	}
 
 	return nil
@@ -356,11 +395,19 @@ func (r *Reaper) Exec() error {
 		return err
 	}
 
+	// 配置了构建步骤时按步骤执行, 代码拉取需要作为git步骤配置
+	if len(r.Ctx.Steps) > 0 {
+		return r.runSteps()
+	}
+
 	// 运行Git命令
-	if err := r.runGitCmds(); err != nil {
+	if err := r.runGitCmds(context.Background()); err != nil {
 		return err
 	}
 
+	// 根据lock文件恢复缓存
+	r.restoreKeyedCache()
+
 	// 生成Git commits信息
 	if err := r.createReadme(ReadmeFile); err != nil {
 		log.Warningf("create readme file error: %v", err)
@@ -371,7 +418,7 @@ func (r *Reaper) Exec() error {
 		return err
 	}
 
-	return r.runDockerBuild()
+	return r.runDockerBuild(context.Background())
 }
 
 // AfterExec ...
@@ -429,6 +476,14 @@ func (r *Reaper) AfterExec(upStreamErr error) error {
 
 	}
 
+	if r.Ctx.GinkgoTest != nil && r.Ctx.GinkgoTest.CoveragePath != "" {
+		// 将覆盖率报告和汇总结果上传到S3
+		if err = r.archiveCoverageReport(); err != nil {
+			log.Errorf("archiveCoverageReport err %v", err)
+			return err
+		}
+	}
+
 	// should archive file first, since compress cache will clean the workspace
 	if upStreamErr == nil {
 		if r.Ctx.ArtifactInfo == nil {
@@ -0,0 +1,71 @@
+/*
+Copyright 2021 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package reaper
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+
+	"github.com/koderover/zadig/pkg/setting"
+	"github.com/koderover/zadig/pkg/tool/log"
+	"github.com/koderover/zadig/pkg/util"
+)
+
+const syftExe = "syft"
+
+func syftPackages(image, format, dest string) *exec.Cmd {
+	return exec.Command(syftExe, "packages", "docker:"+image, "-q", "-o", format, "--file", dest)
+}
+
+// generateSBOM 使用syft生成已构建镜像的SBOM, 上传到当前任务的sbom目录, 交付中心根据镜像名称找到对应的文件
+func (r *Reaper) generateSBOM(ctx context.Context) error {
+	format := r.Ctx.DockerBuildCtx.SBOMFormat
+	if format != setting.SBOMFormatSPDX && format != setting.SBOMFormatCycloneDX {
+		return fmt.Errorf("unsupported sbom format: %s", format)
+	}
+
+	dir, err := ioutil.TempDir("", "sbom")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = os.RemoveAll(dir)
+	}()
+
+	image := r.Ctx.DockerBuildCtx.ImageName
+	fileName := util.GetSBOMFileName(image)
+	dest := filepath.Join(dir, fileName)
+
+	cmd := syftPackages(image, format, dest)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	cmd.Dir = r.ActiveWorkspace
+	cmd.Env = r.getUserEnvs()
+	if err := runCommand(ctx, cmd); err != nil {
+		return fmt.Errorf("failed to generate sbom of %s: %v", image, err)
+	}
+
+	if err := r.uploadTaskFile(dest, setting.SBOMFileType, fileName); err != nil {
+		return fmt.Errorf("failed to upload sbom of %s: %v", image, err)
+	}
+	log.Infof("sbom of %s uploaded", image)
+	return nil
+}
@@ -0,0 +1,332 @@
+/*
+Copyright 2021 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package reaper
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/koderover/zadig/pkg/microservice/reaper/core/service/meta"
+	"github.com/koderover/zadig/pkg/microservice/reaper/internal/s3"
+	"github.com/koderover/zadig/pkg/setting"
+	"github.com/koderover/zadig/pkg/tool/log"
+	s3tool "github.com/koderover/zadig/pkg/tool/s3"
+)
+
+// runSteps 按顺序执行构建步骤, 某个步骤失败后只执行 on_failure 和 always 的步骤, 返回第一个失败步骤的错误
+func (r *Reaper) runSteps() error {
+	var stepErr error
+	for i, step := range r.Ctx.Steps {
+		if step.Name == "" {
+			step.Name = fmt.Sprintf("step-%d", i)
+		}
+		if !shouldRunStep(step, stepErr != nil) {
+			log.Infof("step %s skipped", step.Name)
+			continue
+		}
+
+		log.Infof("step %s started", step.Name)
+		start := time.Now()
+		err := r.runStep(i, step)
+		if err != nil {
+			log.Errorf("step %s failed after %.2f seconds: %v", step.Name, time.Since(start).Seconds(), err)
+			if stepErr == nil {
+				stepErr = fmt.Errorf("step %s failed: %v", step.Name, err)
+			}
+			continue
+		}
+		log.Infof("step %s succeeded in %.2f seconds", step.Name, time.Since(start).Seconds())
+	}
+
+	return stepErr
+}
+
+// stepStopGracePeriod 步骤超时后等待步骤退出的最长时间
+const stepStopGracePeriod = 30 * time.Second
+
+func shouldRunStep(step *meta.Step, failed bool) bool {
+	switch step.When {
+	case setting.BuildStepAlways:
+		return true
+	case setting.BuildStepOnFailure:
+		return failed
+	default:
+		return !failed
+	}
+}
+
+// runStep 执行单个步骤, 超时后终止步骤中正在执行的命令并返回错误
+// 超时后最多等待stepStopGracePeriod, 不会因为无法终止的步骤一直阻塞构建
+func (r *Reaper) runStep(index int, step *meta.Step) error {
+	ctx := context.Background()
+	if step.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, time.Duration(step.Timeout)*time.Minute)
+		defer cancel()
+	}
+
+	// 敏感信息在日志中需要隐藏
+	r.Ctx.SecretEnvs = append(r.Ctx.SecretEnvs, step.SecretEnvs...)
+
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- r.execStep(ctx, index, step)
+	}()
+
+	select {
+	case err := <-errCh:
+		return err
+	case <-ctx.Done():
+		log.Warningf("step %s timed out, waiting for it to stop", step.Name)
+		select {
+		case <-errCh:
+		case <-time.After(stepStopGracePeriod):
+			log.Warningf("step %s did not stop in %s", step.Name, stepStopGracePeriod)
+		}
+		return fmt.Errorf("timeout after %d minutes", step.Timeout)
+	}
+}
+
+func (r *Reaper) execStep(ctx context.Context, index int, step *meta.Step) error {
+	switch step.Type {
+	case setting.BuildStepShell:
+		return r.runShellStep(ctx, index, step)
+	case setting.BuildStepGit:
+		if err := r.runGitCmds(ctx); err != nil {
+			return err
+		}
+		if err := r.createReadme(ReadmeFile); err != nil {
+			log.Warningf("create readme file error: %v", err)
+		}
+		r.restoreKeyedCache()
+		return nil
+	case setting.BuildStepDockerBuild:
+		if step.DockerBuild != nil {
+			// 步骤中的镜像构建配置只在当前步骤生效
+			dockerBuildCtx := r.Ctx.DockerBuildCtx
+			r.Ctx.DockerBuildCtx = step.DockerBuild
+			defer func() {
+				r.Ctx.DockerBuildCtx = dockerBuildCtx
+			}()
+		}
+		if r.Ctx.DockerBuildCtx == nil {
+			return fmt.Errorf("docker build config is required")
+		}
+		return r.runDockerBuild(ctx)
+	case setting.BuildStepArchive:
+		return r.runArchiveStep(ctx, step)
+	case setting.BuildStepUpload:
+		return r.runUploadStep(ctx, step)
+	case setting.BuildStepJunit:
+		return r.runJunitStep(ctx, step)
+	default:
+		return fmt.Errorf("unsupported step type: %s", step.Type)
+	}
+}
+
+func (r *Reaper) stepEnvs(step *meta.Step) []string {
+	envs := r.getUserEnvs()
+	envs = append(envs, step.Envs.Environs()...)
+	return append(envs, step.SecretEnvs.Environs()...)
+}
+
+func (r *Reaper) runShellStep(ctx context.Context, index int, step *meta.Step) error {
+	scripts := r.prepareScriptsEnv()
+	scripts = append(scripts, step.Scripts...)
+
+	file := filepath.Join(os.TempDir(), fmt.Sprintf("step_script_%d.sh", index))
+	if err := ioutil.WriteFile(file, []byte(strings.Join(scripts, "\n")), 0700); err != nil {
+		return fmt.Errorf("write script file error: %v", err)
+	}
+	defer func() {
+		_ = os.Remove(file)
+	}()
+
+	cmd := exec.Command("/bin/bash", file)
+	cmd.Dir = r.ActiveWorkspace
+	cmd.Env = r.stepEnvs(step)
+
+	cmdOutReader, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+
+	outScanner := bufio.NewScanner(cmdOutReader)
+	go func() {
+		for outScanner.Scan() {
+			fmt.Printf("%s\n", r.maskSecretEnvs(outScanner.Text()))
+		}
+	}()
+
+	cmdErrReader, err := cmd.StderrPipe()
+	if err != nil {
+		return err
+	}
+
+	errScanner := bufio.NewScanner(cmdErrReader)
+	go func() {
+		for errScanner.Scan() {
+			fmt.Printf("%s\n", r.maskSecretEnvs(errScanner.Text()))
+		}
+	}()
+
+	return runCommand(ctx, cmd)
+}
+
+// runArchiveStep 将工作目录下的路径打包成tar.gz文件
+func (r *Reaper) runArchiveStep(ctx context.Context, step *meta.Step) error {
+	if step.Dest == "" || len(step.Paths) == 0 {
+		return fmt.Errorf("dest and paths are required by archive step")
+	}
+
+	dest := step.Dest
+	if !filepath.IsAbs(dest) {
+		dest = filepath.Join(r.ActiveWorkspace, dest)
+	}
+	if err := os.MkdirAll(filepath.Dir(dest), os.ModePerm); err != nil {
+		return err
+	}
+
+	args := []string{"czf", dest, "-C", r.ActiveWorkspace}
+	cmd := exec.Command("tar", append(args, step.Paths...)...)
+	cmd.Dir = r.ActiveWorkspace
+	cmd.Env = r.stepEnvs(step)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	return runCommand(ctx, cmd)
+}
+
+// runCommand 执行命令, ctx结束时终止命令所在的进程组
+// 脚本和docker build都通过子进程执行, 只终止直接启动的进程无法停止整个步骤
+func runCommand(ctx context.Context, cmd *exec.Cmd) error {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- cmd.Wait()
+	}()
+
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		_ = syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+		<-done
+		return ctx.Err()
+	}
+}
+
+// runUploadStep 上传文件到当前任务的文件目录
+func (r *Reaper) runUploadStep(ctx context.Context, step *meta.Step) error {
+	var files []string
+	for _, p := range step.Paths {
+		matches, err := filepath.Glob(filepath.Join(r.ActiveWorkspace, p))
+		if err != nil {
+			return fmt.Errorf("invalid upload path %s: %v", p, err)
+		}
+		if len(matches) == 0 {
+			return fmt.Errorf("no file matches %s", p)
+		}
+		files = append(files, matches...)
+	}
+
+	for _, file := range files {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if info, err := os.Stat(file); err != nil || info.IsDir() {
+			log.Warningf("%s is not a regular file, skipped", file)
+			continue
+		}
+		if err := r.uploadTaskFile(file, "file", filepath.Base(file)); err != nil {
+			return err
+		}
+		log.Infof("%s uploaded", file)
+	}
+	return nil
+}
+
+// runJunitStep 合并junit测试结果并上传到当前任务的测试目录
+func (r *Reaper) runJunitStep(ctx context.Context, step *meta.Step) error {
+	if step.ResultPath == "" {
+		return fmt.Errorf("result path is required by junit step")
+	}
+	resultPath := step.ResultPath
+	if !filepath.IsAbs(resultPath) {
+		resultPath = filepath.Join(r.ActiveWorkspace, resultPath)
+	}
+
+	uploadDir, err := ioutil.TempDir("", "junit")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = os.RemoveAll(uploadDir)
+	}()
+
+	resultFile := fmt.Sprintf("%s-%s.xml", r.Ctx.ServiceName, step.Name)
+	if err := mergeGinkgoTestResults(resultFile, resultPath, uploadDir, r.StartTime); err != nil {
+		return err
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return r.uploadTaskFile(filepath.Join(uploadDir, resultFile), "test", resultFile)
+}
+
+func (r *Reaper) uploadTaskFile(src, fileType, name string) error {
+	if r.Ctx.StorageURI == "" {
+		return fmt.Errorf("storage is not configured")
+	}
+
+	store, err := s3.NewS3StorageFromEncryptedURI(r.Ctx.StorageURI)
+	if err != nil {
+		log.Errorf("failed to create s3 storage %s, err: %s", r.Ctx.StorageURI, err)
+		return err
+	}
+	if store.Subfolder != "" {
+		store.Subfolder = fmt.Sprintf("%s/%s/%d/%s", store.Subfolder, r.Ctx.PipelineName, r.Ctx.TaskID, fileType)
+	} else {
+		store.Subfolder = fmt.Sprintf("%s/%d/%s", r.Ctx.PipelineName, r.Ctx.TaskID, fileType)
+	}
+
+	forcedPathStyle := true
+	if store.Provider == setting.ProviderSourceAli {
+		forcedPathStyle = false
+	}
+	s3client, err := s3tool.NewClient(store.Endpoint, store.Ak, store.Sk, store.Insecure, forcedPathStyle)
+	if err != nil {
+		log.Errorf("failed to create s3 client, error is: %+v", err)
+		return err
+	}
+	if err := s3client.Upload(store.Bucket, src, store.GetObjectPath(name)); err != nil {
+		log.Errorf("failed to upload file %s, %v", src, err)
+		return err
+	}
+	return nil
+}
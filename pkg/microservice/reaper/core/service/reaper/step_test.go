@@ -0,0 +1,115 @@
+/*
+Copyright 2021 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package reaper
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/koderover/zadig/pkg/microservice/reaper/core/service/meta"
+	"github.com/koderover/zadig/pkg/setting"
+)
+
+func TestReaper_RunSteps(t *testing.T) {
+	r := createReaperForTest(t, NewGoCacheManager())
+	assert.Nil(t, r.EnsureActiveWorkspace(""))
+	defer os.RemoveAll(r.ActiveWorkspace)
+	r.Ctx.Paths = os.Getenv("PATH")
+
+	r.Ctx.Steps = []*meta.Step{
+		{Name: "build", Type: setting.BuildStepShell, Envs: meta.EnvVar{"OUTPUT=build.txt"}, Scripts: []string{"echo built > $OUTPUT"}},
+		{Name: "test", Type: setting.BuildStepShell, Scripts: []string{"exit 1"}},
+		{Name: "skipped", Type: setting.BuildStepShell, Scripts: []string{"touch skipped.txt"}},
+		{Name: "notify", Type: setting.BuildStepShell, When: setting.BuildStepOnFailure, Scripts: []string{"touch failed.txt"}},
+		{Name: "cleanup", Type: setting.BuildStepShell, When: setting.BuildStepAlways, Scripts: []string{"touch cleanup.txt"}},
+	}
+
+	err := r.runSteps()
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "step test failed")
+
+	content, err := ioutil.ReadFile(filepath.Join(r.ActiveWorkspace, "build.txt"))
+	assert.Nil(t, err)
+	assert.Equal(t, "built\n", string(content))
+
+	for file, exists := range map[string]bool{
+		"skipped.txt": false,
+		"failed.txt":  true,
+		"cleanup.txt": true,
+	} {
+		_, err := os.Stat(filepath.Join(r.ActiveWorkspace, file))
+		assert.Equal(t, exists, err == nil, file)
+	}
+}
+
+func TestReaper_RunStepsUnsupportedType(t *testing.T) {
+	r := createReaperForTest(t, NewGoCacheManager())
+	assert.Nil(t, r.EnsureActiveWorkspace(""))
+	defer os.RemoveAll(r.ActiveWorkspace)
+
+	r.Ctx.Steps = []*meta.Step{{Type: "unknown"}}
+	assert.Error(t, r.runSteps())
+	assert.Equal(t, "step-0", r.Ctx.Steps[0].Name)
+}
+
+func TestReaper_RunStepsCleanup(t *testing.T) {
+	r := createReaperForTest(t, NewGoCacheManager())
+	assert.Nil(t, r.EnsureActiveWorkspace(""))
+	defer os.RemoveAll(r.ActiveWorkspace)
+	r.Ctx.Paths = os.Getenv("PATH")
+
+	dockerBuildCtx := &meta.DockerBuildCtx{ImageName: "zadig/reaper:latest"}
+	r.Ctx.DockerBuildCtx = dockerBuildCtx
+	r.Ctx.Steps = []*meta.Step{
+		{Name: "build", Type: setting.BuildStepShell, Scripts: []string{"true"}},
+		// 工作目录中没有Dockerfile, 镜像构建会失败
+		{Name: "image", Type: setting.BuildStepDockerBuild, DockerBuild: &meta.DockerBuildCtx{ImageName: "zadig/step:latest", WorkDir: "."}},
+	}
+
+	assert.Error(t, r.runSteps())
+	assert.Equal(t, dockerBuildCtx, r.Ctx.DockerBuildCtx)
+
+	_, err := os.Stat(filepath.Join(os.TempDir(), "step_script_0.sh"))
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestRunCommandCancel(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+
+	// 子进程持有输出管道, 只终止sh时Wait会一直等待子进程退出
+	cmd := exec.Command("sh", "-c", "sleep 30 & sleep 30")
+	cmd.Stdout = &bytes.Buffer{}
+
+	start := time.Now()
+	err := runCommand(ctx, cmd)
+	assert.Equal(t, context.DeadlineExceeded, err)
+	assert.True(t, time.Since(start) < 10*time.Second)
+}
+
+func TestRunCommand(t *testing.T) {
+	assert.Nil(t, runCommand(context.Background(), exec.Command("true")))
+	assert.Error(t, runCommand(context.Background(), exec.Command("false")))
+}
@@ -57,3 +57,12 @@ func DefaultRegistryAK() string {
 func DefaultRegistrySK() string {
 	return viper.GetString(setting.DefaultRegistrySK)
 }
+
+// TaskSlots returns how many pipeline tasks one warpdrive instance runs concurrently.
+func TaskSlots() int {
+	slots := viper.GetInt(setting.WarpDriveTaskSlots)
+	if slots <= 0 {
+		return 1
+	}
+	return slots
+}
@@ -33,6 +33,15 @@ const (
 	TaskSecurity       TaskType = "security"
 	TaskResetImage     TaskType = "reset_image"
 	TaskDistribute     TaskType = "distribute"
+	TaskApproval       TaskType = "approval"
+)
+
+type ApprovalStatus string
+
+const (
+	ApprovalStatusWaiting  ApprovalStatus = "waiting"
+	ApprovalStatusApproved ApprovalStatus = "approved"
+	ApprovalStatusRejected ApprovalStatus = "rejected"
 )
 
 type Status string
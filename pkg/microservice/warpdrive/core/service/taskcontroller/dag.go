@@ -0,0 +1,241 @@
+/*
+Copyright 2021 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package taskcontroller
+
+import (
+	"fmt"
+
+	"github.com/koderover/zadig/pkg/microservice/warpdrive/config"
+	"github.com/koderover/zadig/pkg/microservice/warpdrive/core/service/types/task"
+)
+
+// stageNode is a sub task of a stage, scheduled as a vertex of the stage graph
+type stageNode struct {
+	stagePos int
+	key      string
+	target   string
+	deps     []*stageNode
+	task     *Task
+	done     bool
+}
+
+// stageGraph is the DAG of all sub tasks in the stages of a pipeline task
+// stages with AfterAll are not part of the graph and run after it
+type stageGraph struct {
+	nodes      []*stageNode
+	stageNodes map[int][]*stageNode
+}
+
+// targetOfKey 返回subtask所属的target, 旧任务没有记录target时使用subtask key
+func targetOfKey(stage *task.Stage, key string) string {
+	if target, ok := stage.SubTaskTargets[key]; ok {
+		return target
+	}
+	return key
+}
+
+// newStageGraph builds the sub task graph from the stage dependencies and makes sure it has no cycle
+func newStageGraph(stages []*task.Stage) (*stageGraph, error) {
+	g := &stageGraph{stageNodes: make(map[int][]*stageNode)}
+
+	stagePos := make(map[config.TaskType]int)
+	for pos, stage := range stages {
+		if stage == nil || stage.AfterAll {
+			continue
+		}
+		stagePos[stage.TaskType] = pos
+		for key := range stage.SubTasks {
+			node := &stageNode{stagePos: pos, key: key, target: targetOfKey(stage, key)}
+			g.nodes = append(g.nodes, node)
+			g.stageNodes[pos] = append(g.stageNodes[pos], node)
+		}
+	}
+
+	for pos, stage := range stages {
+		if stage == nil || stage.AfterAll {
+			continue
+		}
+		for _, dep := range stage.DependsOn {
+			depPos, ok := stagePos[dep.TaskType]
+			if !ok || depPos == pos {
+				continue
+			}
+			for _, node := range g.stageNodes[pos] {
+				for _, depNode := range g.stageNodes[depPos] {
+					if dep.SameTarget && depNode.target != node.target {
+						continue
+					}
+					node.deps = append(node.deps, depNode)
+				}
+			}
+		}
+	}
+
+	if err := g.checkAcyclic(); err != nil {
+		return nil, err
+	}
+	return g, nil
+}
+
+func (g *stageGraph) checkAcyclic() error {
+	inDegree := make(map[*stageNode]int)
+	dependents := make(map[*stageNode][]*stageNode)
+	for _, node := range g.nodes {
+		inDegree[node] = len(node.deps)
+		for _, dep := range node.deps {
+			dependents[dep] = append(dependents[dep], node)
+		}
+	}
+
+	var queue []*stageNode
+	for _, node := range g.nodes {
+		if inDegree[node] == 0 {
+			queue = append(queue, node)
+		}
+	}
+
+	visited := 0
+	for len(queue) > 0 {
+		node := queue[0]
+		queue = queue[1:]
+		visited++
+		for _, dependent := range dependents[node] {
+			inDegree[dependent]--
+			if inDegree[dependent] == 0 {
+				queue = append(queue, dependent)
+			}
+		}
+	}
+
+	if visited != len(g.nodes) {
+		return fmt.Errorf("circular dependency found in stages")
+	}
+	return nil
+}
+
+func (n *stageNode) ready() bool {
+	for _, dep := range n.deps {
+		if !dep.done {
+			return false
+		}
+	}
+	return true
+}
+
+// runStageGraph 按照依赖关系调度所有subtask, 依赖全部完成的subtask即可开始执行
+// 每个stage内部的并发数和串行执行时保持一致, 任意subtask失败后不再启动新的subtask
+func (r *taskRunner) runStageGraph(g *stageGraph) {
+	xl := r.xl
+	pipelineTask := r.pipelineTask
+
+	limits := make(map[int]int)
+	running := make(map[int]int)
+	remaining := make(map[int]int)
+	for pos, nodes := range g.stageNodes {
+		stage := pipelineTask.Stages[pos]
+		limits[pos] = getStageConcurrency(stage)
+		remaining[pos] = len(nodes)
+
+		pluginInitiator, ok := r.handler.TaskPlugins[stage.TaskType]
+		if !ok {
+			xl.Errorf("Error to find plugin initiator to init task plugin of type %s", stage.TaskType)
+			updatePipelineStageStatus(config.StatusFailed, pipelineTask, pos, xl)
+			return
+		}
+		for _, node := range nodes {
+			pluginInstance := pluginInitiator(stage.TaskType)
+			node.task = NewTask(r.ctx, r.executeTask, pluginInstance, stage.SubTasks[node.key], pos, node.key, xl)
+		}
+	}
+
+	pending := append([]*stageNode{}, g.nodes...)
+	doneChan := make(chan *stageNode)
+	inFlight := 0
+	stopped := false
+
+	for {
+		if !stopped && r.ctx.Err() == nil {
+			var waiting []*stageNode
+			for _, node := range pending {
+				if !node.ready() || running[node.stagePos] >= limits[node.stagePos] {
+					waiting = append(waiting, node)
+					continue
+				}
+
+				if running[node.stagePos] == 0 && remaining[node.stagePos] == len(g.stageNodes[node.stagePos]) {
+					xl.Infof("start to execute pipeline stage: %s at position: %d", pipelineTask.Stages[node.stagePos].TaskType, node.stagePos)
+					updatePipelineStageStatus(config.StatusRunning, pipelineTask, node.stagePos, xl)
+					r.sendAck()
+				}
+
+				running[node.stagePos]++
+				inFlight++
+				go func(n *stageNode) {
+					n.task.exec()
+					doneChan <- n
+				}(node)
+			}
+			pending = waiting
+		}
+
+		if inFlight == 0 {
+			break
+		}
+
+		node := <-doneChan
+		inFlight--
+		running[node.stagePos]--
+		remaining[node.stagePos]--
+		node.done = true
+		if isFailedStatus(node.task.Status) {
+			stopped = true
+		}
+
+		if remaining[node.stagePos] == 0 {
+			var tasks []*Task
+			for _, n := range g.stageNodes[node.stagePos] {
+				tasks = append(tasks, n.task)
+			}
+			stageStatus := getStageStatus(tasks, xl)
+			xl.Infof("aggregated stage status of stage %d with type %s is: %s", node.stagePos, pipelineTask.Stages[node.stagePos].TaskType, stageStatus)
+			updatePipelineStageStatus(stageStatus, pipelineTask, node.stagePos, xl)
+			r.sendAck()
+		}
+	}
+
+	// 没有subtask的stage不会出现在图中, 和串行执行时一样标记为skipped
+	for pos, stage := range pipelineTask.Stages {
+		if stage != nil && !stage.AfterAll && len(g.stageNodes[pos]) == 0 {
+			updatePipelineStageStatus(config.StatusSkipped, pipelineTask, pos, xl)
+		}
+	}
+
+	// 已经开始但因为其他subtask失败而没有全部执行的stage, 按照已执行的subtask汇总状态
+	for pos, nodes := range g.stageNodes {
+		if remaining[pos] == 0 || remaining[pos] == len(nodes) {
+			continue
+		}
+		var tasks []*Task
+		for _, n := range nodes {
+			if n.done {
+				tasks = append(tasks, n.task)
+			}
+		}
+		updatePipelineStageStatus(getStageStatus(tasks, xl), pipelineTask, pos, xl)
+	}
+	r.sendAck()
+}
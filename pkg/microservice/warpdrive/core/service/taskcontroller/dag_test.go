@@ -0,0 +1,56 @@
+/*
+Copyright 2021 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package taskcontroller
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/koderover/zadig/pkg/microservice/warpdrive/config"
+	"github.com/koderover/zadig/pkg/microservice/warpdrive/core/service/types/task"
+)
+
+func TestNewStageGraphSameTarget(t *testing.T) {
+	assert := assert.New(t)
+
+	stages := []*task.Stage{
+		{
+			TaskType:       config.TaskBuild,
+			SubTasks:       map[string]map[string]interface{}{"api": {}, "api_2": {}},
+			SubTaskTargets: map[string]string{"api": "api", "api_2": "api_2"},
+		},
+		{
+			TaskType:       config.TaskDeploy,
+			SubTasks:       map[string]map[string]interface{}{"api_2": {}, "api_2_1": {}},
+			SubTaskTargets: map[string]string{"api_2": "api_2", "api_2_1": "api_2"},
+			DependsOn:      []*task.StageDependency{{TaskType: config.TaskBuild, SameTarget: true}},
+		},
+	}
+
+	g, err := newStageGraph(stages)
+	assert.NoError(err)
+	for _, node := range g.stageNodes[1] {
+		if assert.Len(node.deps, 1) {
+			assert.Equal("api_2", node.deps[0].key)
+		}
+	}
+
+	stages[0].DependsOn = []*task.StageDependency{{TaskType: config.TaskDeploy}}
+	_, err = newStageGraph(stages)
+	assert.Error(err)
+}
@@ -0,0 +1,112 @@
+/*
+Copyright 2021 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package taskcontroller
+
+import (
+	"fmt"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/koderover/zadig/pkg/microservice/warpdrive/config"
+	plugins "github.com/koderover/zadig/pkg/microservice/warpdrive/core/service/taskplugin"
+	"github.com/koderover/zadig/pkg/microservice/warpdrive/core/service/types/task"
+)
+
+// maxRetryBackoff 重试等待时间的上限
+const maxRetryBackoff = 10 * time.Minute
+
+// getRetryPolicy 返回subtask的重试策略, 服务组件单独配置的策略优先于stage的策略
+func getRetryPolicy(pipelineTask *task.Task, pos int, servicename string) *task.RetryPolicy {
+	if pos < 0 || pos >= len(pipelineTask.Stages) || pipelineTask.Stages[pos] == nil {
+		return nil
+	}
+	stage := pipelineTask.Stages[pos]
+	if policy, ok := stage.ModuleRetryPolicies[servicename]; ok {
+		return policy
+	}
+	// 同一个组件有多个部署目标时subtask key为target_N, 按照subtask记录的target查找组件的策略
+	if target, ok := stage.SubTaskTargets[servicename]; ok {
+		if policy, ok := stage.ModuleRetryPolicies[target]; ok {
+			return policy
+		}
+	}
+	return stage.RetryPolicy
+}
+
+// shouldRetry 判断第attempt次执行结束后是否需要重试, 被取消的subtask不会重试
+func shouldRetry(policy *task.RetryPolicy, attempt int, status config.Status) bool {
+	if policy == nil || attempt >= policy.MaxAttempts {
+		return false
+	}
+
+	retryOn := policy.RetryOn
+	if len(retryOn) == 0 {
+		retryOn = []config.Status{config.StatusFailed, config.StatusTimeout}
+	}
+	for _, s := range retryOn {
+		if s == status && s != config.StatusCancelled {
+			return true
+		}
+	}
+	return false
+}
+
+// retryBackoff 第attempt次执行失败后需要等待的时间, 每次重试翻倍
+func retryBackoff(policy *task.RetryPolicy, attempt int) time.Duration {
+	backoff := time.Duration(policy.Backoff) * time.Second
+	for i := 1; i < attempt && backoff < maxRetryBackoff; i++ {
+		backoff *= 2
+	}
+	if backoff > maxRetryBackoff {
+		backoff = maxRetryBackoff
+	}
+	return backoff
+}
+
+// recordAttempt 在重试前保存subtask本次执行的结果, 并归档日志避免被下一次执行覆盖
+func (r *taskRunner) recordAttempt(pos int, servicename string, attempt int, xl *zap.SugaredLogger) {
+	pipelineTask := r.pipelineTask
+
+	pipelineTask.RwLock.Lock()
+	stage := pipelineTask.Stages[pos]
+	record := &task.SubTaskAttempt{Attempt: attempt}
+	if err := task.IToi(stage.SubTasks[servicename], record); err != nil {
+		xl.Errorf("failed to record attempt %d of sub task %s: %v", attempt, servicename, err)
+	}
+	record.Attempt = attempt
+	pipelineTask.RwLock.Unlock()
+
+	if record.LogFile != "" {
+		archivedName := fmt.Sprintf("retry-%d-%s", attempt, record.LogFile)
+		if err := plugins.ArchiveTaskLog(pipelineTask, record.LogFile, archivedName); err != nil {
+			xl.Warnf("failed to archive log of attempt %d of sub task %s: %v", attempt, servicename, err)
+			record.LogFile = ""
+		} else {
+			record.LogFile = archivedName
+		}
+	}
+
+	pipelineTask.RwLock.Lock()
+	if stage.RetryAttempts == nil {
+		stage.RetryAttempts = make(map[string][]*task.SubTaskAttempt)
+	}
+	stage.RetryAttempts[servicename] = append(stage.RetryAttempts[servicename], record)
+	pipelineTask.RwLock.Unlock()
+
+	r.sendAck()
+}
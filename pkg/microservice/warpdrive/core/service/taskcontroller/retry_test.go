@@ -0,0 +1,78 @@
+/*
+Copyright 2021 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package taskcontroller
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/koderover/zadig/pkg/microservice/warpdrive/config"
+	"github.com/koderover/zadig/pkg/microservice/warpdrive/core/service/types/task"
+)
+
+func TestShouldRetry(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.False(shouldRetry(nil, 1, config.StatusFailed))
+
+	policy := &task.RetryPolicy{MaxAttempts: 3}
+	assert.True(shouldRetry(policy, 1, config.StatusFailed))
+	assert.True(shouldRetry(policy, 2, config.StatusTimeout))
+	assert.False(shouldRetry(policy, 3, config.StatusFailed))
+	assert.False(shouldRetry(policy, 1, config.StatusPassed))
+	assert.False(shouldRetry(policy, 1, config.StatusCancelled))
+
+	policy.RetryOn = []config.Status{config.StatusTimeout, config.StatusCancelled}
+	assert.False(shouldRetry(policy, 1, config.StatusFailed))
+	assert.True(shouldRetry(policy, 1, config.StatusTimeout))
+	assert.False(shouldRetry(policy, 1, config.StatusCancelled))
+}
+
+func TestRetryBackoff(t *testing.T) {
+	assert := assert.New(t)
+
+	policy := &task.RetryPolicy{MaxAttempts: 20, Backoff: 10}
+	assert.Equal(10*time.Second, retryBackoff(policy, 1))
+	assert.Equal(20*time.Second, retryBackoff(policy, 2))
+	assert.Equal(40*time.Second, retryBackoff(policy, 3))
+	assert.Equal(maxRetryBackoff, retryBackoff(policy, 15))
+}
+
+func TestGetRetryPolicy(t *testing.T) {
+	assert := assert.New(t)
+
+	stagePolicy := &task.RetryPolicy{MaxAttempts: 2}
+	modulePolicy := &task.RetryPolicy{MaxAttempts: 3}
+	pipelineTask := &task.Task{
+		Stages: []*task.Stage{
+			{
+				TaskType:            config.TaskDeploy,
+				RetryPolicy:         stagePolicy,
+				ModuleRetryPolicies: map[string]*task.RetryPolicy{"aslan": modulePolicy},
+				SubTaskTargets:      map[string]string{"aslan": "aslan", "aslan_1": "aslan"},
+			},
+		},
+	}
+
+	assert.Equal(modulePolicy, getRetryPolicy(pipelineTask, 0, "aslan"))
+	assert.Equal(modulePolicy, getRetryPolicy(pipelineTask, 0, "aslan_1"))
+	assert.Equal(stagePolicy, getRetryPolicy(pipelineTask, 0, "warpdrive"))
+	assert.Equal(stagePolicy, getRetryPolicy(pipelineTask, 0, "aslan_2"))
+	assert.Nil(getRetryPolicy(pipelineTask, 1, "aslan"))
+}
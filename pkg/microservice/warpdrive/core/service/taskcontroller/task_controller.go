@@ -77,16 +77,14 @@ func InitTaskController(ctx context.Context) error {
 		return fmt.Errorf("ensure nsq topic error: %v", err)
 	}
 
-	execHandler := &ExecHandler{
-		Sender: sender,
-	}
+	execHandler := NewExecHandler(sender, config.TaskSlots())
 
 	processor.AddHandler(execHandler)
 
 	//Add task plugin initiators to exec Handler
 	initTaskPlugins(execHandler)
 
-	cancelHandler := &CancelHandler{}
+	cancelHandler := &CancelHandler{execHandler: execHandler}
 
 	canceller.AddHandler(cancelHandler)
 
@@ -21,6 +21,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/nsqio/go-nsq"
@@ -36,64 +37,135 @@ import (
 	"github.com/koderover/zadig/pkg/util/rand"
 )
 
-var (
-	ctx          context.Context
-	cancel       context.CancelFunc
-	pipelineTask *task.Task
-	pipelineCtx  *task.PipelineCtx
-	itReport     *types.ItReport
-	xl           *zap.SugaredLogger
-)
-
 // ExecHandler ...
 // Sender: sender to send ack/notification
 // TaskPlugins: registered task plugin initiators to initiate specific plugin to execute task
+// slots: limits how many pipeline tasks run concurrently on this warpdrive
+// runners: in-flight pipeline tasks, keyed by pipeline name and task id
 type ExecHandler struct {
 	Sender      *nsq.Producer
 	TaskPlugins map[config.TaskType]plugins.Initiator
+
+	slots   chan struct{}
+	mu      sync.RWMutex
+	runners map[string]*taskRunner
+}
+
+// NewExecHandler returns an ExecHandler which runs at most slots pipeline tasks at the same time.
+func NewExecHandler(sender *nsq.Producer, slots int) *ExecHandler {
+	if slots <= 0 {
+		slots = 1
+	}
+	return &ExecHandler{
+		Sender:  sender,
+		slots:   make(chan struct{}, slots),
+		runners: make(map[string]*taskRunner),
+	}
 }
 
 // CancelHandler ...
-type CancelHandler struct{}
+// execHandler: handler owning the in-flight pipeline tasks to be cancelled
+type CancelHandler struct {
+	execHandler *ExecHandler
+}
+
+// taskRunner holds everything belonging to a single pipeline task execution,
+// so that several tasks can run side by side in one warpdrive instance.
+type taskRunner struct {
+	handler      *ExecHandler
+	ctx          context.Context
+	cancel       context.CancelFunc
+	pipelineTask *task.Task
+	pipelineCtx  *task.PipelineCtx
+	itReport     *types.ItReport
+	xl           *zap.SugaredLogger
+
+	// ackChan serializes the acks of this task, ackDone is closed when all of them are published
+	// ackMu guards ackChan against being closed while a late ack is sent
+	ackChan   chan []byte
+	ackDone   chan struct{}
+	ackMu     sync.Mutex
+	ackClosed bool
+}
+
+func runnerKey(pipelineName string, taskID int64) string {
+	return fmt.Sprintf("%s:%d", pipelineName, taskID)
+}
 
 // HandleMessage ...
 // Message handler to handle task execution message
 func (h *ExecHandler) HandleMessage(message *nsq.Message) error {
-	defer func() {
-		// 每次处理完消息, 等待一段时间不处理新消息
-		time.Sleep(time.Second * 10)
-	}()
-
-	xl = log.SugaredLogger()
+	xl := log.SugaredLogger()
 
-	// 如果存在运行中的 PipelineTask, 则重新requeue pipeline task
+	// 如果没有空闲的slot, 则重新requeue pipeline task
 	// task处理逻辑全部放在requeue之后，防止requeue影响正在运行的task
-	if pipelineTask != nil {
-		xl.Infof("warpdrive instance have one running pipeline task %s:%d", pipelineTask.PipelineName, pipelineTask.TaskID)
+	select {
+	case h.slots <- struct{}{}:
+	default:
+		xl.Infof("warpdrive instance have %d running pipeline tasks, requeue", cap(h.slots))
 		message.Requeue(time.Millisecond * 100)
 		return nil
 	}
 
+	defer func() {
+		// 每次处理完消息, 等待一段时间不处理新消息
+		time.Sleep(time.Second * 10)
+	}()
+
 	// 获取 PipelineTask 内容
+	var pipelineTask *task.Task
 	if err := json.Unmarshal(message.Body, &pipelineTask); err != nil {
 		xl.Errorf("unmarshal PipelineTask error: %v", err)
+		<-h.slots
 		return nil
 	}
 	xl.Infof("receiving pipeline task %s:%d message", pipelineTask.PipelineName, pipelineTask.TaskID)
 
-	// xl - global logger
-	xl = Logger(pipelineTask)
-
-	// 初始化 Context, CancelFunc, PipelineTask
-	ctx, cancel = context.WithCancel(context.Background())
+	// 初始化 Context, CancelFunc, Logger
+	ctx, cancel := context.WithCancel(context.Background())
+	r := &taskRunner{
+		handler:      h,
+		ctx:          ctx,
+		cancel:       cancel,
+		pipelineTask: pipelineTask,
+		xl:           Logger(pipelineTask),
+		ackChan:      make(chan []byte, 100),
+		ackDone:      make(chan struct{}),
+	}
 
-	go h.runPipelineTask(ctx, cancel, xl)
+	key := runnerKey(pipelineTask.PipelineName, pipelineTask.TaskID)
+	h.mu.Lock()
+	h.runners[key] = r
+	h.mu.Unlock()
+
+	go r.publishAcks()
+	go func() {
+		defer func() {
+			h.mu.Lock()
+			delete(h.runners, key)
+			h.mu.Unlock()
+			cancel()
+			<-h.slots
+		}()
+		r.runPipelineTask()
+	}()
 	return nil
 }
 
-func (h *ExecHandler) runPipelineTask(ctx context.Context, cancel context.CancelFunc, xl *zap.SugaredLogger) {
+// getRunner returns the in-flight runner of the given pipeline task, or nil if it does not run here.
+func (h *ExecHandler) getRunner(pipelineName string, taskID int64) *taskRunner {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	return h.runners[runnerKey(pipelineName, taskID)]
+}
+
+func (r *taskRunner) runPipelineTask() {
+	pipelineTask := r.pipelineTask
+	xl := r.xl
+
 	defer func() {
-		h.SendNotification()
+		r.sendNotification()
 
 		if pipelineTask.Type == config.SingleType || pipelineTask.Type == config.WorkflowType {
 			xl.Infof("Pipeline completeGitCheck %s:%d:%s", pipelineTask.PipelineName, pipelineTask.TaskID, pipelineTask.Status)
@@ -102,13 +174,12 @@ func (h *ExecHandler) runPipelineTask(ctx context.Context, cancel context.Cancel
 			}
 		}
 
-		h.SendAck()
+		r.sendAck()
 
-		// 重置 task/itrepot 防止新的task Unmarshal到上次内容
-		pipelineTask = nil
-		pipelineCtx = nil
-		itReport = nil
-		xl.Info("Pipeline task all done, tear down vars.")
+		// 等待所有ACK发送完成
+		r.closeAcks()
+		<-r.ackDone
+		xl.Info("Pipeline task all done, tear down runner.")
 	}()
 
 	// Step 1.1 - 检查配置，如果配置为空，则结束此次Task执行
@@ -145,7 +216,7 @@ func (h *ExecHandler) runPipelineTask(ctx context.Context, cancel context.Cancel
 	// DistDir: pipeline distribute dir
 	// DockerMountDir: docker mount dir
 	// ConfigMapMountDir: config map mount dir
-	pipelineCtx = &task.PipelineCtx{
+	r.pipelineCtx = &task.PipelineCtx{
 		DockerHost:        dockerHost,
 		Workspace:         fmt.Sprintf("%s/%s", pipelineTask.ConfigPayload.S3Storage.Path, pipelineTask.PipelineName),
 		DistDir:           fmt.Sprintf("%s/%s/dist/%d", pipelineTask.ConfigPayload.S3Storage.Path, pipelineTask.PipelineName, pipelineTask.TaskID),
@@ -158,18 +229,18 @@ func (h *ExecHandler) runPipelineTask(ctx context.Context, cancel context.Cancel
 	xl.Infof("start to run pipeline task %s:%d ......", pipelineTask.PipelineName, pipelineTask.TaskID)
 	initPipelineTask(pipelineTask, xl)
 	// 发送初始状态ACK给backend，更新pipeline状态
-	h.SendAck()
-	h.SendNotification()
+	r.sendAck()
+	r.sendNotification()
 
 	// Step 3 - pipelineTask执行，真的开始了...
-	h.execute(ctx, pipelineTask, pipelineCtx, xl)
+	r.execute()
 
 	// Return 之前会执行defer内容，更新pipeline end time, 发送ACK，发送notification
 }
 
 // HandleMessage ...
 func (h *CancelHandler) HandleMessage(message *nsq.Message) error {
-	xl = Logger(pipelineTask)
+	xl := log.SugaredLogger()
 
 	// 获取 cancel message
 	var msg *CancelMessage
@@ -180,14 +251,15 @@ func (h *CancelHandler) HandleMessage(message *nsq.Message) error {
 
 	xl.Infof("receiving cancel task %s:%d message", msg.PipelineName, msg.TaskID)
 
-	// 如果存在处理的 PipelineTask 并且匹配 PipelineName, 则取消PipelineTask
-	if pipelineTask != nil && pipelineTask.PipelineName == msg.PipelineName && pipelineTask.TaskID == msg.TaskID {
-		xl.Infof("cancelling message: %+v", msg)
-		pipelineTask.TaskRevoker = msg.Revoker
+	// 如果存在处理中的 PipelineTask 并且匹配 PipelineName 和 TaskID, 则取消PipelineTask
+	if r := h.execHandler.getRunner(msg.PipelineName, msg.TaskID); r != nil {
+		r.xl.Infof("cancelling message: %+v", msg)
+		r.pipelineTask.RwLock.Lock()
+		r.pipelineTask.TaskRevoker = msg.Revoker
+		r.pipelineTask.RwLock.Unlock()
 
 		//取消pipelineTask
-		cancel()
-		//xl.Debug("no matched pipeline task found on this warpdrive")
+		r.cancel()
 	}
 	return nil
 }
@@ -196,15 +268,14 @@ func (h *CancelHandler) HandleMessage(message *nsq.Message) error {
 // helper functions
 // ----------------------------------------------------------------------------------------------
 
-// SendAck 发送task实时状态信息
+// sendAck 发送task实时状态信息
 // 无需发送cancel信息
-func (h *ExecHandler) SendAck() {
-	xl = Logger(pipelineTask)
+func (r *taskRunner) sendAck() {
 	pb, err := func() ([]byte, error) {
-		pipelineTask.RwLock.Lock()
-		defer pipelineTask.RwLock.Unlock()
+		r.pipelineTask.RwLock.Lock()
+		defer r.pipelineTask.RwLock.Unlock()
 
-		pb, err := json.Marshal(&pipelineTask)
+		pb, err := json.Marshal(r.pipelineTask)
 		if err != nil {
 			return nil, err
 		}
@@ -212,35 +283,62 @@ func (h *ExecHandler) SendAck() {
 	}()
 
 	if err != nil {
-		xl.Errorf("marshal PipelineTask error: %v", err)
+		r.xl.Errorf("marshal PipelineTask error: %v", err)
 		return
 	}
 
 	//DEBUG ONLY
-	xl.Infof("Sending ACK: %#v", pipelineTask)
+	r.xl.Infof("Sending ACK: %#v", r.pipelineTask)
 
-	if err := h.Sender.Publish(setting.TopicAck, pb); err != nil {
-		xl.Errorf("publish [%s] error: %v", setting.TopicAck, err)
+	r.ackMu.Lock()
+	defer r.ackMu.Unlock()
+	// task结束后不再接受新的ACK
+	if r.ackClosed {
+		r.xl.Warnf("ack channel of %s:%d is closed, ACK dropped", r.pipelineTask.PipelineName, r.pipelineTask.TaskID)
 		return
 	}
+	r.ackChan <- pb
+}
+
+// closeAcks 关闭ackChan, 之后发送的ACK会被丢弃
+func (r *taskRunner) closeAcks() {
+	r.ackMu.Lock()
+	defer r.ackMu.Unlock()
+
+	if !r.ackClosed {
+		r.ackClosed = true
+		close(r.ackChan)
+	}
 }
 
-// SendItReport ...
-func (h *ExecHandler) SendItReport() {
-	pb, err := json.Marshal(&itReport)
+// publishAcks 按顺序发送当前task的ACK, 直到ackChan被关闭
+func (r *taskRunner) publishAcks() {
+	defer close(r.ackDone)
+
+	for pb := range r.ackChan {
+		if err := r.handler.Sender.Publish(setting.TopicAck, pb); err != nil {
+			r.xl.Errorf("publish [%s] error: %v", setting.TopicAck, err)
+		}
+	}
+}
+
+// sendItReport ...
+func (r *taskRunner) sendItReport() {
+	pb, err := json.Marshal(r.itReport)
 	if err != nil {
-		xl.Errorf("marshal itReport error: %v", err)
+		r.xl.Errorf("marshal itReport error: %v", err)
 		return
 	}
 
-	if err := h.Sender.Publish(setting.TopicItReport, pb); err != nil {
-		xl.Errorf("publish [%s] error: %v", setting.TopicItReport, err)
+	if err := r.handler.Sender.Publish(setting.TopicItReport, pb); err != nil {
+		r.xl.Errorf("publish [%s] error: %v", setting.TopicItReport, err)
 		return
 	}
 }
 
-// SendNotification ...
-func (h *ExecHandler) SendNotification() {
+// sendNotification ...
+func (r *taskRunner) sendNotification() {
+	pipelineTask := r.pipelineTask
 	notify := &types.Notify{
 		Type:     config.PipelineStatus,
 		Receiver: pipelineTask.TaskCreator,
@@ -258,19 +356,21 @@ func (h *ExecHandler) SendNotification() {
 
 	nb, err := json.Marshal(notify)
 	if err != nil {
-		xl.Errorf("marshal Notify error: %v", err)
+		r.xl.Errorf("marshal Notify error: %v", err)
 		return
 	}
 
-	if err := h.Sender.Publish(setting.TopicNotification, nb); err != nil {
-		xl.Errorf("publish [%s] error: %v", setting.TopicNotification, err)
+	if err := r.handler.Sender.Publish(setting.TopicNotification, nb); err != nil {
+		r.xl.Errorf("publish [%s] error: %v", setting.TopicNotification, err)
 		return
 	}
 }
 
-func (h *ExecHandler) runStage(stagePosition int, stage *task.Stage) {
+func (r *taskRunner) runStage(stagePosition int, stage *task.Stage) {
+	xl := r.xl
+	pipelineTask := r.pipelineTask
 	xl.Infof("start to execute pipeline stage: %s at position: %d", stage.TaskType, stagePosition)
-	pluginInitiator, ok := h.TaskPlugins[stage.TaskType]
+	pluginInitiator, ok := r.handler.TaskPlugins[stage.TaskType]
 	if !ok {
 		xl.Errorf("Error to find plugin initiator to init task plugin of type %s", stage.TaskType)
 		return
@@ -279,20 +379,8 @@ func (h *ExecHandler) runStage(stagePosition int, stage *task.Stage) {
 	xl.Info("start to init worker pool for execute tasks in stage")
 	// 初始化stage status为running
 	updatePipelineStageStatus(config.StatusRunning, pipelineTask, stagePosition, xl)
-	h.SendAck()
-	// runParallel: Stage内部是否支持并发
-	runParallel := stage.RunParallel
-	// Default worker concurrency is 1, run tasks sequentially
-	var workerConcurrency = 1
-	if runParallel {
-		// MaxWorkerInParallel is 5 for now
-		if len(stage.SubTasks) > maxWorkerInParallel {
-			workerConcurrency = maxWorkerInParallel
-		} else {
-			workerConcurrency = len(stage.SubTasks)
-		}
-
-	}
+	r.sendAck()
+	workerConcurrency := getStageConcurrency(stage)
 	xl.Infof("set worker concurrency to: %d", workerConcurrency)
 
 	// Task is struct for worker
@@ -304,19 +392,8 @@ func (h *ExecHandler) runStage(stagePosition int, stage *task.Stage) {
 		xl.Infof("new sub task of service name: %s, type: %s", serviceName, stage.TaskType)
 		pluginInstance = pluginInitiator(stage.TaskType)
 		//xl.Errorf("%v", ctx.Value(CtxKeyBuildInfos))
-		tasks = append(tasks, NewTask(ctx, h.executeTask, pluginInstance, subTask, stagePosition, serviceName, xl))
-	}
-	// 判断subTask是否是deploy，如果是的话判断是否是helm类型的服务，
-	//todo helm类型的服务的部署暂时只支持串行执行
-	for _, subTask := range stage.SubTasks {
-		if deploy, err := plugins.ToDeployTask(subTask); err == nil {
-			if deploy.ServiceType == "helm" {
-				workerConcurrency = 1
-				break
-			}
-		}
+		tasks = append(tasks, NewTask(r.ctx, r.executeTask, pluginInstance, subTask, stagePosition, serviceName, xl))
 	}
-
 	// 设置WorkPool来控制最大并发数和并发执行
 	workerPool := NewPool(tasks, workerConcurrency)
 	// 发起workerConcurrency个并发执行，等待所有Task执行完成并返回
@@ -328,13 +405,15 @@ func (h *ExecHandler) runStage(stagePosition int, stage *task.Stage) {
 	stage.Status = stageStatus
 	// 更新Stage状态
 	updatePipelineStageStatus(stage.Status, pipelineTask, stagePosition, xl)
-	h.SendAck()
+	r.sendAck()
 }
 
 // execute: PipelineTask Executor
 // 兼容支持1.0和2.0的数据结构
 // 支持根据RunParallel参数指定的并发或串行执行
-func (h *ExecHandler) execute(ctx context.Context, pipelineTask *task.Task, pipelineCtx *task.PipelineCtx, xl *zap.SugaredLogger) {
+func (r *taskRunner) execute() {
+	xl := r.xl
+	pipelineTask := r.pipelineTask
 	xl.Info("start pipeline task executor...")
 	// 如果是pipeline 1.0， 先将subtasks进行transform，转化为stages结构
 	if pipelineTask.Type == config.SingleType || pipelineTask.Type == "" {
@@ -347,33 +426,78 @@ func (h *ExecHandler) execute(ctx context.Context, pipelineTask *task.Task, pipe
 		}
 	}
 
-	// Stage之间仅支持串行
-	for stagePosition, stage := range pipelineTask.Stages {
-		if !stage.AfterAll {
-			h.runStage(stagePosition, stage)
-			// 如果一个Stage执行失败了，跳出执行循环，并且更新pipelinetask状态为失败，发送ACK，并返回
-			if stage.Status == config.StatusFailed || stage.Status == config.StatusCancelled || stage.Status == config.StatusTimeout {
-				break
+	var graphErr error
+	if pipelineTask.DAGEnabled {
+		// Stage之间按照依赖关系以DAG方式调度, 依赖关系错误时不执行任何stage, 但仍然执行AfterAll的stage并上报任务状态
+		var graph *stageGraph
+		graph, graphErr = newStageGraph(pipelineTask.Stages)
+		if graphErr != nil {
+			xl.Errorf("error when building stage graph: %v", graphErr)
+			pipelineTask.Error = graphErr.Error()
+		} else {
+			r.runStageGraph(graph)
+		}
+	} else {
+		// Stage之间串行执行
+		for stagePosition, stage := range pipelineTask.Stages {
+			if !stage.AfterAll {
+				r.runStage(stagePosition, stage)
+				// 如果一个Stage执行失败了，跳出执行循环，并且更新pipelinetask状态为失败，发送ACK，并返回
+				if isFailedStatus(stage.Status) {
+					break
+				}
 			}
 		}
 	}
 
 	for stagePosition, stage := range pipelineTask.Stages {
 		if stage.AfterAll {
-			h.runStage(stagePosition, stage)
+			r.runStage(stagePosition, stage)
 		}
 	}
 
 	// 根据stage status汇总pipeline task状态，并且更新pipeline状态，发送ACK
 	updatePipelineStatus(pipelineTask, xl)
-	h.SendAck()
+	if graphErr != nil {
+		pipelineTask.Status = config.StatusFailed
+	}
+	r.sendAck()
 }
 
 // executeTask
 // 执行单个subtask，并将subtask执行状态更新到pipelineTask中
 // 返回Task状态+Error，Task Status将在Stage Level进行Aggregation到Stage Status
 // SubTask终止状态包括：disabled, passed, skipped, timeout, failed, cancelled.
-func (h *ExecHandler) executeTask(taskCtx context.Context, plugin plugins.TaskPlugin, subTask map[string]interface{}, pos int, servicename string, xl *zap.SugaredLogger) (config.Status, error) {
+// stage配置了重试策略时, 失败的subtask会按照策略重新执行, 每次失败的结果记录在stage的RetryAttempts中
+func (r *taskRunner) executeTask(taskCtx context.Context, plugin plugins.TaskPlugin, subTask map[string]interface{}, pos int, servicename string, xl *zap.SugaredLogger) (config.Status, error) {
+	policy := getRetryPolicy(r.pipelineTask, pos, servicename)
+
+	status, err := r.executeSubTask(taskCtx, plugin, subTask, pos, servicename, xl)
+	for attempt := 1; shouldRetry(policy, attempt, status) && taskCtx.Err() == nil; attempt++ {
+		r.recordAttempt(pos, servicename, attempt, xl)
+
+		backoff := retryBackoff(policy, attempt)
+		xl.Infof("retry sub task [%s:%s] after %s, attempt %d of %d", plugin.Type(), servicename, backoff, attempt+1, policy.MaxAttempts)
+		select {
+		case <-taskCtx.Done():
+			return status, err
+		case <-time.After(backoff):
+		}
+
+		pluginInitiator, ok := r.handler.TaskPlugins[plugin.Type()]
+		if !ok {
+			break
+		}
+		// 每次重试使用新的plugin实例和原始的subtask参数
+		plugin = pluginInitiator(plugin.Type())
+		status, err = r.executeSubTask(taskCtx, plugin, subTask, pos, servicename, xl)
+	}
+
+	return status, err
+}
+
+func (r *taskRunner) executeSubTask(taskCtx context.Context, plugin plugins.TaskPlugin, subTask map[string]interface{}, pos int, servicename string, xl *zap.SugaredLogger) (config.Status, error) {
+	pipelineTask := r.pipelineTask
 	//设置Plugin执行参数：JOBNAME; 设置plugin logger;设置plugin log文件名称
 	//e.g. build task JOBNAME = pipelinename-taskid-buildv2-bsonId
 	//e.g. build task FILENAME(singgle模式) = pipelinename-taskid-buildv2-servicename
@@ -444,21 +568,21 @@ func (h *ExecHandler) executeTask(taskCtx context.Context, plugin plugins.TaskPl
 	plugin.ResetError()
 
 	updatePipelineSubTask(plugin.GetTask(), pipelineTask, pos, servicename, xl)
-	h.SendAck()
+	r.sendAck()
 
 	plugin.SetAckFunc(func() {
 		updatePipelineSubTask(plugin.GetTask(), pipelineTask, pos, servicename, xl)
-		h.SendAck()
+		r.sendAck()
 	})
 
 	xl.Info("start to call plugin.Run")
 	// 如果是并行跑，用servicename来区分不同的workspace
-	runCtx := *pipelineCtx
+	runCtx := *r.pipelineCtx
 	if pipelineTask.Type == config.WorkflowType {
-		runCtx.Workspace = fmt.Sprintf("%s/%s", pipelineCtx.Workspace, servicename)
+		runCtx.Workspace = fmt.Sprintf("%s/%s", r.pipelineCtx.Workspace, servicename)
 	}
 	// 运行 SubTask, 如果需要异步，请在方法内实现
-	plugin.Run(ctx, pipelineTask, &runCtx, servicename)
+	plugin.Run(taskCtx, pipelineTask, &runCtx, servicename)
 
 	// 如果 SubTask 执行失败, 则不继续执行, 发送 Task 失败执行结果
 	// Failed, Timeout, Cancelled
@@ -472,24 +596,24 @@ func (h *ExecHandler) executeTask(taskCtx context.Context, plugin plugins.TaskPl
 
 	// 等待完成前, 更新 SubTask 执行结果到 PipelineTask
 	updatePipelineSubTask(plugin.GetTask(), pipelineTask, pos, servicename, xl)
-	h.SendAck()
+	r.sendAck()
 
 	// 等待 SubTask 结束
 	xl.Infof("waiting %s task to complete ...", plugin.Type())
-	plugin.Wait(ctx)
+	plugin.Wait(taskCtx)
 	xl.Infof("task status: %s", plugin.Status())
 
-	plugin.Complete(ctx, pipelineTask, servicename)
+	plugin.Complete(taskCtx, pipelineTask, servicename)
 	xl.Infof("task status: %s", plugin.Status())
 
 	// XXX - TODO需要确认这里的逻辑是？
-	if itReport != nil {
-		h.SendItReport()
+	if r.itReport != nil {
+		r.sendItReport()
 	}
 	// 更新 SubTask 执行结果到 PipelineTask
 	plugin.SetEndTime()
 	updatePipelineSubTask(plugin.GetTask(), pipelineTask, pos, servicename, xl)
-	h.SendAck()
+	r.sendAck()
 
 	xl.Infof("end sub task [%s:%s]", plugin.Type(), plugin.Status())
 	return plugin.Status(), nil
@@ -499,7 +623,7 @@ func Logger(pipelineTask *task.Task) *zap.SugaredLogger {
 	// 初始化Logger
 	l := log.Logger()
 	if pipelineTask != nil {
-		l.With(zap.String(setting.RequestID, pipelineTask.ReqID))
+		l = l.With(zap.String(setting.RequestID, pipelineTask.ReqID))
 	}
 
 	return l.Sugar()
@@ -143,6 +143,9 @@ func updatePipelineSubTask(t interface{}, pipelineTask *task.Task, pos int, serv
 // 一个Stage执行结束后，更新PipelineTask的Stage状态
 func updatePipelineStageStatus(stageStatus config.Status, pipelineTask *task.Task, pos int, xl *zap.SugaredLogger) {
 	xl.Infof("updating pipeline task, stage status: %s, stage position: %d", stageStatus, pos)
+	pipelineTask.RwLock.Lock()
+	defer pipelineTask.RwLock.Unlock()
+
 	if pipelineTask.Stages[pos] == nil {
 		pipelineTask.Stages[pos] = &task.Stage{}
 	}
@@ -153,7 +156,7 @@ func updatePipelineStatus(pipelineTask *task.Task, xl *zap.SugaredLogger) {
 	pipelineTask.EndTime = time.Now().Unix()
 	//这里不需要处理1.0还是2.0了，因为stage内容已经都更新了，所以根据stage来判断就好
 	for _, stage := range pipelineTask.Stages {
-		if stage.Status == config.StatusFailed || stage.Status == config.StatusCancelled || stage.Status == config.StatusTimeout {
+		if isFailedStatus(stage.Status) {
 			pipelineTask.Status = stage.Status
 			xl.Infof("Pipeline task completed abnormal: %s:%d:%s %+v", pipelineTask.PipelineName, pipelineTask.TaskID, pipelineTask.Status, pipelineTask)
 			return
@@ -164,6 +167,35 @@ func updatePipelineStatus(pipelineTask *task.Task, xl *zap.SugaredLogger) {
 	xl.Infof("%+v", pipelineTask)
 }
 
+// isFailedStatus 判断stage或者subtask是否以失败结束, 包括failed, cancelled, timeout
+func isFailedStatus(status config.Status) bool {
+	return status == config.StatusFailed || status == config.StatusCancelled || status == config.StatusTimeout
+}
+
+// getStageConcurrency 计算stage内部子任务的最大并发数
+func getStageConcurrency(stage *task.Stage) int {
+	// Default worker concurrency is 1, run tasks sequentially
+	if !stage.RunParallel {
+		return 1
+	}
+
+	// 判断subTask是否是deploy，如果是的话判断是否是helm类型的服务，
+	//todo helm类型的服务的部署暂时只支持串行执行
+	for _, subTask := range stage.SubTasks {
+		if deploy, err := plugins.ToDeployTask(subTask); err == nil {
+			if deploy.ServiceType == "helm" {
+				return 1
+			}
+		}
+	}
+
+	// MaxWorkerInParallel is 5 for now
+	if len(stage.SubTasks) > maxWorkerInParallel {
+		return maxWorkerInParallel
+	}
+	return len(stage.SubTasks)
+}
+
 //汇总Stage Status
 //制定Status Map，遍历Tasks状态，根据Map赋值。
 //最后取值最大的那个状态。
@@ -549,6 +581,7 @@ func initTaskPlugins(execHandler *ExecHandler) {
 		config.TaskReleaseImage:   plugins.InitializeReleaseImagePlugin,
 		config.TaskDistributeToS3: plugins.InitializeDistribute2S3TaskPlugin,
 		config.TaskResetImage:     plugins.InitializeDeployTaskPlugin,
+		config.TaskApproval:       plugins.InitializeApprovalTaskPlugin,
 	}
 	for name, pluginInitiator := range pluginConf {
 		registerTaskPlugin(execHandler, name, pluginInitiator)
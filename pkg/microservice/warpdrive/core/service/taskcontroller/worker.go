@@ -108,6 +108,11 @@ func NewTask(ctx context.Context, taskExecutorFunc taskOperater, plugin plugins.
 // Run runs a Task and does appropriate accounting via a
 // given sync.WorkGroup.
 func (t *Task) Run(wg *sync.WaitGroup) {
-	t.Status, t.Err = t.taskExecutorFunc(t.ctx, t.plugin, t.subTask, t.pos, t.servicename, t.log)
 	defer wg.Done()
+	t.exec()
+}
+
+// exec runs a Task and records its status and error.
+func (t *Task) exec() {
+	t.Status, t.Err = t.taskExecutorFunc(t.ctx, t.plugin, t.subTask, t.pos, t.servicename, t.log)
 }
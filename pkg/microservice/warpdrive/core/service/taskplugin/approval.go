@@ -0,0 +1,231 @@
+/*
+Copyright 2021 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package taskplugin
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"go.uber.org/zap"
+
+	configbase "github.com/koderover/zadig/pkg/config"
+	"github.com/koderover/zadig/pkg/microservice/warpdrive/config"
+	"github.com/koderover/zadig/pkg/microservice/warpdrive/core/service/types/task"
+	"github.com/koderover/zadig/pkg/setting"
+	"github.com/koderover/zadig/pkg/tool/httpclient"
+)
+
+// InitializeApprovalTaskPlugin ...
+func InitializeApprovalTaskPlugin(taskType config.TaskType) TaskPlugin {
+	return &ApprovalPlugin{
+		Name:      taskType,
+		errorChan: make(chan error, 1),
+		httpClient: httpclient.New(
+			httpclient.SetAuthScheme(setting.RootAPIKey),
+			httpclient.SetAuthToken(config.PoetryAPIRootKey()),
+			httpclient.SetHostURL(configbase.AslanServiceAddress()),
+		),
+	}
+}
+
+const (
+	// ApprovalTaskTimeout 等待审批的默认时间, 单位为分钟
+	ApprovalTaskTimeout  = 60 * 24 // 1 day
+	approvalPollInterval = 5 * time.Second
+)
+
+// ApprovalPlugin 暂停工作流任务, 直到审批人在aslan中通过或者拒绝
+type ApprovalPlugin struct {
+	Name         config.TaskType
+	ApprovalTask *task.Approval
+	Log          *zap.SugaredLogger
+	errorChan    chan error
+
+	pipelineName string
+	taskID       int64
+	httpClient   *httpclient.Client
+}
+
+// workflowApproval aslan审批记录中warpdrive用到的字段, 审批人的校验由aslan负责
+type workflowApproval struct {
+	PipelineName string                `json:"pipeline_name"`
+	TaskID       int64                 `json:"task_id"`
+	Status       config.ApprovalStatus `json:"status"`
+	Approver     string                `json:"approver"`
+	Comment      string                `json:"comment"`
+	UpdateTime   int64                 `json:"update_time"`
+}
+
+func (p *ApprovalPlugin) SetAckFunc(func()) {
+}
+
+// Init ...
+func (p *ApprovalPlugin) Init(jobname, filename string, xl *zap.SugaredLogger) {
+	p.Log = xl
+}
+
+// Type ...
+func (p *ApprovalPlugin) Type() config.TaskType {
+	return p.Name
+}
+
+// Status ...
+func (p *ApprovalPlugin) Status() config.Status {
+	return p.ApprovalTask.TaskStatus
+}
+
+// SetStatus ...
+func (p *ApprovalPlugin) SetStatus(status config.Status) {
+	p.ApprovalTask.TaskStatus = status
+}
+
+// TaskTimeout 返回的超时时间单位为秒
+func (p *ApprovalPlugin) TaskTimeout() int {
+	if p.ApprovalTask.Timeout == 0 {
+		p.ApprovalTask.Timeout = ApprovalTaskTimeout
+	}
+	return p.ApprovalTask.Timeout * 60
+}
+
+// Run 在aslan中创建审批记录, aslan负责通知审批人
+func (p *ApprovalPlugin) Run(ctx context.Context, pipelineTask *task.Task, pipelineCtx *task.PipelineCtx, serviceName string) {
+	p.pipelineName = pipelineTask.PipelineName
+	p.taskID = pipelineTask.TaskID
+	p.ApprovalTask.Approver = ""
+	p.ApprovalTask.Comment = ""
+	p.ApprovalTask.ApproveTime = 0
+
+	// 审批人等信息由aslan从任务的审批subtask中获取
+	approval := &workflowApproval{
+		PipelineName: pipelineTask.PipelineName,
+		TaskID:       pipelineTask.TaskID,
+	}
+	if _, err := p.httpClient.Post("/api/workflow/approval", httpclient.SetBody(approval)); err != nil {
+		p.Log.Errorf("failed to create approval for %s#%d: %v", p.pipelineName, p.taskID, err)
+		p.errorChan <- err
+		return
+	}
+	p.Log.Infof("waiting for approval of %s#%d from %v", p.pipelineName, p.taskID, p.ApprovalTask.Approvers)
+}
+
+func (p *ApprovalPlugin) getApproval() (*workflowApproval, error) {
+	url := fmt.Sprintf("/api/workflow/approval/id/%d/pipelines/%s", p.taskID, p.pipelineName)
+
+	approval := new(workflowApproval)
+	if _, err := p.httpClient.Get(url, httpclient.SetResult(approval)); err != nil {
+		return nil, err
+	}
+	return approval, nil
+}
+
+// Wait 轮询审批结果, 审批通过后继续执行, 拒绝或者超时则任务失败
+func (p *ApprovalPlugin) Wait(ctx context.Context) {
+	timeout := time.After(time.Duration(p.TaskTimeout()) * time.Second)
+	for {
+		select {
+		case <-ctx.Done():
+			p.ApprovalTask.TaskStatus = config.StatusCancelled
+			return
+		case err := <-p.errorChan:
+			p.ApprovalTask.TaskStatus = config.StatusFailed
+			p.ApprovalTask.Error = err.Error()
+			return
+		case <-timeout:
+			p.ApprovalTask.TaskStatus = config.StatusTimeout
+			p.ApprovalTask.Error = "approval timeout"
+			return
+		case <-time.After(approvalPollInterval):
+			approval, err := p.getApproval()
+			if err != nil {
+				// aslan暂时不可用时继续等待, 直到超时
+				p.Log.Warnf("failed to get approval of %s#%d: %v", p.pipelineName, p.taskID, err)
+				continue
+			}
+
+			switch approval.Status {
+			case config.ApprovalStatusApproved:
+				p.ApprovalTask.TaskStatus = config.StatusPassed
+			case config.ApprovalStatusRejected:
+				p.ApprovalTask.TaskStatus = config.StatusFailed
+				p.ApprovalTask.Error = fmt.Sprintf("rejected by %s", approval.Approver)
+			default:
+				continue
+			}
+			p.ApprovalTask.Approver = approval.Approver
+			p.ApprovalTask.Comment = approval.Comment
+			p.ApprovalTask.ApproveTime = approval.UpdateTime
+			p.Log.Infof("approval of %s#%d is %s by %s", p.pipelineName, p.taskID, approval.Status, approval.Approver)
+			return
+		}
+	}
+}
+
+// Complete ...
+func (p *ApprovalPlugin) Complete(ctx context.Context, pipelineTask *task.Task, serviceName string) {
+}
+
+// SetTask ...
+func (p *ApprovalPlugin) SetTask(t map[string]interface{}) error {
+	task, err := ToApprovalTask(t)
+	if err != nil {
+		return err
+	}
+	p.ApprovalTask = task
+	return nil
+}
+
+// GetTask ...
+func (p *ApprovalPlugin) GetTask() interface{} {
+	return p.ApprovalTask
+}
+
+// IsTaskDone ...
+func (p *ApprovalPlugin) IsTaskDone() bool {
+	if p.ApprovalTask.TaskStatus != config.StatusCreated && p.ApprovalTask.TaskStatus != config.StatusRunning {
+		return true
+	}
+	return false
+}
+
+// IsTaskFailed ...
+func (p *ApprovalPlugin) IsTaskFailed() bool {
+	if p.ApprovalTask.TaskStatus == config.StatusFailed || p.ApprovalTask.TaskStatus == config.StatusTimeout || p.ApprovalTask.TaskStatus == config.StatusCancelled {
+		return true
+	}
+	return false
+}
+
+// SetStartTime ...
+func (p *ApprovalPlugin) SetStartTime() {
+	p.ApprovalTask.StartTime = time.Now().Unix()
+}
+
+// SetEndTime ...
+func (p *ApprovalPlugin) SetEndTime() {
+	p.ApprovalTask.EndTime = time.Now().Unix()
+}
+
+// IsTaskEnabled ...
+func (p *ApprovalPlugin) IsTaskEnabled() bool {
+	return p.ApprovalTask.Enabled
+}
+
+// ResetError ...
+func (p *ApprovalPlugin) ResetError() {
+	p.ApprovalTask.Error = ""
+}
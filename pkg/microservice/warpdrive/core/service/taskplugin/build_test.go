@@ -30,7 +30,7 @@ import (
 
 func TestBuildTaskPlugin_TaskTimeout_Default(t *testing.T) {
 	assert := assert.New(t)
-	plugin := InitializeBuildTaskPlugin(config.TaskBuild).(*BuildTaskPlugin)
+	plugin := &BuildTaskPlugin{Name: config.TaskBuild}
 
 	plugin.Task = buildTaskForTest()
 	assert.Equal(BuildTaskV2Timeout, plugin.TaskTimeout())
@@ -38,7 +38,7 @@ func TestBuildTaskPlugin_TaskTimeout_Default(t *testing.T) {
 
 func TestBuildTaskPlugin_TaskTimeout_Restart(t *testing.T) {
 	assert := assert.New(t)
-	plugin := InitializeBuildTaskPlugin(config.TaskBuild).(*BuildTaskPlugin)
+	plugin := &BuildTaskPlugin{Name: config.TaskBuild}
 
 	initTimeout := 20
 	plugin.Task = buildTaskForTest()
@@ -50,7 +50,7 @@ func TestBuildTaskPlugin_TaskTimeout_Restart(t *testing.T) {
 
 func TestBuildTaskPlugin_TaskTimeout_NotRestart(t *testing.T) {
 	assert := assert.New(t)
-	plugin := InitializeBuildTaskPlugin(config.TaskBuild).(*BuildTaskPlugin)
+	plugin := &BuildTaskPlugin{Name: config.TaskBuild}
 
 	initTimeout := 20
 	plugin.Task = buildTaskForTest()
@@ -64,7 +64,7 @@ func TestBuildTaskPlugin_TaskTimeout_NotRestart(t *testing.T) {
 }
 
 func TestBuildTaskPlugin_SetBuildStatusCompleted(t *testing.T) {
-	plugin := InitializeBuildTaskPlugin(config.TaskBuild).(*BuildTaskPlugin)
+	plugin := &BuildTaskPlugin{Name: config.TaskBuild}
 	plugin.Task = buildTaskForTest()
 	plugin.SetBuildStatusCompleted(config.StatusPassed)
 
@@ -72,6 +72,8 @@ func TestBuildTaskPlugin_SetBuildStatusCompleted(t *testing.T) {
 }
 
 func TestBuildTaskPlugin_Run(t *testing.T) {
+	skipWithoutCluster(t)
+
 	assert := assert.New(t)
 	log := log.NopSugaredLogger()
 
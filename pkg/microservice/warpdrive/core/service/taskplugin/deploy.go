@@ -24,6 +24,7 @@ import (
 	"strings"
 	"time"
 
+	"github.com/hashicorp/go-multierror"
 	helmclient "github.com/mittwald/go-helm-client"
 	"github.com/pkg/errors"
 	"go.uber.org/zap"
@@ -78,6 +79,33 @@ type DeployTaskPlugin struct {
 	ReplaceImage string
 
 	httpClient *httpclient.Client
+
+	rollbackOnFailure bool
+	helmRollback      *helmRollbackInfo
+}
+
+// helmRollbackInfo 记录helm服务部署前的release版本, 用于部署失败后回滚
+type helmRollbackInfo struct {
+	client    helmclient.Client
+	chartSpec *helmclient.ChartSpec
+	// revision 为0时表示部署前release不存在
+	revision int
+	// renderSet 部署成功后更新的渲染配置, 回滚时需要恢复服务原来的values.yaml
+	renderSet        *types.RenderSet
+	originValuesYaml string
+}
+
+// newHelmRollbackInfo 在升级release前记录当前的版本, release不存在时revision为0, 失败后不回滚
+func newHelmRollbackInfo(helmClient helmclient.Client, chartSpec *helmclient.ChartSpec, originValuesYaml string) *helmRollbackInfo {
+	info := &helmRollbackInfo{
+		client:           helmClient,
+		chartSpec:        chartSpec,
+		originValuesYaml: originValuesYaml,
+	}
+	if release, err := helmClient.GetRelease(chartSpec.ReleaseName); err == nil {
+		info.revision = release.Version
+	}
+	return info
 }
 
 func (p *DeployTaskPlugin) SetAckFunc(func()) {
@@ -164,11 +192,15 @@ func (p *DeployTaskPlugin) Run(ctx context.Context, pipelineTask *task.Task, _ *
 		replaced = false
 	)
 
+	// 重置镜像的任务不需要回滚
+	p.rollbackOnFailure = pipelineTask.RollbackOnFailure && p.Task.TaskType == config.TaskDeploy
+
 	defer func() {
 		if err != nil {
 			p.Log.Error(err)
 			p.Task.TaskStatus = config.StatusFailed
 			p.Task.Error = err.Error()
+			p.rollback()
 			return
 		}
 	}()
@@ -187,6 +219,13 @@ func (p *DeployTaskPlugin) Run(ctx context.Context, pipelineTask *task.Task, _ *
 		}
 	}
 
+	// 重置镜像时不验证签名
+	if pipelineTask.SignatureKeyID != "" && p.Task.TaskType == config.TaskDeploy {
+		if err = p.verifySignature(ctx, pipelineTask); err != nil {
+			return
+		}
+	}
+
 	if p.Task.ServiceType != setting.HelmDeployType {
 		// get servcie info
 		var (
@@ -218,22 +257,15 @@ func (p *DeployTaskPlugin) Run(ctx context.Context, pipelineTask *task.Task, _ *
 
 		L:
 			for _, deploy := range deployments {
+				// 蓝绿部署的槽位由原工作负载的部署任务维护
+				if _, ok := deploy.Labels[setting.BlueGreenSlotLabel]; ok {
+					continue
+				}
 				for _, container := range deploy.Spec.Template.Spec.Containers {
 					if container.Name == p.Task.ContainerName {
-						err = updater.UpdateDeploymentImage(deploy.Namespace, deploy.Name, p.Task.ContainerName, p.Task.Image, p.kubeClient)
-						if err != nil {
-							err = errors.WithMessagef(
-								err,
-								"failed to update container image in %s/deployments/%s/%s",
-								p.Task.Namespace, deploy.Name, container.Name)
+						if err = p.updateDeployment(ctx, deploy); err != nil {
 							return
 						}
-						p.Task.ReplaceResources = append(p.Task.ReplaceResources, task.Resource{
-							Kind:      setting.Deployment,
-							Container: container.Name,
-							Origin:    container.Image,
-							Name:      deploy.Name,
-						})
 						replaced = true
 						break L
 					}
@@ -243,6 +275,10 @@ func (p *DeployTaskPlugin) Run(ctx context.Context, pipelineTask *task.Task, _ *
 			for _, sts := range statefulSets {
 				for _, container := range sts.Spec.Template.Spec.Containers {
 					if container.Name == p.Task.ContainerName {
+						if p.strategyType() != setting.DeployStrategyRolling {
+							err = errors.Errorf("deploy strategy %s is not supported by statefulset %s", p.Task.Strategy.Type, sts.Name)
+							return
+						}
 						err = updater.UpdateStatefulSetImage(sts.Namespace, sts.Name, p.Task.ContainerName, p.Task.Image, p.kubeClient)
 						if err != nil {
 							err = errors.WithMessagef(
@@ -272,6 +308,10 @@ func (p *DeployTaskPlugin) Run(ctx context.Context, pipelineTask *task.Task, _ *
 				}
 				for _, container := range statefulSet.Spec.Template.Spec.Containers {
 					if container.Name == p.Task.ContainerName {
+						if p.strategyType() != setting.DeployStrategyRolling {
+							err = errors.Errorf("deploy strategy %s is not supported by statefulset %s", p.Task.Strategy.Type, statefulSet.Name)
+							return
+						}
 						err = updater.UpdateStatefulSetImage(statefulSet.Namespace, statefulSet.Name, p.Task.ContainerName, p.Task.Image, p.kubeClient)
 						if err != nil {
 							err = errors.WithMessagef(
@@ -298,20 +338,9 @@ func (p *DeployTaskPlugin) Run(ctx context.Context, pipelineTask *task.Task, _ *
 				}
 				for _, container := range deployment.Spec.Template.Spec.Containers {
 					if container.Name == p.Task.ContainerName {
-						err = updater.UpdateDeploymentImage(deployment.Namespace, deployment.Name, p.Task.ContainerName, p.Task.Image, p.kubeClient)
-						if err != nil {
-							err = errors.WithMessagef(
-								err,
-								"failed to update container image in %s/deployments/%s/%s",
-								p.Task.Namespace, deployment.Name, container.Name)
+						if err = p.updateDeployment(ctx, deployment); err != nil {
 							return
 						}
-						p.Task.ReplaceResources = append(p.Task.ReplaceResources, task.Resource{
-							Kind:      setting.Deployment,
-							Container: container.Name,
-							Origin:    container.Image,
-							Name:      deployment.Name,
-						})
 						replaced = true
 						break
 					}
@@ -519,6 +548,8 @@ func (p *DeployTaskPlugin) Run(ctx context.Context, pipelineTask *task.Task, _ *
 			Timeout:     time.Second * DeployTimeout,
 		}
 
+		p.helmRollback = newHelmRollbackInfo(helmClient, &chartSpec, serviceValuesYaml)
+
 		if _, err = helmClient.InstallOrUpgradeChart(context.TODO(), &chartSpec); err != nil {
 			err = errors.WithMessagef(
 				err,
@@ -536,18 +567,21 @@ func (p *DeployTaskPlugin) Run(ctx context.Context, pipelineTask *task.Task, _ *
 		}
 
 		// TODO too dangerous to override entire renderset!
-		err = p.updateRenderSet(ctx, &types.RenderSet{
+		renderSet := &types.RenderSet{
 			Name:          renderInfo.Name,
 			Revision:      renderInfo.Revision,
 			DefaultValues: renderInfo.DefaultValues,
 			ChartInfos:    renderInfo.ChartInfos,
-		})
+		}
+		err = p.updateRenderSet(ctx, renderSet)
 		if err != nil {
 			err = errors.WithMessagef(
 				err,
 				"failed to update renderset info %s/%s, renderset %s",
 				p.Task.Namespace, p.Task.ServiceName, renderInfo.Name)
+			return
 		}
+		p.helmRollback.renderSet = renderSet
 	}
 }
 
@@ -718,7 +752,8 @@ func (p *DeployTaskPlugin) Wait(ctx context.Context) {
 		return
 	}
 
-	timeout := time.After(time.Duration(p.TaskTimeout()) * time.Second)
+	deadline := time.Now().Add(time.Duration(p.TaskTimeout()) * time.Second)
+	timeout := time.After(time.Until(deadline))
 
 	selector := labels.Set{setting.ProductLabel: p.Task.ProductName, setting.ServiceLabel: p.Task.ServiceName}.AsSelector()
 
@@ -730,6 +765,7 @@ func (p *DeployTaskPlugin) Wait(ctx context.Context) {
 
 		case <-timeout:
 			p.Task.TaskStatus = config.StatusTimeout
+			defer p.rollback()
 
 			pods, err := getter.ListPods(p.Task.Namespace, selector, p.kubeClient)
 			if err != nil {
@@ -809,6 +845,27 @@ func (p *DeployTaskPlugin) Wait(ctx context.Context) {
 			}
 
 			if ready {
+				// 验证占用部署剩余的超时时间, 超时后按部署超时处理
+				verifyCtx, cancel := context.WithDeadline(ctx, deadline)
+				err := p.verify(verifyCtx)
+				cancel()
+				if err != nil {
+					if ctx.Err() != nil {
+						p.Task.TaskStatus = config.StatusCancelled
+						return
+					}
+					if time.Now().After(deadline) {
+						p.Task.TaskStatus = config.StatusTimeout
+						p.Task.Error = errors.WithMessage(err, "deploy timeout during verification").Error()
+						p.rollback()
+						return
+					}
+					p.Task.TaskStatus = config.StatusFailed
+					p.Task.Error = err.Error()
+					p.rollback()
+					return
+				}
+				p.scaleDownStandby()
 				p.Task.TaskStatus = config.StatusPassed
 			}
 
@@ -819,6 +876,101 @@ func (p *DeployTaskPlugin) Wait(ctx context.Context) {
 	}
 }
 
+// rollback 部署失败或超时后恢复部署前的镜像, helm服务回滚到部署前的release版本
+func (p *DeployTaskPlugin) rollback() {
+	if !p.rollbackOnFailure || p.Task.Rollback != nil {
+		return
+	}
+
+	var rollbackFunc func() error
+	if p.Task.ServiceType == setting.HelmDeployType {
+		if p.helmRollback == nil {
+			return
+		}
+		rollbackFunc = p.rollbackHelmRelease
+	} else {
+		if len(p.Task.ReplaceResources) == 0 {
+			return
+		}
+		rollbackFunc = p.restoreOriginImages
+	}
+
+	p.Log.Infof("start to rollback service %s in %s", p.Task.ServiceName, p.Task.Namespace)
+	p.Task.Rollback = &task.DeployRollback{
+		Status:    config.StatusRunning,
+		StartTime: time.Now().Unix(),
+	}
+	err := rollbackFunc()
+	p.Task.Rollback.EndTime = time.Now().Unix()
+	if err != nil {
+		p.Log.Errorf("failed to rollback service %s in %s: %v", p.Task.ServiceName, p.Task.Namespace, err)
+		p.Task.Rollback.Status = config.StatusFailed
+		p.Task.Rollback.Error = err.Error()
+		return
+	}
+
+	p.Log.Infof("succeed to rollback service %s in %s", p.Task.ServiceName, p.Task.Namespace)
+	p.Task.Rollback.Status = config.StatusPassed
+}
+
+// restoreOriginImages 将部署时替换过镜像的资源恢复为原来的镜像, 蓝绿部署切换过的Service恢复原来的selector
+func (p *DeployTaskPlugin) restoreOriginImages() error {
+	var result *multierror.Error
+	for _, resource := range p.Task.ReplaceResources {
+		var err error
+		switch resource.Kind {
+		case setting.Service:
+			if err = p.restoreServiceSelector(resource.Name, resource.Origin); err != nil {
+				result = multierror.Append(result, errors.WithMessagef(
+					err,
+					"failed to restore selector of %s/%s/%s",
+					p.Task.Namespace, resource.Kind, resource.Name))
+			}
+			continue
+		case setting.Deployment:
+			err = updater.UpdateDeploymentImage(p.Task.Namespace, resource.Name, resource.Container, resource.Origin, p.kubeClient)
+		case setting.StatefulSet:
+			err = updater.UpdateStatefulSetImage(p.Task.Namespace, resource.Name, resource.Container, resource.Origin, p.kubeClient)
+		default:
+			continue
+		}
+		if err != nil {
+			result = multierror.Append(result, errors.WithMessagef(
+				err,
+				"failed to restore container image in %s/%s/%s/%s",
+				p.Task.Namespace, resource.Kind, resource.Name, resource.Container))
+		}
+	}
+	return result.ErrorOrNil()
+}
+
+// rollbackHelmRelease 将helm release回滚到部署前的版本, 并恢复渲染配置中服务的values.yaml
+func (p *DeployTaskPlugin) rollbackHelmRelease() error {
+	info := p.helmRollback
+	if info.revision == 0 {
+		return errors.Errorf("release %s has no previous revision to rollback", info.chartSpec.ReleaseName)
+	}
+
+	p.Task.Rollback.Revision = info.revision
+	if err := info.client.RollbackRelease(info.chartSpec, info.revision); err != nil {
+		return errors.WithMessagef(err, "failed to rollback release %s to revision %d", info.chartSpec.ReleaseName, info.revision)
+	}
+
+	if info.renderSet == nil {
+		return nil
+	}
+	for _, chartInfo := range info.renderSet.ChartInfos {
+		if chartInfo.ServiceName == p.Task.ServiceName {
+			chartInfo.ValuesYaml = info.originValuesYaml
+			break
+		}
+	}
+	if err := p.updateRenderSet(context.TODO(), info.renderSet); err != nil {
+		return errors.WithMessagef(err, "failed to restore renderset %s", info.renderSet.Name)
+	}
+	return nil
+}
+
 func (p *DeployTaskPlugin) Complete(ctx context.Context, pipelineTask *task.Task, serviceName string) {
 }
 
@@ -0,0 +1,163 @@
+/*
+Copyright 2021 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package taskplugin
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/koderover/zadig/pkg/microservice/warpdrive/core/service/types/task"
+	"github.com/koderover/zadig/pkg/setting"
+	"github.com/koderover/zadig/pkg/tool/kube/getter"
+	"github.com/koderover/zadig/pkg/tool/kube/updater"
+)
+
+const (
+	signatureVerificationTimeout = 300
+	signatureVerificationJobType = "signature-verification"
+	signatureVolumeName          = "cosign"
+	signatureMountPath           = "/cosign"
+)
+
+// verifySignature 更新工作负载前在部署的命名空间中运行cosign verify, 镜像未使用指定的密钥签名时返回错误
+// 公钥和镜像仓库认证信息通过Secret挂载到Job中, 结束后删除Job和Secret
+func (p *DeployTaskPlugin) verifySignature(ctx context.Context, pipelineTask *task.Task) error {
+	key := pipelineTask.ConfigPayload.GetSigningKey(pipelineTask.SignatureKeyID)
+	if key == nil {
+		return errors.Errorf("signing key %s not found", pipelineTask.SignatureKeyID)
+	}
+
+	dockerConfig, err := dockerConfigJSON(matchRegistry(scanRegistries(pipelineTask.ConfigPayload), p.Task.Image))
+	if err != nil {
+		return err
+	}
+
+	name := fmt.Sprintf("%s-signature-%d", p.Task.ServiceName, time.Now().Unix())
+	labels := map[string]string{
+		setting.TypeLabel: signatureVerificationJobType,
+	}
+
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: p.Task.Namespace,
+			Labels:    labels,
+		},
+		Data: map[string][]byte{
+			"cosign.pub":  []byte(key.PublicKey),
+			"config.json": dockerConfig,
+		},
+	}
+	if err := updater.UpdateOrCreateSecret(secret, p.kubeClient); err != nil {
+		return errors.WithMessagef(err, "failed to create signature verification secret %s", name)
+	}
+	defer func() {
+		if err := updater.DeleteSecret(p.Task.Namespace, name, p.kubeClient); err != nil {
+			p.Log.Errorf("failed to delete signature verification secret %s/%s: %v", p.Task.Namespace, name, err)
+		}
+	}()
+
+	backoffLimit := int32(0)
+	job := &batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: p.Task.Namespace,
+			Labels:    labels,
+		},
+		Spec: batchv1.JobSpec{
+			BackoffLimit: &backoffLimit,
+			Template: corev1.PodTemplateSpec{
+				Spec: corev1.PodSpec{
+					RestartPolicy: corev1.RestartPolicyNever,
+					Containers: []corev1.Container{
+						{
+							Name:  "cosign",
+							Image: setting.DefaultCosignImage,
+							Args:  []string{"verify", "--key", signatureMountPath + "/cosign.pub", p.Task.Image},
+							Env: []corev1.EnvVar{
+								{Name: "DOCKER_CONFIG", Value: signatureMountPath},
+							},
+							VolumeMounts: []corev1.VolumeMount{
+								{Name: signatureVolumeName, MountPath: signatureMountPath, ReadOnly: true},
+							},
+						},
+					},
+					Volumes: []corev1.Volume{
+						{
+							Name: signatureVolumeName,
+							VolumeSource: corev1.VolumeSource{
+								Secret: &corev1.SecretVolumeSource{SecretName: name},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	if err := updater.CreateJob(job, p.kubeClient); err != nil {
+		return errors.WithMessagef(err, "failed to create signature verification job %s", name)
+	}
+	defer func() {
+		if err := updater.DeleteJob(p.Task.Namespace, name, p.kubeClient); err != nil {
+			p.Log.Errorf("failed to delete signature verification job %s/%s: %v", p.Task.Namespace, name, err)
+		}
+	}()
+
+	deadline := time.After(signatureVerificationTimeout * time.Second)
+	for {
+		select {
+		case <-ctx.Done():
+			return errors.New("signature verification is cancelled")
+		case <-deadline:
+			return errors.Errorf("timeout waiting for signature verification job %s", name)
+		case <-time.After(2 * time.Second):
+			j, found, err := getter.GetJob(p.Task.Namespace, name, p.kubeClient)
+			if err != nil || !found {
+				p.Log.Errorf("failed to get signature verification job %s/%s - %v", p.Task.Namespace, name, err)
+				continue
+			}
+			if j.Status.Succeeded > 0 {
+				p.Log.Infof("signature of image %s is verified", p.Task.Image)
+				return nil
+			}
+			if j.Status.Failed > 0 {
+				return errors.Errorf("image %s is not signed by key %s", p.Task.Image, key.Name)
+			}
+		}
+	}
+}
+
+// dockerConfigJSON 生成cosign访问镜像仓库使用的docker配置
+func dockerConfigJSON(reg *task.RegistryNamespace) ([]byte, error) {
+	auths := map[string]map[string]string{}
+	if reg != nil {
+		auths[registryHost(reg.RegAddr)] = map[string]string{
+			"auth": base64.StdEncoding.EncodeToString([]byte(strings.Join([]string{reg.AccessKey, reg.SecretKey}, ":"))),
+		}
+	}
+	return json.Marshal(map[string]interface{}{"auths": auths})
+}
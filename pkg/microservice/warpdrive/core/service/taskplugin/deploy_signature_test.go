@@ -0,0 +1,156 @@
+/*
+Copyright 2021 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package taskplugin
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/koderover/zadig/pkg/microservice/warpdrive/config"
+	"github.com/koderover/zadig/pkg/microservice/warpdrive/core/service/types/task"
+	"github.com/koderover/zadig/pkg/setting"
+	"github.com/koderover/zadig/pkg/tool/log"
+)
+
+const signatureTestNamespace = "signature-ns"
+
+func newSignaturePipelineTask() *task.Task {
+	return &task.Task{
+		SignatureKeyID: "key-1",
+		ConfigPayload: &task.ConfigPayload{
+			SigningKeys: []*task.SigningKey{{ID: "key-1", Name: "release", PublicKey: "public-key"}},
+			RepoConfigs: map[string]*task.RegistryNamespace{
+				"reg-1": {RegAddr: "https://harbor.example.com", AccessKey: "robot", SecretKey: "token"},
+			},
+		},
+	}
+}
+
+// finishSignatureJob 等待验证Job创建后检查Secret内容, 并将Job状态设置为成功或失败
+func finishSignatureJob(t *testing.T, kubeClient client.Client, succeeded bool) <-chan struct{} {
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 50; i++ {
+			jobs := &batchv1.JobList{}
+			if err := kubeClient.List(context.TODO(), jobs, client.InNamespace(signatureTestNamespace)); err != nil || len(jobs.Items) == 0 {
+				time.Sleep(100 * time.Millisecond)
+				continue
+			}
+
+			job := &jobs.Items[0]
+			assert.Equal(t, signatureVerificationJobType, job.Labels[setting.TypeLabel])
+			assert.Equal(t, []string{"verify", "--key", "/cosign/cosign.pub", "harbor.example.com/app/web:v2"}, job.Spec.Template.Spec.Containers[0].Args)
+
+			secret := &corev1.Secret{}
+			assert.Nil(t, kubeClient.Get(context.TODO(), client.ObjectKey{Namespace: signatureTestNamespace, Name: job.Name}, secret))
+			assert.Len(t, secret.Data, 2)
+			assert.Equal(t, "public-key", string(secret.Data["cosign.pub"]))
+			dockerConfig := map[string]map[string]map[string]string{}
+			assert.Nil(t, json.Unmarshal(secret.Data["config.json"], &dockerConfig))
+			assert.Equal(t, "cm9ib3Q6dG9rZW4=", dockerConfig["auths"]["harbor.example.com"]["auth"])
+
+			if succeeded {
+				job.Status.Succeeded = 1
+			} else {
+				job.Status.Failed = 1
+			}
+			assert.Nil(t, kubeClient.Status().Update(context.TODO(), job))
+			return
+		}
+		t.Error("signature verification job is not created")
+	}()
+	return done
+}
+
+func TestVerifySignature(t *testing.T) {
+	tests := []struct {
+		name      string
+		succeeded bool
+		wantErr   string
+	}{
+		{name: "signed image", succeeded: true},
+		{name: "unsigned image", succeeded: false, wantErr: "image harbor.example.com/app/web:v2 is not signed by key release"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			kubeClient := fake.NewClientBuilder().Build()
+			p := &DeployTaskPlugin{
+				Name:       config.TaskDeploy,
+				kubeClient: kubeClient,
+				Log:        log.SugaredLogger(),
+				Task:       &task.Deploy{Namespace: signatureTestNamespace, ServiceName: "web", Image: "harbor.example.com/app/web:v2"},
+			}
+
+			done := finishSignatureJob(t, kubeClient, tt.succeeded)
+			err := p.verifySignature(context.TODO(), newSignaturePipelineTask())
+			<-done
+			if tt.wantErr != "" {
+				assert.EqualError(t, err, tt.wantErr)
+			} else {
+				assert.Nil(t, err)
+			}
+
+			// 验证结束后删除Job和Secret
+			jobs := &batchv1.JobList{}
+			assert.Nil(t, kubeClient.List(context.TODO(), jobs, client.InNamespace(signatureTestNamespace)))
+			assert.Empty(t, jobs.Items)
+			secrets := &corev1.SecretList{}
+			assert.Nil(t, kubeClient.List(context.TODO(), secrets, client.InNamespace(signatureTestNamespace)))
+			assert.Empty(t, secrets.Items)
+		})
+	}
+}
+
+func TestVerifySignatureKeyNotFound(t *testing.T) {
+	p := &DeployTaskPlugin{
+		Name:       config.TaskDeploy,
+		kubeClient: fake.NewClientBuilder().Build(),
+		Log:        log.SugaredLogger(),
+		Task:       &task.Deploy{Namespace: signatureTestNamespace, ServiceName: "web", Image: "harbor.example.com/app/web:v2"},
+	}
+	pipelineTask := newSignaturePipelineTask()
+	pipelineTask.SignatureKeyID = "key-2"
+
+	assert.EqualError(t, p.verifySignature(context.TODO(), pipelineTask), "signing key key-2 not found")
+}
+
+func TestVerifySignatureCancelled(t *testing.T) {
+	kubeClient := fake.NewClientBuilder().Build()
+	p := &DeployTaskPlugin{
+		Name:       config.TaskDeploy,
+		kubeClient: kubeClient,
+		Log:        log.SugaredLogger(),
+		Task:       &task.Deploy{Namespace: signatureTestNamespace, ServiceName: "web", Image: "harbor.example.com/app/web:v2"},
+	}
+	ctx, cancel := context.WithCancel(context.TODO())
+	cancel()
+
+	assert.EqualError(t, p.verifySignature(ctx, newSignaturePipelineTask()), "signature verification is cancelled")
+	secrets := &corev1.SecretList{}
+	assert.Nil(t, kubeClient.List(context.TODO(), secrets, client.InNamespace(signatureTestNamespace)))
+	assert.Empty(t, secrets.Items)
+}
@@ -0,0 +1,473 @@
+/*
+Copyright 2021 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package taskplugin
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/pkg/errors"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+
+	"github.com/koderover/zadig/pkg/microservice/warpdrive/core/service/types/task"
+	"github.com/koderover/zadig/pkg/setting"
+	"github.com/koderover/zadig/pkg/tool/kube/getter"
+	"github.com/koderover/zadig/pkg/tool/kube/updater"
+)
+
+const (
+	canarySuffix    = "-canary"
+	blueSlotSuffix  = "-blue"
+	greenSlotSuffix = "-green"
+)
+
+func (p *DeployTaskPlugin) strategyType() string {
+	if p.Task.Strategy == nil || p.Task.Strategy.Type == "" {
+		return setting.DeployStrategyRolling
+	}
+	return p.Task.Strategy.Type
+}
+
+// strategyTimeout 等待金丝雀或蓝绿部署的工作负载就绪的超时时间
+func (p *DeployTaskPlugin) strategyTimeout() time.Duration {
+	if p.Task.Timeout == 0 {
+		return DeployTimeout * time.Second
+	}
+	if p.Task.IsRestart {
+		return time.Duration(p.Task.Timeout) * time.Second
+	}
+	return time.Duration(p.Task.Timeout) * time.Minute
+}
+
+// deployWithStrategy 按照部署策略更新Deployment, 返回需要替换镜像的Deployment
+// 返回true时表示部署已经完成, 不需要再直接替换工作负载的镜像
+func (p *DeployTaskPlugin) deployWithStrategy(ctx context.Context, deploy *appsv1.Deployment) (*appsv1.Deployment, bool, error) {
+	if p.strategyType() == setting.DeployStrategyBlueGreen {
+		return deploy, true, p.blueGreenDeploy(ctx, deploy)
+	}
+
+	active, err := p.activeDeployment(deploy)
+	if err != nil {
+		return nil, false, err
+	}
+	if p.strategyType() == setting.DeployStrategyCanary {
+		// 金丝雀验证通过后直接替换工作负载的镜像完成全量发布
+		return active, false, p.canaryDeploy(ctx, active)
+	}
+	return active, false, nil
+}
+
+// updateDeployment 按照部署策略替换Deployment中容器的镜像, 记录替换前的镜像用于回滚
+func (p *DeployTaskPlugin) updateDeployment(ctx context.Context, deploy *appsv1.Deployment) error {
+	target, done, err := p.deployWithStrategy(ctx, deploy)
+	if err != nil || done {
+		return err
+	}
+
+	for _, container := range target.Spec.Template.Spec.Containers {
+		if container.Name != p.Task.ContainerName {
+			continue
+		}
+		if err := updater.UpdateDeploymentImage(target.Namespace, target.Name, p.Task.ContainerName, p.Task.Image, p.kubeClient); err != nil {
+			return errors.WithMessagef(
+				err,
+				"failed to update container image in %s/deployments/%s/%s",
+				p.Task.Namespace, target.Name, container.Name)
+		}
+		p.Task.ReplaceResources = append(p.Task.ReplaceResources, task.Resource{
+			Kind:      setting.Deployment,
+			Container: container.Name,
+			Origin:    container.Image,
+			Name:      target.Name,
+		})
+		return nil
+	}
+	return errors.Errorf("container %s is not found in deployment %s/%s", p.Task.ContainerName, p.Task.Namespace, target.Name)
+}
+
+// activeDeployment 返回当前提供服务的Deployment
+// 蓝绿部署切换流量后原工作负载已经缩容, 之后的滚动和金丝雀部署需要更新Service所选的槽位
+func (p *DeployTaskPlugin) activeDeployment(deploy *appsv1.Deployment) (*appsv1.Deployment, error) {
+	services, err := getter.ListServices(p.Task.Namespace, nil, p.kubeClient)
+	if err != nil {
+		return nil, err
+	}
+
+	blue, green := deploy.Name+blueSlotSuffix, deploy.Name+greenSlotSuffix
+	for _, svc := range services {
+		slot := svc.Spec.Selector[setting.BlueGreenSlotLabel]
+		if slot != blue && slot != green {
+			continue
+		}
+		active, found, err := getter.GetDeployment(p.Task.Namespace, slot, p.kubeClient)
+		if err != nil {
+			return nil, err
+		}
+		if !found {
+			return nil, errors.Errorf("deployment %s/%s selected by service %s is not found", p.Task.Namespace, slot, svc.Name)
+		}
+		return active, nil
+	}
+	return deploy, nil
+}
+
+// canaryDeploy 创建一定比例副本数的金丝雀版本, 观察一段时间后验证金丝雀版本是否正常, 无论结果如何都会删除金丝雀版本
+func (p *DeployTaskPlugin) canaryDeploy(ctx context.Context, deploy *appsv1.Deployment) error {
+	replicas := int32(1)
+	if deploy.Spec.Replicas != nil {
+		replicas = *deploy.Spec.Replicas
+	}
+	canaryReplicas := (replicas*int32(p.Task.Strategy.CanaryPercent) + 99) / 100
+	if canaryReplicas < 1 {
+		canaryReplicas = 1
+	}
+
+	podLabels, err := p.canaryPodLabels(deploy)
+	if err != nil {
+		return err
+	}
+	name := deploy.Name + canarySuffix
+	selector := &metav1.LabelSelector{MatchLabels: map[string]string{setting.CanaryLabel: deploy.Name}}
+
+	canary := p.newDeploymentCopy(deploy, name, selector, podLabels, canaryReplicas)
+	// 去掉项目和服务的label, 以免之后的部署任务把金丝雀版本当作服务的工作负载
+	delete(canary.Labels, setting.ProductLabel)
+	delete(canary.Labels, setting.ServiceLabel)
+	canary.Labels[setting.CanaryLabel] = deploy.Name
+
+	p.Log.Infof("create canary deployment %s/%s with %d replicas", p.Task.Namespace, name, canaryReplicas)
+	if err := updater.CreateOrPatchDeployment(canary, p.kubeClient); err != nil {
+		return errors.WithMessagef(err, "failed to create canary deployment %s/%s", p.Task.Namespace, name)
+	}
+	defer func() {
+		if err := updater.DeleteDeployment(p.Task.Namespace, name, p.kubeClient); err != nil {
+			p.Log.Errorf("failed to delete canary deployment %s/%s: %v", p.Task.Namespace, name, err)
+		}
+	}()
+
+	if err := p.waitDeploymentReady(ctx, name); err != nil {
+		return errors.WithMessage(err, "canary is aborted")
+	}
+
+	pause := time.Duration(p.Task.Strategy.PauseSeconds) * time.Second
+	p.Log.Infof("canary deployment %s/%s is ready, pause %s before verification", p.Task.Namespace, name, pause)
+	select {
+	case <-ctx.Done():
+		return errors.New("canary is cancelled")
+	case <-time.After(pause):
+	}
+
+	if err := p.verifyCanary(name); err != nil {
+		return errors.WithMessage(err, "canary is aborted")
+	}
+
+	p.Log.Infof("canary deployment %s/%s is verified, promote to %s", p.Task.Namespace, name, deploy.Name)
+	return nil
+}
+
+// canaryPodLabels 金丝雀版本的Pod保留Service选择的label以接收流量, 去掉只有原工作负载的selector使用的label
+// 原工作负载的selector不能修改, 如果它选中的label都被Service使用, 金丝雀版本的Pod无法和原工作负载区分开
+func (p *DeployTaskPlugin) canaryPodLabels(deploy *appsv1.Deployment) (map[string]string, error) {
+	services, err := getter.ListServices(p.Task.Namespace, nil, p.kubeClient)
+	if err != nil {
+		return nil, err
+	}
+	serviceLabels := make(map[string]string)
+	for _, svc := range services {
+		if len(svc.Spec.Selector) > 0 &&
+			labels.SelectorFromSet(svc.Spec.Selector).Matches(labels.Set(deploy.Spec.Template.Labels)) {
+			serviceLabels = copyLabels(svc.Spec.Selector, serviceLabels)
+		}
+	}
+
+	podLabels := copyLabels(deploy.Spec.Template.Labels, nil)
+	for k := range deploy.Spec.Selector.MatchLabels {
+		if _, ok := serviceLabels[k]; !ok {
+			delete(podLabels, k)
+		}
+	}
+	podLabels[setting.CanaryLabel] = deploy.Name
+
+	originSelector, err := metav1.LabelSelectorAsSelector(deploy.Spec.Selector)
+	if err != nil {
+		return nil, err
+	}
+	if originSelector.Matches(labels.Set(podLabels)) {
+		return nil, errors.Errorf(
+			"canary pods can not be separated from deployment %s/%s, its selector %s only uses labels selected by services, "+
+				"add a label that services do not select (e.g. track: stable) to its selector",
+			p.Task.Namespace, deploy.Name, originSelector)
+	}
+	return podLabels, nil
+}
+
+// verifyCanary 金丝雀版本在观察期内所有副本保持可用且没有发生重启时认为验证通过
+func (p *DeployTaskPlugin) verifyCanary(name string) error {
+	canary, found, err := getter.GetDeployment(p.Task.Namespace, name, p.kubeClient)
+	if err != nil {
+		return err
+	}
+	if !found {
+		return errors.Errorf("canary deployment %s/%s is not found", p.Task.Namespace, name)
+	}
+	if !deploymentRolledOut(canary) {
+		return errors.Errorf("canary deployment %s/%s is not available", p.Task.Namespace, name)
+	}
+
+	selector, err := metav1.LabelSelectorAsSelector(canary.Spec.Selector)
+	if err != nil {
+		return err
+	}
+	pods, err := getter.ListPods(p.Task.Namespace, selector, p.kubeClient)
+	if err != nil {
+		return err
+	}
+	for _, pod := range pods {
+		for _, cs := range pod.Status.ContainerStatuses {
+			if cs.RestartCount > 0 {
+				return errors.Errorf("container %s of canary pod %s restarted %d times", cs.Name, pod.Name, cs.RestartCount)
+			}
+		}
+	}
+	return nil
+}
+
+// blueGreenDeploy 在当前没有流量的槽位上部署新版本, 就绪后将选中原工作负载的Service切换到新版本
+// 切换前的selector记录在任务的ReplaceResources中, 回滚时改回去; 之前提供服务的工作负载在验证通过后缩容
+func (p *DeployTaskPlugin) blueGreenDeploy(ctx context.Context, deploy *appsv1.Deployment) error {
+	services, err := getter.ListServices(p.Task.Namespace, nil, p.kubeClient)
+	if err != nil {
+		return err
+	}
+
+	blue, green := deploy.Name+blueSlotSuffix, deploy.Name+greenSlotSuffix
+	var (
+		targets     []*corev1.Service
+		liveSlot    string
+		originLabel = make(map[string]string)
+	)
+	for _, svc := range services {
+		switch slot := svc.Spec.Selector[setting.BlueGreenSlotLabel]; {
+		case slot == blue || slot == green:
+			liveSlot = slot
+			origin := make(map[string]string)
+			if value := svc.Annotations[setting.OriginSelectorAnnotation]; value != "" {
+				if err := json.Unmarshal([]byte(value), &origin); err != nil {
+					return errors.WithMessagef(err, "invalid origin selector of service %s", svc.Name)
+				}
+			}
+			originLabel = copyLabels(origin, originLabel)
+		case slot == "" && len(svc.Spec.Selector) > 0 &&
+			labels.SelectorFromSet(svc.Spec.Selector).Matches(labels.Set(deploy.Spec.Template.Labels)):
+			originLabel = copyLabels(svc.Spec.Selector, originLabel)
+		default:
+			continue
+		}
+		targets = append(targets, svc)
+	}
+	if len(targets) == 0 {
+		return errors.Errorf("no service selects deployment %s/%s", p.Task.Namespace, deploy.Name)
+	}
+
+	slot := green
+	if liveSlot == green {
+		slot = blue
+	}
+
+	// 新版本的Pod去掉原Service选择的label, 切换之前不会接收流量
+	podLabels := make(map[string]string)
+	for k, v := range deploy.Spec.Template.Labels {
+		if _, ok := originLabel[k]; !ok {
+			podLabels[k] = v
+		}
+	}
+	podLabels[setting.BlueGreenSlotLabel] = slot
+	selector := &metav1.LabelSelector{MatchLabels: map[string]string{setting.BlueGreenSlotLabel: slot}}
+
+	replicas := int32(1)
+	if deploy.Spec.Replicas != nil {
+		replicas = *deploy.Spec.Replicas
+	}
+	workload := p.newDeploymentCopy(deploy, slot, selector, podLabels, replicas)
+	workload.Labels[setting.BlueGreenSlotLabel] = slot
+
+	p.Log.Infof("deploy %s/%s as the standby slot of %s", p.Task.Namespace, slot, deploy.Name)
+	if err := updater.CreateOrPatchDeployment(workload, p.kubeClient); err != nil {
+		return errors.WithMessagef(err, "failed to deploy %s/%s", p.Task.Namespace, slot)
+	}
+	if err := p.waitDeploymentReady(ctx, slot); err != nil {
+		return err
+	}
+
+	previous := liveSlot
+	if previous == "" {
+		previous = deploy.Name
+	}
+	p.Task.StandbyWorkload = previous
+	for _, svc := range targets {
+		origin, err := json.Marshal(svc.Spec.Selector)
+		if err != nil {
+			return err
+		}
+		if err := p.switchServiceSelector(svc, slot); err != nil {
+			return err
+		}
+		p.Task.ReplaceResources = append(p.Task.ReplaceResources, task.Resource{
+			Kind:   setting.Service,
+			Name:   svc.Name,
+			Origin: string(origin),
+		})
+		p.Log.Infof("service %s/%s is switched to %s, %s is kept for revert", p.Task.Namespace, svc.Name, slot, previous)
+	}
+	return nil
+}
+
+// restoreServiceSelector 将蓝绿部署切换过的Service的selector恢复为切换前的值
+func (p *DeployTaskPlugin) restoreServiceSelector(name, origin string) error {
+	selector := make(map[string]interface{})
+	if err := json.Unmarshal([]byte(origin), &selector); err != nil {
+		return errors.WithMessagef(err, "invalid origin selector of service %s", name)
+	}
+	if _, ok := selector[setting.BlueGreenSlotLabel]; !ok {
+		selector[setting.BlueGreenSlotLabel] = nil
+	}
+
+	patchBytes, err := json.Marshal(map[string]interface{}{
+		"spec": map[string]interface{}{"selector": selector},
+	})
+	if err != nil {
+		return err
+	}
+	return updater.PatchService(p.Task.Namespace, name, patchBytes, p.kubeClient)
+}
+
+// scaleDownStandby 蓝绿部署验证通过后缩容不再提供服务的工作负载
+func (p *DeployTaskPlugin) scaleDownStandby() {
+	if p.Task.StandbyWorkload == "" {
+		return
+	}
+	p.Log.Infof("scale down standby deployment %s/%s", p.Task.Namespace, p.Task.StandbyWorkload)
+	if err := updater.ScaleDeployment(p.Task.Namespace, p.Task.StandbyWorkload, 0, p.kubeClient); err != nil {
+		p.Log.Errorf("failed to scale down standby deployment %s/%s: %v", p.Task.Namespace, p.Task.StandbyWorkload, err)
+	}
+}
+
+// switchServiceSelector 将Service的selector替换为新版本所在的槽位, 第一次切换时记录原来的selector
+func (p *DeployTaskPlugin) switchServiceSelector(svc *corev1.Service, slot string) error {
+	selector := make(map[string]interface{})
+	for k := range svc.Spec.Selector {
+		selector[k] = nil
+	}
+	selector[setting.BlueGreenSlotLabel] = slot
+
+	patch := map[string]interface{}{
+		"spec": map[string]interface{}{"selector": selector},
+	}
+	if _, ok := svc.Annotations[setting.OriginSelectorAnnotation]; !ok {
+		origin, err := json.Marshal(svc.Spec.Selector)
+		if err != nil {
+			return err
+		}
+		patch["metadata"] = map[string]interface{}{
+			"annotations": map[string]string{setting.OriginSelectorAnnotation: string(origin)},
+		}
+	}
+
+	patchBytes, err := json.Marshal(patch)
+	if err != nil {
+		return err
+	}
+	if err := updater.PatchService(p.Task.Namespace, svc.Name, patchBytes, p.kubeClient); err != nil {
+		return errors.WithMessagef(err, "failed to switch selector of service %s/%s", p.Task.Namespace, svc.Name)
+	}
+	return nil
+}
+
+// newDeploymentCopy 以原工作负载为模板生成使用新镜像的Deployment
+func (p *DeployTaskPlugin) newDeploymentCopy(origin *appsv1.Deployment, name string, selector *metav1.LabelSelector, podLabels map[string]string, replicas int32) *appsv1.Deployment {
+	objectLabels := copyLabels(origin.Labels, nil)
+
+	spec := origin.Spec.DeepCopy()
+	spec.Replicas = &replicas
+	spec.Selector = selector
+	spec.Template.Labels = podLabels
+	for i := range spec.Template.Spec.Containers {
+		if spec.Template.Spec.Containers[i].Name == p.Task.ContainerName {
+			spec.Template.Spec.Containers[i].Image = p.Task.Image
+		}
+	}
+
+	return &appsv1.Deployment{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: "apps/v1",
+			Kind:       setting.Deployment,
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: origin.Namespace,
+			Labels:    objectLabels,
+		},
+		Spec: *spec,
+	}
+}
+
+func (p *DeployTaskPlugin) waitDeploymentReady(ctx context.Context, name string) error {
+	timeout := time.After(p.strategyTimeout())
+	for {
+		select {
+		case <-ctx.Done():
+			return errors.New("deploy is cancelled")
+		case <-timeout:
+			return errors.Errorf("timeout waiting for deployment %s/%s to be ready", p.Task.Namespace, name)
+		case <-time.After(2 * time.Second):
+			d, found, err := getter.GetDeployment(p.Task.Namespace, name, p.kubeClient)
+			if err != nil || !found {
+				p.Log.Errorf("failed to check deployment ready status %s/%s - %v", p.Task.Namespace, name, err)
+				continue
+			}
+			if deploymentRolledOut(d) {
+				return nil
+			}
+		}
+	}
+}
+
+// deploymentRolledOut 所有副本都已更新为最新的模板并且可用
+func deploymentRolledOut(d *appsv1.Deployment) bool {
+	replicas := int32(1)
+	if d.Spec.Replicas != nil {
+		replicas = *d.Spec.Replicas
+	}
+	return d.Status.ObservedGeneration >= d.Generation &&
+		d.Status.UpdatedReplicas == replicas &&
+		d.Status.AvailableReplicas == replicas &&
+		d.Status.Replicas == replicas
+}
+
+func copyLabels(src, dst map[string]string) map[string]string {
+	if dst == nil {
+		dst = make(map[string]string, len(src))
+	}
+	for k, v := range src {
+		dst[k] = v
+	}
+	return dst
+}
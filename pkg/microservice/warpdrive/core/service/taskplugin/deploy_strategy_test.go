@@ -0,0 +1,235 @@
+/*
+Copyright 2021 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package taskplugin
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/koderover/zadig/pkg/microservice/warpdrive/config"
+	"github.com/koderover/zadig/pkg/microservice/warpdrive/core/service/types/task"
+	"github.com/koderover/zadig/pkg/setting"
+	"github.com/koderover/zadig/pkg/tool/log"
+)
+
+const strategyTestNamespace = "strategy-ns"
+
+func newStrategyDeployment(name string, replicas int32, selector, podLabels map[string]string, image string) *appsv1.Deployment {
+	return &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: strategyTestNamespace, Labels: map[string]string{}},
+		Spec: appsv1.DeploymentSpec{
+			Replicas: &replicas,
+			Selector: &metav1.LabelSelector{MatchLabels: selector},
+			Template: corev1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{Labels: podLabels},
+				Spec:       corev1.PodSpec{Containers: []corev1.Container{{Name: "app", Image: image}}},
+			},
+		},
+		Status: appsv1.DeploymentStatus{Replicas: replicas, UpdatedReplicas: replicas, AvailableReplicas: replicas},
+	}
+}
+
+func newStrategyService(name string, selector map[string]string) *corev1.Service {
+	return &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: strategyTestNamespace},
+		Spec:       corev1.ServiceSpec{Selector: selector},
+	}
+}
+
+func newStrategyPlugin(strategy string, objs ...client.Object) (*DeployTaskPlugin, client.Client) {
+	kubeClient := fake.NewClientBuilder().WithObjects(objs...).Build()
+	return &DeployTaskPlugin{
+		Name:       config.TaskDeploy,
+		kubeClient: kubeClient,
+		Log:        log.SugaredLogger(),
+		Task: &task.Deploy{
+			Namespace:     strategyTestNamespace,
+			ServiceName:   "web",
+			ContainerName: "app",
+			Image:         "web:v2",
+			Strategy:      &task.DeployStrategy{Type: strategy, CanaryPercent: 20},
+		},
+	}, kubeClient
+}
+
+func getStrategyDeployment(t *testing.T, kubeClient client.Client, name string) *appsv1.Deployment {
+	d := &appsv1.Deployment{}
+	assert.Nil(t, kubeClient.Get(context.TODO(), client.ObjectKey{Namespace: strategyTestNamespace, Name: name}, d))
+	return d
+}
+
+// markDeploymentReady fake client中没有controller, 等部署任务创建出Deployment后把它的状态改为已就绪
+func markDeploymentReady(ctx context.Context, kubeClient client.Client, name string) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(100 * time.Millisecond):
+		}
+		d := &appsv1.Deployment{}
+		if err := kubeClient.Get(ctx, client.ObjectKey{Namespace: strategyTestNamespace, Name: name}, d); err != nil {
+			continue
+		}
+		d.Status = appsv1.DeploymentStatus{Replicas: *d.Spec.Replicas, UpdatedReplicas: *d.Spec.Replicas, AvailableReplicas: *d.Spec.Replicas}
+		if err := kubeClient.Status().Update(ctx, d); err == nil {
+			return
+		}
+	}
+}
+
+func TestBlueGreenDeploy(t *testing.T) {
+	appLabels := map[string]string{"app": "web"}
+	plugin, kubeClient := newStrategyPlugin(setting.DeployStrategyBlueGreen,
+		newStrategyDeployment("web", 2, appLabels, appLabels, "web:v1"),
+		newStrategyService("web", appLabels),
+	)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go markDeploymentReady(ctx, kubeClient, "web-green")
+
+	target, done, err := plugin.deployWithStrategy(context.TODO(), getStrategyDeployment(t, kubeClient, "web"))
+	assert.Nil(t, err)
+	assert.True(t, done)
+	assert.Equal(t, "web", target.Name)
+
+	green := getStrategyDeployment(t, kubeClient, "web-green")
+	assert.Equal(t, "web:v2", green.Spec.Template.Spec.Containers[0].Image)
+	assert.Equal(t, int32(2), *green.Spec.Replicas)
+	assert.NotContains(t, green.Spec.Template.Labels, "app")
+
+	svc := &corev1.Service{}
+	assert.Nil(t, kubeClient.Get(context.TODO(), client.ObjectKey{Namespace: strategyTestNamespace, Name: "web"}, svc))
+	assert.Equal(t, map[string]string{setting.BlueGreenSlotLabel: "web-green"}, svc.Spec.Selector)
+	assert.Equal(t, `{"app":"web"}`, svc.Annotations[setting.OriginSelectorAnnotation])
+
+	assert.Equal(t, "web", plugin.Task.StandbyWorkload)
+	assert.Equal(t, []task.Resource{{Kind: setting.Service, Name: "web", Origin: `{"app":"web"}`}}, plugin.Task.ReplaceResources)
+
+	// 回滚时Service恢复原来的selector
+	assert.Nil(t, plugin.restoreOriginImages())
+	svc = &corev1.Service{}
+	assert.Nil(t, kubeClient.Get(context.TODO(), client.ObjectKey{Namespace: strategyTestNamespace, Name: "web"}, svc))
+	assert.Equal(t, appLabels, svc.Spec.Selector)
+}
+
+func TestRollingDeployAfterBlueGreen(t *testing.T) {
+	appLabels := map[string]string{"app": "web"}
+	slotLabels := map[string]string{setting.BlueGreenSlotLabel: "web-green"}
+	plugin, kubeClient := newStrategyPlugin(setting.DeployStrategyRolling,
+		newStrategyDeployment("web", 0, appLabels, appLabels, "web:v0"),
+		newStrategyDeployment("web-green", 2, slotLabels, slotLabels, "web:v1"),
+		newStrategyService("web", slotLabels),
+	)
+
+	assert.Nil(t, plugin.updateDeployment(context.TODO(), getStrategyDeployment(t, kubeClient, "web")))
+
+	// 流量在槽位上, 滚动更新槽位而不是已经缩容的原工作负载
+	assert.Equal(t, "web:v2", getStrategyDeployment(t, kubeClient, "web-green").Spec.Template.Spec.Containers[0].Image)
+	assert.Equal(t, "web:v0", getStrategyDeployment(t, kubeClient, "web").Spec.Template.Spec.Containers[0].Image)
+	assert.Equal(t, []task.Resource{{Kind: setting.Deployment, Name: "web-green", Container: "app", Origin: "web:v1"}}, plugin.Task.ReplaceResources)
+}
+
+func TestRollingDeploy(t *testing.T) {
+	appLabels := map[string]string{"app": "web"}
+	plugin, kubeClient := newStrategyPlugin(setting.DeployStrategyRolling,
+		newStrategyDeployment("web", 2, appLabels, appLabels, "web:v1"),
+		newStrategyService("web", appLabels),
+	)
+
+	assert.Nil(t, plugin.updateDeployment(context.TODO(), getStrategyDeployment(t, kubeClient, "web")))
+	assert.Equal(t, "web:v2", getStrategyDeployment(t, kubeClient, "web").Spec.Template.Spec.Containers[0].Image)
+	assert.Equal(t, []task.Resource{{Kind: setting.Deployment, Name: "web", Container: "app", Origin: "web:v1"}}, plugin.Task.ReplaceResources)
+}
+
+func TestCanaryPodLabels(t *testing.T) {
+	tests := []struct {
+		name      string
+		selector  map[string]string
+		podLabels map[string]string
+		service   map[string]string
+		expected  map[string]string
+		wantErr   bool
+	}{
+		{
+			name:      "drop labels only selected by deployment",
+			selector:  map[string]string{"app": "web", "track": "stable"},
+			podLabels: map[string]string{"app": "web", "track": "stable", "version": "v1"},
+			service:   map[string]string{"app": "web"},
+			expected:  map[string]string{"app": "web", "version": "v1", setting.CanaryLabel: "web"},
+		},
+		{
+			name:      "no service selects the deployment",
+			selector:  map[string]string{"app": "web"},
+			podLabels: map[string]string{"app": "web"},
+			service:   map[string]string{"app": "other"},
+			expected:  map[string]string{setting.CanaryLabel: "web"},
+		},
+		{
+			name:      "deployment selector is the same as service selector",
+			selector:  map[string]string{"app": "web"},
+			podLabels: map[string]string{"app": "web"},
+			service:   map[string]string{"app": "web"},
+			wantErr:   true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			plugin, _ := newStrategyPlugin(setting.DeployStrategyCanary, newStrategyService("web", tt.service))
+			deploy := newStrategyDeployment("web", 5, tt.selector, tt.podLabels, "web:v1")
+
+			podLabels, err := plugin.canaryPodLabels(deploy)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.Nil(t, err)
+			assert.Equal(t, tt.expected, podLabels)
+
+			// 原工作负载的selector不能选中金丝雀版本的Pod
+			selector, err := metav1.LabelSelectorAsSelector(deploy.Spec.Selector)
+			assert.Nil(t, err)
+			assert.False(t, selector.Matches(labels.Set(podLabels)))
+		})
+	}
+}
+
+func TestCanaryDeployAfterBlueGreen(t *testing.T) {
+	appLabels := map[string]string{"app": "web"}
+	slotLabels := map[string]string{setting.BlueGreenSlotLabel: "web-green"}
+	plugin, kubeClient := newStrategyPlugin(setting.DeployStrategyCanary,
+		newStrategyDeployment("web", 0, appLabels, appLabels, "web:v0"),
+		newStrategyDeployment("web-green", 2, slotLabels, slotLabels, "web:v1"),
+		newStrategyService("web", slotLabels),
+	)
+
+	// 槽位的selector和Service相同, 金丝雀版本无法和槽位区分, 不会创建金丝雀版本
+	err := plugin.updateDeployment(context.TODO(), getStrategyDeployment(t, kubeClient, "web"))
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "web-green")
+	assert.Equal(t, "web:v1", getStrategyDeployment(t, kubeClient, "web-green").Spec.Template.Spec.Containers[0].Image)
+	assert.Empty(t, plugin.Task.ReplaceResources)
+}
@@ -17,9 +17,23 @@ limitations under the License.
 package taskplugin
 
 import (
+	"context"
+	"fmt"
+
+	helmclient "github.com/mittwald/go-helm-client"
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
+	"helm.sh/helm/v3/pkg/release"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
 
+	"github.com/koderover/zadig/pkg/microservice/warpdrive/config"
+	"github.com/koderover/zadig/pkg/microservice/warpdrive/core/service/types/task"
+	"github.com/koderover/zadig/pkg/setting"
+	"github.com/koderover/zadig/pkg/tool/log"
 	"github.com/koderover/zadig/pkg/util/converter"
 )
 
@@ -91,3 +105,188 @@ var _ = Describe("Testing replace image", func() {
 
 	})
 })
+
+// fakeHelmClient 只实现回滚用到的方法, 其他方法调用时会panic
+type fakeHelmClient struct {
+	helmclient.Client
+	releases    map[string]*release.Release
+	rollbackErr error
+	rollbackTo  int
+}
+
+func (c *fakeHelmClient) GetRelease(name string) (*release.Release, error) {
+	if r, ok := c.releases[name]; ok {
+		return r, nil
+	}
+	return nil, fmt.Errorf("release: not found")
+}
+
+func (c *fakeHelmClient) RollbackRelease(spec *helmclient.ChartSpec, version int) error {
+	c.rollbackTo = version
+	return c.rollbackErr
+}
+
+func newPodTemplate(image string) corev1.PodTemplateSpec {
+	return corev1.PodTemplateSpec{
+		Spec: corev1.PodSpec{
+			Containers: []corev1.Container{
+				{Name: "app", Image: image},
+				{Name: "sidecar", Image: "sidecar:v1"},
+			},
+		},
+	}
+}
+
+var _ = Describe("Testing deploy rollback", func() {
+	const namespace = "rollback-ns"
+
+	var (
+		kubeClient client.Client
+		plugin     *DeployTaskPlugin
+	)
+
+	BeforeEach(func() {
+		kubeClient = fake.NewClientBuilder().WithObjects(
+			&appsv1.Deployment{
+				ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: namespace},
+				Spec:       appsv1.DeploymentSpec{Template: newPodTemplate("web:new")},
+			},
+			&appsv1.StatefulSet{
+				ObjectMeta: metav1.ObjectMeta{Name: "db", Namespace: namespace},
+				Spec:       appsv1.StatefulSetSpec{Template: newPodTemplate("db:new")},
+			},
+		).Build()
+		plugin = &DeployTaskPlugin{
+			Name:              config.TaskDeploy,
+			kubeClient:        kubeClient,
+			Log:               log.SugaredLogger(),
+			rollbackOnFailure: true,
+			Task: &task.Deploy{
+				Namespace:   namespace,
+				ServiceName: "web",
+			},
+		}
+	})
+
+	getImages := func(template corev1.PodTemplateSpec) map[string]string {
+		images := make(map[string]string)
+		for _, c := range template.Spec.Containers {
+			images[c.Name] = c.Image
+		}
+		return images
+	}
+
+	Context("restore origin images", func() {
+		It("restores only the replaced containers of deployments and statefulsets", func() {
+			plugin.Task.ReplaceResources = []task.Resource{
+				{Kind: setting.Deployment, Name: "web", Container: "app", Origin: "web:old"},
+				{Kind: setting.StatefulSet, Name: "db", Container: "app", Origin: "db:old"},
+				{Kind: "ConfigMap", Name: "web", Container: "app", Origin: "ignored"},
+			}
+			Expect(plugin.restoreOriginImages()).To(Succeed())
+
+			deployment := &appsv1.Deployment{}
+			Expect(kubeClient.Get(context.TODO(), client.ObjectKey{Namespace: namespace, Name: "web"}, deployment)).To(Succeed())
+			Expect(getImages(deployment.Spec.Template)).To(Equal(map[string]string{"app": "web:old", "sidecar": "sidecar:v1"}))
+
+			statefulSet := &appsv1.StatefulSet{}
+			Expect(kubeClient.Get(context.TODO(), client.ObjectKey{Namespace: namespace, Name: "db"}, statefulSet)).To(Succeed())
+			Expect(getImages(statefulSet.Spec.Template)).To(Equal(map[string]string{"app": "db:old", "sidecar": "sidecar:v1"}))
+		})
+
+		It("continues restoring other resources when one of them fails", func() {
+			plugin.Task.ReplaceResources = []task.Resource{
+				{Kind: setting.Deployment, Name: "missing", Container: "app", Origin: "missing:old"},
+				{Kind: setting.Deployment, Name: "web", Container: "app", Origin: "web:old"},
+			}
+			err := plugin.restoreOriginImages()
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring(namespace + "/" + setting.Deployment + "/missing/app"))
+
+			deployment := &appsv1.Deployment{}
+			Expect(kubeClient.Get(context.TODO(), client.ObjectKey{Namespace: namespace, Name: "web"}, deployment)).To(Succeed())
+			Expect(getImages(deployment.Spec.Template)["app"]).To(Equal("web:old"))
+		})
+
+		It("is used for non-helm services with replaced resources", func() {
+			plugin.Task.ReplaceResources = []task.Resource{
+				{Kind: setting.Deployment, Name: "web", Container: "app", Origin: "web:old"},
+			}
+			plugin.rollback()
+			Expect(plugin.Task.Rollback).NotTo(BeNil())
+			Expect(plugin.Task.Rollback.Status).To(Equal(config.StatusPassed))
+
+			// 同一个任务只回滚一次
+			plugin.Task.Rollback.Status = config.StatusFailed
+			plugin.rollback()
+			Expect(plugin.Task.Rollback.Status).To(Equal(config.StatusFailed))
+		})
+
+		It("does nothing when rollback is disabled or nothing was replaced", func() {
+			plugin.rollback()
+			Expect(plugin.Task.Rollback).To(BeNil())
+
+			plugin.rollbackOnFailure = false
+			plugin.Task.ReplaceResources = []task.Resource{
+				{Kind: setting.Deployment, Name: "web", Container: "app", Origin: "web:old"},
+			}
+			plugin.rollback()
+			Expect(plugin.Task.Rollback).To(BeNil())
+		})
+	})
+
+	Context("rollback helm release", func() {
+		var helmClient *fakeHelmClient
+
+		BeforeEach(func() {
+			helmClient = &fakeHelmClient{releases: map[string]*release.Release{
+				"rollback-ns-web": {Name: "rollback-ns-web", Version: 3},
+			}}
+			plugin.Task.ServiceType = setting.HelmDeployType
+		})
+
+		It("records the release version before upgrade", func() {
+			info := newHelmRollbackInfo(helmClient, &helmclient.ChartSpec{ReleaseName: "rollback-ns-web"}, "a: 1")
+			Expect(info.revision).To(Equal(3))
+			Expect(info.originValuesYaml).To(Equal("a: 1"))
+
+			info = newHelmRollbackInfo(helmClient, &helmclient.ChartSpec{ReleaseName: "rollback-ns-new"}, "")
+			Expect(info.revision).To(Equal(0))
+		})
+
+		It("rolls back to the recorded revision", func() {
+			plugin.helmRollback = newHelmRollbackInfo(helmClient, &helmclient.ChartSpec{ReleaseName: "rollback-ns-web"}, "")
+			plugin.rollback()
+
+			Expect(helmClient.rollbackTo).To(Equal(3))
+			Expect(plugin.Task.Rollback.Status).To(Equal(config.StatusPassed))
+			Expect(plugin.Task.Rollback.Revision).To(Equal(3))
+		})
+
+		It("fails when the release did not exist before deploying", func() {
+			plugin.helmRollback = newHelmRollbackInfo(helmClient, &helmclient.ChartSpec{ReleaseName: "rollback-ns-new"}, "")
+			plugin.rollback()
+
+			Expect(helmClient.rollbackTo).To(Equal(0))
+			Expect(plugin.Task.Rollback.Status).To(Equal(config.StatusFailed))
+			Expect(plugin.Task.Rollback.Error).To(ContainSubstring("no previous revision"))
+		})
+
+		It("reports the helm rollback error", func() {
+			helmClient.rollbackErr = fmt.Errorf("timed out")
+			plugin.helmRollback = newHelmRollbackInfo(helmClient, &helmclient.ChartSpec{ReleaseName: "rollback-ns-web"}, "")
+			plugin.rollback()
+
+			Expect(plugin.Task.Rollback.Status).To(Equal(config.StatusFailed))
+			Expect(plugin.Task.Rollback.Error).To(ContainSubstring("timed out"))
+		})
+
+		It("does not restore images for helm services", func() {
+			plugin.Task.ReplaceResources = []task.Resource{
+				{Kind: setting.Deployment, Name: "web", Container: "app", Origin: "web:old"},
+			}
+			plugin.rollback()
+			Expect(plugin.Task.Rollback).To(BeNil())
+		})
+	})
+})
@@ -0,0 +1,310 @@
+/*
+Copyright 2021 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package taskplugin
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/koderover/zadig/pkg/microservice/warpdrive/config"
+	"github.com/koderover/zadig/pkg/microservice/warpdrive/core/service/types/task"
+	"github.com/koderover/zadig/pkg/setting"
+	"github.com/koderover/zadig/pkg/tool/httpclient"
+	"github.com/koderover/zadig/pkg/tool/kube/getter"
+	"github.com/koderover/zadig/pkg/tool/kube/updater"
+)
+
+const (
+	defaultVerificationWindow   = 60
+	defaultVerificationInterval = 10
+	verificationJobType         = "deploy-verification"
+	// maxJobNameLength Job名称会作为Pod的label值, 不能超过63个字符
+	maxJobNameLength = 63
+)
+
+// verify 部署就绪后按顺序执行验证, 返回第一个失败的验证的错误
+func (p *DeployTaskPlugin) verify(ctx context.Context) error {
+	p.Task.VerificationResults = nil
+	for _, v := range p.Task.Verifications {
+		result := &task.VerificationResult{Name: v.Name, Type: v.Type, Status: config.StatusRunning}
+		p.Task.VerificationResults = append(p.Task.VerificationResults, result)
+
+		p.Log.Infof("start %s verification %s of service %s", v.Type, v.Name, p.Task.ServiceName)
+		var err error
+		switch v.Type {
+		case setting.DeployVerificationHTTP:
+			err = p.verifyHTTP(ctx, v)
+		case setting.DeployVerificationPrometheus:
+			err = p.verifyPrometheus(ctx, v)
+		case setting.DeployVerificationJob:
+			err = p.verifyJob(ctx, v)
+		default:
+			err = errors.Errorf("unsupported verification type: %s", v.Type)
+		}
+
+		if err != nil {
+			p.Log.Errorf("verification %s of service %s failed: %v", v.Name, p.Task.ServiceName, err)
+			result.Status = config.StatusFailed
+			result.Message = err.Error()
+			return errors.WithMessagef(err, "verification %s failed", v.Name)
+		}
+		result.Status = config.StatusPassed
+	}
+	return nil
+}
+
+func verificationWindow(v *task.DeployVerification) time.Duration {
+	if v.Window <= 0 {
+		return defaultVerificationWindow * time.Second
+	}
+	return time.Duration(v.Window) * time.Second
+}
+
+func verificationInterval(v *task.DeployVerification) time.Duration {
+	if v.Interval <= 0 {
+		return defaultVerificationInterval * time.Second
+	}
+	return time.Duration(v.Interval) * time.Second
+}
+
+// verifyHTTP 在窗口期内重复请求, 有一次返回期望的状态码即通过
+func (p *DeployTaskPlugin) verifyHTTP(ctx context.Context, v *task.DeployVerification) error {
+	var cfs []httpclient.ClientFunc
+	if v.ExpectedStatus != 0 {
+		cfs = append(cfs, httpclient.SetIgnoreCodes(v.ExpectedStatus))
+	}
+	client := httpclient.New(cfs...)
+
+	deadline := time.After(verificationWindow(v))
+	var lastErr error
+	for {
+		res, err := client.Get(v.URL)
+		switch {
+		case err != nil:
+			lastErr = err
+		case v.ExpectedStatus != 0 && res.StatusCode() != v.ExpectedStatus:
+			lastErr = errors.Errorf("expected status %d, got %d", v.ExpectedStatus, res.StatusCode())
+		default:
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return errors.New("verification is cancelled")
+		case <-deadline:
+			return errors.WithMessagef(lastErr, "%s is not healthy", v.URL)
+		case <-time.After(verificationInterval(v)):
+		}
+	}
+}
+
+type prometheusQueryResponse struct {
+	Status string `json:"status"`
+	Error  string `json:"error"`
+	Data   struct {
+		ResultType string        `json:"resultType"`
+		Result     []interface{} `json:"result"`
+	} `json:"data"`
+}
+
+// verifyPrometheus 在窗口期内按间隔执行查询, 每次查询的所有结果都需要满足阈值
+func (p *DeployTaskPlugin) verifyPrometheus(ctx context.Context, v *task.DeployVerification) error {
+	deadline := time.After(verificationWindow(v))
+	for {
+		values, err := queryPrometheus(v.PrometheusURL, v.Query)
+		if err != nil {
+			return err
+		}
+		if len(values) == 0 {
+			return errors.Errorf("query %s returns no data", v.Query)
+		}
+		for _, value := range values {
+			ok, err := compareThreshold(value, v.Operator, v.Threshold)
+			if err != nil {
+				return err
+			}
+			if !ok {
+				return errors.Errorf("query %s returns %v, expected %s %v", v.Query, value, v.Operator, v.Threshold)
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return errors.New("verification is cancelled")
+		case <-deadline:
+			return nil
+		case <-time.After(verificationInterval(v)):
+		}
+	}
+}
+
+// queryPrometheus 执行即时查询, 返回标量或者向量中每个序列的值
+func queryPrometheus(address, query string) ([]float64, error) {
+	url := strings.TrimSuffix(address, "/") + "/api/v1/query"
+	resp, err := httpclient.Get(url, httpclient.SetQueryParam("query", query))
+	if err != nil {
+		return nil, err
+	}
+	// 不依赖响应的Content-Type, 经过代理时可能不是application/json
+	res := &prometheusQueryResponse{}
+	if err := json.Unmarshal(resp.Body(), res); err != nil {
+		return nil, errors.WithMessagef(err, "invalid response of query %s", query)
+	}
+	if res.Status != "success" {
+		return nil, errors.Errorf("failed to query %s: %s", query, res.Error)
+	}
+
+	var samples [][]interface{}
+	switch res.Data.ResultType {
+	case "scalar":
+		samples = append(samples, res.Data.Result)
+	case "vector":
+		for _, r := range res.Data.Result {
+			series, ok := r.(map[string]interface{})
+			if !ok {
+				return nil, errors.Errorf("invalid result of query %s", query)
+			}
+			sample, _ := series["value"].([]interface{})
+			samples = append(samples, sample)
+		}
+	default:
+		return nil, errors.Errorf("unsupported result type %s of query %s", res.Data.ResultType, query)
+	}
+
+	values := make([]float64, 0, len(samples))
+	for _, sample := range samples {
+		if len(sample) != 2 {
+			return nil, errors.Errorf("invalid result of query %s", query)
+		}
+		s, _ := sample[1].(string)
+		value, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return nil, errors.WithMessagef(err, "invalid value of query %s", query)
+		}
+		values = append(values, value)
+	}
+	return values, nil
+}
+
+// compareThreshold 比较查询结果和阈值, 查询结果为NaN时无法比较, 返回错误
+func compareThreshold(value float64, operator string, threshold float64) (bool, error) {
+	if math.IsNaN(value) {
+		return false, errors.New("value is NaN")
+	}
+	switch operator {
+	case ">":
+		return value > threshold, nil
+	case ">=":
+		return value >= threshold, nil
+	case "<":
+		return value < threshold, nil
+	case "<=":
+		return value <= threshold, nil
+	case "==":
+		return value == threshold, nil
+	case "!=":
+		return value != threshold, nil
+	default:
+		return false, errors.Errorf("unsupported operator %q", operator)
+	}
+}
+
+// verificationJobName 服务名过长时截断, 保证Job名称不超过63个字符
+func verificationJobName(serviceName string, timestamp int64) string {
+	suffix := fmt.Sprintf("-verify-%d", timestamp)
+	if len(serviceName)+len(suffix) > maxJobNameLength {
+		serviceName = strings.TrimRight(serviceName[:maxJobNameLength-len(suffix)], "-.")
+	}
+	return serviceName + suffix
+}
+
+// verifyJob 在部署的命名空间中运行验证Job, 窗口期内Job成功即通过, 结束后删除Job
+func (p *DeployTaskPlugin) verifyJob(ctx context.Context, v *task.DeployVerification) error {
+	name := verificationJobName(p.Task.ServiceName, time.Now().Unix())
+	backoffLimit := int32(0)
+	job := &batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: p.Task.Namespace,
+			Labels: map[string]string{
+				setting.TypeLabel: verificationJobType,
+			},
+		},
+		Spec: batchv1.JobSpec{
+			BackoffLimit: &backoffLimit,
+			Template: corev1.PodTemplateSpec{
+				Spec: corev1.PodSpec{
+					RestartPolicy: corev1.RestartPolicyNever,
+					Containers: []corev1.Container{
+						{
+							Name:    "verify",
+							Image:   v.Image,
+							Command: []string{"/bin/sh", "-c", v.Script},
+							Env: []corev1.EnvVar{
+								{Name: "ENV_NAME", Value: p.Task.EnvName},
+								{Name: "NAMESPACE", Value: p.Task.Namespace},
+								{Name: "SERVICE_NAME", Value: p.Task.ServiceName},
+								{Name: "IMAGE", Value: p.Task.Image},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	if err := updater.CreateJob(job, p.kubeClient); err != nil {
+		return errors.WithMessagef(err, "failed to create verification job %s", name)
+	}
+	defer func() {
+		if err := updater.DeleteJob(p.Task.Namespace, name, p.kubeClient); err != nil {
+			p.Log.Errorf("failed to delete verification job %s/%s: %v", p.Task.Namespace, name, err)
+		}
+	}()
+
+	deadline := time.After(verificationWindow(v))
+	for {
+		select {
+		case <-ctx.Done():
+			return errors.New("verification is cancelled")
+		case <-deadline:
+			return errors.Errorf("timeout waiting for verification job %s", name)
+		case <-time.After(2 * time.Second):
+			j, found, err := getter.GetJob(p.Task.Namespace, name, p.kubeClient)
+			if err != nil || !found {
+				p.Log.Errorf("failed to get verification job %s/%s - %v", p.Task.Namespace, name, err)
+				continue
+			}
+			if j.Status.Succeeded > 0 {
+				return nil
+			}
+			if j.Status.Failed > 0 {
+				return errors.Errorf("verification job %s failed", name)
+			}
+		}
+	}
+}
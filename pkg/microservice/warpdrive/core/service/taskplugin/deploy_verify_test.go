@@ -0,0 +1,185 @@
+/*
+Copyright 2021 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package taskplugin
+
+import (
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCompareThreshold(t *testing.T) {
+	tests := []struct {
+		value     float64
+		operator  string
+		threshold float64
+		expected  bool
+		wantErr   bool
+	}{
+		{value: 2, operator: ">", threshold: 1, expected: true},
+		{value: 1, operator: ">", threshold: 1, expected: false},
+		{value: 1, operator: ">=", threshold: 1, expected: true},
+		{value: 0.5, operator: "<", threshold: 1, expected: true},
+		{value: 1, operator: "<", threshold: 1, expected: false},
+		{value: 1, operator: "<=", threshold: 1, expected: true},
+		{value: 1, operator: "==", threshold: 1, expected: true},
+		{value: 1, operator: "!=", threshold: 1, expected: false},
+		{value: math.Inf(1), operator: ">", threshold: 1, expected: true},
+		{value: math.NaN(), operator: "!=", threshold: 1, wantErr: true},
+		{value: math.NaN(), operator: "<", threshold: 1, wantErr: true},
+		{value: 1, operator: "=", threshold: 1, wantErr: true},
+		{value: 1, operator: "", threshold: 1, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		ok, err := compareThreshold(tt.value, tt.operator, tt.threshold)
+		if tt.wantErr {
+			assert.Error(t, err, "%v %s %v", tt.value, tt.operator, tt.threshold)
+			assert.False(t, ok)
+			continue
+		}
+		assert.Nil(t, err)
+		assert.Equal(t, tt.expected, ok, "%v %s %v", tt.value, tt.operator, tt.threshold)
+	}
+}
+
+func TestQueryPrometheus(t *testing.T) {
+	tests := []struct {
+		name     string
+		code     int
+		body     string
+		expected []float64
+		wantErr  string
+	}{
+		{
+			name:     "vector",
+			body:     `{"status":"success","data":{"resultType":"vector","result":[{"metric":{"pod":"a"},"value":[1635000000.1,"0.5"]},{"metric":{"pod":"b"},"value":[1635000000.1,"1"]}]}}`,
+			expected: []float64{0.5, 1},
+		},
+		{
+			name:     "scalar",
+			body:     `{"status":"success","data":{"resultType":"scalar","result":[1635000000.1,"3"]}}`,
+			expected: []float64{3},
+		},
+		{
+			name:     "empty vector",
+			body:     `{"status":"success","data":{"resultType":"vector","result":[]}}`,
+			expected: []float64{},
+		},
+		{
+			name:     "NaN value",
+			body:     `{"status":"success","data":{"resultType":"vector","result":[{"metric":{},"value":[1635000000.1,"NaN"]}]}}`,
+			expected: []float64{math.NaN()},
+		},
+		{
+			name:    "query error",
+			code:    http.StatusBadRequest,
+			body:    `{"status":"error","errorType":"bad_data","error":"parse error"}`,
+			wantErr: "400",
+		},
+		{
+			name:    "unsupported result type",
+			body:    `{"status":"success","data":{"resultType":"matrix","result":[{"metric":{},"values":[[1635000000.1,"1"]]}]}}`,
+			wantErr: "unsupported result type matrix",
+		},
+		{
+			name:    "series without value",
+			body:    `{"status":"success","data":{"resultType":"vector","result":[{"metric":{}}]}}`,
+			wantErr: "invalid result",
+		},
+		{
+			name:    "series is not an object",
+			body:    `{"status":"success","data":{"resultType":"vector","result":["1"]}}`,
+			wantErr: "invalid result",
+		},
+		{
+			name:    "value is not a string",
+			body:    `{"status":"success","data":{"resultType":"vector","result":[{"metric":{},"value":[1635000000.1,1]}]}}`,
+			wantErr: "invalid value",
+		},
+		{
+			name:    "value is not a number",
+			body:    `{"status":"success","data":{"resultType":"scalar","result":[1635000000.1,"abc"]}}`,
+			wantErr: "invalid value",
+		},
+		{
+			name:    "not json",
+			body:    `<html>502 Bad Gateway</html>`,
+			wantErr: "invalid response",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var query string
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				assert.Equal(t, "/api/v1/query", r.URL.Path)
+				query = r.URL.Query().Get("query")
+				if tt.code != 0 {
+					w.WriteHeader(tt.code)
+				}
+				_, _ = w.Write([]byte(tt.body))
+			}))
+			defer server.Close()
+
+			values, err := queryPrometheus(server.URL+"/", "up")
+			assert.Equal(t, "up", query)
+			if tt.wantErr != "" {
+				assert.Error(t, err)
+				if err != nil {
+					assert.Contains(t, err.Error(), tt.wantErr)
+				}
+				return
+			}
+			assert.Nil(t, err)
+			assert.Len(t, values, len(tt.expected))
+			for i := range tt.expected {
+				if math.IsNaN(tt.expected[i]) {
+					assert.True(t, math.IsNaN(values[i]))
+					continue
+				}
+				assert.Equal(t, tt.expected[i], values[i])
+			}
+		})
+	}
+}
+
+func TestVerificationJobName(t *testing.T) {
+	const timestamp = int64(1635000000)
+
+	tests := []struct {
+		serviceName string
+		expected    string
+	}{
+		{serviceName: "web", expected: "web-verify-1635000000"},
+		{serviceName: strings.Repeat("a", 45), expected: strings.Repeat("a", 45) + "-verify-1635000000"},
+		{serviceName: strings.Repeat("a", 60), expected: strings.Repeat("a", 45) + "-verify-1635000000"},
+		// 截断后不能以-或者.结尾
+		{serviceName: strings.Repeat("a", 44) + "--b", expected: strings.Repeat("a", 44) + "-verify-1635000000"},
+		{serviceName: strings.Repeat("a", 43) + "-.b", expected: strings.Repeat("a", 43) + "-verify-1635000000"},
+	}
+
+	for _, tt := range tests {
+		name := verificationJobName(tt.serviceName, timestamp)
+		assert.Equal(t, tt.expected, name)
+		assert.LessOrEqual(t, len(name), maxJobNameLength)
+	}
+}
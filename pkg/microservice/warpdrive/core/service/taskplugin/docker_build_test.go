@@ -28,6 +28,8 @@ import (
 )
 
 func TestDockerBuildTaskPlugin(t *testing.T) {
+	skipWithoutCluster(t)
+
 	assert := assert.New(t)
 	log := log.SugaredLogger()
 
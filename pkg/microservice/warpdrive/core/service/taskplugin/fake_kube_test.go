@@ -0,0 +1,118 @@
+/*
+Copyright 2021 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package taskplugin
+
+import (
+	"context"
+	"testing"
+
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/koderover/zadig/pkg/tool/log"
+)
+
+func init() {
+	log.Init(&log.Config{Level: "debug", Development: true})
+}
+
+// FakeKubeCli 测试中使用的内存kube client, 不需要连接集群
+var FakeKubeCli = &fakeKubeCli{Client: fake.NewClientBuilder().Build()}
+
+type fakeKubeCli struct {
+	client.Client
+}
+
+func (c *fakeKubeCli) CreateNamespace(name string) error {
+	return c.Create(context.TODO(), &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: name}})
+}
+
+func (c *fakeKubeCli) GetNamespace(name string) (*corev1.Namespace, error) {
+	ns := &corev1.Namespace{}
+	err := c.Get(context.TODO(), client.ObjectKey{Name: name}, ns)
+	return ns, err
+}
+
+func (c *fakeKubeCli) DeleteNamespace(name string) error {
+	return c.Delete(context.TODO(), &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: name}})
+}
+
+func (c *fakeKubeCli) CreateConfigMap(namespace string, cm *corev1.ConfigMap) error {
+	cm.Namespace = namespace
+	return c.Create(context.TODO(), cm)
+}
+
+func (c *fakeKubeCli) GetConfigMap(namespace, name string) (*corev1.ConfigMap, error) {
+	cm := &corev1.ConfigMap{}
+	err := c.Get(context.TODO(), client.ObjectKey{Namespace: namespace, Name: name}, cm)
+	return cm, err
+}
+
+func (c *fakeKubeCli) ListConfigMaps(namespace string, selector labels.Selector) ([]*corev1.ConfigMap, error) {
+	list := &corev1.ConfigMapList{}
+	if err := c.List(context.TODO(), list, client.InNamespace(namespace), client.MatchingLabelsSelector{Selector: selector}); err != nil {
+		return nil, err
+	}
+	var res []*corev1.ConfigMap
+	for i := range list.Items {
+		res = append(res, &list.Items[i])
+	}
+	return res, nil
+}
+
+func (c *fakeKubeCli) DeleteConfigMap(namespace, name string) error {
+	return c.Delete(context.TODO(), &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: name}})
+}
+
+func (c *fakeKubeCli) DeleteConfigMaps(namespace string, selector labels.Selector) error {
+	return c.DeleteAllOf(context.TODO(), &corev1.ConfigMap{}, client.InNamespace(namespace), client.MatchingLabelsSelector{Selector: selector})
+}
+
+func (c *fakeKubeCli) CreateJob(namespace string, job *batchv1.Job) error {
+	job.Namespace = namespace
+	return c.Create(context.TODO(), job)
+}
+
+func (c *fakeKubeCli) GetJob(namespace, name string) (*batchv1.Job, error) {
+	job := &batchv1.Job{}
+	err := c.Get(context.TODO(), client.ObjectKey{Namespace: namespace, Name: name}, job)
+	return job, err
+}
+
+func (c *fakeKubeCli) DeleteJob(namespace, name string) error {
+	return c.Delete(context.TODO(), &batchv1.Job{ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: name}})
+}
+
+func (c *fakeKubeCli) DeleteJobs(namespace string, selector labels.Selector) error {
+	return c.DeleteAllOf(context.TODO(), &batchv1.Job{}, client.InNamespace(namespace), client.MatchingLabelsSelector{Selector: selector})
+}
+
+func getJobSelector(ls map[string]string) labels.Selector {
+	return labels.Set(ls).AsSelector()
+}
+
+// skipWithoutCluster 插件初始化时会连接集群, 没有可用的集群配置时跳过测试
+func skipWithoutCluster(t *testing.T) {
+	if _, err := ctrl.GetConfig(); err != nil {
+		t.Skipf("kubernetes cluster is not available: %v", err)
+	}
+}
@@ -75,6 +75,37 @@ func saveFile(src io.Reader, localFile string) error {
 	return err
 }
 
+// newLogStore 返回任务日志所在的对象存储, 日志保存在 {pipeline}/{taskID}/log 目录下
+func newLogStore(pipelineTask *task.Task) (*s3.S3, *s3tool.Client, error) {
+	store, err := s3.NewS3StorageFromEncryptedURI(pipelineTask.StorageURI)
+	if err != nil {
+		return nil, nil, err
+	}
+	if store.Subfolder != "" {
+		store.Subfolder = fmt.Sprintf("%s/%s/%d/%s", store.Subfolder, pipelineTask.PipelineName, pipelineTask.TaskID, "log")
+	} else {
+		store.Subfolder = fmt.Sprintf("%s/%d/%s", pipelineTask.PipelineName, pipelineTask.TaskID, "log")
+	}
+	forcedPathStyle := true
+	if store.Provider == setting.ProviderSourceAli {
+		forcedPathStyle = false
+	}
+	s3client, err := s3tool.NewClient(store.Endpoint, store.Ak, store.Sk, store.Insecure, forcedPathStyle)
+	if err != nil {
+		return nil, nil, err
+	}
+	return store, s3client, nil
+}
+
+// ArchiveTaskLog 复制subtask的日志文件, 避免subtask重试时被覆盖
+func ArchiveTaskLog(pipelineTask *task.Task, fileName, archivedName string) error {
+	store, s3client, err := newLogStore(pipelineTask)
+	if err != nil {
+		return err
+	}
+	return s3client.CopyObject(store.Bucket, store.GetObjectPath(fileName+".log"), store.GetObjectPath(archivedName+".log"))
+}
+
 func saveContainerLog(pipelineTask *task.Task, namespace, fileName string, jobLabel *JobLabel, kubeClient client.Client) error {
 	selector := labels.Set(getJobLabels(jobLabel)).AsSelector()
 	pods, err := getter.ListPods(namespace, selector, kubeClient)
@@ -99,25 +130,33 @@ func saveContainerLog(pipelineTask *task.Task, namespace, fileName string, jobLa
 		return err
 	}
 
+	if err := uploadContainerLog(pipelineTask, fileName, buf); err != nil {
+		return err
+	}
+
+	// 下载容器日志到本地 （单线程pipeline）
+	//logDir := pipelineTask.ConfigPayload.NFS.GetLogPath()
+	//if err = os.MkdirAll(logDir, os.ModePerm); err != nil {
+	//	return fmt.Errorf("failed to create log dir: %v", err)
+	//}
+	//
+	//localFile := path.Join(logDir, fileName)
+	//err = saveFile(buf, localFile)
+	//if err != nil {
+	//	return fmt.Errorf("save build log file error: %v", err)
+	//}
+
+	return nil
+}
+
+// uploadContainerLog 把容器日志上传到对象存储
+func uploadContainerLog(pipelineTask *task.Task, fileName string, buf io.Reader) error {
 	if tempFileName, err := util.GenerateTmpFile(); err == nil {
 		defer func() {
 			_ = os.Remove(tempFileName)
 		}()
 		if err = saveFile(buf, tempFileName); err == nil {
-			var store *s3.S3
-			if store, err = s3.NewS3StorageFromEncryptedURI(pipelineTask.StorageURI); err != nil {
-				return err
-			}
-			if store.Subfolder != "" {
-				store.Subfolder = fmt.Sprintf("%s/%s/%d/%s", store.Subfolder, pipelineTask.PipelineName, pipelineTask.TaskID, "log")
-			} else {
-				store.Subfolder = fmt.Sprintf("%s/%d/%s", pipelineTask.PipelineName, pipelineTask.TaskID, "log")
-			}
-			forcedPathStyle := true
-			if store.Provider == setting.ProviderSourceAli {
-				forcedPathStyle = false
-			}
-			s3client, err := s3tool.NewClient(store.Endpoint, store.Ak, store.Sk, store.Insecure, forcedPathStyle)
+			store, s3client, err := newLogStore(pipelineTask)
 			if err != nil {
 				return fmt.Errorf("saveContainerLog s3 create client error: %v", err)
 			}
@@ -135,19 +174,6 @@ func saveContainerLog(pipelineTask *task.Task, namespace, fileName string, jobLa
 	} else {
 		return fmt.Errorf("saveContainerLog GenerateTmpFile error: %v", err)
 	}
-
-	// 下载容器日志到本地 （单线程pipeline）
-	//logDir := pipelineTask.ConfigPayload.NFS.GetLogPath()
-	//if err = os.MkdirAll(logDir, os.ModePerm); err != nil {
-	//	return fmt.Errorf("failed to create log dir: %v", err)
-	//}
-	//
-	//localFile := path.Join(logDir, fileName)
-	//err = saveFile(buf, localFile)
-	//if err != nil {
-	//	return fmt.Errorf("save build log file error: %v", err)
-	//}
-
 	return nil
 }
 
@@ -161,6 +187,33 @@ type JobCtxBuilder struct {
 	Installs       []*task.Install
 }
 
+func buildStep(step *task.Step) *types.Step {
+	s := &types.Step{
+		Name:        step.Name,
+		Type:        step.Type,
+		When:        step.When,
+		Timeout:     step.Timeout,
+		Envs:        types.EnvVar{},
+		SecretEnvs:  types.EnvVar{},
+		DockerBuild: step.DockerBuild,
+		Paths:       step.Paths,
+		Dest:        step.Dest,
+		ResultPath:  step.ResultPath,
+	}
+	if step.Scripts != "" {
+		s.Scripts = strings.Split(replaceWrapLine(step.Scripts), "\n")
+	}
+	for _, ev := range step.Envs {
+		val := fmt.Sprintf("%s=%s", ev.Key, ev.Value)
+		if ev.IsCredential {
+			s.SecretEnvs = append(s.SecretEnvs, val)
+		} else {
+			s.Envs = append(s.Envs, val)
+		}
+	}
+	return s
+}
+
 func replaceWrapLine(script string) string {
 	return strings.Replace(strings.Replace(
 		script,
@@ -260,6 +313,10 @@ func (b *JobCtxBuilder) BuildReaperContext(pipelineTask *task.Task, serviceName
 		ctx.Scripts = append(ctx.Scripts, strings.Split(replaceWrapLine(buildStep.Scripts), "\n")...)
 	}
 
+	for _, step := range b.JobCtx.Steps {
+		ctx.Steps = append(ctx.Steps, buildStep(step))
+	}
+
 	if b.JobCtx.PostScripts != "" {
 		ctx.PostScripts = append(ctx.PostScripts, strings.Split(replaceWrapLine(b.JobCtx.PostScripts), "\n")...)
 	}
@@ -289,6 +346,7 @@ func (b *JobCtxBuilder) BuildReaperContext(pipelineTask *task.Task, serviceName
 			DockerFile: b.JobCtx.DockerBuildCtx.DockerFile,
 			ImageName:  b.JobCtx.DockerBuildCtx.ImageName,
 			BuildArgs:  b.JobCtx.DockerBuildCtx.BuildArgs,
+			SBOMFormat: b.JobCtx.DockerBuildCtx.SBOMFormat,
 		}
 	}
 
@@ -297,12 +355,16 @@ func (b *JobCtxBuilder) BuildReaperContext(pipelineTask *task.Task, serviceName
 	}
 
 	ctx.Caches = b.JobCtx.Caches
+	ctx.CacheType = b.JobCtx.CacheType
+	ctx.CacheKeyFiles = b.JobCtx.CacheKeyFiles
 
-	if b.JobCtx.TestResultPath != "" {
+	if b.JobCtx.TestResultPath != "" || b.JobCtx.CoverageReportPath != "" {
 		ctx.GinkgoTest = &types.GinkgoTest{
 			ResultPath:     b.JobCtx.TestResultPath,
 			TestReportPath: b.JobCtx.TestReportPath,
 			ArtifactPaths:  b.JobCtx.ArtifactPaths,
+			CoveragePath:   b.JobCtx.CoverageReportPath,
+			CoverageFormat: b.JobCtx.CoverageFormat,
 		}
 	}
 
@@ -63,7 +63,7 @@ func TestCreateJobConfigMap(t *testing.T) {
 		TaskType:     fmt.Sprintf("%s", config.TaskBuild),
 	}
 
-	createJobConfigMap(FakeKubeCli, namespace, jobname, jobLabel, jobCtx)
+	createJobConfigMap(namespace, jobname, jobLabel, jobCtx, FakeKubeCli)
 	jobConfigmap, err := FakeKubeCli.GetConfigMap(namespace, jobname)
 	assert.Nil(err)
 	assert.Equal(jobname, jobConfigmap.Name)
@@ -167,8 +167,9 @@ func TestBuildJob(t *testing.T) {
 
 func TestGetVolumes(t *testing.T) {
 	vols := getVolumes("demo-job")
-	assert.Len(t, vols, 1)
+	assert.Len(t, vols, 2)
 	assert.Equal(t, "job-config", vols[0].Name)
+	assert.Equal(t, "aes-key", vols[1].Name)
 }
 
 func TestEnsureDeleteJob(t *testing.T) {
@@ -18,12 +18,16 @@ package taskplugin
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"strconv"
 	"time"
 
 	"go.uber.org/zap"
 	yaml "gopkg.in/yaml.v3"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 
 	"github.com/koderover/zadig/pkg/microservice/warpdrive/config"
@@ -59,6 +63,12 @@ func (p *ReleaseImagePlugin) SetAckFunc(func()) {
 const (
 	// RelealseImageTaskTimeout ...
 	RelealseImageTaskTimeout = 60 * 5 // 5 minutes
+
+	signingSecretKeyKey      = "cosign.key"
+	signingSecretPasswordKey = "password"
+	// predator从以下环境变量读取cosign私钥和私钥密码
+	cosignPrivateKeyEnv = "COSIGN_PRIVATE_KEY"
+	cosignPasswordEnv   = "COSIGN_PASSWORD"
 )
 
 // Init ...
@@ -138,6 +148,28 @@ func (p *ReleaseImagePlugin) Run(ctx context.Context, pipelineTask *task.Task, p
 		ReleaseImages: releases,
 	}
 
+	var signingKey *task.SigningKey
+	if p.Task.Signing != nil {
+		signingKey = pipelineTask.ConfigPayload.GetSigningKey(p.Task.Signing.KeyID)
+		if signingKey == nil {
+			msg := fmt.Sprintf("signing key %s not found", p.Task.Signing.KeyID)
+			p.Log.Error(msg)
+			p.Task.TaskStatus = config.StatusFailed
+			p.Task.Error = msg
+			return
+		}
+
+		signing, err := p.imageSigning(pipelineTask, serviceName)
+		if err != nil {
+			msg := fmt.Sprintf("failed to prepare image signing: %v", err)
+			p.Log.Error(msg)
+			p.Task.TaskStatus = config.StatusFailed
+			p.Task.Error = msg
+			return
+		}
+		jobCtx.Signing = signing
+	}
+
 	jobCtxBytes, err := yaml.Marshal(jobCtx)
 	if err != nil {
 		msg := fmt.Sprintf("cannot mashal predetor.Context data: %v", err)
@@ -193,6 +225,28 @@ func (p *ReleaseImagePlugin) Run(ctx context.Context, pipelineTask *task.Task, p
 		return
 	}
 
+	// 签名私钥和密码不写入ConfigMap, 通过以任务名称命名的Secret注入, 任务结束后删除
+	if signingKey != nil {
+		if err := p.createSigningSecret(jobLabel, signingKey); err != nil {
+			msg := fmt.Sprintf("create image signing secret error: %v", err)
+			p.Log.Error(msg)
+			p.Task.TaskStatus = config.StatusFailed
+			p.Task.Error = msg
+			return
+		}
+		defer func() {
+			if p.IsTaskFailed() {
+				p.deleteSigningSecret()
+			}
+		}()
+		for i := range job.Spec.Template.Spec.Containers {
+			job.Spec.Template.Spec.Containers[i].Env = append(job.Spec.Template.Spec.Containers[i].Env,
+				secretEnvVar(cosignPrivateKeyEnv, p.JobName, signingSecretKeyKey),
+				secretEnvVar(cosignPasswordEnv, p.JobName, signingSecretPasswordKey),
+			)
+		}
+	}
+
 	job.Namespace = p.KubeNamespace
 	if err := updater.CreateJob(job, p.kubeClient); err != nil {
 		msg := fmt.Sprintf("create release image job error: %v", err)
@@ -204,6 +258,95 @@ func (p *ReleaseImagePlugin) Run(ctx context.Context, pipelineTask *task.Task, p
 	p.Log.Infof("succeed to create image job %s", p.JobName)
 }
 
+func (p *ReleaseImagePlugin) createSigningSecret(jobLabel *JobLabel, key *task.SigningKey) error {
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      p.JobName,
+			Namespace: p.KubeNamespace,
+			Labels:    getJobLabels(jobLabel),
+		},
+		Data: map[string][]byte{
+			signingSecretKeyKey:      []byte(key.PrivateKey),
+			signingSecretPasswordKey: []byte(key.Password),
+		},
+	}
+	return updater.UpdateOrCreateSecret(secret, p.kubeClient)
+}
+
+func (p *ReleaseImagePlugin) deleteSigningSecret() {
+	if err := updater.DeleteSecret(p.KubeNamespace, p.JobName, p.kubeClient); err != nil && !apierrors.IsNotFound(err) {
+		p.Log.Errorf("failed to delete image signing secret %s/%s: %v", p.KubeNamespace, p.JobName, err)
+	}
+}
+
+// imageSigning 需要时生成包含工作流, 任务和代码提交信息的构建来源证明, 签名私钥通过Secret注入
+func (p *ReleaseImagePlugin) imageSigning(pipelineTask *task.Task, serviceName string) (*types.ImageSigning, error) {
+	signing := &types.ImageSigning{}
+	if !p.Task.Signing.Provenance {
+		return signing, nil
+	}
+
+	provenance := &imageProvenance{
+		Workflow:    pipelineTask.PipelineName,
+		TaskID:      pipelineTask.TaskID,
+		ServiceName: serviceName,
+		Image:       p.Task.ImageTest,
+		CreateBy:    pipelineTask.TaskCreator,
+		Commits:     buildCommits(pipelineTask, serviceName),
+	}
+	data, err := json.Marshal(provenance)
+	if err != nil {
+		return nil, err
+	}
+	signing.Provenance = string(data)
+	return signing, nil
+}
+
+type imageProvenance struct {
+	Workflow    string         `json:"workflow"`
+	TaskID      int64          `json:"task_id"`
+	ServiceName string         `json:"service_name"`
+	Image       string         `json:"image"`
+	CreateBy    string         `json:"create_by"`
+	Commits     []*buildCommit `json:"commits"`
+}
+
+type buildCommit struct {
+	Address   string `json:"address"`
+	RepoOwner string `json:"repo_owner"`
+	RepoName  string `json:"repo_name"`
+	Branch    string `json:"branch"`
+	CommitID  string `json:"commit_id"`
+}
+
+// buildCommits 从同一个服务组件的构建任务中获取代码信息
+func buildCommits(pipelineTask *task.Task, serviceName string) []*buildCommit {
+	commits := make([]*buildCommit, 0)
+	for _, stage := range pipelineTask.Stages {
+		if stage.TaskType != config.TaskBuild {
+			continue
+		}
+		subTask, ok := stage.SubTasks[serviceName]
+		if !ok {
+			continue
+		}
+		buildTask, err := ToBuildTask(subTask)
+		if err != nil {
+			continue
+		}
+		for _, repo := range buildTask.JobCtx.Builds {
+			commits = append(commits, &buildCommit{
+				Address:   repo.Address,
+				RepoOwner: repo.RepoOwner,
+				RepoName:  repo.RepoName,
+				Branch:    repo.Branch,
+				CommitID:  repo.CommitID,
+			})
+		}
+	}
+	return commits
+}
+
 // Wait ...
 func (p *ReleaseImagePlugin) Wait(ctx context.Context) {
 	status := waitJobEnd(ctx, p.TaskTimeout(), p.KubeNamespace, p.JobName, p.kubeClient, p.Log)
@@ -220,6 +363,10 @@ func (p *ReleaseImagePlugin) Complete(ctx context.Context, pipelineTask *task.Ta
 		PipelineType: string(pipelineTask.Type),
 	}
 
+	if p.Task.Signing != nil {
+		defer p.deleteSigningSecret()
+	}
+
 	// 清理用户取消和超时的任务
 	defer func() {
 		if p.Task.TaskStatus == config.StatusCancelled || p.Task.TaskStatus == config.StatusTimeout {
@@ -18,9 +18,14 @@ package taskplugin
 
 import (
 	"context"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
 
 	"github.com/koderover/zadig/pkg/microservice/warpdrive/config"
 	"github.com/koderover/zadig/pkg/microservice/warpdrive/core/service/types/task"
@@ -28,13 +33,13 @@ import (
 )
 
 func TestReleaseImagePlugin_TaskTimeout_Default(t *testing.T) {
-	plugin := InitializeReleaseImagePlugin(config.TaskReleaseImage).(*ReleaseImagePlugin)
+	plugin := &ReleaseImagePlugin{Name: config.TaskReleaseImage}
 	plugin.Task = releaseTaskForTest()
 	assert.Equal(t, RelealseImageTaskTimeout, plugin.TaskTimeout())
 }
 
 func TestReleaseImagePlugin_TaskTimeout_GivenTimeout(t *testing.T) {
-	plugin := InitializeReleaseImagePlugin(config.TaskReleaseImage).(*ReleaseImagePlugin)
+	plugin := &ReleaseImagePlugin{Name: config.TaskReleaseImage}
 	task := releaseTaskForTest()
 	task.Timeout = 20
 	plugin.Task = task
@@ -42,6 +47,8 @@ func TestReleaseImagePlugin_TaskTimeout_GivenTimeout(t *testing.T) {
 }
 
 func TestReleaseImagePlugin_Run(t *testing.T) {
+	skipWithoutCluster(t)
+
 	assert := assert.New(t)
 	log := log.NopSugaredLogger()
 
@@ -123,6 +130,62 @@ func TestReleaseImagePlugin_Run(t *testing.T) {
 	//TODO: add more assertions here
 }
 
+func TestReleaseImagePlugin_RunWithSigning(t *testing.T) {
+	const (
+		namespace = "signing-ns"
+		jobName   = "signing-job"
+		repoID    = "repoId"
+	)
+
+	kubeClient := fake.NewClientBuilder().Build()
+	plugin := &ReleaseImagePlugin{Name: config.TaskReleaseImage, kubeClient: kubeClient}
+	plugin.Init(jobName, jobName, log.SugaredLogger())
+	plugin.Task = releaseTaskForTest()
+	plugin.Task.Releases = []task.RepoImage{{RepoID: repoID, Name: "releaseName", Host: "os.koderover.com", Namespace: namespace}}
+	plugin.Task.Signing = &task.ImageSigning{KeyID: "key-1"}
+
+	pipelineTask := &task.Task{
+		TaskID:       1,
+		PipelineName: "test-pipeline-name",
+		ConfigPayload: &task.ConfigPayload{
+			Build:       task.BuildConfig{KubeNamespace: namespace},
+			Release:     task.ReleaseConfig{PredatorImage: "predator-plugin"},
+			RepoConfigs: map[string]*task.RegistryNamespace{repoID: {}},
+			SigningKeys: []*task.SigningKey{{ID: "key-1", Name: "release", PrivateKey: "private-key", Password: "key-password", PublicKey: "public-key"}},
+		},
+	}
+
+	plugin.Run(context.Background(), pipelineTask, &task.PipelineCtx{}, "test123")
+	assert.Empty(t, plugin.Task.Error)
+
+	// 私钥和密码不写入ConfigMap, 只能从Secret读取
+	cm := &corev1.ConfigMap{}
+	assert.Nil(t, kubeClient.Get(context.TODO(), client.ObjectKey{Namespace: namespace, Name: jobName}, cm))
+	for _, data := range cm.Data {
+		assert.False(t, strings.Contains(data, "private-key"))
+		assert.False(t, strings.Contains(data, "key-password"))
+	}
+
+	secret := &corev1.Secret{}
+	assert.Nil(t, kubeClient.Get(context.TODO(), client.ObjectKey{Namespace: namespace, Name: jobName}, secret))
+	assert.Equal(t, "private-key", string(secret.Data[signingSecretKeyKey]))
+	assert.Equal(t, "key-password", string(secret.Data[signingSecretPasswordKey]))
+
+	jobs := &batchv1.JobList{}
+	assert.Nil(t, kubeClient.List(context.TODO(), jobs, client.InNamespace(namespace)))
+	if assert.Len(t, jobs.Items, 1) {
+		env := jobs.Items[0].Spec.Template.Spec.Containers[0].Env
+		assert.Contains(t, env, secretEnvVar(cosignPrivateKeyEnv, jobName, signingSecretKeyKey))
+		assert.Contains(t, env, secretEnvVar(cosignPasswordEnv, jobName, signingSecretPasswordKey))
+	}
+
+	// 任务结束后删除Secret
+	plugin.Complete(context.Background(), pipelineTask, "test123")
+	secrets := &corev1.SecretList{}
+	assert.Nil(t, kubeClient.List(context.TODO(), secrets, client.InNamespace(namespace)))
+	assert.Empty(t, secrets.Items)
+}
+
 func releaseTaskForTest() *task.ReleaseImage {
 	return &task.ReleaseImage{
 		TaskType:     config.TaskReleaseImage,
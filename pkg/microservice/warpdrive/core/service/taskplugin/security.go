@@ -20,10 +20,14 @@ import (
 	"context"
 	"fmt"
 	"io/ioutil"
+	"sort"
 	"strings"
 	"time"
 
 	"go.uber.org/zap"
+	"k8s.io/apimachinery/pkg/util/sets"
+
+	"sigs.k8s.io/controller-runtime/pkg/client"
 
 	configbase "github.com/koderover/zadig/pkg/config"
 	"github.com/koderover/zadig/pkg/microservice/warpdrive/config"
@@ -31,6 +35,7 @@ import (
 	"github.com/koderover/zadig/pkg/microservice/warpdrive/core/service/types/task"
 	"github.com/koderover/zadig/pkg/setting"
 	"github.com/koderover/zadig/pkg/tool/httpclient"
+	krkubeclient "github.com/koderover/zadig/pkg/tool/kube/client"
 )
 
 // InitializeSecurityPlugin ...
@@ -43,6 +48,7 @@ func InitializeSecurityPlugin(taskType config.TaskType) TaskPlugin {
 			httpclient.SetAuthToken(config.PoetryAPIRootKey()),
 			httpclient.SetHostURL(configbase.AslanServiceAddress()),
 		),
+		kubeClient: krkubeclient.Client(),
 	}
 }
 
@@ -62,6 +68,7 @@ type SecurityPlugin struct {
 	errorChan     chan error
 
 	httpClient *httpclient.Client
+	kubeClient client.Client
 }
 
 type deliverySecurityInfo struct {
@@ -97,6 +104,9 @@ func (p *SecurityPlugin) SetStatus(status config.Status) {
 func (p *SecurityPlugin) TaskTimeout() int {
 	if p.Task.Timeout == 0 {
 		p.Task.Timeout = SecurityTaskTimeout
+		if p.Task.Scanner == setting.SecurityScannerTrivy || p.Task.Scanner == setting.SecurityScannerGrype {
+			p.Task.Timeout = SecurityJobTaskTimeout
+		}
 	}
 	return p.Task.Timeout
 }
@@ -113,12 +123,18 @@ func (p *SecurityPlugin) Run(ctx context.Context, pipelineTask *task.Task, pipel
 		namespace = strings.TrimSpace(string(namespaceData))
 	}
 
+	scanner, err := p.newScanner(pipelineTask, pipelineCtx, namespace)
+	if err != nil {
+		p.errorChan <- err
+		return
+	}
+
 	imageName := p.Task.ImageName
 	go func() {
-		// send request to clair client to analysis image
-		body, err := p.analysis(ctx, imageName, pipelineCtx.DockerHost, namespace)
+		// scan image with clair, trivy or grype
+		body, err := scanner.Scan(ctx, imageName)
 		if err != nil {
-			p.Log.Errorf("analysis err:%+v", err)
+			p.Log.Errorf("scan err:%+v", err)
 			p.errorChan <- err
 			return
 		}
@@ -131,6 +147,12 @@ func (p *SecurityPlugin) Run(ctx context.Context, pipelineTask *task.Task, pipel
 			return
 		}
 		p.Task.ImageID = imageID
+		// 没有发现漏洞时aslan不会保存扫描结果
+		if imageID == "" {
+			p.Task.Summary = map[string]int{}
+			p.Task.TaskStatus = config.StatusPassed
+			return
+		}
 
 		// get analysis summary and save to task
 		summary, err := p.getSummary(ctx, imageID)
@@ -140,10 +162,82 @@ func (p *SecurityPlugin) Run(ctx context.Context, pipelineTask *task.Task, pipel
 			return
 		}
 		p.Task.Summary = summary
+
+		if p.Task.Policy != nil {
+			vulnerabilities, err := p.getVulnerabilities(ctx, imageID)
+			if err != nil {
+				p.Log.Errorf("getVulnerabilities err:%+v", err)
+				p.errorChan <- err
+				return
+			}
+			p.Task.Violations = evaluateSecurityPolicy(p.Task.Policy, vulnerabilities, time.Now().Unix())
+			if len(p.Task.Violations) > 0 {
+				p.errorChan <- fmt.Errorf("image %s violates security policy: %s", imageName, strings.Join(p.Task.Violations, "; "))
+				return
+			}
+		}
 		p.Task.TaskStatus = config.StatusPassed
 	}()
 }
 
+func (p *SecurityPlugin) getVulnerabilities(ctx context.Context, imageID string) ([]*types.DeliverySecurity, error) {
+	url := "/api/delivery/security"
+
+	vulnerabilities := make([]*types.DeliverySecurity, 0)
+	_, err := p.httpClient.Get(url, httpclient.SetResult(&vulnerabilities), httpclient.SetQueryParam("imageId", imageID))
+	if err != nil {
+		return nil, err
+	}
+	return vulnerabilities, nil
+}
+
+// evaluateSecurityPolicy 统计豁免之外的漏洞数量, 返回超过各级别限制的说明
+func evaluateSecurityPolicy(policy *task.SecurityPolicy, vulnerabilities []*types.DeliverySecurity, now int64) []string {
+	allowed := sets.NewString()
+	for _, v := range policy.AllowList {
+		if v.ExpireAt == 0 || v.ExpireAt > now {
+			allowed.Insert(v.Name)
+		}
+	}
+
+	counted := make(map[string][]string)
+	for _, v := range vulnerabilities {
+		if allowed.Has(v.Vulnerability.Name) {
+			continue
+		}
+		if policy.FixableOnly && v.Vulnerability.FixedBy == "" {
+			continue
+		}
+		counted[v.Severity] = append(counted[v.Severity], v.Vulnerability.Name)
+	}
+
+	severities := make([]string, 0, len(policy.MaxSeverityCount))
+	for _, severity := range setting.VulnerabilitySeverities {
+		if _, ok := policy.MaxSeverityCount[severity]; ok {
+			severities = append(severities, severity)
+		}
+	}
+	known := sets.NewString(setting.VulnerabilitySeverities...)
+	var others []string
+	for severity := range policy.MaxSeverityCount {
+		if !known.Has(severity) {
+			others = append(others, severity)
+		}
+	}
+	sort.Strings(others)
+	severities = append(severities, others...)
+
+	var violations []string
+	for _, severity := range severities {
+		limit := policy.MaxSeverityCount[severity]
+		if names := counted[severity]; len(names) > limit {
+			violations = append(violations, fmt.Sprintf("%d %s vulnerabilities exceed the limit %d: %s",
+				len(names), severity, limit, strings.Join(sets.NewString(names...).List(), ", ")))
+		}
+	}
+	return violations
+}
+
 func (p *SecurityPlugin) getSummary(ctx context.Context, imageID string) (map[string]int, error) {
 	url := "/api/delivery/security/stats"
 
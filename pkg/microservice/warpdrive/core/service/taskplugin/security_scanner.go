@@ -0,0 +1,437 @@
+/*
+Copyright 2021 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package taskplugin
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+
+	"github.com/koderover/zadig/pkg/microservice/warpdrive/config"
+	"github.com/koderover/zadig/pkg/microservice/warpdrive/core/service/types"
+	"github.com/koderover/zadig/pkg/microservice/warpdrive/core/service/types/task"
+	"github.com/koderover/zadig/pkg/setting"
+	krkubeclient "github.com/koderover/zadig/pkg/tool/kube/client"
+	"github.com/koderover/zadig/pkg/tool/kube/containerlog"
+	"github.com/koderover/zadig/pkg/tool/kube/getter"
+	"github.com/koderover/zadig/pkg/tool/kube/updater"
+	"github.com/koderover/zadig/pkg/util"
+)
+
+const (
+	SecurityJobTaskTimeout = 60 * 10 // 10 minutes
+	securityScanJobType    = "security-scan"
+	scanSecretUsernameKey  = "username"
+	scanSecretPasswordKey  = "password"
+)
+
+// imageScanner 扫描镜像并返回上报给aslan的扫描结果
+type imageScanner interface {
+	Scan(ctx context.Context, imageName string) ([]byte, error)
+}
+
+func (p *SecurityPlugin) newScanner(pipelineTask *task.Task, pipelineCtx *task.PipelineCtx, namespace string) (imageScanner, error) {
+	switch p.Task.Scanner {
+	case "", setting.SecurityScannerClair:
+		return &clairScanner{plugin: p, dockerHost: pipelineCtx.DockerHost, namespace: namespace}, nil
+	case setting.SecurityScannerTrivy, setting.SecurityScannerGrype:
+		return &jobScanner{plugin: p, registries: scanRegistries(pipelineTask.ConfigPayload)}, nil
+	default:
+		return nil, fmt.Errorf("unsupported security scanner: %s", p.Task.Scanner)
+	}
+}
+
+// clairScanner 通过clair服务分析构建机上的镜像
+type clairScanner struct {
+	plugin     *SecurityPlugin
+	dockerHost string
+	namespace  string
+}
+
+func (s *clairScanner) Scan(ctx context.Context, imageName string) ([]byte, error) {
+	return s.plugin.analysis(ctx, imageName, s.dockerHost, s.namespace)
+}
+
+// jobScanner 在构建的命名空间中以Job的方式运行trivy或grype, 从容器日志中读取json格式的扫描结果
+type jobScanner struct {
+	plugin     *SecurityPlugin
+	registries []*task.RegistryNamespace
+}
+
+func scanRegistries(payload *task.ConfigPayload) []*task.RegistryNamespace {
+	registries := make([]*task.RegistryNamespace, 0, len(payload.RepoConfigs)+1)
+	for _, reg := range payload.RepoConfigs {
+		registries = append(registries, reg)
+	}
+	registries = append(registries, &task.RegistryNamespace{
+		RegAddr:   payload.Registry.Addr,
+		AccessKey: payload.Registry.AccessKey,
+		SecretKey: payload.Registry.SecretKey,
+	})
+	return registries
+}
+
+// registryHost 返回镜像仓库地址中的主机部分
+func registryHost(address string) string {
+	address = strings.TrimPrefix(strings.TrimPrefix(address, "https://"), "http://")
+	return strings.SplitN(address, "/", 2)[0]
+}
+
+// registryAuth 根据镜像地址匹配镜像仓库的认证信息
+func (s *jobScanner) registryAuth(imageName string) *task.RegistryNamespace {
+	return matchRegistry(s.registries, imageName)
+}
+
+func matchRegistry(registries []*task.RegistryNamespace, imageName string) *task.RegistryNamespace {
+	host := registryHost(imageName)
+	for _, reg := range registries {
+		if reg.AccessKey != "" && registryHost(reg.RegAddr) == host {
+			return reg
+		}
+	}
+	return nil
+}
+
+// scanContainer 生成扫描容器, 镜像仓库的认证信息从名为secretName的Secret中读取
+func (s *jobScanner) scanContainer(imageName string, auth *task.RegistryNamespace, secretName string) corev1.Container {
+	p := s.plugin
+	container := corev1.Container{Name: p.Task.Scanner, Image: p.Task.ScannerImage}
+
+	switch p.Task.Scanner {
+	case setting.SecurityScannerTrivy:
+		if container.Image == "" {
+			container.Image = setting.DefaultTrivyImage
+		}
+		container.Args = []string{"image", "-q", "--format", "json", imageName}
+		if auth != nil {
+			container.Env = append(container.Env,
+				secretEnvVar("TRIVY_USERNAME", secretName, scanSecretUsernameKey),
+				secretEnvVar("TRIVY_PASSWORD", secretName, scanSecretPasswordKey),
+			)
+		}
+	case setting.SecurityScannerGrype:
+		if container.Image == "" {
+			container.Image = setting.DefaultGrypeImage
+		}
+		container.Args = []string{"-q", "-o", "json", "registry:" + imageName}
+		if auth != nil {
+			container.Env = append(container.Env,
+				corev1.EnvVar{Name: "GRYPE_REGISTRY_AUTH_AUTHORITY", Value: registryHost(auth.RegAddr)},
+				secretEnvVar("GRYPE_REGISTRY_AUTH_USERNAME", secretName, scanSecretUsernameKey),
+				secretEnvVar("GRYPE_REGISTRY_AUTH_PASSWORD", secretName, scanSecretPasswordKey),
+			)
+		}
+	}
+
+	for _, env := range p.Task.ScannerEnvs {
+		container.Env = append(container.Env, corev1.EnvVar{Name: env.Key, Value: env.Value})
+	}
+	return container
+}
+
+func secretEnvVar(name, secretName, key string) corev1.EnvVar {
+	return corev1.EnvVar{
+		Name: name,
+		ValueFrom: &corev1.EnvVarSource{
+			SecretKeyRef: &corev1.SecretKeySelector{
+				LocalObjectReference: corev1.LocalObjectReference{Name: secretName},
+				Key:                  key,
+			},
+		},
+	}
+}
+
+func (s *jobScanner) Scan(ctx context.Context, imageName string) ([]byte, error) {
+	p := s.plugin
+	name := fmt.Sprintf("%s-%s-%d", securityScanJobType, p.Task.Scanner, time.Now().Unix())
+	labels := map[string]string{
+		setting.TypeLabel: securityScanJobType,
+	}
+
+	// 镜像仓库的认证信息通过Secret传给扫描容器, 结束后删除
+	auth := s.registryAuth(imageName)
+	if auth != nil {
+		secret := &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: p.KubeNamespace,
+				Labels:    labels,
+			},
+			Data: map[string][]byte{
+				scanSecretUsernameKey: []byte(auth.AccessKey),
+				scanSecretPasswordKey: []byte(auth.SecretKey),
+			},
+		}
+		if err := updater.UpdateOrCreateSecret(secret, p.kubeClient); err != nil {
+			return nil, errors.WithMessagef(err, "failed to create scan secret %s", name)
+		}
+		defer func() {
+			if err := updater.DeleteSecret(p.KubeNamespace, name, p.kubeClient); err != nil {
+				p.Log.Errorf("failed to delete scan secret %s/%s: %v", p.KubeNamespace, name, err)
+			}
+		}()
+	}
+
+	backoffLimit := int32(0)
+	job := &batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: p.KubeNamespace,
+			Labels:    labels,
+		},
+		Spec: batchv1.JobSpec{
+			BackoffLimit: &backoffLimit,
+			Template: corev1.PodTemplateSpec{
+				Spec: corev1.PodSpec{
+					RestartPolicy: corev1.RestartPolicyNever,
+					Containers:    []corev1.Container{s.scanContainer(imageName, auth, name)},
+				},
+			},
+		},
+	}
+
+	if err := updater.CreateJob(job, p.kubeClient); err != nil {
+		return nil, errors.WithMessagef(err, "failed to create scan job %s", name)
+	}
+	defer func() {
+		if err := updater.DeleteJobAndWait(p.KubeNamespace, name, p.kubeClient); err != nil {
+			p.Log.Errorf("failed to delete scan job %s/%s: %v", p.KubeNamespace, name, err)
+		}
+	}()
+
+	// 扫描工具发现漏洞时也会以0退出, 失败说明扫描本身出错
+	status := waitJobEnd(ctx, p.TaskTimeout(), p.KubeNamespace, name, p.kubeClient, p.Log)
+	output, err := s.jobOutput(name)
+	if status != config.StatusPassed {
+		if err == nil {
+			p.Log.Errorf("output of scan job %s: %s", name, output)
+		}
+		return nil, errors.Errorf("scan job %s is %s", name, status)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var securities []*types.DeliverySecurity
+	switch p.Task.Scanner {
+	case setting.SecurityScannerTrivy:
+		securities, err = parseTrivyReport(imageName, output)
+	case setting.SecurityScannerGrype:
+		securities, err = parseGrypeReport(imageName, output)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(&types.DeliverySecurityInfo{Result: "success", DeliverySecuritys: securities})
+}
+
+func (s *jobScanner) jobOutput(jobName string) ([]byte, error) {
+	p := s.plugin
+	selector := labels.Set{"job-name": jobName}.AsSelector()
+	pods, err := getter.ListPods(p.KubeNamespace, selector, p.kubeClient)
+	if err != nil {
+		return nil, err
+	}
+	if len(pods) < 1 {
+		return nil, fmt.Errorf("no pod found with selector: %s", selector)
+	}
+
+	buf := new(bytes.Buffer)
+	if err := containerlog.GetContainerLogs(p.KubeNamespace, pods[0].Name, pods[0].Spec.Containers[0].Name, false, int64(0), buf, krkubeclient.Clientset()); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// trimReport 从容器日志中找出扫描工具输出的json报告
+// 报告从某一行的行首开始, 完整解析后所在行不能还有其他内容, 以免把 [0000] WARN ... 这样的日志当作报告
+func trimReport(output []byte) []byte {
+	for rest := output; len(rest) > 0; {
+		line := bytes.TrimLeft(rest, " \t\r")
+		if len(line) > 0 && (line[0] == '{' || line[0] == '[') {
+			dec := json.NewDecoder(bytes.NewReader(line))
+			var report json.RawMessage
+			if err := dec.Decode(&report); err == nil {
+				tail := line[dec.InputOffset():]
+				if i := bytes.IndexByte(tail, '\n'); i >= 0 {
+					tail = tail[:i]
+				}
+				if len(bytes.TrimSpace(tail)) == 0 {
+					return report
+				}
+			}
+		}
+
+		i := bytes.IndexByte(rest, '\n')
+		if i < 0 {
+			break
+		}
+		rest = rest[i+1:]
+	}
+	return output
+}
+
+func fallbackImageID(imageName string) string {
+	return fmt.Sprintf("sha256:%x", sha256.Sum256([]byte(imageName)))
+}
+
+type trivyResult struct {
+	Target          string `json:"Target"`
+	Vulnerabilities []struct {
+		VulnerabilityID  string `json:"VulnerabilityID"`
+		PkgName          string `json:"PkgName"`
+		InstalledVersion string `json:"InstalledVersion"`
+		FixedVersion     string `json:"FixedVersion"`
+		Severity         string `json:"Severity"`
+		Description      string `json:"Description"`
+		PrimaryURL       string `json:"PrimaryURL"`
+		Layer            struct {
+			DiffID string `json:"DiffID"`
+		} `json:"Layer"`
+	} `json:"Vulnerabilities"`
+}
+
+type trivyReport struct {
+	Metadata struct {
+		ImageID string `json:"ImageID"`
+	} `json:"Metadata"`
+	Results []*trivyResult `json:"Results"`
+}
+
+// parseTrivyReport 解析trivy的json报告, 兼容早期版本顶层为数组的格式
+func parseTrivyReport(imageName string, output []byte) ([]*types.DeliverySecurity, error) {
+	output = trimReport(output)
+	report := &trivyReport{}
+	if bytes.HasPrefix(output, []byte("[")) {
+		if err := json.Unmarshal(output, &report.Results); err != nil {
+			return nil, errors.WithMessage(err, "invalid trivy report")
+		}
+	} else if err := json.Unmarshal(output, report); err != nil {
+		return nil, errors.WithMessage(err, "invalid trivy report")
+	}
+
+	imageID := report.Metadata.ImageID
+	if imageID == "" {
+		imageID = fallbackImageID(imageName)
+	}
+
+	now := time.Now().Unix()
+	securities := make([]*types.DeliverySecurity, 0)
+	for _, result := range report.Results {
+		for _, v := range result.Vulnerabilities {
+			feature := types.Feature{Name: v.PkgName, NamespaceName: result.Target, Version: v.InstalledVersion, AddedBy: v.Layer.DiffID}
+			severity, _ := util.NormalizeVulnerabilitySeverity(v.Severity)
+			securities = append(securities, &types.DeliverySecurity{
+				ImageID:   imageID,
+				ImageName: imageName,
+				LayerID:   v.Layer.DiffID,
+				Vulnerability: types.Vulnerability{
+					Name:          v.VulnerabilityID,
+					NamespaceName: result.Target,
+					Description:   v.Description,
+					Link:          v.PrimaryURL,
+					Severity:      severity,
+					FixedBy:       v.FixedVersion,
+				},
+				Feature:   feature,
+				Severity:  severity,
+				CreatedAt: now,
+			})
+		}
+	}
+	return securities, nil
+}
+
+type grypeReport struct {
+	Matches []struct {
+		Vulnerability struct {
+			ID          string   `json:"id"`
+			Namespace   string   `json:"namespace"`
+			Severity    string   `json:"severity"`
+			Description string   `json:"description"`
+			URLs        []string `json:"urls"`
+			Fix         struct {
+				Versions []string `json:"versions"`
+			} `json:"fix"`
+		} `json:"vulnerability"`
+		Artifact struct {
+			Name    string `json:"name"`
+			Version string `json:"version"`
+			Type    string `json:"type"`
+		} `json:"artifact"`
+	} `json:"matches"`
+	Source struct {
+		Target struct {
+			ImageID string `json:"imageID"`
+		} `json:"target"`
+	} `json:"source"`
+}
+
+// parseGrypeReport 解析grype的json报告
+func parseGrypeReport(imageName string, output []byte) ([]*types.DeliverySecurity, error) {
+	report := &grypeReport{}
+	if err := json.Unmarshal(trimReport(output), report); err != nil {
+		return nil, errors.WithMessage(err, "invalid grype report")
+	}
+
+	imageID := report.Source.Target.ImageID
+	if imageID == "" {
+		imageID = fallbackImageID(imageName)
+	}
+
+	now := time.Now().Unix()
+	securities := make([]*types.DeliverySecurity, 0, len(report.Matches))
+	for _, m := range report.Matches {
+		severity, _ := util.NormalizeVulnerabilitySeverity(m.Vulnerability.Severity)
+		var link string
+		if len(m.Vulnerability.URLs) > 0 {
+			link = m.Vulnerability.URLs[0]
+		}
+		securities = append(securities, &types.DeliverySecurity{
+			ImageID:   imageID,
+			ImageName: imageName,
+			Vulnerability: types.Vulnerability{
+				Name:          m.Vulnerability.ID,
+				NamespaceName: m.Vulnerability.Namespace,
+				Description:   m.Vulnerability.Description,
+				Link:          link,
+				Severity:      severity,
+				FixedBy:       strings.Join(m.Vulnerability.Fix.Versions, ","),
+			},
+			Feature: types.Feature{
+				Name:          m.Artifact.Name,
+				NamespaceName: m.Vulnerability.Namespace,
+				VersionFormat: m.Artifact.Type,
+				Version:       m.Artifact.Version,
+			},
+			Severity:  severity,
+			CreatedAt: now,
+		})
+	}
+	return securities, nil
+}
@@ -0,0 +1,402 @@
+/*
+Copyright 2021 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package taskplugin
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// trivy 0.20.2 `trivy image --format json` 的输出, 前面带有 trivy 自身的日志
+const trivyOutput = `2021-11-12T08:21:03.417Z	INFO	Need to update DB
+2021-11-12T08:21:03.417Z	INFO	Downloading DB...
+2021-11-12T08:21:09.115Z	INFO	Detected OS: alpine
+2021-11-12T08:21:09.115Z	INFO	Detecting Alpine vulnerabilities...
+2021-11-12T08:21:09.117Z	INFO	Number of language-specific files: 0
+{
+  "SchemaVersion": 2,
+  "ArtifactName": "nginx:1.21-alpine",
+  "ArtifactType": "container_image",
+  "Metadata": {
+    "OS": {
+      "Family": "alpine",
+      "Name": "3.14.2"
+    },
+    "ImageID": "sha256:b46db85084b80a87b94cc930a74105b74763d0175e14f5913ea5b07c312870f8",
+    "DiffIDs": [
+      "sha256:e2eb06d8af8218cfec8210147357a68b7e13f7c485b991c288c2d01dc228bb68"
+    ],
+    "RepoTags": [
+      "nginx:1.21-alpine"
+    ]
+  },
+  "Results": [
+    {
+      "Target": "nginx:1.21-alpine (alpine 3.14.2)",
+      "Class": "os-pkgs",
+      "Type": "alpine",
+      "Vulnerabilities": [
+        {
+          "VulnerabilityID": "CVE-2021-22945",
+          "PkgName": "curl",
+          "InstalledVersion": "7.78.0-r0",
+          "FixedVersion": "7.79.0-r0",
+          "Layer": {
+            "Digest": "sha256:a0d0a0d46f8b52473982a3c466318f479767577551a53ffc9074c9fa7035982e",
+            "DiffID": "sha256:e2eb06d8af8218cfec8210147357a68b7e13f7c485b991c288c2d01dc228bb68"
+          },
+          "SeveritySource": "nvd",
+          "PrimaryURL": "https://avd.aquasec.com/nvd/cve-2021-22945",
+          "Title": "curl: use-after-free and double-free in MQTT sending",
+          "Description": "When sending data to an MQTT server, libcurl could in some circumstances erroneously keep a pointer to an already freed memory area.",
+          "Severity": "CRITICAL",
+          "CweIDs": [
+            "CWE-415"
+          ]
+        },
+        {
+          "VulnerabilityID": "CVE-2021-22946",
+          "PkgName": "libcurl",
+          "InstalledVersion": "7.78.0-r0",
+          "FixedVersion": "7.79.0-r0",
+          "Layer": {
+            "DiffID": "sha256:e2eb06d8af8218cfec8210147357a68b7e13f7c485b991c288c2d01dc228bb68"
+          },
+          "PrimaryURL": "https://avd.aquasec.com/nvd/cve-2021-22946",
+          "Description": "A user can tell curl >= 7.20.0 and <= 7.78.0 to require a successful upgrade to TLS when speaking to an IMAP, POP3 or FTP server.",
+          "Severity": "HIGH"
+        }
+      ]
+    },
+    {
+      "Target": "usr/local/bin/app",
+      "Class": "lang-pkgs",
+      "Type": "gobinary"
+    }
+  ]
+}
+`
+
+// trivy 0.20 之前的输出是结果数组
+const trivyLegacyOutput = `[
+  {
+    "Target": "alpine:3.10 (alpine 3.10.9)",
+    "Type": "alpine",
+    "Vulnerabilities": [
+      {
+        "VulnerabilityID": "CVE-2021-36159",
+        "PkgName": "apk-tools",
+        "InstalledVersion": "2.10.6-r0",
+        "FixedVersion": "2.10.7-r0",
+        "Layer": {
+          "DiffID": "sha256:9fb3aa2f8b8023a4bebbf92aa567caf88e38e969ada9f0ac12643b2847391635"
+        },
+        "PrimaryURL": "https://avd.aquasec.com/nvd/cve-2021-36159",
+        "Description": "libfetch before 2021-07-26 can have an out-of-bounds read.",
+        "Severity": "UNKNOWN"
+      }
+    ]
+  }
+]`
+
+// grype v0.27.0 `grype -o json` 的输出, 前面带有 grype 自身的日志
+const grypeOutput = `[0000]  WARN unable to check for vulnerability database update
+[0001]  INFO found 2 vulnerabilities
+{
+ "matches": [
+  {
+   "vulnerability": {
+    "id": "CVE-2021-22945",
+    "dataSource": "https://security.alpinelinux.org/vuln/CVE-2021-22945",
+    "namespace": "alpine:3.14",
+    "severity": "Critical",
+    "urls": [
+     "https://security.alpinelinux.org/vuln/CVE-2021-22945",
+     "https://curl.se/docs/CVE-2021-22945.html"
+    ],
+    "description": "When sending data to an MQTT server, libcurl could erroneously keep a pointer to a freed memory area.",
+    "cvss": [],
+    "fix": {
+     "versions": [
+      "7.79.0-r0",
+      "7.79.1-r0"
+     ],
+     "state": "fixed"
+    },
+    "advisories": []
+   },
+   "relatedVulnerabilities": [],
+   "matchDetails": [],
+   "artifact": {
+    "name": "curl",
+    "version": "7.78.0-r0",
+    "type": "apk",
+    "locations": [
+     {
+      "path": "/lib/apk/db/installed",
+      "layerID": "sha256:e2eb06d8af8218cfec8210147357a68b7e13f7c485b991c288c2d01dc228bb68"
+     }
+    ],
+    "language": "",
+    "licenses": [
+     "MIT"
+    ],
+    "cpes": [],
+    "purl": "pkg:alpine/curl@7.78.0-r0?arch=x86_64",
+    "metadata": null
+   }
+  },
+  {
+   "vulnerability": {
+    "id": "GHSA-xxxx-yyyy-zzzz",
+    "namespace": "github:go",
+    "severity": "Negligible",
+    "urls": [],
+    "fix": {
+     "versions": [],
+     "state": "not-fixed"
+    }
+   },
+   "artifact": {
+    "name": "golang.org/x/text",
+    "version": "v0.3.5",
+    "type": "go-module"
+   }
+  }
+ ],
+ "source": {
+  "type": "image",
+  "target": {
+   "userInput": "nginx:1.21-alpine",
+   "imageID": "sha256:b46db85084b80a87b94cc930a74105b74763d0175e14f5913ea5b07c312870f8",
+   "manifestDigest": "sha256:8e4c6f2a06ba2f21ff3b4b4d2c7bb0e1c6a3a3f8a5b4c8e0b3c2e7e1f0f5a6a3",
+   "tags": [
+    "nginx:1.21-alpine"
+   ]
+  }
+ },
+ "distro": {
+  "name": "alpine",
+  "version": "3.14.2",
+  "idLike": ""
+ },
+ "descriptor": {
+  "name": "grype",
+  "version": "0.27.0"
+ }
+}
+[0002]  INFO done
+`
+
+func TestTrimReport(t *testing.T) {
+	tests := []struct {
+		name   string
+		output string
+		want   string
+	}{
+		{
+			name:   "report only",
+			output: `{"matches":[]}`,
+			want:   `{"matches":[]}`,
+		},
+		{
+			name:   "log lines before report",
+			output: "[0000]  WARN unable to check for vulnerability database update\n{\"matches\":[]}\n",
+			want:   `{"matches":[]}`,
+		},
+		{
+			name:   "log lines after report",
+			output: "INFO scanning\n[\n  {\"Target\": \"a\"}\n]\n[0002]  INFO done\n",
+			want:   "[\n  {\"Target\": \"a\"}\n]",
+		},
+		{
+			name:   "bracketed log line parses as json prefix",
+			output: "[12] foo\n[0] bar\n{\"Results\":null}",
+			want:   `{"Results":null}`,
+		},
+		{
+			name:   "indented report",
+			output: "\t  {\"a\": 1}\r\n",
+			want:   `{"a": 1}`,
+		},
+		{
+			name:   "no report",
+			output: "FATAL unable to pull image\n",
+			want:   "FATAL unable to pull image\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, string(trimReport([]byte(tt.output))))
+		})
+	}
+}
+
+func TestParseTrivyReport(t *testing.T) {
+	const imageName = "nginx:1.21-alpine"
+	tests := []struct {
+		name    string
+		output  string
+		wantErr bool
+		check   func(t *testing.T, imageID string, names, severities, fixedBy []string)
+	}{
+		{
+			name:   "trivy 0.20 report",
+			output: trivyOutput,
+			check: func(t *testing.T, imageID string, names, severities, fixedBy []string) {
+				assert.Equal(t, "sha256:b46db85084b80a87b94cc930a74105b74763d0175e14f5913ea5b07c312870f8", imageID)
+				assert.Equal(t, []string{"CVE-2021-22945", "CVE-2021-22946"}, names)
+				assert.Equal(t, []string{"Critical", "High"}, severities)
+				assert.Equal(t, []string{"7.79.0-r0", "7.79.0-r0"}, fixedBy)
+			},
+		},
+		{
+			name:   "legacy array report",
+			output: trivyLegacyOutput,
+			check: func(t *testing.T, imageID string, names, severities, fixedBy []string) {
+				assert.Equal(t, fallbackImageID(imageName), imageID)
+				assert.Equal(t, []string{"CVE-2021-36159"}, names)
+				assert.Equal(t, []string{"Unknown"}, severities)
+				assert.Equal(t, []string{"2.10.7-r0"}, fixedBy)
+			},
+		},
+		{
+			name:   "no vulnerabilities",
+			output: `{"SchemaVersion": 2, "ArtifactName": "scratch", "Results": [{"Target": "app", "Class": "lang-pkgs"}]}`,
+			check: func(t *testing.T, imageID string, names, severities, fixedBy []string) {
+				assert.Empty(t, names)
+			},
+		},
+		{
+			name:    "malformed report",
+			output:  "FATAL image scan error: unable to initialize a scanner\n",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			securities, err := parseTrivyReport(imageName, []byte(tt.output))
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+
+			var imageID string
+			var names, severities, fixedBy []string
+			for _, s := range securities {
+				imageID = s.ImageID
+				assert.Equal(t, imageName, s.ImageName)
+				assert.Equal(t, s.Severity, s.Vulnerability.Severity)
+				names = append(names, s.Vulnerability.Name)
+				severities = append(severities, s.Severity)
+				fixedBy = append(fixedBy, s.Vulnerability.FixedBy)
+			}
+			if imageID == "" && len(securities) == 0 {
+				imageID = fallbackImageID(imageName)
+			}
+			tt.check(t, imageID, names, severities, fixedBy)
+		})
+	}
+}
+
+func TestParseTrivyReportFields(t *testing.T) {
+	securities, err := parseTrivyReport("nginx:1.21-alpine", []byte(trivyOutput))
+	require.NoError(t, err)
+	require.Len(t, securities, 2)
+
+	s := securities[0]
+	assert.Equal(t, "sha256:e2eb06d8af8218cfec8210147357a68b7e13f7c485b991c288c2d01dc228bb68", s.LayerID)
+	assert.Equal(t, "nginx:1.21-alpine (alpine 3.14.2)", s.Vulnerability.NamespaceName)
+	assert.Equal(t, "https://avd.aquasec.com/nvd/cve-2021-22945", s.Vulnerability.Link)
+	assert.Equal(t, "curl", s.Feature.Name)
+	assert.Equal(t, "7.78.0-r0", s.Feature.Version)
+	assert.Equal(t, s.LayerID, s.Feature.AddedBy)
+}
+
+func TestParseGrypeReport(t *testing.T) {
+	const imageName = "nginx:1.21-alpine"
+	tests := []struct {
+		name           string
+		output         string
+		wantErr        bool
+		wantImageID    string
+		wantNames      []string
+		wantSeverities []string
+		wantFixedBy    []string
+	}{
+		{
+			name:           "grype 0.27 report",
+			output:         grypeOutput,
+			wantImageID:    "sha256:b46db85084b80a87b94cc930a74105b74763d0175e14f5913ea5b07c312870f8",
+			wantNames:      []string{"CVE-2021-22945", "GHSA-xxxx-yyyy-zzzz"},
+			wantSeverities: []string{"Critical", "Negligible"},
+			wantFixedBy:    []string{"7.79.0-r0,7.79.1-r0", ""},
+		},
+		{
+			name:           "unknown severity without image id",
+			output:         `{"matches":[{"vulnerability":{"id":"CVE-1","severity":"moderate"},"artifact":{"name":"a"}}],"source":{"type":"image","target":{}}}`,
+			wantImageID:    fallbackImageID(imageName),
+			wantNames:      []string{"CVE-1"},
+			wantSeverities: []string{"Unknown"},
+			wantFixedBy:    []string{""},
+		},
+		{
+			name:    "malformed report",
+			output:  "[0000] ERROR failed to catalog: could not fetch image\n",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			securities, err := parseGrypeReport(imageName, []byte(tt.output))
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Len(t, securities, len(tt.wantNames))
+
+			for i, s := range securities {
+				assert.Equal(t, tt.wantImageID, s.ImageID)
+				assert.Equal(t, imageName, s.ImageName)
+				assert.Equal(t, tt.wantNames[i], s.Vulnerability.Name)
+				assert.Equal(t, tt.wantSeverities[i], s.Severity)
+				assert.Equal(t, tt.wantFixedBy[i], s.Vulnerability.FixedBy)
+			}
+		})
+	}
+}
+
+func TestParseGrypeReportFields(t *testing.T) {
+	securities, err := parseGrypeReport("nginx:1.21-alpine", []byte(grypeOutput))
+	require.NoError(t, err)
+	require.Len(t, securities, 2)
+
+	s := securities[0]
+	assert.Equal(t, "alpine:3.14", s.Vulnerability.NamespaceName)
+	assert.Equal(t, "https://security.alpinelinux.org/vuln/CVE-2021-22945", s.Vulnerability.Link)
+	assert.Equal(t, "curl", s.Feature.Name)
+	assert.Equal(t, "7.78.0-r0", s.Feature.Version)
+	assert.Equal(t, "apk", s.Feature.VersionFormat)
+	assert.Empty(t, securities[1].Vulnerability.Link)
+}
@@ -0,0 +1,95 @@
+/*
+Copyright 2021 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package taskplugin
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/koderover/zadig/pkg/microservice/warpdrive/core/service/types"
+	"github.com/koderover/zadig/pkg/microservice/warpdrive/core/service/types/task"
+)
+
+func newDeliverySecurity(name, severity, fixedBy string) *types.DeliverySecurity {
+	return &types.DeliverySecurity{
+		Severity:      severity,
+		Vulnerability: types.Vulnerability{Name: name, FixedBy: fixedBy},
+	}
+}
+
+func TestEvaluateSecurityPolicy(t *testing.T) {
+	const now = int64(1000)
+	vulnerabilities := []*types.DeliverySecurity{
+		newDeliverySecurity("CVE-1", "Critical", "1.0.1"),
+		newDeliverySecurity("CVE-2", "High", ""),
+		newDeliverySecurity("CVE-3", "High", "2.0.0"),
+		newDeliverySecurity("CVE-4", "Low", ""),
+	}
+
+	tests := []struct {
+		name     string
+		policy   *task.SecurityPolicy
+		expected []string
+	}{
+		{
+			name:   "no limit",
+			policy: &task.SecurityPolicy{},
+		},
+		{
+			name:   "within limit",
+			policy: &task.SecurityPolicy{MaxSeverityCount: map[string]int{"Critical": 1, "High": 2}},
+		},
+		{
+			name:   "exceed limits in severity order",
+			policy: &task.SecurityPolicy{MaxSeverityCount: map[string]int{"High": 1, "Critical": 0}},
+			expected: []string{
+				"1 Critical vulnerabilities exceed the limit 0: CVE-1",
+				"2 High vulnerabilities exceed the limit 1: CVE-2, CVE-3",
+			},
+		},
+		{
+			name: "allowed vulnerability is not counted",
+			policy: &task.SecurityPolicy{
+				MaxSeverityCount: map[string]int{"Critical": 0},
+				AllowList:        []*task.AllowedVulnerability{{Name: "CVE-1"}},
+			},
+		},
+		{
+			name: "expired allowance is ignored",
+			policy: &task.SecurityPolicy{
+				MaxSeverityCount: map[string]int{"Critical": 0},
+				AllowList:        []*task.AllowedVulnerability{{Name: "CVE-1", ExpireAt: now}},
+			},
+			expected: []string{"1 Critical vulnerabilities exceed the limit 0: CVE-1"},
+		},
+		{
+			name: "only fixable vulnerabilities are counted",
+			policy: &task.SecurityPolicy{
+				MaxSeverityCount: map[string]int{"High": 0, "Low": 0},
+				FixableOnly:      true,
+			},
+			expected: []string{"1 High vulnerabilities exceed the limit 0: CVE-3"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, evaluateSecurityPolicy(tt.policy, vulnerabilities, now))
+		})
+	}
+}
@@ -19,6 +19,7 @@ package taskplugin
 import (
 	"context"
 	"encoding/csv"
+	"encoding/json"
 	"encoding/xml"
 	"fmt"
 	"os"
@@ -27,6 +28,7 @@ import (
 
 	"go.uber.org/zap"
 	"gopkg.in/yaml.v3"
+	"k8s.io/apimachinery/pkg/util/sets"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 
 	"github.com/koderover/zadig/pkg/microservice/warpdrive/config"
@@ -37,6 +39,7 @@ import (
 	krkubeclient "github.com/koderover/zadig/pkg/tool/kube/client"
 	"github.com/koderover/zadig/pkg/tool/kube/updater"
 	s3tool "github.com/koderover/zadig/pkg/tool/s3"
+	zadigtypes "github.com/koderover/zadig/pkg/types"
 	"github.com/koderover/zadig/pkg/util"
 )
 
@@ -152,24 +155,6 @@ func (p *TestPlugin) Run(ctx context.Context, pipelineTask *task.Task, pipelineC
 	fileName = strings.Replace(strings.ToLower(fileName), "_", "-", -1)
 	testReportFile = strings.Replace(strings.ToLower(testReportFile), "_", "-", -1)
 
-	jobCtx := JobCtxBuilder{
-		JobName:        p.JobName,
-		PipelineCtx:    pipelineCtx,
-		ArchiveFile:    fileName,
-		TestReportFile: testReportFile,
-		JobCtx:         p.Task.JobCtx,
-		Installs:       p.Task.InstallCtx,
-	}
-
-	jobCtxBytes, err := yaml.Marshal(jobCtx.BuildReaperContext(pipelineTask, serviceName))
-	if err != nil {
-		msg := fmt.Sprintf("cannot reaper.Context data: %v", err)
-		p.Log.Error(msg)
-		p.Task.TaskStatus = config.StatusFailed
-		p.Task.Error = msg
-		return
-	}
-
 	jobLabel := &JobLabel{
 		PipelineName: pipelineTask.PipelineName,
 		ServiceName:  serviceName,
@@ -185,14 +170,52 @@ func (p *TestPlugin) Run(ctx context.Context, pipelineTask *task.Task, pipelineC
 		return
 	}
 
-	if err := createJobConfigMap(p.KubeNamespace, p.JobName, jobLabel, string(jobCtxBytes), p.kubeClient); err != nil {
-		msg := fmt.Sprintf("createJobConfigMap error: %v", err)
+	if err := ensureDeleteJob(p.KubeNamespace, jobLabel, p.kubeClient); err != nil {
+		msg := fmt.Sprintf("delete testing job error: %v", err)
 		p.Log.Error(msg)
 		p.Task.TaskStatus = config.StatusFailed
 		p.Task.Error = msg
 		return
 	}
 
+	// 将集成到KodeRover的私有镜像仓库的访问权限设置到namespace中
+	if err := createOrUpdateRegistrySecrets(p.KubeNamespace, p.Task.Registries, p.kubeClient); err != nil {
+		p.Log.Errorf("create secret error: %v", err)
+	}
+
+	// 开启分片时每个分片启动一个job, 否则只启动一个job
+	for _, shard := range p.shards(fileName, testReportFile) {
+		if err := p.createJob(pipelineTask, pipelineCtx, serviceName, linkedNamespace, jobLabel, shard); err != nil {
+			p.Log.Error(err)
+			p.Task.TaskStatus = config.StatusFailed
+			p.Task.Error = err.Error()
+			return
+		}
+	}
+}
+
+func (p *TestPlugin) createJob(pipelineTask *task.Task, pipelineCtx *task.PipelineCtx, serviceName, linkedNamespace string, jobLabel *JobLabel, shard *testShard) error {
+	jobCtx := JobCtxBuilder{
+		JobName:        shard.JobName,
+		PipelineCtx:    pipelineCtx,
+		ArchiveFile:    shard.ArchiveFile,
+		TestReportFile: shard.TestReportFile,
+		JobCtx:         p.Task.JobCtx,
+		Installs:       p.Task.InstallCtx,
+	}
+	if len(shard.EnvVars) > 0 {
+		jobCtx.JobCtx.EnvVars = append(append([]*task.KeyVal{}, p.Task.JobCtx.EnvVars...), shard.EnvVars...)
+	}
+
+	jobCtxBytes, err := yaml.Marshal(jobCtx.BuildReaperContext(pipelineTask, serviceName))
+	if err != nil {
+		return fmt.Errorf("cannot reaper.Context data: %v", err)
+	}
+
+	if err := createJobConfigMap(p.KubeNamespace, shard.JobName, jobLabel, string(jobCtxBytes), p.kubeClient); err != nil {
+		return fmt.Errorf("createJobConfigMap error: %v", err)
+	}
+
 	jobImage := fmt.Sprintf("%s-%s", pipelineTask.ConfigPayload.Release.ReaperImage, p.Task.BuildOS)
 	if p.Task.ImageFrom == config.ImageFromCustom {
 		jobImage = p.Task.BuildOS
@@ -200,46 +223,29 @@ func (p *TestPlugin) Run(ctx context.Context, pipelineTask *task.Task, pipelineC
 
 	// search namespace should also include desired namespace
 	job, err := buildJobWithLinkedNs(
-		p.Type(), jobImage, p.JobName, serviceName, p.Task.ResReq, pipelineCtx, pipelineTask, p.Task.Registries,
+		p.Type(), jobImage, shard.JobName, serviceName, p.Task.ResReq, pipelineCtx, pipelineTask, p.Task.Registries,
 		p.KubeNamespace,
 		linkedNamespace,
 	)
-
-	job.Namespace = p.KubeNamespace
-
 	if err != nil {
-		msg := fmt.Sprintf("create testing job context error: %v", err)
-		p.Log.Error(msg)
-		p.Task.TaskStatus = config.StatusFailed
-		p.Task.Error = msg
-		return
-	}
-
-	if err := ensureDeleteJob(p.KubeNamespace, jobLabel, p.kubeClient); err != nil {
-		msg := fmt.Sprintf("delete testing job error: %v", err)
-		p.Log.Error(msg)
-		p.Task.TaskStatus = config.StatusFailed
-		p.Task.Error = msg
-		return
+		return fmt.Errorf("create testing job context error: %v", err)
 	}
+	job.Namespace = p.KubeNamespace
 
-	// 将集成到KodeRover的私有镜像仓库的访问权限设置到namespace中
-	if err := createOrUpdateRegistrySecrets(p.KubeNamespace, p.Task.Registries, p.kubeClient); err != nil {
-		p.Log.Errorf("create secret error: %v", err)
-	}
 	if err := updater.CreateJob(job, p.kubeClient); err != nil {
-		msg := fmt.Sprintf("create testing job error: %v", err)
-		p.Log.Error(msg)
-		p.Task.TaskStatus = config.StatusFailed
-		p.Task.Error = msg
-		return
+		return fmt.Errorf("create testing job error: %v", err)
 	}
+	return nil
 }
 
 // Wait ...
 func (p *TestPlugin) Wait(ctx context.Context) {
-	status := waitJobEndWithFile(ctx, p.TaskTimeout(), p.KubeNamespace, p.JobName, true, p.kubeClient, p.Log)
-	p.SetStatus(status)
+	if p.Task.JobCtx.ShardCount <= 1 {
+		status := waitJobEndWithFile(ctx, p.TaskTimeout(), p.KubeNamespace, p.JobName, true, p.kubeClient, p.Log)
+		p.SetStatus(status)
+		return
+	}
+	p.SetStatus(p.waitShards(ctx))
 }
 
 // Complete ...
@@ -266,7 +272,12 @@ func (p *TestPlugin) Complete(ctx context.Context, pipelineTask *task.Task, serv
 		}
 	}()
 
-	err := saveContainerLog(pipelineTask, p.KubeNamespace, p.FileName, jobLabel, p.kubeClient)
+	var err error
+	if p.Task.JobCtx.ShardCount <= 1 {
+		err = saveContainerLog(pipelineTask, p.KubeNamespace, p.FileName, jobLabel, p.kubeClient)
+	} else {
+		err = p.saveShardLogs(pipelineTask)
+	}
 	if err != nil {
 		p.Log.Error(err)
 		p.Task.Error = err.Error()
@@ -285,12 +296,29 @@ func (p *TestPlugin) Complete(ctx context.Context, pipelineTask *task.Task, serv
 
 	fileName = strings.Replace(strings.ToLower(fileName), "_", "-", -1)
 
+	if p.Task.JobCtx.CoverageReportPath != "" {
+		if err := p.checkCoverage(pipelineTask, fileName); err != nil {
+			p.Log.Error(err)
+			p.Task.Error = err.Error()
+			p.Task.TaskStatus = config.StatusFailed
+		}
+	}
+
 	//如果用户配置了测试结果目录需要收集,则下载测试结果,发送到aslan server
 	//Note here: p.Task.TestName目前只有默认值test
 	if p.Task.JobCtx.TestResultPath == "" {
 		return
 	}
 
+	if p.Task.JobCtx.ShardCount > 1 {
+		if err := p.mergeShardResults(pipelineTask, fileName); err != nil {
+			p.Log.Error(err)
+			p.Task.Error = err.Error()
+			p.Task.TaskStatus = config.StatusFailed
+			return
+		}
+	}
+
 	testReport := new(types.TestReport)
 	if pipelineTask.TestReports == nil {
 		pipelineTask.TestReports = make(map[string]interface{})
@@ -359,15 +387,16 @@ func (p *TestPlugin) Complete(ctx context.Context, pipelineTask *task.Task, serv
 			return
 		}
 		p.Task.ReportReady = true
+		quarantined := quarantinedFailures(testReport.FunctionTestSuite.TestCases, p.Task.JobCtx.QuarantinedCases)
 		testReport.FunctionTestSuite.TestCases = []types.TestCase{}
 		//测试报告
 		pipelineTask.TestReports[serviceName] = testReport
 
-		if testReport.FunctionTestSuite.Errors > 0 || testReport.FunctionTestSuite.Failures > 0 {
-			msg := fmt.Sprintf(
-				"%d failure case(s) found",
-				testReport.FunctionTestSuite.Errors+testReport.FunctionTestSuite.Failures,
-			)
+		if len(quarantined) > 0 {
+			p.Log.Infof("ignore failures of quarantined test case(s): %s", strings.Join(quarantined, ", "))
+		}
+		if failures := testReport.FunctionTestSuite.Errors + testReport.FunctionTestSuite.Failures - len(quarantined); failures > 0 {
+			msg := fmt.Sprintf("%d failure case(s) found", failures)
 			p.Log.Error(msg)
 			p.Task.Error = msg
 			p.Task.TaskStatus = config.StatusFailed
@@ -442,6 +471,90 @@ func (p *TestPlugin) Complete(ctx context.Context, pipelineTask *task.Task, serv
 
 }
 
+// checkCoverage 下载reaper上传的覆盖率汇总结果, 根据覆盖率要求检查最小覆盖率和相对基准分支的下降
+// 开启分片时各分片按照分片的文件名上传, 合并后再检查
+func (p *TestPlugin) checkCoverage(pipelineTask *task.Task, fileName string) error {
+	store, s3client, err := newTestResultStore(pipelineTask)
+	if err != nil {
+		return err
+	}
+
+	tmpFilename, err := util.GenerateTmpFile()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = os.Remove(tmpFilename)
+	}()
+
+	names := []string{fileName}
+	if p.Task.JobCtx.ShardCount > 1 {
+		names = make([]string, 0, p.Task.JobCtx.ShardCount)
+		for i := 0; i < p.Task.JobCtx.ShardCount; i++ {
+			names = append(names, shardName(fileName, i))
+		}
+	}
+
+	policy := p.Task.JobCtx.CoveragePolicy
+	summaries := make([]*zadigtypes.CoverageSummary, 0, len(names))
+	for _, name := range names {
+		if err := s3client.Download(store.Bucket, store.GetObjectPath(zadigtypes.CoverageReportFile(name)), tmpFilename); err != nil {
+			if policy != nil && (policy.MinLineRate > 0 || policy.MaxDrop > 0) {
+				return fmt.Errorf("no coverage report is found for %s", name)
+			}
+			p.Log.Warnf("failed to download coverage report: %v", err)
+			return nil
+		}
+		data, err := os.ReadFile(tmpFilename)
+		if err != nil {
+			return err
+		}
+		summary := new(zadigtypes.CoverageSummary)
+		if err := json.Unmarshal(data, summary); err != nil {
+			return fmt.Errorf("invalid coverage report of %s: %v", name, err)
+		}
+		summaries = append(summaries, summary)
+	}
+
+	summary := mergeCoverageSummaries(summaries)
+	p.Task.Coverage = summary
+
+	return evaluateCoveragePolicy(policy, summary.LineRate, p.Task.JobCtx.BaseLineRate)
+}
+
+func evaluateCoveragePolicy(policy *zadigtypes.CoveragePolicy, lineRate float64, baseLineRate *float64) error {
+	if policy == nil {
+		return nil
+	}
+	if policy.MinLineRate > 0 && lineRate < policy.MinLineRate {
+		return fmt.Errorf("line coverage %.2f%% is below the minimum %.2f%%", lineRate, policy.MinLineRate)
+	}
+	if policy.MaxDrop > 0 && baseLineRate != nil && *baseLineRate-lineRate > policy.MaxDrop {
+		return fmt.Errorf("line coverage %.2f%% drops more than %.2f%% from %.2f%% of branch %s",
+			lineRate, policy.MaxDrop, *baseLineRate, policy.BaseBranch)
+	}
+	return nil
+}
+
+// quarantinedFailures 返回失败的用例中被隔离的用例
+func quarantinedFailures(testCases []types.TestCase, quarantinedCases []string) []string {
+	if len(quarantinedCases) == 0 {
+		return nil
+	}
+
+	quarantinedSet := sets.NewString(quarantinedCases...)
+	var failures []string
+	for _, tc := range testCases {
+		if tc.Failure == nil && tc.Error == nil {
+			continue
+		}
+		if id := util.GetTestCaseID(tc.ClassName, tc.Name); quarantinedSet.Has(id) {
+			failures = append(failures, id)
+		}
+	}
+	return failures
+}
+
 func (p *TestPlugin) SetTask(t map[string]interface{}) error {
 	task, err := ToTestingTask(t)
 	if err != nil {
@@ -0,0 +1,251 @@
+/*
+Copyright 2021 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package taskplugin
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"k8s.io/apimachinery/pkg/labels"
+
+	"github.com/koderover/zadig/pkg/microservice/warpdrive/config"
+	"github.com/koderover/zadig/pkg/microservice/warpdrive/core/service/taskplugin/s3"
+	"github.com/koderover/zadig/pkg/microservice/warpdrive/core/service/types"
+	"github.com/koderover/zadig/pkg/microservice/warpdrive/core/service/types/task"
+	"github.com/koderover/zadig/pkg/setting"
+	krkubeclient "github.com/koderover/zadig/pkg/tool/kube/client"
+	"github.com/koderover/zadig/pkg/tool/kube/containerlog"
+	"github.com/koderover/zadig/pkg/tool/kube/getter"
+	s3tool "github.com/koderover/zadig/pkg/tool/s3"
+	zadigtypes "github.com/koderover/zadig/pkg/types"
+	"github.com/koderover/zadig/pkg/util"
+)
+
+// testShard 测试分片对应的job以及结果文件
+type testShard struct {
+	JobName        string
+	ArchiveFile    string
+	TestReportFile string
+	EnvVars        []*task.KeyVal
+}
+
+// junitTestSuite 合并后的测试结果, 根节点和reaper生成的结果保持一致
+type junitTestSuite struct {
+	XMLName xml.Name `xml:"testsuite"`
+	*types.TestSuite
+}
+
+func shardName(name string, index int) string {
+	return fmt.Sprintf("%s-shard-%d", name, index)
+}
+
+// shards 未开启分片时只有一个job, job名称和结果文件名与之前保持一致
+func (p *TestPlugin) shards(fileName, testReportFile string) []*testShard {
+	total := p.Task.JobCtx.ShardCount
+	if total <= 1 {
+		return []*testShard{{JobName: p.JobName, ArchiveFile: fileName, TestReportFile: testReportFile}}
+	}
+
+	// 有耗时记录的测试类, 其余的新测试类由测试脚本按照分片序号划分
+	var timed []string
+	for _, tests := range p.Task.JobCtx.TestShards {
+		timed = append(timed, tests...)
+	}
+
+	shards := make([]*testShard, 0, total)
+	for i := 0; i < total; i++ {
+		shard := &testShard{
+			JobName:     shardName(p.JobName, i),
+			ArchiveFile: shardName(fileName, i),
+			EnvVars: []*task.KeyVal{
+				{Key: setting.ShardIndexEnv, Value: strconv.Itoa(i)},
+				{Key: setting.ShardTotalEnv, Value: strconv.Itoa(total)},
+			},
+		}
+		// 第一个分片的html测试报告作为整个测试任务的报告
+		if testReportFile != "" {
+			shard.TestReportFile = testReportFile
+			if i > 0 {
+				shard.TestReportFile = shardName(testReportFile, i)
+			}
+		}
+		if len(timed) > 0 {
+			shard.EnvVars = append(shard.EnvVars, &task.KeyVal{Key: setting.ShardTimedTestsEnv, Value: strings.Join(timed, ",")})
+		}
+		// 没有分到测试类的分片不设置 ZADIG_SHARD_TESTS, 以免测试脚本把空值当作运行全部用例
+		if i < len(p.Task.JobCtx.TestShards) && len(p.Task.JobCtx.TestShards[i]) > 0 {
+			shard.EnvVars = append(shard.EnvVars, &task.KeyVal{Key: setting.ShardTestsEnv, Value: strings.Join(p.Task.JobCtx.TestShards[i], ",")})
+		}
+		shards = append(shards, shard)
+	}
+	return shards
+}
+
+// waitShards 等待所有分片结束, 任何一个分片没有成功整个测试任务都不算成功
+func (p *TestPlugin) waitShards(ctx context.Context) config.Status {
+	timeout := p.TaskTimeout()
+	statuses := make([]config.Status, p.Task.JobCtx.ShardCount)
+
+	var wg sync.WaitGroup
+	for i := range statuses {
+		wg.Add(1)
+		go func(index int) {
+			defer wg.Done()
+			statuses[index] = waitJobEndWithFile(ctx, timeout, p.KubeNamespace, shardName(p.JobName, index), true, p.kubeClient, p.Log)
+		}(i)
+	}
+	wg.Wait()
+
+	return mergeShardStatus(statuses)
+}
+
+func mergeShardStatus(statuses []config.Status) config.Status {
+	for _, status := range []config.Status{config.StatusCancelled, config.StatusTimeout, config.StatusFailed} {
+		for _, s := range statuses {
+			if s == status {
+				return status
+			}
+		}
+	}
+	return config.StatusPassed
+}
+
+// saveShardLogs 按分片顺序拼接所有分片的日志, 作为整个测试任务的日志
+func (p *TestPlugin) saveShardLogs(pipelineTask *task.Task) error {
+	buf := new(bytes.Buffer)
+	for i := 0; i < p.Task.JobCtx.ShardCount; i++ {
+		jobName := shardName(p.JobName, i)
+		pods, err := getter.ListPods(p.KubeNamespace, labels.Set{"job-name": jobName}.AsSelector(), p.kubeClient)
+		if err != nil {
+			return err
+		}
+		if len(pods) < 1 {
+			return fmt.Errorf("no pod found with label job-name=%s", jobName)
+		}
+		sort.SliceStable(pods, func(i, j int) bool {
+			return pods[i].CreationTimestamp.Before(&pods[j].CreationTimestamp)
+		})
+
+		fmt.Fprintf(buf, "========== shard %d/%d ==========\n", i+1, p.Task.JobCtx.ShardCount)
+		if err := containerlog.GetContainerLogs(p.KubeNamespace, pods[0].Name, pods[0].Spec.Containers[0].Name, false, int64(0), buf, krkubeclient.Clientset()); err != nil {
+			return err
+		}
+	}
+
+	return uploadContainerLog(pipelineTask, p.FileName, buf)
+}
+
+// mergeShardResults 下载各个分片的测试结果, 合并后按照未分片时的文件名上传
+func (p *TestPlugin) mergeShardResults(pipelineTask *task.Task, fileName string) error {
+	store, s3client, err := newTestResultStore(pipelineTask)
+	if err != nil {
+		return err
+	}
+
+	tmpFilename, err := util.GenerateTmpFile()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = os.Remove(tmpFilename)
+	}()
+
+	suites := make([]*types.TestSuite, 0, p.Task.JobCtx.ShardCount)
+	for i := 0; i < p.Task.JobCtx.ShardCount; i++ {
+		if err := s3client.Download(store.Bucket, store.GetObjectPath(shardName(fileName, i)), tmpFilename); err != nil {
+			return fmt.Errorf("no test result is found for shard %d: %v", i, err)
+		}
+		b, err := os.ReadFile(tmpFilename)
+		if err != nil {
+			return err
+		}
+		suite := new(types.TestSuite)
+		if err := xml.Unmarshal(b, suite); err != nil {
+			return fmt.Errorf("unmarshal test result of shard %d error: %v", i, err)
+		}
+		suites = append(suites, suite)
+	}
+
+	b, err := xml.MarshalIndent(&junitTestSuite{TestSuite: mergeTestSuites(suites)}, "  ", "    ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(tmpFilename, append([]byte(xml.Header), b...), 0644); err != nil {
+		return err
+	}
+	return s3client.Upload(store.Bucket, tmpFilename, store.GetObjectPath(fileName))
+}
+
+// mergeTestSuites 分片并行运行, 总耗时取最长的分片
+func mergeTestSuites(suites []*types.TestSuite) *types.TestSuite {
+	merged := &types.TestSuite{TestCases: []types.TestCase{}}
+	for _, suite := range suites {
+		merged.Tests += suite.Tests
+		merged.Failures += suite.Failures
+		merged.Successes += suite.Successes
+		merged.Skips += suite.Skips
+		merged.Errors += suite.Errors
+		if suite.Time > merged.Time {
+			merged.Time = suite.Time
+		}
+		merged.TestCases = append(merged.TestCases, suite.TestCases...)
+	}
+	return merged
+}
+
+// mergeCoverageSummaries 和reaper合并同一个任务中的多个覆盖率报告一样, 累加各分片的行数和分支数
+func mergeCoverageSummaries(summaries []*zadigtypes.CoverageSummary) *zadigtypes.CoverageSummary {
+	if len(summaries) == 1 {
+		return summaries[0]
+	}
+
+	merged := &zadigtypes.CoverageSummary{}
+	for _, summary := range summaries {
+		merged.Format = summary.Format
+		merged.Add(summary)
+	}
+	return merged
+}
+
+// newTestResultStore 测试结果和覆盖率汇总保存在任务的test目录下
+func newTestResultStore(pipelineTask *task.Task) (*s3.S3, *s3tool.Client, error) {
+	store, err := s3.NewS3StorageFromEncryptedURI(pipelineTask.StorageURI)
+	if err != nil {
+		return nil, nil, err
+	}
+	if store.Subfolder != "" {
+		store.Subfolder = fmt.Sprintf("%s/%s/%d/%s", store.Subfolder, pipelineTask.PipelineName, pipelineTask.TaskID, "test")
+	} else {
+		store.Subfolder = fmt.Sprintf("%s/%d/%s", pipelineTask.PipelineName, pipelineTask.TaskID, "test")
+	}
+	forcedPathStyle := true
+	if store.Provider == setting.ProviderSourceAli {
+		forcedPathStyle = false
+	}
+	s3client, err := s3tool.NewClient(store.Endpoint, store.Ak, store.Sk, store.Insecure, forcedPathStyle)
+	if err != nil {
+		return nil, nil, err
+	}
+	return store, s3client, nil
+}
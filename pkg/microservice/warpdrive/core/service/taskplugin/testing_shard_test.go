@@ -0,0 +1,66 @@
+/*
+Copyright 2021 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package taskplugin
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/koderover/zadig/pkg/microservice/warpdrive/core/service/types/task"
+	zadigtypes "github.com/koderover/zadig/pkg/types"
+)
+
+func TestMergeCoverageSummaries(t *testing.T) {
+	single := &zadigtypes.CoverageSummary{Format: zadigtypes.CoverageFormatJaCoCo, LineRate: 75, LinesCovered: 3, LinesValid: 4}
+	assert.Same(t, single, mergeCoverageSummaries([]*zadigtypes.CoverageSummary{single}))
+
+	merged := mergeCoverageSummaries([]*zadigtypes.CoverageSummary{
+		{Format: zadigtypes.CoverageFormatJaCoCo, LineRate: 80, BranchRate: 50, LinesCovered: 80, LinesValid: 100, BranchesCovered: 5, BranchesValid: 10},
+		{Format: zadigtypes.CoverageFormatJaCoCo, LineRate: 20, LinesCovered: 40, LinesValid: 200},
+		{Format: zadigtypes.CoverageFormatJaCoCo},
+	})
+	assert.Equal(t, &zadigtypes.CoverageSummary{
+		Format:          zadigtypes.CoverageFormatJaCoCo,
+		LineRate:        40,
+		BranchRate:      50,
+		LinesCovered:    120,
+		LinesValid:      300,
+		BranchesCovered: 5,
+		BranchesValid:   10,
+	}, merged)
+
+	// 合并后的覆盖率低于要求时检查失败, 即使其中一个分片满足要求
+	policy := &zadigtypes.CoveragePolicy{MinLineRate: 60}
+	assert.Error(t, evaluateCoveragePolicy(policy, merged.LineRate, nil))
+}
+
+func TestTestPluginShards(t *testing.T) {
+	p := &TestPlugin{JobName: "test-job", Task: &task.Testing{JobCtx: task.JobCtx{ShardCount: 2}}}
+	shards := p.shards("workflow-demo-1-testingv2-svc", "report.html")
+	assert.Len(t, shards, 2)
+	for i, shard := range shards {
+		assert.Equal(t, shardName("test-job", i), shard.JobName)
+		assert.Equal(t, shardName("workflow-demo-1-testingv2-svc", i), shard.ArchiveFile)
+	}
+	assert.Equal(t, "report.html", shards[0].TestReportFile)
+	assert.Equal(t, "report.html-shard-1", shards[1].TestReportFile)
+
+	p.Task.JobCtx.ShardCount = 0
+	assert.Equal(t, []*testShard{{JobName: "test-job", ArchiveFile: "workflow-demo-1-testingv2-svc", TestReportFile: "report.html"}},
+		p.shards("workflow-demo-1-testingv2-svc", "report.html"))
+}
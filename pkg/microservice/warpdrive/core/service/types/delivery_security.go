@@ -0,0 +1,52 @@
+/*
+Copyright 2021 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package types
+
+// DeliverySecurity 镜像扫描发现的一个漏洞, 与aslan中保存的扫描结果格式一致
+type DeliverySecurity struct {
+	ImageID       string        `json:"imageId"`
+	ImageName     string        `json:"imageName"`
+	LayerID       string        `json:"layerId"`
+	Vulnerability Vulnerability `json:"vulnerability"`
+	Feature       Feature       `json:"feature"`
+	Severity      string        `json:"severity"`
+	CreatedAt     int64         `json:"created_at"`
+}
+
+type Vulnerability struct {
+	Name          string    `json:"name,omitempty"`
+	NamespaceName string    `json:"namespaceName,omitempty"`
+	Description   string    `json:"description,omitempty"`
+	Link          string    `json:"link,omitempty"`
+	Severity      string    `json:"severity,omitempty"`
+	FixedBy       string    `json:"fixedBy,omitempty"`
+	FixedIn       []Feature `json:"fixedIn,omitempty"`
+}
+
+type Feature struct {
+	Name          string `json:"name,omitempty"`
+	NamespaceName string `json:"namespaceName,omitempty"`
+	VersionFormat string `json:"versionFormat,omitempty"`
+	Version       string `json:"version,omitempty"`
+	AddedBy       string `json:"addedBy,omitempty"`
+}
+
+// DeliverySecurityInfo 上报给aslan的扫描结果
+type DeliverySecurityInfo struct {
+	Result            string              `json:"result,omitempty"`
+	DeliverySecuritys []*DeliverySecurity `json:"message,omitempty"`
+}
@@ -24,4 +24,11 @@ type PredatorContext struct {
 	DockerBuildCtx *task.DockerBuildCtx `yaml:"build_ctx"`
 	OnSetup        string               `yaml:"setup,omitempty"`
 	ReleaseImages  []task.RepoImage     `yaml:"release_images"`
+	Signing        *ImageSigning        `yaml:"signing,omitempty"`
+}
+
+// ImageSigning 推送镜像后使用cosign签名, Provenance为JSON格式的构建来源证明
+// cosign私钥和密码不写入任务配置, 通过Secret以环境变量注入
+type ImageSigning struct {
+	Provenance string `yaml:"provenance,omitempty"`
 }
@@ -86,6 +86,15 @@ type Context struct {
 	// Caches Caches配置
 	Caches []string `yaml:"caches"`
 
+	// Steps 构建步骤, 配置后代替 Scripts 等固定流程按顺序执行
+	Steps []*Step `yaml:"steps"`
+
+	// CacheType 缓存方式, 为空时使用tar包
+	CacheType string `yaml:"cache_type"`
+
+	// CacheKeyFiles 计算缓存key的lock文件, 仅分块缓存使用
+	CacheKeyFiles []string `yaml:"cache_key_files"`
+
 	// testType
 	TestType string `yaml:"test_type"`
 
@@ -178,6 +187,32 @@ func (ev EnvVar) Environs() []string {
 }
 
 // Install ...
+// Step 构建步骤, 按顺序执行
+type Step struct {
+	// Name 步骤名称
+	Name string `yaml:"name"`
+	// Type 步骤类型: shell, git, docker_build, archive, upload, junit
+	Type string `yaml:"type"`
+	// When 执行条件: on_success, on_failure, always
+	When string `yaml:"when"`
+	// Timeout 超时时间, 单位为分钟
+	Timeout int `yaml:"timeout"`
+	// Envs 步骤环境变量
+	Envs EnvVar `yaml:"envs"`
+	// SecretEnvs 步骤敏感环境变量
+	SecretEnvs EnvVar `yaml:"secret_envs"`
+	// Scripts shell脚本
+	Scripts []string `yaml:"scripts"`
+	// DockerBuild 镜像构建配置
+	DockerBuild *task.DockerBuildCtx `yaml:"docker_build"`
+	// Paths 打包或上传的路径
+	Paths []string `yaml:"paths"`
+	// Dest 打包生成的文件
+	Dest string `yaml:"dest"`
+	// ResultPath junit测试结果目录
+	ResultPath string `yaml:"result_path"`
+}
+
 type Install struct {
 	// 安装名称
 	Name string `yaml:"name"`
@@ -265,6 +300,9 @@ type GinkgoTest struct {
 	ResultPath     string   `yaml:"result_path"`
 	TestReportPath string   `yaml:"test_report_path"`
 	ArtifactPaths  []string `yaml:"artifact_paths"`
+	// CoveragePath 覆盖率报告文件或者目录, CoverageFormat 为报告格式
+	CoveragePath   string `yaml:"coverage_path,omitempty"`
+	CoverageFormat string `yaml:"coverage_format,omitempty"`
 }
 
 // DockerRegistry 推送镜像到 docker registry 配置
@@ -0,0 +1,46 @@
+/*
+Copyright 2021 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package task
+
+import (
+	"fmt"
+
+	"github.com/koderover/zadig/pkg/microservice/warpdrive/config"
+)
+
+type Approval struct {
+	TaskType    config.TaskType `bson:"type"                          json:"type"`
+	Enabled     bool            `bson:"enabled"                       json:"enabled"`
+	TaskStatus  config.Status   `bson:"status"                        json:"status"`
+	Approvers   []string        `bson:"approvers"                     json:"approvers"`
+	Description string          `bson:"description,omitempty"         json:"description,omitempty"`
+	Timeout     int             `bson:"timeout,omitempty"             json:"timeout,omitempty"` // 等待审批的超时时间, 单位为分钟
+	Approver    string          `bson:"approver,omitempty"            json:"approver,omitempty"`
+	Comment     string          `bson:"comment,omitempty"             json:"comment,omitempty"`
+	ApproveTime int64           `bson:"approve_time,omitempty"        json:"approve_time,omitempty"`
+	Error       string          `bson:"error,omitempty"               json:"error,omitempty"`
+	StartTime   int64           `bson:"start_time,omitempty"          json:"start_time,omitempty"`
+	EndTime     int64           `bson:"end_time,omitempty"            json:"end_time,omitempty"`
+}
+
+func (a *Approval) ToSubTask() (map[string]interface{}, error) {
+	var task map[string]interface{}
+	if err := IToi(a, &task); err != nil {
+		return nil, fmt.Errorf("convert ApprovalTask to interface error: %v", err)
+	}
+	return task, nil
+}
@@ -49,6 +49,8 @@ type ConfigPayload struct {
 	// ResetCache means ignore workspace cache
 	ResetCache  bool          `json:"reset_cache"`
 	PrivateKeys []*PrivateKey `json:"private_keys"`
+	// SigningKeys 工作流中镜像签名和验证用到的密钥
+	SigningKeys []*SigningKey `json:"signing_keys,omitempty"`
 }
 
 func (cp *ConfigPayload) GetGitKnownHost() string {
@@ -148,3 +150,21 @@ type JenkinsBuildConfig struct {
 type PrivateKey struct {
 	Name string `json:"name"`
 }
+
+type SigningKey struct {
+	ID         string `json:"id"`
+	Name       string `json:"name"`
+	PrivateKey string `json:"private_key"`
+	Password   string `json:"password"`
+	PublicKey  string `json:"public_key"`
+}
+
+// GetSigningKey 根据ID查找签名密钥, 找不到时返回nil
+func (cp *ConfigPayload) GetSigningKey(id string) *SigningKey {
+	for _, key := range cp.SigningKeys {
+		if key.ID == id {
+			return key
+		}
+	}
+	return nil
+}
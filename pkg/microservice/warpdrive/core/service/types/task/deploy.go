@@ -31,26 +31,87 @@ type Resource struct {
 
 // Deploy 容器部署任务
 type Deploy struct {
-	TaskType         config.TaskType `bson:"type"                          json:"type"`
-	Enabled          bool            `bson:"enabled"                       json:"enabled"`
-	TaskStatus       config.Status   `bson:"status"                        json:"status"`
-	Namespace        string          `bson:"namespace"                     json:"namespace"`
-	EnvName          string          `bson:"env_name"                      json:"env_name"`
-	ProductName      string          `bson:"product_name"                  json:"product_name"`
-	ServiceName      string          `bson:"service_name"                  json:"service_name"`
-	ServiceType      string          `bson:"service_type"                  json:"service_type"`
-	ServiceRevision  int64           `bson:"service_revision,omitempty"    json:"service_revision,omitempty"`
-	ContainerName    string          `bson:"container_name"                json:"container_name"`
-	Image            string          `bson:"image"                         json:"image"`
-	Timeout          int             `bson:"timeout,omitempty"             json:"timeout,omitempty"`
-	Error            string          `bson:"error,omitempty"               json:"error,omitempty"`
-	StartTime        int64           `bson:"start_time,omitempty"          json:"start_time,omitempty"`
-	EndTime          int64           `bson:"end_time,omitempty"            json:"end_time,omitempty"`
-	ClusterID        string          `bson:"cluster_id,omitempty"          json:"cluster_id,omitempty"`
-	ReplaceResources []Resource      `bson:"replace_resources"             json:"replace_resources"`
-	SkipWaiting      bool            `bson:"skipWaiting"                   json:"skipWaiting"`
-	IsRestart        bool            `bson:"is_restart"                    json:"is_restart"`
-	ResetImage       bool            `bson:"reset_image"                   json:"reset_image"`
+	TaskType            config.TaskType       `bson:"type"                          json:"type"`
+	Enabled             bool                  `bson:"enabled"                       json:"enabled"`
+	TaskStatus          config.Status         `bson:"status"                        json:"status"`
+	Namespace           string                `bson:"namespace"                     json:"namespace"`
+	EnvName             string                `bson:"env_name"                      json:"env_name"`
+	ProductName         string                `bson:"product_name"                  json:"product_name"`
+	ServiceName         string                `bson:"service_name"                  json:"service_name"`
+	ServiceType         string                `bson:"service_type"                  json:"service_type"`
+	ServiceRevision     int64                 `bson:"service_revision,omitempty"    json:"service_revision,omitempty"`
+	ContainerName       string                `bson:"container_name"                json:"container_name"`
+	Image               string                `bson:"image"                         json:"image"`
+	Timeout             int                   `bson:"timeout,omitempty"             json:"timeout,omitempty"`
+	Error               string                `bson:"error,omitempty"               json:"error,omitempty"`
+	StartTime           int64                 `bson:"start_time,omitempty"          json:"start_time,omitempty"`
+	EndTime             int64                 `bson:"end_time,omitempty"            json:"end_time,omitempty"`
+	ClusterID           string                `bson:"cluster_id,omitempty"          json:"cluster_id,omitempty"`
+	ReplaceResources    []Resource            `bson:"replace_resources"             json:"replace_resources"`
+	SkipWaiting         bool                  `bson:"skipWaiting"                   json:"skipWaiting"`
+	IsRestart           bool                  `bson:"is_restart"                    json:"is_restart"`
+	ResetImage          bool                  `bson:"reset_image"                   json:"reset_image"`
+	Rollback            *DeployRollback       `bson:"rollback,omitempty"            json:"rollback,omitempty"`
+	Strategy            *DeployStrategy       `bson:"strategy,omitempty"            json:"strategy,omitempty"`
+	Verifications       []*DeployVerification `bson:"verifications,omitempty"       json:"verifications,omitempty"`
+	VerificationResults []*VerificationResult `bson:"verification_results,omitempty" json:"verification_results,omitempty"`
+	// StandbyWorkload 蓝绿部署切换流量后不再提供服务的工作负载, 验证通过后缩容, 回滚时Service重新指向它
+	StandbyWorkload string `bson:"standby_workload,omitempty"    json:"standby_workload,omitempty"`
+}
+
+// DeployStrategy 部署策略, 为空时直接替换工作负载的镜像
+type DeployStrategy struct {
+	Type string `bson:"type"                     json:"type"`
+	// CanaryPercent 金丝雀版本的副本数占原工作负载副本数的百分比
+	CanaryPercent int `bson:"canary_percent,omitempty" json:"canary_percent,omitempty"`
+	// PauseSeconds 金丝雀版本就绪后观察多长时间再验证
+	PauseSeconds int `bson:"pause_seconds,omitempty"  json:"pause_seconds,omitempty"`
+}
+
+// DeployVerification 部署就绪后的验证
+type DeployVerification struct {
+	Name string `bson:"name"                      json:"name"`
+	// Type http/prometheus/job
+	Type string `bson:"type"                      json:"type"`
+	// URL http验证请求的地址
+	URL string `bson:"url,omitempty"             json:"url,omitempty"`
+	// ExpectedStatus http验证期望的状态码, 为0时只要求请求成功
+	ExpectedStatus int `bson:"expected_status,omitempty" json:"expected_status,omitempty"`
+	// PrometheusURL prometheus验证查询的地址, 查询结果通过 Operator 和 Threshold 比较
+	PrometheusURL string  `bson:"prometheus_url,omitempty"  json:"prometheus_url,omitempty"`
+	Query         string  `bson:"query,omitempty"           json:"query,omitempty"`
+	Operator      string  `bson:"operator,omitempty"        json:"operator,omitempty"`
+	Threshold     float64 `bson:"threshold,omitempty"       json:"threshold,omitempty"`
+	// Image 和 Script 自定义验证Job使用的镜像和脚本
+	Image  string `bson:"image,omitempty"           json:"image,omitempty"`
+	Script string `bson:"script,omitempty"          json:"script,omitempty"`
+	// Window 验证持续的时间(秒), 也是http验证和Job验证的超时时间
+	Window int `bson:"window,omitempty"          json:"window,omitempty"`
+	// Interval 两次检查之间的间隔(秒)
+	Interval int `bson:"interval,omitempty"        json:"interval,omitempty"`
+}
+
+// VerificationResult 部署验证的结果
+type VerificationResult struct {
+	Name    string        `bson:"name"              json:"name"`
+	Type    string        `bson:"type"              json:"type"`
+	Status  config.Status `bson:"status"            json:"status"`
+	Message string        `bson:"message,omitempty" json:"message,omitempty"`
+}
+
+// DeployRollback 部署失败或超时后自动回滚的结果
+type DeployRollback struct {
+	Status config.Status `bson:"status"             json:"status"`
+	// Revision helm服务回滚到的release版本
+	Revision  int    `bson:"revision,omitempty" json:"revision,omitempty"`
+	Error     string `bson:"error,omitempty"    json:"error,omitempty"`
+	StartTime int64  `bson:"start_time"         json:"start_time"`
+	EndTime   int64  `bson:"end_time"           json:"end_time"`
+}
+
+// RolledBack 回滚成功时部署的镜像并没有生效
+func (d *Deploy) RolledBack() bool {
+	return d.Rollback != nil && d.Rollback.Status == config.StatusPassed
 }
 
 // SetNamespace ...
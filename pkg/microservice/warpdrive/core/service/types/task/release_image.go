@@ -42,7 +42,13 @@ type ReleaseImage struct {
 	LogFile      string          `bson:"log_file"                       json:"log_file"`
 
 	// destinations to distribute images
-	Releases []RepoImage `bson:"releases"                 json:"releases"`
+	Releases []RepoImage   `bson:"releases"                 json:"releases"`
+	Signing  *ImageSigning `bson:"signing,omitempty"        json:"signing,omitempty"`
+}
+
+type ImageSigning struct {
+	KeyID      string `bson:"key_id"      json:"key_id"`
+	Provenance bool   `bson:"provenance"  json:"provenance"`
 }
 
 type RepoImage struct {
@@ -34,6 +34,30 @@ type Security struct {
 	EndTime    int64           `bson:"end_time,omitempty"            json:"end_time,omitempty"`
 	LogFile    string          `bson:"log_file"                      json:"log_file"`
 	Summary    map[string]int  `bson:"summary"                       json:"summary"`
+	Policy     *SecurityPolicy `bson:"policy,omitempty"              json:"policy,omitempty"`
+	// Violations 违反安全策略的说明
+	Violations   []string  `bson:"violations,omitempty"          json:"violations,omitempty"`
+	Scanner      string    `bson:"scanner,omitempty"             json:"scanner,omitempty"`
+	ScannerImage string    `bson:"scanner_image,omitempty"       json:"scanner_image,omitempty"`
+	ScannerEnvs  []*KeyVal `bson:"scanner_envs,omitempty"        json:"scanner_envs,omitempty"`
+}
+
+// SecurityPolicy 镜像安全扫描的准入策略, 违反策略时安全扫描任务失败
+type SecurityPolicy struct {
+	// MaxSeverityCount 各个级别允许的最大漏洞数量, 例如 {"Critical": 0, "High": 10}, 没有配置的级别不做限制
+	MaxSeverityCount map[string]int `bson:"max_severity_count"     json:"max_severity_count"`
+	// AllowList 豁免的漏洞, 不计入漏洞数量
+	AllowList []*AllowedVulnerability `bson:"allow_list"             json:"allow_list"`
+	// FixableOnly 为true时只统计已经有修复版本的漏洞
+	FixableOnly bool `bson:"fixable_only"           json:"fixable_only"`
+}
+
+type AllowedVulnerability struct {
+	// Name 漏洞编号, 例如 CVE-2021-3711
+	Name   string `bson:"name"                   json:"name"`
+	Reason string `bson:"reason,omitempty"       json:"reason,omitempty"`
+	// ExpireAt 豁免的过期时间, 为0时一直有效
+	ExpireAt int64 `bson:"expire_at,omitempty"    json:"expire_at,omitempty"`
 }
 
 func (s *Security) SetImageName(imageName string) {
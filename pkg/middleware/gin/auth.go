@@ -82,3 +82,13 @@ func Auth() gin.HandlerFunc {
 		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"message": "auth failed"})
 	}
 }
+
+// RequireRootAPIKey 只允许使用root key的内部服务调用, 用于只供warpdrive等内部服务使用的接口
+func RequireRootAPIKey(c *gin.Context) {
+	token := strings.Split(c.Request.Header.Get(setting.AuthorizationHeader), " ")
+	if len(token) == 2 && token[0] == setting.RootAPIKey && token[1] != "" && token[1] == config.PoetryAPIRootKey() {
+		c.Next()
+		return
+	}
+	c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"message": "Require internal api key"})
+}
@@ -64,6 +64,12 @@ const (
 	ENVS3StoragePath     = "S3STORAGE_PATH"
 	ENVKubeServerAddr    = "KUBE_SERVER_ADDR"
 
+	ENVSMTPHost     = "SMTP_HOST"
+	ENVSMTPPort     = "SMTP_PORT"
+	ENVSMTPUsername = "SMTP_USERNAME"
+	ENVSMTPPassword = "SMTP_PASSWORD"
+	ENVSMTPFrom     = "SMTP_FROM"
+
 	// cron
 	ENVRootToken = "ROOT_TOKEN"
 
@@ -83,6 +89,7 @@ const (
 	DefaultRegistryAddr = "DEFAULT_REG_ADDRESS"
 	DefaultRegistryAK   = "DEFAULT_REG_ACCESS_KEY"
 	DefaultRegistrySK   = "DEFAULT_REG_SECRET_KEY"
+	WarpDriveTaskSlots  = "WD_TASK_SLOTS"
 
 	// reaper
 	Home          = "HOME"
@@ -144,6 +151,9 @@ const (
 	ModifiedByAnnotation            = companyLabel + "/" + "last-modified-by"
 	EditorIDAnnotation              = companyLabel + "/" + "editor-id"
 	LastUpdateTimeAnnotation        = companyLabel + "/" + "last-update-time"
+	CanaryLabel                     = "s-canary"
+	BlueGreenSlotLabel              = "s-slot"
+	OriginSelectorAnnotation        = companyLabel + "/" + "origin-selector"
 
 	LabelValueTrue = "true"
 
@@ -214,6 +224,10 @@ const (
 	SourceFromGerrit = "gerrit"
 	// SourceFromCodeHub 配置来源为codehub
 	SourceFromCodeHub = "codehub"
+	// SourceFromGitea 配置来源为gitea
+	SourceFromGitea = "gitea"
+	// SourceFromBitbucket 配置来源为bitbucket server
+	SourceFromBitbucket = "bitbucket"
 	// SourceFromChartTemplate 配置来源为helmTemplate
 	SourceFromChartTemplate = "chartTemplate"
 	// SourceFromPublicRepo 配置来源为publicRepo
@@ -413,6 +427,19 @@ const (
 	PerformanceTest = "performance"
 )
 
+const (
+	// TestShardByIndex 测试脚本根据 ZADIG_SHARD_INDEX 和 ZADIG_SHARD_TOTAL 自行选择要运行的用例
+	TestShardByIndex = "index"
+	// TestShardByTiming 根据历史测试结果中各个测试类的耗时划分分片, 通过 ZADIG_SHARD_TESTS 传给测试脚本
+	// 不在 ZADIG_SHARD_TIMED_TESTS 中的新测试类仍然根据 ZADIG_SHARD_INDEX 和 ZADIG_SHARD_TOTAL 划分
+	TestShardByTiming = "timing"
+
+	ShardIndexEnv      = "ZADIG_SHARD_INDEX"
+	ShardTotalEnv      = "ZADIG_SHARD_TOTAL"
+	ShardTestsEnv      = "ZADIG_SHARD_TESTS"
+	ShardTimedTestsEnv = "ZADIG_SHARD_TIMED_TESTS"
+)
+
 const (
 	// UbuntuPrecis ...
 	UbuntuPrecis = "precise"
@@ -432,6 +459,7 @@ const (
 	EnvRecyclePolicyAlways     = "always"
 	EnvRecyclePolicyTaskStatus = "success"
 	EnvRecyclePolicyNever      = "never"
+	EnvRecyclePolicyPrClosed   = "pr_closed"
 )
 
 const (
@@ -495,6 +523,79 @@ const (
 	ZadigDockerfilePath = "zadig-dockerfile"
 )
 
+// SBOM constant
+const (
+	SBOMFormatSPDX      = "spdx-json"
+	SBOMFormatCycloneDX = "cyclonedx-json"
+
+	// SBOMFileType 任务存储中保存SBOM文件的目录
+	SBOMFileType = "sbom"
+)
+
+// Build step constant
+const (
+	BuildStepShell       = "shell"
+	BuildStepGit         = "git"
+	BuildStepDockerBuild = "docker_build"
+	BuildStepArchive     = "archive"
+	BuildStepUpload      = "upload"
+	BuildStepJunit       = "junit"
+
+	// BuildStepOnSuccess 之前的步骤都成功时执行, 默认值
+	BuildStepOnSuccess = "on_success"
+	// BuildStepOnFailure 之前有步骤失败时才执行
+	BuildStepOnFailure = "on_failure"
+	// BuildStepAlways 总是执行
+	BuildStepAlways = "always"
+)
+
+// Deploy strategy constant
+const (
+	// DeployStrategyRolling 直接替换工作负载的镜像, 默认值
+	DeployStrategyRolling = "rolling"
+	// DeployStrategyCanary 先部署部分副本的金丝雀版本, 验证通过后再全量更新
+	DeployStrategyCanary = "canary"
+	// DeployStrategyBlueGreen 部署新的工作负载, 就绪后将Service切换到新的工作负载
+	DeployStrategyBlueGreen = "blue_green"
+)
+
+// Deploy verification type constant
+const (
+	// DeployVerificationHTTP 请求http地址, 在窗口期内成功一次即通过
+	DeployVerificationHTTP = "http"
+	// DeployVerificationPrometheus 在窗口期内多次执行prometheus查询, 每次结果都需要满足阈值
+	DeployVerificationPrometheus = "prometheus"
+	// DeployVerificationJob 在部署的命名空间中运行自定义的Job, Job成功即通过
+	DeployVerificationJob = "job"
+)
+
+// Security scanner constant
+const (
+	// SecurityScannerClair 通过clair服务分析构建机上的镜像, 默认值
+	SecurityScannerClair = "clair"
+	// SecurityScannerTrivy 以Job的方式运行trivy扫描镜像仓库中的镜像
+	SecurityScannerTrivy = "trivy"
+	// SecurityScannerGrype 以Job的方式运行grype扫描镜像仓库中的镜像
+	SecurityScannerGrype = "grype"
+
+	DefaultTrivyImage = "aquasec/trivy:0.20.2"
+	DefaultGrypeImage = "anchore/grype:v0.27.0"
+)
+
+// Image signing constant
+const (
+	// DefaultCosignImage 部署前验证镜像签名使用的cosign镜像
+	DefaultCosignImage = "gcr.io/projectsigstore/cosign:v1.6.0"
+)
+
+// Build cache type constant
+const (
+	// BuildCacheTypeTar 每次构建把缓存目录打成一个tar包上传
+	BuildCacheTypeTar = "tar"
+	// BuildCacheTypeChunk 缓存文件按内容哈希分块存储, 只上传有变化的分块
+	BuildCacheTypeChunk = "chunk"
+)
+
 // Yaml template constant
 const (
 	RegExpParameter = `{{.(\w)+}}`
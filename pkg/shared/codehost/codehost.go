@@ -25,10 +25,12 @@ import (
 )
 
 const (
-	GitLabProvider  = "gitlab"
-	GitHubProvider  = "github"
-	GerritProvider  = "gerrit"
-	CodeHubProvider = "codehub"
+	GitLabProvider    = "gitlab"
+	GitHubProvider    = "github"
+	GerritProvider    = "gerrit"
+	CodeHubProvider   = "codehub"
+	GiteaProvider     = "gitea"
+	BitbucketProvider = "bitbucket"
 )
 
 type CodeHost struct {
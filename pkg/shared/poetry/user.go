@@ -416,3 +416,22 @@ func (c *Client) GetUserEnvPermission(userID int, log *zap.SugaredLogger) ([]*Us
 
 	return resp, nil
 }
+
+// GetUserByEmail 根据邮箱查找zadig用户，邮箱不区分大小写，找不到时返回nil
+func (c *Client) GetUserByEmail(email string, log *zap.SugaredLogger) (*UserInfo, error) {
+	url := "/directory/userss/search"
+
+	users := make([]*UserInfo, 0)
+	_, err := c.Get(url, httpclient.SetResult(&users), httpclient.SetQueryParam("email", email))
+	if err != nil {
+		log.Errorf("GetUserByEmail error: %v", err)
+		return nil, err
+	}
+
+	for _, user := range users {
+		if strings.EqualFold(user.Email, email) {
+			return user, nil
+		}
+	}
+	return nil, nil
+}
@@ -0,0 +1,72 @@
+/*
+Copyright 2021 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package bitbucket
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/koderover/zadig/pkg/tool/httpclient"
+)
+
+const (
+	pageSize = 100
+	maxPages = 20
+)
+
+type Client struct {
+	*httpclient.Client
+
+	address string
+}
+
+// NewClient 使用personal access token访问bitbucket server REST API
+func NewClient(address, accessToken string) *Client {
+	address = strings.TrimSuffix(address, "/")
+	c := httpclient.New(
+		httpclient.SetAuthScheme("Bearer"),
+		httpclient.SetAuthToken(accessToken),
+		httpclient.SetHostURL(address+"/rest"),
+	)
+
+	return &Client{
+		Client:  c,
+		address: address,
+	}
+}
+
+// page bitbucket server分页接口的返回结构
+type page struct {
+	Size          int  `json:"size"`
+	Limit         int  `json:"limit"`
+	IsLastPage    bool `json:"isLastPage"`
+	Start         int  `json:"start"`
+	NextPageStart int  `json:"nextPageStart"`
+}
+
+func repoPath(projectKey, repoSlug string) string {
+	return fmt.Sprintf("/api/1.0/projects/%s/repos/%s", url.PathEscape(projectKey), url.PathEscape(repoSlug))
+}
+
+func pageParams(start int) map[string]string {
+	return map[string]string{
+		"start": strconv.Itoa(start),
+		"limit": strconv.Itoa(pageSize),
+	}
+}
@@ -0,0 +1,119 @@
+/*
+Copyright 2021 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package bitbucket
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// EventType represents a Bitbucket Server event type.
+type EventType string
+
+// List of available event types.
+const (
+	EventTypeRefsChanged      EventType = "repo:refs_changed"
+	EventTypePROpened         EventType = "pr:opened"
+	EventTypePRFromRefUpdated EventType = "pr:from_ref_updated"
+	EventTypePRMerged         EventType = "pr:merged"
+	EventTypePRDeclined       EventType = "pr:declined"
+	EventTypePRDeleted        EventType = "pr:deleted"
+	// EventTypeDiagnosticsPing 在bitbucket中测试webhook连接时发送
+	EventTypeDiagnosticsPing EventType = "diagnostics:ping"
+)
+
+const (
+	ChangeTypeAdd    = "ADD"
+	ChangeTypeUpdate = "UPDATE"
+	ChangeTypeDelete = "DELETE"
+)
+
+const (
+	eventTypeHeader = "X-Event-Key"
+	signatureHeader = "X-Hub-Signature"
+	signaturePrefix = "sha256="
+)
+
+// HookEventType returns the event type for the given request.
+func HookEventType(r *http.Request) EventType {
+	return EventType(r.Header.Get(eventTypeHeader))
+}
+
+// ValidatePayload 校验请求中的签名, secret为空时不做校验
+func ValidatePayload(r *http.Request, payload []byte, secret string) error {
+	if secret == "" {
+		return nil
+	}
+
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(payload)
+	expected := signaturePrefix + hex.EncodeToString(mac.Sum(nil))
+	if !hmac.Equal([]byte(expected), []byte(r.Header.Get(signatureHeader))) {
+		return errors.New("payload signature check failed")
+	}
+	return nil
+}
+
+func ParseHook(eventType EventType, payload []byte) (event interface{}, err error) {
+	switch eventType {
+	case EventTypeRefsChanged:
+		event = &RefsChangedEvent{}
+	case EventTypePROpened, EventTypePRFromRefUpdated, EventTypePRMerged, EventTypePRDeclined, EventTypePRDeleted:
+		event = &PullRequestEvent{}
+	default:
+		return nil, fmt.Errorf("unexpected event type: %s", eventType)
+	}
+
+	if err := json.Unmarshal(payload, event); err != nil {
+		return nil, err
+	}
+
+	return event, nil
+}
+
+type RefChange struct {
+	Ref      *Ref   `json:"ref"`
+	RefID    string `json:"refId"`
+	FromHash string `json:"fromHash"`
+	ToHash   string `json:"toHash"`
+	Type     string `json:"type"`
+}
+
+type RefsChangedEvent struct {
+	EventKey   EventType    `json:"eventKey"`
+	Date       string       `json:"date"`
+	Actor      *User        `json:"actor"`
+	Repository *Repository  `json:"repository"`
+	Changes    []*RefChange `json:"changes"`
+}
+
+type PullRequestEvent struct {
+	EventKey    EventType    `json:"eventKey"`
+	Date        string       `json:"date"`
+	Actor       *User        `json:"actor"`
+	PullRequest *PullRequest `json:"pullRequest"`
+}
+
+// IsClosed pull request合并, 拒绝或删除
+func (e *PullRequestEvent) IsClosed() bool {
+	return e.EventKey == EventTypePRMerged || e.EventKey == EventTypePRDeclined || e.EventKey == EventTypePRDeleted
+}
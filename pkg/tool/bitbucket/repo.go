@@ -0,0 +1,275 @@
+/*
+Copyright 2021 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package bitbucket
+
+import (
+	"fmt"
+	"net/url"
+
+	"github.com/koderover/zadig/pkg/tool/httpclient"
+)
+
+const (
+	RefTypeBranch = "BRANCH"
+	RefTypeTag    = "TAG"
+
+	PullRequestStateOpen = "OPEN"
+)
+
+type User struct {
+	Name         string `json:"name"`
+	EmailAddress string `json:"emailAddress"`
+	DisplayName  string `json:"displayName"`
+	Slug         string `json:"slug"`
+}
+
+type Project struct {
+	ID          int    `json:"id"`
+	Key         string `json:"key"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+}
+
+type Repository struct {
+	ID      int      `json:"id"`
+	Slug    string   `json:"slug"`
+	Name    string   `json:"name"`
+	Project *Project `json:"project"`
+}
+
+type Ref struct {
+	ID           string      `json:"id"`
+	DisplayID    string      `json:"displayId"`
+	Type         string      `json:"type"`
+	LatestCommit string      `json:"latestCommit"`
+	IsDefault    bool        `json:"isDefault"`
+	Repository   *Repository `json:"repository,omitempty"`
+}
+
+type PullRequestParticipant struct {
+	User *User `json:"user"`
+}
+
+type PullRequest struct {
+	ID          int                     `json:"id"`
+	Title       string                  `json:"title"`
+	Description string                  `json:"description"`
+	State       string                  `json:"state"`
+	Author      *PullRequestParticipant `json:"author"`
+	FromRef     *Ref                    `json:"fromRef"`
+	ToRef       *Ref                    `json:"toRef"`
+	CreatedDate int64                   `json:"createdDate"`
+	UpdatedDate int64                   `json:"updatedDate"`
+}
+
+type path struct {
+	ToString string `json:"toString"`
+}
+
+type change struct {
+	Path    *path `json:"path"`
+	SrcPath *path `json:"srcPath"`
+}
+
+func (c *Client) ListProjects() ([]*Project, error) {
+	var projects []*Project
+	for start, i := 0, 0; i < maxPages; i++ {
+		res := &struct {
+			page
+			Values []*Project `json:"values"`
+		}{}
+		if _, err := c.Get("/api/1.0/projects", httpclient.SetQueryParams(pageParams(start)), httpclient.SetResult(res)); err != nil {
+			return nil, err
+		}
+		projects = append(projects, res.Values...)
+		if res.IsLastPage {
+			break
+		}
+		start = res.NextPageStart
+	}
+	return projects, nil
+}
+
+// ListRepositories keyword不为空时按名称过滤
+func (c *Client) ListRepositories(projectKey, keyword string) ([]*Repository, error) {
+	var repos []*Repository
+	for start, i := 0, 0; i < maxPages; i++ {
+		params := pageParams(start)
+		params["projectkey"] = projectKey
+		if keyword != "" {
+			params["name"] = keyword
+		}
+		res := &struct {
+			page
+			Values []*Repository `json:"values"`
+		}{}
+		if _, err := c.Get("/api/1.0/repos", httpclient.SetQueryParams(params), httpclient.SetResult(res)); err != nil {
+			return nil, err
+		}
+		repos = append(repos, res.Values...)
+		if res.IsLastPage {
+			break
+		}
+		start = res.NextPageStart
+	}
+	return repos, nil
+}
+
+func (c *Client) ListBranches(projectKey, repoSlug string) ([]*Ref, error) {
+	return c.listRefs(repoPath(projectKey, repoSlug)+"/branches", "")
+}
+
+func (c *Client) ListTags(projectKey, repoSlug string) ([]*Ref, error) {
+	return c.listRefs(repoPath(projectKey, repoSlug)+"/tags", "")
+}
+
+// GetBranch filterText是模糊匹配, 需要再按名称精确匹配一次
+func (c *Client) GetBranch(projectKey, repoSlug, branch string) (*Ref, error) {
+	refs, err := c.listRefs(repoPath(projectKey, repoSlug)+"/branches", branch)
+	if err != nil {
+		return nil, err
+	}
+	for _, ref := range refs {
+		if ref.DisplayID == branch {
+			return ref, nil
+		}
+	}
+	return nil, fmt.Errorf("branch %s not found in %s/%s", branch, projectKey, repoSlug)
+}
+
+func (c *Client) GetTag(projectKey, repoSlug, tag string) (*Ref, error) {
+	ref := new(Ref)
+	if _, err := c.Get(fmt.Sprintf("%s/tags/%s", repoPath(projectKey, repoSlug), url.PathEscape(tag)), httpclient.SetResult(ref)); err != nil {
+		return nil, err
+	}
+	return ref, nil
+}
+
+func (c *Client) listRefs(refPath, filter string) ([]*Ref, error) {
+	var refs []*Ref
+	for start, i := 0, 0; i < maxPages; i++ {
+		params := pageParams(start)
+		if filter != "" {
+			params["filterText"] = filter
+		}
+		res := &struct {
+			page
+			Values []*Ref `json:"values"`
+		}{}
+		if _, err := c.Get(refPath, httpclient.SetQueryParams(params), httpclient.SetResult(res)); err != nil {
+			return nil, err
+		}
+		refs = append(refs, res.Values...)
+		if res.IsLastPage {
+			break
+		}
+		start = res.NextPageStart
+	}
+	return refs, nil
+}
+
+// ListOpenPullRequests targetBranch不为空时只返回合并到该分支的pull request
+func (c *Client) ListOpenPullRequests(projectKey, repoSlug, targetBranch string) ([]*PullRequest, error) {
+	var prs []*PullRequest
+	for start, i := 0, 0; i < maxPages; i++ {
+		params := pageParams(start)
+		params["state"] = PullRequestStateOpen
+		if targetBranch != "" {
+			params["at"] = "refs/heads/" + targetBranch
+		}
+		res := &struct {
+			page
+			Values []*PullRequest `json:"values"`
+		}{}
+		if _, err := c.Get(repoPath(projectKey, repoSlug)+"/pull-requests", httpclient.SetQueryParams(params), httpclient.SetResult(res)); err != nil {
+			return nil, err
+		}
+		prs = append(prs, res.Values...)
+		if res.IsLastPage {
+			break
+		}
+		start = res.NextPageStart
+	}
+	return prs, nil
+}
+
+func (c *Client) GetPullRequest(projectKey, repoSlug string, id int) (*PullRequest, error) {
+	pr := new(PullRequest)
+	if _, err := c.Get(fmt.Sprintf("%s/pull-requests/%d", repoPath(projectKey, repoSlug), id), httpclient.SetResult(pr)); err != nil {
+		return nil, err
+	}
+	return pr, nil
+}
+
+// ListPullRequestFiles 获取pull request中变更的文件, 重命名的文件同时返回新旧路径
+func (c *Client) ListPullRequestFiles(projectKey, repoSlug string, id int) ([]string, error) {
+	var files []string
+	for start, i := 0, 0; i < maxPages; i++ {
+		res := &struct {
+			page
+			Values []*change `json:"values"`
+		}{}
+		if _, err := c.Get(fmt.Sprintf("%s/pull-requests/%d/changes", repoPath(projectKey, repoSlug), id), httpclient.SetQueryParams(pageParams(start)), httpclient.SetResult(res)); err != nil {
+			return nil, err
+		}
+		for _, ch := range res.Values {
+			if ch.Path != nil {
+				files = append(files, ch.Path.ToString)
+			}
+			if ch.SrcPath != nil {
+				files = append(files, ch.SrcPath.ToString)
+			}
+		}
+		if res.IsLastPage {
+			break
+		}
+		start = res.NextPageStart
+	}
+	return files, nil
+}
+
+// ListChangedFiles 获取两次提交之间变更的文件, since为空时返回until提交本身的变更
+func (c *Client) ListChangedFiles(projectKey, repoSlug, since, until string) ([]string, error) {
+	var files []string
+	for start, i := 0, 0; i < maxPages; i++ {
+		params := pageParams(start)
+		params["until"] = until
+		if since != "" {
+			params["since"] = since
+		}
+		res := &struct {
+			page
+			Values []*change `json:"values"`
+		}{}
+		if _, err := c.Get(repoPath(projectKey, repoSlug)+"/changes", httpclient.SetQueryParams(params), httpclient.SetResult(res)); err != nil {
+			return nil, err
+		}
+		for _, ch := range res.Values {
+			if ch.Path != nil {
+				files = append(files, ch.Path.ToString)
+			}
+			if ch.SrcPath != nil {
+				files = append(files, ch.SrcPath.ToString)
+			}
+		}
+		if res.IsLastPage {
+			break
+		}
+		start = res.NextPageStart
+	}
+	return files, nil
+}
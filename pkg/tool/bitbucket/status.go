@@ -0,0 +1,47 @@
+/*
+Copyright 2021 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package bitbucket
+
+import (
+	"fmt"
+	"net/url"
+
+	"github.com/koderover/zadig/pkg/tool/httpclient"
+)
+
+// BuildState bitbucket server build status的状态
+type BuildState string
+
+const (
+	BuildStateInProgress BuildState = "INPROGRESS"
+	BuildStateSuccessful BuildState = "SUCCESSFUL"
+	BuildStateFailed     BuildState = "FAILED"
+)
+
+// BuildStatus 同一个commit上key相同的build status会被最新的一次覆盖
+type BuildStatus struct {
+	State       BuildState `json:"state"`
+	Key         string     `json:"key"`
+	Name        string     `json:"name"`
+	URL         string     `json:"url"`
+	Description string     `json:"description"`
+}
+
+func (c *Client) CreateBuildStatus(sha string, status *BuildStatus) error {
+	_, err := c.Post(fmt.Sprintf("/build-status/1.0/commits/%s", url.PathEscape(sha)), httpclient.SetBody(status))
+	return err
+}
@@ -0,0 +1,68 @@
+/*
+Copyright 2021 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package bitbucket
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/koderover/zadig/pkg/tool/httpclient"
+)
+
+const hookName = "zadig"
+
+var hookEvents = []string{
+	string(EventTypeRefsChanged),
+	string(EventTypePROpened),
+	string(EventTypePRFromRefUpdated),
+	string(EventTypePRMerged),
+	string(EventTypePRDeclined),
+	string(EventTypePRDeleted),
+}
+
+type Hook struct {
+	ID            int               `json:"id,omitempty"`
+	Name          string            `json:"name"`
+	URL           string            `json:"url"`
+	Active        bool              `json:"active"`
+	Events        []string          `json:"events"`
+	Configuration map[string]string `json:"configuration"`
+}
+
+// CreateWebHook 返回创建的webhook id
+func (c *Client) CreateWebHook(projectKey, repoSlug, hookURL, secret string) (string, error) {
+	hook := &Hook{
+		Name:   hookName,
+		URL:    hookURL,
+		Active: true,
+		Events: hookEvents,
+		Configuration: map[string]string{
+			"secret": secret,
+		},
+	}
+
+	res := new(Hook)
+	if _, err := c.Post(repoPath(projectKey, repoSlug)+"/webhooks", httpclient.SetBody(hook), httpclient.SetResult(res)); err != nil {
+		return "", err
+	}
+	return strconv.Itoa(res.ID), nil
+}
+
+func (c *Client) DeleteWebHook(projectKey, repoSlug, hookID string) error {
+	_, err := c.Delete(fmt.Sprintf("%s/webhooks/%s", repoPath(projectKey, repoSlug), hookID))
+	return err
+}
@@ -50,6 +50,8 @@ func parseWebhook(eventType EventType, payload []byte) (event interface{}, err e
 		event = &MergeEvent{}
 	case EventTypePush:
 		event = &PushEvent{}
+	case EventTypeTagPush:
+		event = &TagPushEvent{}
 	default:
 		return nil, fmt.Errorf("unexpected event type: %s", eventType)
 	}
@@ -201,6 +203,21 @@ type PushEvent struct {
 	TotalCommitsCount int               `json:"total_commits_count"`
 }
 
+// TagPushEvent 删除tag时after为全0
+type TagPushEvent struct {
+	ObjectKind   string         `json:"object_kind"`
+	EventName    string         `json:"event_name"`
+	Before       string         `json:"before"`
+	After        string         `json:"after"`
+	Ref          string         `json:"ref"`
+	CheckoutSha  string         `json:"checkout_sha"`
+	UserName     string         `json:"user_name"`
+	UserUsername string         `json:"user_username"`
+	UserEmail    string         `json:"user_email"`
+	ProjectID    int            `json:"project_id"`
+	Project      WebhookProject `json:"project"`
+}
+
 type PushEventCommit struct {
 	ID        string    `json:"id"`
 	Message   string    `json:"message"`
@@ -0,0 +1,82 @@
+/*
+Copyright 2021 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package codehub
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+)
+
+type MergeRequestNotePayload struct {
+	Body string `json:"body"`
+}
+
+type MergeRequestNoteResp struct {
+	Result MergeRequestNote `json:"result"`
+	Status string           `json:"status"`
+}
+
+type MergeRequestNote struct {
+	ID   int    `json:"id"`
+	Body string `json:"body"`
+}
+
+// CreateMergeRequestNote 在merge request下创建评论, 返回评论ID
+func (c *CodeHubClient) CreateMergeRequestNote(repoOwner, repoName string, mergeRequestIID int, comment string) (string, error) {
+	payload, err := json.Marshal(&MergeRequestNotePayload{Body: comment})
+	if err != nil {
+		return "", err
+	}
+	body, err := c.sendRequest("POST", fmt.Sprintf("/v1/repositories/%s/%s/merge_requests/%d/notes", repoOwner, repoName, mergeRequestIID), payload)
+	if err != nil {
+		return "", err
+	}
+	defer body.Close()
+
+	noteResp := new(MergeRequestNoteResp)
+	if err = json.NewDecoder(body).Decode(noteResp); err != nil {
+		return "", err
+	}
+	if noteResp.Status == "success" {
+		return strconv.Itoa(noteResp.Result.ID), nil
+	}
+
+	return "", fmt.Errorf("create codehub merge request note failed")
+}
+
+func (c *CodeHubClient) UpdateMergeRequestNote(repoOwner, repoName string, mergeRequestIID int, noteID, comment string) error {
+	payload, err := json.Marshal(&MergeRequestNotePayload{Body: comment})
+	if err != nil {
+		return err
+	}
+	body, err := c.sendRequest("PUT", fmt.Sprintf("/v1/repositories/%s/%s/merge_requests/%d/notes/%s", repoOwner, repoName, mergeRequestIID, noteID), payload)
+	if err != nil {
+		return err
+	}
+	defer body.Close()
+
+	noteResp := new(MergeRequestNoteResp)
+	if err = json.NewDecoder(body).Decode(noteResp); err != nil {
+		return err
+	}
+	if noteResp.Status == "success" {
+		return nil
+	}
+
+	return fmt.Errorf("update codehub merge request note [%s] failed", noteID)
+}
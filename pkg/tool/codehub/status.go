@@ -0,0 +1,68 @@
+/*
+Copyright 2021 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package codehub
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// CommitStatusState codehub commit status的状态, 与gitlab保持一致
+type CommitStatusState string
+
+const (
+	CommitStatusPending  CommitStatusState = "pending"
+	CommitStatusRunning  CommitStatusState = "running"
+	CommitStatusSuccess  CommitStatusState = "success"
+	CommitStatusFailed   CommitStatusState = "failed"
+	CommitStatusCanceled CommitStatusState = "canceled"
+)
+
+type CommitStatus struct {
+	State       CommitStatusState `json:"state"`
+	Ref         string            `json:"ref,omitempty"`
+	Name        string            `json:"name"`
+	TargetURL   string            `json:"target_url"`
+	Description string            `json:"description"`
+}
+
+type CommitStatusResp struct {
+	Status string `json:"status"`
+}
+
+// CreateCommitStatus 同一个name的status会被最新的一次覆盖
+func (c *CodeHubClient) CreateCommitStatus(repoOwner, repoName, sha string, status *CommitStatus) error {
+	payload, err := json.Marshal(status)
+	if err != nil {
+		return err
+	}
+	body, err := c.sendRequest("POST", fmt.Sprintf("/v1/repositories/%s/%s/statuses/%s", repoOwner, repoName, sha), payload)
+	if err != nil {
+		return err
+	}
+	defer body.Close()
+
+	statusResp := new(CommitStatusResp)
+	if err = json.NewDecoder(body).Decode(statusResp); err != nil {
+		return err
+	}
+	if statusResp.Status == "success" {
+		return nil
+	}
+
+	return fmt.Errorf("create codehub commit status of %s failed", sha)
+}
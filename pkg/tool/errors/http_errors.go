@@ -563,6 +563,8 @@ var (
 	ErrCreateActivity       = NewHTTPError(6664, "添加交付事件失败")
 	ErrFindActivities       = NewHTTPError(6665, "获取交付事件列表失败")
 	ErrCreateArtifactFailed = NewHTTPError(6666, "该交付物已经存在")
+	ErrDownloadSBOM         = NewHTTPError(6667, "下载交付物SBOM失败")
+	ErrSearchSBOMPackages   = NewHTTPError(6668, "查询SBOM软件包失败")
 
 	//-----------------------------------------------------------------------------------------------
 	// basicImage APIs Range: 6670 - 6679
@@ -671,4 +673,38 @@ var (
 	ErrTestJenkinsConnection    = NewHTTPError(6835, "用户名或者密码不正确")
 	ErrListJobNames             = NewHTTPError(6836, "获取job名称列表失败")
 	ErrListJobBuildArgs         = NewHTTPError(6837, "获取job构建参数列表失败")
+
+	//-----------------------------------------------------------------------------------------------
+	// workflow approval Error Range: 6840 - 6849
+	//-----------------------------------------------------------------------------------------------
+	ErrCreateApproval = NewHTTPError(6840, "创建审批失败")
+	ErrGetApproval    = NewHTTPError(6841, "获取审批失败")
+	ErrApprove        = NewHTTPError(6842, "审批失败")
+	ErrNotApprover    = NewHTTPError(6843, "当前用户不是审批人")
+
+	//-----------------------------------------------------------------------------------------------
+	// task queue Error Range: 6850 - 6859
+	//-----------------------------------------------------------------------------------------------
+	ErrUpdateTaskPriority = NewHTTPError(6850, "更新任务优先级失败")
+
+	//-----------------------------------------------------------------------------------------------
+	// signing key Error Range: 6860 - 6869
+	//-----------------------------------------------------------------------------------------------
+	ErrGetSigningKey        = NewHTTPError(6861, "获取镜像签名密钥失败")
+	ErrCreateSigningKey     = NewHTTPError(6862, "创建镜像签名密钥失败")
+	ErrUpdateSigningKey     = NewHTTPError(6863, "更新镜像签名密钥失败")
+	ErrListSigningKeys      = NewHTTPError(6864, "列出镜像签名密钥失败")
+	ErrDeleteSigningKey     = NewHTTPError(6865, "删除镜像签名密钥失败")
+	ErrDeleteUsedSigningKey = NewHTTPError(6866, "删除镜像签名密钥失败，此密钥已经被工作流引用，请确认")
+
+	//-----------------------------------------------------------------------------------------------
+	// test case stat Error Range: 6870 - 6879
+	//-----------------------------------------------------------------------------------------------
+	ErrListTestCaseStats  = NewHTTPError(6870, "列出不稳定测试用例失败")
+	ErrQuarantineTestCase = NewHTTPError(6871, "隔离测试用例失败")
+
+	//-----------------------------------------------------------------------------------------------
+	// test coverage Error Range: 6880 - 6889
+	//-----------------------------------------------------------------------------------------------
+	ErrListTestCoverage = NewHTTPError(6880, "列出测试覆盖率失败")
 )
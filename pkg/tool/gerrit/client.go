@@ -198,6 +198,29 @@ func (c *Client) SetReview(projectName string, changeID int, m, label, score, re
 	return err
 }
 
+// accessCheckInfo check.access 接口的返回，status 为 200 表示拥有该权限
+type accessCheckInfo struct {
+	Status  int    `json:"status"`
+	Message string `json:"message"`
+}
+
+// CanPush 检查 account 对 project 的 branch 是否拥有 push 权限
+func (c *Client) CanPush(projectName, branch, account string) (bool, error) {
+	projectName = Unescape(projectName)
+	if !strings.HasPrefix(branch, refHeader) {
+		branch = refHeader + branch
+	}
+	u := fmt.Sprintf("projects/%s/check.access?account=%s&perm=push&ref=%s",
+		url.QueryEscape(projectName), url.QueryEscape(account), url.QueryEscape(branch))
+
+	info := new(accessCheckInfo)
+	if _, err := c.cli.Call(http.MethodGet, u, nil, info); err != nil {
+		return false, err
+	}
+
+	return info.Status == http.StatusOK, nil
+}
+
 // CompareTwoPatchset 如果两个Patchset更新的内容相同，返回true，不相同则返回false
 func (c *Client) CompareTwoPatchset(changeID, newPatchSetID, oldPatchSetID string) (bool, error) {
 	newPatchSetChangeFiles, _, err := c.cli.Changes.ListFiles(changeID, newPatchSetID)
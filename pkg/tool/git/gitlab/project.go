@@ -135,6 +135,10 @@ func addEventsToProjectHookOptions(events []string, opts *gitlab.AddProjectHookO
 			opts.MergeRequestsEvents = boolptr.True()
 		case git.BranchOrTagCreateEvent:
 			opts.TagPushEvents = boolptr.True()
+		case git.ReleaseEvent:
+			opts.ReleasesEvents = boolptr.True()
+		case git.NoteEvent:
+			opts.NoteEvents = boolptr.True()
 		}
 	}
 
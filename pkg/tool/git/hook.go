@@ -21,6 +21,9 @@ const (
 	PullRequestEvent       = "pull_request"
 	CheckRunEvent          = "check_run"
 	BranchOrTagCreateEvent = "create"
+	ReleaseEvent           = "release"
+	IssueCommentEvent      = "issue_comment"
+	NoteEvent              = "note"
 )
 
 type Hook struct {
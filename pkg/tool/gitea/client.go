@@ -0,0 +1,64 @@
+/*
+Copyright 2021 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gitea
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/koderover/zadig/pkg/tool/httpclient"
+)
+
+const (
+	// pageSize gitea默认单页最多返回50条数据
+	pageSize = 50
+	maxPages = 20
+)
+
+type Client struct {
+	*httpclient.Client
+
+	address string
+}
+
+// NewClient 使用access token访问gitea API
+func NewClient(address, accessToken string) *Client {
+	address = strings.TrimSuffix(address, "/")
+	c := httpclient.New(
+		httpclient.SetAuthScheme("token"),
+		httpclient.SetAuthToken(accessToken),
+		httpclient.SetHostURL(address+"/api/v1"),
+	)
+
+	return &Client{
+		Client:  c,
+		address: address,
+	}
+}
+
+func repoPath(owner, repo string) string {
+	return fmt.Sprintf("/repos/%s/%s", url.PathEscape(owner), url.PathEscape(repo))
+}
+
+func pageParams(page int) map[string]string {
+	return map[string]string{
+		"page":  strconv.Itoa(page),
+		"limit": strconv.Itoa(pageSize),
+	}
+}
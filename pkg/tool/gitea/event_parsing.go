@@ -0,0 +1,116 @@
+/*
+Copyright 2021 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gitea
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// EventType represents a Gitea event type.
+type EventType string
+
+// List of available event types.
+const (
+	EventTypePush        EventType = "push"
+	EventTypePullRequest EventType = "pull_request"
+)
+
+const (
+	eventTypeHeader = "X-Gitea-Event"
+	signatureHeader = "X-Gitea-Signature"
+
+	tagRefPrefix    = "refs/tags/"
+	branchRefPrefix = "refs/heads/"
+	emptyCommitID   = "0000000000000000000000000000000000000000"
+)
+
+// HookEventType returns the event type for the given request.
+func HookEventType(r *http.Request) EventType {
+	return EventType(r.Header.Get(eventTypeHeader))
+}
+
+// ValidatePayload 校验请求中的签名, secret为空时不做校验
+func ValidatePayload(r *http.Request, payload []byte, secret string) error {
+	if secret == "" {
+		return nil
+	}
+
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(payload)
+	if !hmac.Equal([]byte(hex.EncodeToString(mac.Sum(nil))), []byte(r.Header.Get(signatureHeader))) {
+		return errors.New("payload signature check failed")
+	}
+	return nil
+}
+
+func ParseHook(eventType EventType, payload []byte) (event interface{}, err error) {
+	switch eventType {
+	case EventTypePush:
+		event = &PushEvent{}
+	case EventTypePullRequest:
+		event = &PullRequestEvent{}
+	default:
+		return nil, fmt.Errorf("unexpected event type: %s", eventType)
+	}
+
+	if err := json.Unmarshal(payload, event); err != nil {
+		return nil, err
+	}
+
+	return event, nil
+}
+
+type PushEvent struct {
+	Ref        string           `json:"ref"`
+	Before     string           `json:"before"`
+	After      string           `json:"after"`
+	CompareURL string           `json:"compare_url"`
+	Commits    []*PayloadCommit `json:"commits"`
+	HeadCommit *PayloadCommit   `json:"head_commit"`
+	Repo       *Repository      `json:"repository"`
+	Pusher     *User            `json:"pusher"`
+	Sender     *User            `json:"sender"`
+}
+
+// IsTag push事件也会在创建tag时发送, ref以refs/tags/开头
+func (e *PushEvent) IsTag() bool {
+	return strings.HasPrefix(e.Ref, tagRefPrefix)
+}
+
+func (e *PushEvent) IsDeleted() bool {
+	return e.After == emptyCommitID
+}
+
+// RefName 去掉refs/heads/或refs/tags/前缀后的分支或tag名称
+func (e *PushEvent) RefName() string {
+	return strings.TrimPrefix(strings.TrimPrefix(e.Ref, branchRefPrefix), tagRefPrefix)
+}
+
+type PullRequestEvent struct {
+	Action      string       `json:"action"`
+	Number      int          `json:"number"`
+	PullRequest *PullRequest `json:"pull_request"`
+	Repository  *Repository  `json:"repository"`
+	Sender      *User        `json:"sender"`
+}
@@ -0,0 +1,49 @@
+/*
+Copyright 2021 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gitea
+
+import (
+	"bytes"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParsePushEvent(t *testing.T) {
+	ast := require.New(t)
+
+	payload := []byte(`{"ref":"refs/tags/v1.0.0","after":"8d3f0c1"}`)
+	req, err := http.NewRequest(http.MethodPost, "/", bytes.NewReader(payload))
+	ast.Nil(err)
+	req.Header.Set(eventTypeHeader, string(EventTypePush))
+
+	req.Header.Set(signatureHeader, "invalid")
+	ast.NotNil(ValidatePayload(req, payload, "secret"))
+	ast.Nil(ValidatePayload(req, payload, ""))
+
+	req.Header.Set(signatureHeader, "10aaa7a6b6fceb6798cf7b8797e1c8b8967cdc59e4b450a9c999c5bfe7e2946c")
+	ast.Nil(ValidatePayload(req, payload, "secret"))
+
+	event, err := ParseHook(HookEventType(req), payload)
+	ast.Nil(err)
+	pushEvent, ok := event.(*PushEvent)
+	ast.True(ok)
+	ast.True(pushEvent.IsTag())
+	ast.False(pushEvent.IsDeleted())
+	ast.Equal("v1.0.0", pushEvent.RefName())
+}
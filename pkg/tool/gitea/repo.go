@@ -0,0 +1,249 @@
+/*
+Copyright 2021 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gitea
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/koderover/zadig/pkg/tool/httpclient"
+)
+
+type User struct {
+	ID       int64  `json:"id"`
+	UserName string `json:"login"`
+	FullName string `json:"full_name"`
+	Email    string `json:"email"`
+}
+
+type Organization struct {
+	ID       int64  `json:"id"`
+	UserName string `json:"username"`
+	FullName string `json:"full_name"`
+}
+
+type Repository struct {
+	ID            int64  `json:"id"`
+	Owner         *User  `json:"owner"`
+	Name          string `json:"name"`
+	FullName      string `json:"full_name"`
+	Description   string `json:"description"`
+	DefaultBranch string `json:"default_branch"`
+	CloneURL      string `json:"clone_url"`
+}
+
+type PayloadUser struct {
+	Name     string `json:"name"`
+	Email    string `json:"email"`
+	UserName string `json:"username"`
+}
+
+type PayloadCommit struct {
+	ID        string       `json:"id"`
+	Message   string       `json:"message"`
+	URL       string       `json:"url"`
+	Author    *PayloadUser `json:"author"`
+	Committer *PayloadUser `json:"committer"`
+	Added     []string     `json:"added"`
+	Removed   []string     `json:"removed"`
+	Modified  []string     `json:"modified"`
+}
+
+type Branch struct {
+	Name      string         `json:"name"`
+	Commit    *PayloadCommit `json:"commit"`
+	Protected bool           `json:"protected"`
+}
+
+type CommitMeta struct {
+	SHA string `json:"sha"`
+}
+
+type Tag struct {
+	Name       string      `json:"name"`
+	Message    string      `json:"message"`
+	ID         string      `json:"id"`
+	Commit     *CommitMeta `json:"commit"`
+	ZipballURL string      `json:"zipball_url"`
+	TarballURL string      `json:"tarball_url"`
+}
+
+type PRBranchInfo struct {
+	Name   string      `json:"label"`
+	Ref    string      `json:"ref"`
+	Sha    string      `json:"sha"`
+	RepoID int64       `json:"repo_id"`
+	Repo   *Repository `json:"repo"`
+}
+
+type PullRequest struct {
+	ID      int64         `json:"id"`
+	Index   int           `json:"number"`
+	Poster  *User         `json:"user"`
+	Title   string        `json:"title"`
+	State   string        `json:"state"`
+	Merged  bool          `json:"merged"`
+	Head    *PRBranchInfo `json:"head"`
+	Base    *PRBranchInfo `json:"base"`
+	Created *time.Time    `json:"created_at"`
+	Updated *time.Time    `json:"updated_at"`
+}
+
+type ChangedFile struct {
+	Filename         string `json:"filename"`
+	PreviousFilename string `json:"previous_filename"`
+	Status           string `json:"status"`
+}
+
+func (c *Client) GetAuthenticatedUser() (*User, error) {
+	user := new(User)
+	if _, err := c.Get("/user", httpclient.SetResult(user)); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+func (c *Client) ListOrganizations() ([]*Organization, error) {
+	var orgs []*Organization
+	for page := 1; page <= maxPages; page++ {
+		var res []*Organization
+		if _, err := c.Get("/user/orgs", httpclient.SetQueryParams(pageParams(page)), httpclient.SetResult(&res)); err != nil {
+			return nil, err
+		}
+		orgs = append(orgs, res...)
+		if len(res) < pageSize {
+			break
+		}
+	}
+	return orgs, nil
+}
+
+// ListRepositories namespace为组织时获取组织下的代码库, 否则获取用户的代码库, keyword不为空时按名称过滤
+func (c *Client) ListRepositories(namespace string, isOrg bool, keyword string) ([]*Repository, error) {
+	path := fmt.Sprintf("/users/%s/repos", url.PathEscape(namespace))
+	if isOrg {
+		path = fmt.Sprintf("/orgs/%s/repos", url.PathEscape(namespace))
+	}
+
+	var repos []*Repository
+	for page := 1; page <= maxPages; page++ {
+		var res []*Repository
+		if _, err := c.Get(path, httpclient.SetQueryParams(pageParams(page)), httpclient.SetResult(&res)); err != nil {
+			return nil, err
+		}
+		for _, repo := range res {
+			if keyword == "" || strings.Contains(strings.ToLower(repo.Name), strings.ToLower(keyword)) {
+				repos = append(repos, repo)
+			}
+		}
+		if len(res) < pageSize {
+			break
+		}
+	}
+	return repos, nil
+}
+
+func (c *Client) ListBranches(owner, repo string) ([]*Branch, error) {
+	var branches []*Branch
+	for page := 1; page <= maxPages; page++ {
+		var res []*Branch
+		if _, err := c.Get(repoPath(owner, repo)+"/branches", httpclient.SetQueryParams(pageParams(page)), httpclient.SetResult(&res)); err != nil {
+			return nil, err
+		}
+		branches = append(branches, res...)
+		if len(res) < pageSize {
+			break
+		}
+	}
+	return branches, nil
+}
+
+func (c *Client) GetBranch(owner, repo, branch string) (*Branch, error) {
+	res := new(Branch)
+	if _, err := c.Get(fmt.Sprintf("%s/branches/%s", repoPath(owner, repo), url.PathEscape(branch)), httpclient.SetResult(res)); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+func (c *Client) ListTags(owner, repo string) ([]*Tag, error) {
+	var tags []*Tag
+	for page := 1; page <= maxPages; page++ {
+		var res []*Tag
+		if _, err := c.Get(repoPath(owner, repo)+"/tags", httpclient.SetQueryParams(pageParams(page)), httpclient.SetResult(&res)); err != nil {
+			return nil, err
+		}
+		tags = append(tags, res...)
+		if len(res) < pageSize {
+			break
+		}
+	}
+	return tags, nil
+}
+
+// ListOpenPullRequests targetBranch不为空时只返回合并到该分支的pull request
+func (c *Client) ListOpenPullRequests(owner, repo, targetBranch string) ([]*PullRequest, error) {
+	var prs []*PullRequest
+	for page := 1; page <= maxPages; page++ {
+		params := pageParams(page)
+		params["state"] = "open"
+		var res []*PullRequest
+		if _, err := c.Get(repoPath(owner, repo)+"/pulls", httpclient.SetQueryParams(params), httpclient.SetResult(&res)); err != nil {
+			return nil, err
+		}
+		for _, pr := range res {
+			if targetBranch == "" || (pr.Base != nil && pr.Base.Ref == targetBranch) {
+				prs = append(prs, pr)
+			}
+		}
+		if len(res) < pageSize {
+			break
+		}
+	}
+	return prs, nil
+}
+
+func (c *Client) GetPullRequest(owner, repo string, index int) (*PullRequest, error) {
+	pr := new(PullRequest)
+	if _, err := c.Get(fmt.Sprintf("%s/pulls/%d", repoPath(owner, repo), index), httpclient.SetResult(pr)); err != nil {
+		return nil, err
+	}
+	return pr, nil
+}
+
+// ListPullRequestFiles 获取pull request中变更的文件, 需要gitea 1.17及以上版本
+func (c *Client) ListPullRequestFiles(owner, repo string, index int) ([]string, error) {
+	var files []string
+	for page := 1; page <= maxPages; page++ {
+		var res []*ChangedFile
+		if _, err := c.Get(fmt.Sprintf("%s/pulls/%d/files", repoPath(owner, repo), index), httpclient.SetQueryParams(pageParams(page)), httpclient.SetResult(&res)); err != nil {
+			return nil, err
+		}
+		for _, file := range res {
+			files = append(files, file.Filename)
+			if file.PreviousFilename != "" {
+				files = append(files, file.PreviousFilename)
+			}
+		}
+		if len(res) < pageSize {
+			break
+		}
+	}
+	return files, nil
+}
@@ -0,0 +1,48 @@
+/*
+Copyright 2021 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gitea
+
+import (
+	"fmt"
+	"net/url"
+
+	"github.com/koderover/zadig/pkg/tool/httpclient"
+)
+
+// CommitStatusState gitea commit status的状态
+type CommitStatusState string
+
+const (
+	CommitStatusPending CommitStatusState = "pending"
+	CommitStatusSuccess CommitStatusState = "success"
+	CommitStatusError   CommitStatusState = "error"
+	CommitStatusFailure CommitStatusState = "failure"
+	CommitStatusWarning CommitStatusState = "warning"
+)
+
+type CommitStatus struct {
+	State       CommitStatusState `json:"state"`
+	TargetURL   string            `json:"target_url"`
+	Description string            `json:"description"`
+	Context     string            `json:"context"`
+}
+
+// CreateStatus 同一个context的status会被最新的一次覆盖
+func (c *Client) CreateStatus(owner, repo, sha string, status *CommitStatus) error {
+	_, err := c.Post(fmt.Sprintf("%s/statuses/%s", repoPath(owner, repo), url.PathEscape(sha)), httpclient.SetBody(status))
+	return err
+}
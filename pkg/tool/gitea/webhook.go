@@ -0,0 +1,71 @@
+/*
+Copyright 2021 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gitea
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/koderover/zadig/pkg/tool/httpclient"
+)
+
+const (
+	hookTypeGitea   = "gitea"
+	hookContentType = "json"
+)
+
+var hookEvents = []string{"push", "create", "pull_request"}
+
+type Hook struct {
+	ID     int64             `json:"id"`
+	Type   string            `json:"type"`
+	Config map[string]string `json:"config"`
+	Events []string          `json:"events"`
+	Active bool              `json:"active"`
+}
+
+type createHookOption struct {
+	Type   string            `json:"type"`
+	Config map[string]string `json:"config"`
+	Events []string          `json:"events"`
+	Active bool              `json:"active"`
+}
+
+// CreateWebHook 返回创建的webhook id
+func (c *Client) CreateWebHook(owner, repo, hookURL, secret string) (string, error) {
+	opt := &createHookOption{
+		Type: hookTypeGitea,
+		Config: map[string]string{
+			"url":          hookURL,
+			"content_type": hookContentType,
+			"secret":       secret,
+		},
+		Events: hookEvents,
+		Active: true,
+	}
+
+	hook := new(Hook)
+	if _, err := c.Post(repoPath(owner, repo)+"/hooks", httpclient.SetBody(opt), httpclient.SetResult(hook)); err != nil {
+		return "", err
+	}
+	return strconv.FormatInt(hook.ID, 10), nil
+}
+
+func (c *Client) DeleteWebHook(owner, repo, hookID string) error {
+	_, err := c.Delete(fmt.Sprintf("%s/hooks/%s", repoPath(owner, repo), hookID))
+	return err
+}
@@ -65,6 +65,15 @@ func RestartDeployment(ns, name string, cl client.Client) error {
 	return nil
 }
 
+func DeleteDeployment(ns, name string, cl client.Client) error {
+	return deleteObjectWithDefaultOptions(&appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: ns,
+			Name:      name,
+		},
+	}, cl)
+}
+
 func DeleteDeployments(ns string, selector labels.Selector, cl client.Client) error {
 	return deleteObjectsWithDefaultOptions(ns, selector, &appsv1.Deployment{}, cl)
 }
@@ -25,6 +25,15 @@ import (
 	"github.com/koderover/zadig/pkg/tool/kube/getter"
 )
 
+func PatchService(ns, name string, patchBytes []byte, cl client.Client) error {
+	return patchObject(&corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: ns,
+			Name:      name,
+		},
+	}, patchBytes, cl)
+}
+
 // service does not support deleteCollection
 // see here for details: https://github.com/kubernetes/kubernetes/issues/68468#issuecomment-419981870
 func DeleteServices(ns string, selector labels.Selector, cl client.Client) error {
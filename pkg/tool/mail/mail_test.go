@@ -0,0 +1,134 @@
+/*
+Copyright 2021 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mail
+
+import (
+	"bufio"
+	"net"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeSMTPServer 本地的smtp服务, 只实现发送邮件需要的命令
+type fakeSMTPServer struct {
+	listener net.Listener
+	rcpts    []string
+	data     string
+	done     chan struct{}
+}
+
+func newFakeSMTPServer(t *testing.T) *fakeSMTPServer {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+
+	s := &fakeSMTPServer{listener: l, done: make(chan struct{})}
+	go s.serve()
+	return s
+}
+
+func (s *fakeSMTPServer) serve() {
+	defer close(s.done)
+	conn, err := s.listener.Accept()
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	tp := textproto.NewConn(conn)
+	_ = tp.PrintfLine("220 localhost ESMTP")
+	for {
+		line, err := tp.ReadLine()
+		if err != nil {
+			return
+		}
+		cmd := strings.ToUpper(strings.SplitN(line, " ", 2)[0])
+		switch cmd {
+		case "EHLO", "HELO":
+			_ = tp.PrintfLine("250 localhost")
+		case "MAIL":
+			_ = tp.PrintfLine("250 OK")
+		case "RCPT":
+			s.rcpts = append(s.rcpts, strings.Trim(strings.TrimPrefix(line, "RCPT TO:"), "<>"))
+			_ = tp.PrintfLine("250 OK")
+		case "DATA":
+			_ = tp.PrintfLine("354 go ahead")
+			data, err := tp.ReadDotBytes()
+			if err != nil {
+				return
+			}
+			s.data = string(data)
+			_ = tp.PrintfLine("250 OK")
+		case "QUIT":
+			_ = tp.PrintfLine("221 bye")
+			return
+		default:
+			_ = tp.PrintfLine("502 not implemented")
+		}
+	}
+}
+
+func TestSend(t *testing.T) {
+	s := newFakeSMTPServer(t)
+	defer s.listener.Close()
+
+	host, port, _ := net.SplitHostPort(s.listener.Addr().String())
+	p, _ := strconv.Atoi(port)
+	c := New(&Config{Host: host, Port: p, From: "zadig@example.com"})
+
+	err := c.Send([]string{"dev@example.com"}, "工作流状态", "<p>passed</p>")
+	assert.NoError(t, err)
+	<-s.done
+
+	assert.Equal(t, []string{"dev@example.com"}, s.rcpts)
+	headers, err := textproto.NewReader(bufio.NewReader(strings.NewReader(s.data))).ReadMIMEHeader()
+	assert.NoError(t, err)
+	assert.Equal(t, "text/html; charset=UTF-8", headers.Get("Content-Type"))
+	assert.Contains(t, s.data, "<p>passed</p>")
+}
+
+func TestSendWithoutRecipient(t *testing.T) {
+	assert.Error(t, New(&Config{Host: "127.0.0.1", Port: 25}).Send(nil, "subject", "body"))
+}
+
+func TestSendTimeout(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	defer l.Close()
+	// 接受连接但是不回复greeting
+	go func() {
+		conn, err := l.Accept()
+		if err == nil {
+			defer conn.Close()
+			time.Sleep(time.Second)
+		}
+	}()
+
+	origin := sendTimeout
+	sendTimeout = 100 * time.Millisecond
+	defer func() { sendTimeout = origin }()
+
+	host, port, _ := net.SplitHostPort(l.Addr().String())
+	p, _ := strconv.Atoi(port)
+	start := time.Now()
+	assert.Error(t, New(&Config{Host: host, Port: p}).Send([]string{"dev@example.com"}, "subject", "body"))
+	assert.Less(t, int64(time.Since(start)), int64(time.Second))
+}
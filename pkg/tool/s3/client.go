@@ -18,6 +18,7 @@ package s3
 
 import (
 	"fmt"
+	"net/http"
 	"os"
 
 	"github.com/aws/aws-sdk-go/aws"
@@ -191,6 +192,21 @@ func (c *Client) Upload(bucketName, src string, objectKey string) error {
 	return err
 }
 
+// Exists checks whether the object exists in the bucket
+func (c *Client) Exists(bucketName, objectKey string) (bool, error) {
+	input := &s3.HeadObjectInput{
+		Bucket: aws.String(bucketName),
+		Key:    aws.String(objectKey),
+	}
+	if _, err := c.HeadObject(input); err != nil {
+		if e, ok := err.(awserr.RequestFailure); ok && e.StatusCode() == http.StatusNotFound {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
+
 // ListFiles with given prefix
 func (c *Client) ListFiles(bucketName, prefix string, recursive bool) ([]string, error) {
 	ret := make([]string, 0)
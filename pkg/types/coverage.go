@@ -0,0 +1,74 @@
+/*
+Copyright 2021 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package types
+
+import "math"
+
+const (
+	CoverageFormatCobertura = "cobertura"
+	CoverageFormatJaCoCo    = "jacoco"
+	CoverageFormatLCOV      = "lcov"
+	CoverageFormatGo        = "gocover"
+)
+
+// CoverageSummary 代码覆盖率报告的汇总, 覆盖率为0-100的百分比
+// Go cover profile 没有分支覆盖率, 行数为语句数
+type CoverageSummary struct {
+	Format          string  `bson:"format"              json:"format"`
+	LineRate        float64 `bson:"line_rate"           json:"line_rate"`
+	BranchRate      float64 `bson:"branch_rate"         json:"branch_rate"`
+	LinesCovered    int     `bson:"lines_covered"       json:"lines_covered"`
+	LinesValid      int     `bson:"lines_valid"         json:"lines_valid"`
+	BranchesCovered int     `bson:"branches_covered"    json:"branches_covered"`
+	BranchesValid   int     `bson:"branches_valid"      json:"branches_valid"`
+}
+
+// Add 累加另一份报告的行数和分支数, 并重新计算覆盖率
+func (s *CoverageSummary) Add(other *CoverageSummary) {
+	s.LinesCovered += other.LinesCovered
+	s.LinesValid += other.LinesValid
+	s.BranchesCovered += other.BranchesCovered
+	s.BranchesValid += other.BranchesValid
+	s.UpdateRates()
+}
+
+// UpdateRates 根据行数和分支数计算保留两位小数的覆盖率
+func (s *CoverageSummary) UpdateRates() {
+	s.LineRate = coverageRate(s.LinesCovered, s.LinesValid)
+	s.BranchRate = coverageRate(s.BranchesCovered, s.BranchesValid)
+}
+
+func coverageRate(covered, valid int) float64 {
+	if valid == 0 {
+		return 0
+	}
+	return math.Round(float64(covered)*10000/float64(valid)) / 100
+}
+
+// CoveragePolicy 测试覆盖率的要求, 不满足时测试任务失败
+type CoveragePolicy struct {
+	// MinLineRate 行覆盖率的最小值, 0表示不检查
+	MinLineRate float64 `bson:"min_line_rate"       json:"min_line_rate"`
+	// MaxDrop 相对于BaseBranch最近一次测试的行覆盖率允许下降的百分点, 0表示不检查
+	MaxDrop    float64 `bson:"max_drop"            json:"max_drop"`
+	BaseBranch string  `bson:"base_branch"         json:"base_branch"`
+}
+
+// CoverageReportFile 上传到测试目录中的覆盖率汇总文件名
+func CoverageReportFile(testResultFile string) string {
+	return testResultFile + "-coverage.json"
+}
@@ -0,0 +1,28 @@
+/*
+Copyright 2021 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package util
+
+import (
+	"fmt"
+	"strings"
+)
+
+// GetSBOMFileName 根据镜像名称生成SBOM文件名, e.g. xxx.com/ns/app:v1 -> app-v1.sbom.json
+func GetSBOMFileName(image string) string {
+	name := image[strings.LastIndex(image, "/")+1:]
+	return fmt.Sprintf("%s.sbom.json", strings.Replace(name, ":", "-", -1))
+}
@@ -0,0 +1,34 @@
+/*
+Copyright 2021 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package util
+
+import (
+	"strings"
+
+	"github.com/koderover/zadig/pkg/setting"
+)
+
+// NormalizeVulnerabilitySeverity 将漏洞级别统一为setting.VulnerabilitySeverities中的格式, 例如 CRITICAL -> Critical
+// 未知的级别返回Unknown和false
+func NormalizeVulnerabilitySeverity(severity string) (string, bool) {
+	for _, s := range setting.VulnerabilitySeverities {
+		if strings.EqualFold(strings.TrimSpace(severity), s) {
+			return s, true
+		}
+	}
+	return "Unknown", false
+}